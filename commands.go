@@ -0,0 +1,349 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/assure-compliance/eventid/pkg/consumer"
+	"github.com/assure-compliance/eventid/pkg/schema"
+	"github.com/assure-compliance/eventid/pkg/storage"
+)
+
+// command is a subcommand of the binary
+type command struct {
+	name    string
+	summary string
+	run     func(logger *slog.Logger, args []string)
+}
+
+// commands lists every subcommand; consume runs when none is given
+var commands = []command{
+	{"consume", "Consume events from Kafka and store them (default)", runConsume},
+	{"migrate", "Apply database migrations and exit", runMigrate},
+	{"reconcile", "Compare Kafka record counts with stored events per partition", runReconcile},
+	{"replay", "Re-publish stored events to a Kafka topic", runReplay},
+	{"restore-offsets", "Restore the consumer group's offsets from a snapshot", runRestoreOffsets},
+	{"validate-config", "Check the configuration and exit", runValidateConfig},
+}
+
+func findCommand(name string) (command, bool) {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd, true
+		}
+	}
+	return command{}, false
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s [command] [flags]\n\nCommands:\n", os.Args[0])
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-16s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintln(os.Stderr, "\nConfiguration is read from CONFIG_FILE and the environment.")
+}
+
+// parseFlags parses args for a command that takes no flags of its own, so
+// that -h prints usage and stray arguments are rejected
+func parseFlags(name string, args []string) {
+	flags := flag.NewFlagSet(name, flag.ExitOnError)
+	flags.Parse(args)
+	if flags.NArg() > 0 {
+		log.Fatalf("%s: unexpected arguments %v", name, flags.Args())
+	}
+}
+
+// mustLoadConfig loads and validates the configuration, exiting on error
+func mustLoadConfig() Config {
+	config, err := loadConfig()
+	if err != nil {
+		log.Fatalf("Failed to load configuration:\n%v", err)
+	}
+	if err := config.Validate(); err != nil {
+		log.Fatalf("Invalid configuration:\n%v", err)
+	}
+	return config
+}
+
+// mustOpenStore opens the event store selected by config, reporting to
+// metrics (storage.DefaultMetrics if nil), exiting on error
+func mustOpenStore(config Config, logger *slog.Logger, metrics *storage.Metrics) storage.EventStore {
+	switch {
+	case config.ValidateOnly:
+		log.Println("Validation only: events will be validated, not stored")
+		return storage.NewDryRunStore(logger)
+	case config.DryRun:
+		log.Println("Dry run: events will be logged, not stored")
+		return storage.NewDryRunStore(logger)
+	case config.DBBackend == backendMemory:
+		log.Println("Storing events in memory; they are lost on exit")
+		return storage.NewInMemoryStoreWithMetrics(metrics)
+	}
+
+	store, err := storage.NewEventStore(storage.Config{
+		Host:     config.DBHost,
+		Port:     config.DBPort,
+		User:     config.DBUser,
+		Password: config.DBPassword,
+		Database: config.DBName,
+		SSLMode:  config.DBSSLMode,
+		Logger:   logger,
+		Tracing:  config.TracingEnabled, // Uses the global TracerProvider
+
+		SSLCert:     config.DBSSLCert,
+		SSLKey:      config.DBSSLKey,
+		SSLRootCert: config.DBSSLRootCert,
+
+		MaxOpenConns:    config.DBMaxOpenConns,
+		MaxIdleConns:    config.DBMaxIdleConns,
+		ConnMaxLifetime: config.DBConnMaxLifetime,
+		ConnMaxIdleTime: config.DBConnMaxIdleTime,
+
+		UpsertTypes:   eventTypes(config.UpsertEventTypes),
+		StoredHeaders: config.StoredHeaders,
+
+		DedupCacheSize: config.DedupCacheSize,
+		DedupCacheTTL:  config.DedupCacheTTL,
+
+		TableName: config.DBTable,
+		Columns:   config.DBColumns,
+
+		Metrics: metrics,
+	})
+	if err != nil {
+		log.Fatalf("Failed to create event store: %v", err)
+	}
+	return store
+}
+
+// withWriteGuards wraps store with the write retries, circuit breaker and
+// spill buffer selected by config, reporting to metrics, exiting on error.
+// Only consume uses them: a one-shot command sharing SpillDir would replay
+// and remove segments the running consumer is still writing.
+func withWriteGuards(config Config, store storage.EventStore, logger *slog.Logger, metrics *storage.Metrics) storage.EventStore {
+	if config.ValidateOnly || config.DryRun || config.DBBackend == backendMemory {
+		return store
+	}
+
+	wrapped := store
+	if config.DBWriteRetries > 0 {
+		// Ride out brief failures before they count against the breaker
+		wrapped = storage.NewRetryStore(wrapped, storage.RetryConfig{
+			MaxRetries:     config.DBWriteRetries,
+			InitialBackoff: config.DBWriteBackoff,
+			Logger:         logger,
+			Metrics:        metrics,
+		})
+	}
+	if config.DBBreakerThreshold > 0 {
+		// Fail fast while the database is failing instead of piling on retries
+		wrapped = storage.NewBreakerStore(wrapped, storage.BreakerConfig{
+			Threshold: config.DBBreakerThreshold,
+			Cooldown:  config.DBBreakerCooldown,
+			Logger:    logger,
+			Metrics:   metrics,
+		})
+	}
+	if config.SpillDir == "" {
+		return wrapped
+	}
+
+	// Ride out database outages by buffering events on disk
+	spill, err := storage.NewSpillStore(wrapped, storage.SpillConfig{
+		Dir:           config.SpillDir,
+		FlushInterval: config.SpillFlushInterval,
+		Logger:        logger,
+		Metrics:       metrics,
+	})
+	if err != nil {
+		store.Close()
+		log.Fatalf("Failed to open spill buffer: %v", err)
+	}
+	log.Printf("Buffering events in %s while the database is unavailable\n", config.SpillDir)
+	return spill
+}
+
+// withSinks wraps store to mirror stored events to NDJSON files in
+// SinkDir, if set and events are stored, reporting to metrics, exiting on
+// error
+func withSinks(config Config, store storage.EventStore, logger *slog.Logger, metrics *storage.Metrics) storage.EventStore {
+	if config.SinkDir == "" || config.ValidateOnly {
+		return store
+	}
+	sink, err := storage.NewFileSink(storage.FileSinkConfig{
+		Dir:            config.SinkDir,
+		MaxBytes:       config.SinkMaxBytes,
+		RotateInterval: config.SinkRotateInterval,
+	})
+	if err != nil {
+		store.Close()
+		log.Fatalf("Failed to open file sink: %v", err)
+	}
+	fanout, err := storage.NewFanoutStore(store, storage.FanoutConfig{
+		Sinks:   []storage.SinkConfig{{Name: "file", Sink: sink, Required: config.SinkRequired}},
+		Logger:  logger,
+		Metrics: metrics,
+	})
+	if err != nil {
+		store.Close()
+		log.Fatalf("Failed to configure sinks: %v", err)
+	}
+	log.Printf("Mirroring stored events to %s\n", config.SinkDir)
+	return fanout
+}
+
+// consumerConfig maps config to the Kafka consumer settings. Topics and
+// AssignPartitions are left to the caller.
+func consumerConfig(config Config, logger *slog.Logger) consumer.Config {
+	// Validate has already checked the list parses
+	skipTo, _ := consumer.ParsePartitionOffsets(config.KafkaSkipTo)
+
+	return consumer.Config{
+		BootstrapServers:  config.KafkaBrokers,
+		GroupID:           groupID(config),
+		AutoOffsetReset:   "earliest", // Process all events from beginning
+		Logger:            logger,
+		SecurityProtocol:  config.KafkaSecurityProtocol,
+		SASLMechanism:     config.KafkaSASLMechanism,
+		SASLUsername:      config.KafkaSASLUsername,
+		SASLPassword:      config.KafkaSASLPassword,
+		SSLCALocation:     config.KafkaSSLCALocation,
+		MaxRetries:        config.MaxRetries,
+		RetryBackoff:      config.RetryBackoff,
+		HandlerTimeout:    config.HandlerTimeout,
+		DeadLetterTopic:   deadLetterTopic(config),
+		MaxOffsetRetries:  config.MaxOffsetRetries,
+		CommitOnError:     config.CommitOnError,
+		MaxMessageBytes:   config.MaxMessageBytes,
+		CheckOrdering:     config.CheckOrdering,
+		BatchSize:         config.BatchSize,
+		BatchTimeout:      config.BatchTimeout,
+		AutoCommit:        config.AutoCommit,
+		CommitInterval:    config.CommitInterval,
+		DeadLetterInvalid: deadLetterTopic(config) != "",
+		ValidateOnly:      config.ValidateOnly,
+		UnknownTypePolicy: config.UnknownEventTypes,
+		TypeSource:        config.EventTypeSource,
+		TypeHeader:        config.EventTypeHeader,
+		TypeField:         config.EventTypeField,
+		IncludeTypes:      eventTypes(config.IncludeEventTypes),
+		ExcludeTypes:      eventTypes(config.ExcludeEventTypes),
+		LagInterval:       config.LagInterval,
+		StatsInterval:     config.KafkaStatsInterval,
+		Tracing:           config.TracingEnabled,
+		Concurrency:       config.Concurrency,
+
+		StartFromTimestamp:  config.KafkaStartFrom,
+		SkipTo:              skipTo,
+		CommitOnErrorTypes:  eventTypes(config.CommitOnErrorTypes),
+		OrderingTimestamp:   config.OrderingTimestamp,
+		GroupInstanceID:     groupInstanceID(config),
+		SessionTimeout:      config.KafkaSessionTimeout,
+		BrokerWaitTimeout:   config.KafkaBrokerWait,
+		TenantHeader:        config.KafkaTenantHeader,
+		SchemaVersionHeader: config.KafkaVersionHeader,
+
+		FetchMinBytes:  config.KafkaFetchMinBytes,
+		FetchMaxBytes:  config.KafkaFetchMaxBytes,
+		FetchMaxWait:   config.KafkaFetchMaxWait,
+		MaxPollRecords: config.KafkaMaxPollRecords,
+
+		BackpressureHighWater: config.BackpressureHighWater,
+		BackpressureLowWater:  config.BackpressureLowWater,
+		MaxEventsPerSecond:    config.MaxEventsPerSecond,
+
+		MaxInFlightPerPartition: config.MaxInFlight,
+
+		Format:                 config.MessageFormat,
+		SchemaRegistryURL:      config.SchemaRegistryURL,
+		SchemaRegistryUsername: config.SchemaRegistryUsername,
+		SchemaRegistryPassword: config.SchemaRegistryPassword,
+	}
+}
+
+// runMigrate applies database migrations and exits
+func runMigrate(logger *slog.Logger, args []string) {
+	parseFlags("migrate", args)
+
+	config := mustLoadConfig()
+	store := mustOpenStore(config, logger, nil)
+	defer store.Close()
+
+	if err := store.Migrate(context.Background()); err != nil {
+		log.Fatalf("Failed to migrate database: %v", err)
+	}
+	log.Println("Database migrations applied")
+}
+
+// runValidateConfig checks the configuration without connecting to anything
+func runValidateConfig(_ *slog.Logger, args []string) {
+	parseFlags("validate-config", args)
+	mustLoadConfig()
+	log.Println("Configuration is valid")
+}
+
+// runReplay re-publishes stored events matching the flags to a topic
+func runReplay(logger *slog.Logger, args []string) {
+	flags := flag.NewFlagSet("replay", flag.ExitOnError)
+	topic := flags.String("topic", "", "topic to publish to (required)")
+	types := flags.String("type", "", "comma-separated event types to replay (default all)")
+	from := flags.String("from", "", "replay events at or after this RFC 3339 time")
+	to := flags.String("to", "", "replay events at or before this RFC 3339 time")
+	source := flags.String("source", "", "replay only events from this source platform")
+	entity := flags.String("entity", "", "replay only events for this entity ID")
+	tenant := flags.String("tenant", "", "replay only events for this tenant ID")
+	flags.Parse(args)
+	if *topic == "" {
+		log.Fatal("replay: -topic is required")
+	}
+
+	filter := storage.EventFilter{Source: *source, EntityID: *entity, TenantID: *tenant}
+	for _, name := range strings.Split(*types, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			filter.Types = append(filter.Types, schema.EventType(name))
+		}
+	}
+	var err error
+	if filter.From, err = parseFlagTime("from", *from); err != nil {
+		log.Fatal(err)
+	}
+	if filter.To, err = parseFlagTime("to", *to); err != nil {
+		log.Fatal(err)
+	}
+
+	config := mustLoadConfig()
+	store := mustOpenStore(config, logger, nil)
+	defer store.Close()
+
+	replayer, err := consumer.NewReplayer(consumerConfig(config, logger), *topic)
+	if err != nil {
+		log.Fatalf("Failed to create replayer: %v", err)
+	}
+
+	streamErr := store.StreamEvents(context.Background(), filter, replayer.Publish)
+	if err := replayer.Close(); err != nil {
+		log.Fatalf("Replay delivery failed: %v", err)
+	}
+	if streamErr != nil {
+		log.Fatalf("Replay stopped: %v", streamErr)
+	}
+	log.Println("Replay complete")
+}
+
+// parseFlagTime parses an optional RFC 3339 flag value
+func parseFlagTime(name, value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid -%s: %q is not an RFC 3339 time", name, value)
+	}
+	return t, nil
+}
@@ -0,0 +1,728 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/assure-compliance/eventid/pkg/consumer"
+	"github.com/assure-compliance/eventid/pkg/storage"
+	"gopkg.in/yaml.v3"
+)
+
+// Config holds the consumer binary's settings. Each field can be set in a
+// YAML or JSON config file under its yaml key, or by the environment
+// variable named in loadEnv, which takes precedence.
+type Config struct {
+	KafkaBrokers           string        `yaml:"kafka_brokers"`
+	KafkaTopic             string        `yaml:"kafka_topic"`
+	KafkaGroupID           string        `yaml:"kafka_group_id"`
+	KafkaGroupInstanceID   string        `yaml:"kafka_group_instance_id"`
+	KafkaSessionTimeout    time.Duration `yaml:"kafka_session_timeout"`
+	KafkaBrokerWait        time.Duration `yaml:"kafka_broker_wait"`
+	KafkaSecurityProtocol  string        `yaml:"kafka_security_protocol"`
+	KafkaSASLMechanism     string        `yaml:"kafka_sasl_mechanism"`
+	KafkaSASLUsername      string        `yaml:"kafka_sasl_username"`
+	KafkaSASLPassword      string        `yaml:"kafka_sasl_password"`
+	KafkaSSLCALocation     string        `yaml:"kafka_ssl_ca_location"`
+	KafkaAssignPartitions  string        `yaml:"kafka_assign_partitions"`
+	KafkaStartFrom         time.Time     `yaml:"kafka_start_from"`
+	KafkaSkipTo            string        `yaml:"kafka_skip_to"`
+	KafkaTenantHeader      string        `yaml:"kafka_tenant_header"`
+	KafkaVersionHeader     string        `yaml:"kafka_schema_version_header"`
+	KafkaFetchMinBytes     int           `yaml:"kafka_fetch_min_bytes"`
+	KafkaFetchMaxBytes     int           `yaml:"kafka_fetch_max_bytes"`
+	KafkaFetchMaxWait      time.Duration `yaml:"kafka_fetch_max_wait"`
+	KafkaMaxPollRecords    int           `yaml:"kafka_max_poll_records"`
+	AutoCreateTopics       bool          `yaml:"auto_create_topics"`
+	KafkaTopicPartitions   int           `yaml:"kafka_topic_partitions"`
+	KafkaTopicReplication  int           `yaml:"kafka_topic_replication_factor"`
+	DBBackend              string        `yaml:"db_backend"`
+	DBHost                 string        `yaml:"db_host"`
+	DBPort                 int           `yaml:"db_port"`
+	DBUser                 string        `yaml:"db_user"`
+	DBPassword             string        `yaml:"db_password"`
+	DBName                 string        `yaml:"db_name"`
+	DBSSLMode              string        `yaml:"db_sslmode"`
+	DBSSLCert              string        `yaml:"db_sslcert"`
+	DBSSLKey               string        `yaml:"db_sslkey"`
+	DBSSLRootCert          string        `yaml:"db_sslrootcert"`
+	DBTable                string        `yaml:"db_table"`
+	DBMaxOpenConns         int           `yaml:"db_max_open_conns"`
+	DBMaxIdleConns         int           `yaml:"db_max_idle_conns"`
+	DBConnMaxLifetime      time.Duration `yaml:"db_conn_max_lifetime"`
+	DBConnMaxIdleTime      time.Duration `yaml:"db_conn_max_idle_time"`
+	DBBreakerThreshold     int           `yaml:"db_breaker_threshold"`
+	DBBreakerCooldown      time.Duration `yaml:"db_breaker_cooldown"`
+	DBWriteRetries         int           `yaml:"db_write_retries"`
+	DBWriteBackoff         time.Duration `yaml:"db_write_backoff"`
+	UpsertEventTypes       []string      `yaml:"upsert_event_types"`
+	StoredHeaders          []string      `yaml:"stored_headers"`
+	DedupCacheSize         int           `yaml:"dedup_cache_size"`
+	DedupCacheTTL          time.Duration `yaml:"dedup_cache_ttl"`
+	MetricsPort            string        `yaml:"metrics_port"`
+	MetricsNamespace       string        `yaml:"metrics_namespace"`
+	MetricsSubsystem       string        `yaml:"metrics_subsystem"`
+	MaxRetries             int           `yaml:"max_retries"`
+	RetryBackoff           time.Duration `yaml:"retry_backoff"`
+	HandlerTimeout         time.Duration `yaml:"handler_timeout"`
+	DeadLetterTopic        string        `yaml:"dead_letter_topic"`
+	IncludeEventTypes      []string      `yaml:"include_event_types"`
+	ExcludeEventTypes      []string      `yaml:"exclude_event_types"`
+	MaxOffsetRetries       int           `yaml:"max_offset_retries"`
+	CommitOnError          bool          `yaml:"commit_on_error"`
+	CommitOnErrorTypes     []string      `yaml:"commit_on_error_types"`
+	MaxMessageBytes        int           `yaml:"max_message_bytes"`
+	CheckOrdering          bool          `yaml:"check_ordering"`
+	OrderingTimestamp      string        `yaml:"ordering_timestamp"`
+	BatchSize              int           `yaml:"batch_size"`
+	BatchTimeout           time.Duration `yaml:"batch_timeout"`
+	AutoCommit             bool          `yaml:"auto_commit"`
+	CommitInterval         time.Duration `yaml:"commit_interval"`
+	SchemaDir              string        `yaml:"schema_dir"`
+	PayloadMaxDepth        int           `yaml:"payload_max_depth"`
+	PayloadMaxFields       int           `yaml:"payload_max_fields"`
+	PayloadMaxStringLength int           `yaml:"payload_max_string_length"`
+	LagInterval            time.Duration `yaml:"lag_interval"`
+	KafkaStatsInterval     time.Duration `yaml:"kafka_stats_interval"`
+	TracingEnabled         bool          `yaml:"tracing_enabled"`
+	Concurrency            int           `yaml:"consumer_concurrency"`
+	BackpressureHighWater  int           `yaml:"backpressure_high_water"`
+	BackpressureLowWater   int           `yaml:"backpressure_low_water"`
+	MaxInFlight            int           `yaml:"max_in_flight_per_partition"`
+	MaxEventsPerSecond     float64       `yaml:"max_events_per_second"`
+	MessageFormat          string        `yaml:"kafka_message_format"`
+	SchemaRegistryURL      string        `yaml:"schema_registry_url"`
+	SchemaRegistryUsername string        `yaml:"schema_registry_username"`
+	SchemaRegistryPassword string        `yaml:"schema_registry_password"`
+	DryRun                 bool          `yaml:"dry_run"`
+	ValidateOnly           bool          `yaml:"validate_only"`
+	UnknownEventTypes      string        `yaml:"unknown_event_types"`
+	EventTypeSource        string        `yaml:"event_type_source"`
+	EventTypeHeader        string        `yaml:"event_type_header"`
+	EventTypeField         string        `yaml:"event_type_field"`
+	SkipMigrations         bool          `yaml:"skip_migrations"`
+	SpillDir               string        `yaml:"spill_dir"`
+	SpillFlushInterval     time.Duration `yaml:"spill_flush_interval"`
+	// SinkDir mirrors stored events to rotating NDJSON files. There is no
+	// built-in S3 or Parquet sink: upload the completed files from SinkDir
+	// with a separate shipper, skipping those still named .partial.
+	SinkDir                string        `yaml:"sink_dir"`
+	SinkMaxBytes           int           `yaml:"sink_max_bytes"`
+	SinkRotateInterval     time.Duration `yaml:"sink_rotate_interval"`
+	SinkRequired           bool          `yaml:"sink_required"`
+	ShutdownTimeout        time.Duration `yaml:"shutdown_timeout"`
+	OffsetSnapshotInterval time.Duration `yaml:"offset_snapshot_interval"`
+	RawLog                 bool          `yaml:"raw_log"`
+	LatestEventTypes       []string      `yaml:"latest_event_types"`
+
+	// AdminPort serves the operator endpoints (/pause, /resume, /seek and
+	// /events/export) apart from the read-only metrics port. They are only
+	// served when AdminToken is set, and require it as a bearer token.
+	AdminPort  string `yaml:"admin_port"`
+	AdminToken string `yaml:"admin_token"`
+
+	// Retention maps event types to how long they are kept; types not
+	// listed are kept forever. Pruning runs every PruneInterval.
+	Retention     map[string]time.Duration `yaml:"retention"`
+	PruneInterval time.Duration            `yaml:"prune_interval"`
+
+	// DBColumns renames events table columns, keyed by their default name;
+	// with DBTable it fits the store to an existing schema
+	DBColumns map[string]string `yaml:"db_columns"`
+
+	// SchemaRegistrySubjects maps event types to Schema Registry subjects;
+	// at startup each type's schema in SchemaDir is checked for
+	// compatibility with the subject's latest version
+	SchemaRegistrySubjects map[string]string `yaml:"schema_registry_subjects"`
+}
+
+// metricNamePattern matches the metric name prefixes accepted as
+// MetricsNamespace and MetricsSubsystem
+var metricNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// defaultShutdownTimeout leaves a margin within the 30 seconds orchestrators
+// commonly allow between SIGTERM and SIGKILL
+const defaultShutdownTimeout = 25 * time.Second
+
+// defaultBrokerWait rides out brokers restarting alongside the consumer
+// without hiding a misconfigured broker list for long
+const defaultBrokerWait = 2 * time.Minute
+
+// defaultConfig returns the settings used when neither a config file nor
+// the environment sets a value
+func defaultConfig() Config {
+	return Config{
+		KafkaBrokers:       "localhost:9092",
+		KafkaTopic:         "regulatory-events",
+		KafkaGroupID:       "eventid-consumer-audit",
+		KafkaBrokerWait:    defaultBrokerWait,
+		DBBackend:          backendPostgres,
+		DBHost:             "localhost",
+		DBPort:             5432,
+		DBUser:             "eventid",
+		DBPassword:         "password",
+		DBName:             "eventid_events",
+		DBSSLMode:          "disable",
+		DBMaxOpenConns:     storage.DefaultMaxOpenConns,
+		DBMaxIdleConns:     storage.DefaultMaxIdleConns,
+		DBConnMaxLifetime:  storage.DefaultConnMaxLifetime,
+		DBBreakerThreshold: storage.DefaultBreakerThreshold,
+		DBBreakerCooldown:  storage.DefaultBreakerCooldown,
+		DBWriteRetries:     storage.DefaultWriteRetries,
+		DBWriteBackoff:     storage.DefaultWriteRetryBackoff,
+		MetricsPort:        "9090",
+		AdminPort:          "9091",
+		MaxRetries:         3,
+		RetryBackoff:       consumer.DefaultRetryBackoff,
+		BatchTimeout:       consumer.DefaultBatchTimeout,
+		LagInterval:        consumer.DefaultLagInterval,
+		Concurrency:        1,
+		MessageFormat:      consumer.FormatJSON,
+		PruneInterval:      time.Hour,
+		ShutdownTimeout:    defaultShutdownTimeout,
+	}
+}
+
+// loadConfig reads the file named by CONFIG_FILE, if set, and then the
+// environment
+func loadConfig() (Config, error) {
+	if path := os.Getenv("CONFIG_FILE"); path != "" {
+		return LoadConfigFromFile(path)
+	}
+
+	cfg := defaultConfig()
+	return cfg, errors.Join(loadEnv(&cfg)...)
+}
+
+// LoadConfigFromFile reads settings from a YAML or JSON file, then applies
+// environment variable overrides. Durations are written as strings such as
+// "30s". Every unknown key and unparseable value is reported in the returned
+// error; call Validate to check the resulting settings.
+func LoadConfigFromFile(path string) (Config, error) {
+	cfg := defaultConfig()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return cfg, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	// JSON is valid YAML, so one decoder handles both formats
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	var errs []error
+	if err := decoder.Decode(&cfg); err != nil && !errors.Is(err, io.EOF) {
+		errs = append(errs, fmt.Errorf("%s: %w", path, err))
+	}
+
+	errs = append(errs, loadEnv(&cfg)...)
+	return cfg, errors.Join(errs...)
+}
+
+// Storage backends accepted by DB_BACKEND
+const (
+	backendPostgres = "postgres"
+	backendMemory   = "memory"
+)
+
+// postgresSSLModes are the sslmode values accepted by PostgreSQL
+var postgresSSLModes = []string{"disable", "allow", "prefer", "require", "verify-ca", "verify-full"}
+
+// Validate checks the settings before anything connects, reporting every
+// problem at once. Each error names the config key and environment variable.
+func (c Config) Validate() error {
+	var errs []error
+	invalid := func(key, env, format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf("%s (%s): %s", key, env, fmt.Sprintf(format, args...)))
+	}
+
+	if strings.TrimSpace(c.KafkaBrokers) == "" {
+		invalid("kafka_brokers", "KAFKA_BROKERS", "at least one broker is required")
+	} else {
+		for _, broker := range strings.Split(c.KafkaBrokers, ",") {
+			if strings.TrimSpace(broker) == "" {
+				invalid("kafka_brokers", "KAFKA_BROKERS", "%q contains an empty broker address", c.KafkaBrokers)
+				break
+			}
+		}
+	}
+	if c.KafkaTopic == "" && c.KafkaAssignPartitions == "" {
+		invalid("kafka_topic", "KAFKA_TOPIC", "is required")
+	}
+	if strings.TrimSpace(c.KafkaGroupID) == "" {
+		invalid("kafka_group_id", "KAFKA_GROUP_ID", "is required")
+	}
+	if c.KafkaSessionTimeout < 0 {
+		invalid("kafka_session_timeout", "KAFKA_SESSION_TIMEOUT", "must not be negative")
+	}
+	if c.KafkaBrokerWait < 0 {
+		invalid("kafka_broker_wait", "KAFKA_BROKER_WAIT", "must not be negative")
+	}
+	if _, err := consumer.ParsePartitionOffsets(c.KafkaAssignPartitions); err != nil {
+		invalid("kafka_assign_partitions", "KAFKA_ASSIGN_PARTITIONS", "%v", err)
+	}
+	if !c.KafkaStartFrom.IsZero() && c.KafkaAssignPartitions != "" {
+		invalid("kafka_start_from", "KAFKA_START_FROM", "cannot be combined with kafka_assign_partitions")
+	}
+	if skipTo, err := consumer.ParsePartitionOffsets(c.KafkaSkipTo); err != nil {
+		invalid("kafka_skip_to", "KAFKA_SKIP_TO", "%v", err)
+	} else if len(skipTo) > 0 && c.KafkaAssignPartitions != "" {
+		invalid("kafka_skip_to", "KAFKA_SKIP_TO", "cannot be combined with kafka_assign_partitions")
+	} else {
+		for _, p := range skipTo {
+			if p.Offset < 0 {
+				invalid("kafka_skip_to", "KAFKA_SKIP_TO", "%s: offset must be a number", p)
+			}
+		}
+	}
+	if c.KafkaFetchMinBytes < 0 {
+		invalid("kafka_fetch_min_bytes", "KAFKA_FETCH_MIN_BYTES", "must not be negative")
+	}
+	if c.KafkaFetchMaxBytes < 0 {
+		invalid("kafka_fetch_max_bytes", "KAFKA_FETCH_MAX_BYTES", "must not be negative")
+	} else if c.KafkaFetchMaxBytes > 0 && c.KafkaFetchMaxBytes < c.KafkaFetchMinBytes {
+		invalid("kafka_fetch_max_bytes", "KAFKA_FETCH_MAX_BYTES", "must not be less than kafka_fetch_min_bytes")
+	}
+	if c.KafkaFetchMaxWait < 0 {
+		invalid("kafka_fetch_max_wait", "KAFKA_FETCH_MAX_WAIT", "must not be negative")
+	}
+	if c.KafkaMaxPollRecords < 0 {
+		invalid("kafka_max_poll_records", "KAFKA_MAX_POLL_RECORDS", "must not be negative")
+	}
+	if c.KafkaTopicPartitions < 0 {
+		invalid("kafka_topic_partitions", "KAFKA_TOPIC_PARTITIONS", "must not be negative")
+	}
+	if c.KafkaTopicReplication < 0 {
+		invalid("kafka_topic_replication_factor", "KAFKA_TOPIC_REPLICATION_FACTOR", "must not be negative")
+	}
+
+	if c.DBBackend != backendPostgres && c.DBBackend != backendMemory {
+		invalid("db_backend", "DB_BACKEND", "%q must be one of %s, %s", c.DBBackend, backendPostgres, backendMemory)
+	}
+	if c.DBHost == "" {
+		invalid("db_host", "DB_HOST", "is required")
+	}
+	if c.DBPort < 1 || c.DBPort > 65535 {
+		invalid("db_port", "DB_PORT", "%d is not a valid port (1-65535)", c.DBPort)
+	}
+	if c.DBUser == "" {
+		invalid("db_user", "DB_USER", "is required")
+	}
+	if c.DBName == "" {
+		invalid("db_name", "DB_NAME", "is required")
+	}
+	if !contains(postgresSSLModes, c.DBSSLMode) {
+		invalid("db_sslmode", "DB_SSLMODE", "%q must be one of %s", c.DBSSLMode, strings.Join(postgresSSLModes, ", "))
+	}
+	if c.DBSSLMode == "verify-full" && c.DBSSLRootCert == "" {
+		invalid("db_sslrootcert", "DB_SSLROOTCERT", "is required when db_sslmode is verify-full")
+	}
+	if (c.DBSSLCert == "") != (c.DBSSLKey == "") {
+		invalid("db_sslkey", "DB_SSLKEY", "db_sslcert and db_sslkey must be set together")
+	}
+	if c.DBBreakerThreshold < 0 {
+		invalid("db_breaker_threshold", "DB_BREAKER_THRESHOLD", "must not be negative")
+	}
+	if c.DBBreakerCooldown < 0 {
+		invalid("db_breaker_cooldown", "DB_BREAKER_COOLDOWN", "must not be negative")
+	}
+	if c.DBWriteRetries < 0 {
+		invalid("db_write_retries", "DB_WRITE_RETRIES", "must not be negative")
+	}
+	if c.DBWriteBackoff < 0 {
+		invalid("db_write_backoff", "DB_WRITE_BACKOFF", "must not be negative")
+	}
+	if c.DedupCacheSize < 0 {
+		invalid("dedup_cache_size", "DEDUP_CACHE_SIZE", "must not be negative")
+	}
+	if c.DedupCacheTTL < 0 {
+		invalid("dedup_cache_ttl", "DEDUP_CACHE_TTL", "must not be negative")
+	}
+	if err := storage.ValidateNames(c.DBTable, c.DBColumns); err != nil {
+		invalid("db_table", "DB_TABLE", "%v", err)
+	} else if (c.DBTable != "" && c.DBTable != storage.DefaultTableName || len(c.DBColumns) > 0) && !c.SkipMigrations {
+		invalid("skip_migrations", "SKIP_MIGRATIONS", "must be set with db_table or db_columns, as migrations only support the default names")
+	}
+
+	if port, err := strconv.Atoi(c.MetricsPort); err != nil || port < 1 || port > 65535 {
+		invalid("metrics_port", "METRICS_PORT", "%q is not a valid port (1-65535)", c.MetricsPort)
+	}
+	if c.AdminToken != "" {
+		if port, err := strconv.Atoi(c.AdminPort); err != nil || port < 1 || port > 65535 {
+			invalid("admin_port", "ADMIN_PORT", "%q is not a valid port (1-65535)", c.AdminPort)
+		} else if c.AdminPort == c.MetricsPort {
+			invalid("admin_port", "ADMIN_PORT", "must differ from metrics_port, which is not authenticated")
+		}
+	}
+	if c.MetricsNamespace != "" && !metricNamePattern.MatchString(c.MetricsNamespace) {
+		invalid("metrics_namespace", "METRICS_NAMESPACE", "%q may only contain letters, digits and underscores", c.MetricsNamespace)
+	}
+	if c.MetricsSubsystem != "" && !metricNamePattern.MatchString(c.MetricsSubsystem) {
+		invalid("metrics_subsystem", "METRICS_SUBSYSTEM", "%q may only contain letters, digits and underscores", c.MetricsSubsystem)
+	}
+
+	if c.MaxRetries < 0 {
+		invalid("max_retries", "MAX_RETRIES", "must not be negative")
+	}
+	if c.MaxOffsetRetries < 0 {
+		invalid("max_offset_retries", "MAX_OFFSET_RETRIES", "must not be negative")
+	}
+	if c.MaxMessageBytes < 0 {
+		invalid("max_message_bytes", "MAX_MESSAGE_BYTES", "must not be negative")
+	}
+	if c.BatchSize < 0 {
+		invalid("batch_size", "BATCH_SIZE", "must not be negative")
+	}
+	if c.Concurrency < 1 {
+		invalid("consumer_concurrency", "CONSUMER_CONCURRENCY", "must be at least 1")
+	}
+	if c.BackpressureHighWater < 0 {
+		invalid("backpressure_high_water", "BACKPRESSURE_HIGH_WATER", "must not be negative")
+	}
+	if c.MaxEventsPerSecond < 0 {
+		invalid("max_events_per_second", "MAX_EVENTS_PER_SECOND", "must not be negative")
+	}
+	if c.BackpressureLowWater < 0 {
+		invalid("backpressure_low_water", "BACKPRESSURE_LOW_WATER", "must not be negative")
+	} else if c.BackpressureLowWater > 0 && c.BackpressureLowWater >= c.BackpressureHighWater {
+		invalid("backpressure_low_water", "BACKPRESSURE_LOW_WATER", "must be less than backpressure_high_water")
+	}
+	if c.MaxInFlight < 0 {
+		invalid("max_in_flight_per_partition", "MAX_IN_FLIGHT_PER_PARTITION", "must not be negative")
+	}
+	switch c.MessageFormat {
+	case consumer.FormatJSON, consumer.FormatProtobuf:
+	case consumer.FormatAvro:
+		if c.SchemaRegistryURL == "" {
+			invalid("schema_registry_url", "SCHEMA_REGISTRY_URL", "is required for the avro format")
+		}
+	default:
+		invalid("kafka_message_format", "KAFKA_MESSAGE_FORMAT", "%q must be one of json, avro, protobuf", c.MessageFormat)
+	}
+	switch c.OrderingTimestamp {
+	case "", consumer.OrderingEventTime, consumer.OrderingRecordTime:
+	default:
+		invalid("ordering_timestamp", "ORDERING_TIMESTAMP", "%q must be one of event, record", c.OrderingTimestamp)
+	}
+	switch c.UnknownEventTypes {
+	case "", consumer.UnknownTypeStore, consumer.UnknownTypeDrop:
+	case consumer.UnknownTypeDeadLetter:
+		if c.DeadLetterTopic == "" {
+			invalid("dead_letter_topic", "DEAD_LETTER_TOPIC", "is required when unknown_event_types is dead_letter")
+		}
+	default:
+		invalid("unknown_event_types", "UNKNOWN_EVENT_TYPES", "%q must be one of store, dead_letter, drop", c.UnknownEventTypes)
+	}
+	switch c.EventTypeSource {
+	case "", consumer.TypeSourcePayload, consumer.TypeSourceHeader:
+	case consumer.TypeSourceField:
+		if c.EventTypeField == "" {
+			invalid("event_type_field", "EVENT_TYPE_FIELD", "is required when event_type_source is field")
+		}
+	default:
+		invalid("event_type_source", "EVENT_TYPE_SOURCE", "%q must be one of payload, header, field", c.EventTypeSource)
+	}
+	if len(c.SchemaRegistrySubjects) > 0 {
+		if c.SchemaRegistryURL == "" {
+			invalid("schema_registry_url", "SCHEMA_REGISTRY_URL", "is required for schema_registry_subjects")
+		}
+		if c.SchemaDir == "" {
+			invalid("schema_dir", "SCHEMA_DIR", "is required for schema_registry_subjects")
+		}
+	}
+
+	for eventType, age := range c.Retention {
+		if age <= 0 {
+			invalid("retention", "RETENTION", "%s: retention must be positive", eventType)
+		}
+	}
+	if c.OffsetSnapshotInterval < 0 {
+		invalid("offset_snapshot_interval", "OFFSET_SNAPSHOT_INTERVAL", "must not be negative")
+	}
+	if len(c.Retention) > 0 && c.PruneInterval <= 0 {
+		invalid("prune_interval", "PRUNE_INTERVAL", "must be positive when retention is set")
+	}
+	if c.PayloadMaxDepth < 0 {
+		invalid("payload_max_depth", "PAYLOAD_MAX_DEPTH", "must not be negative")
+	}
+	if c.PayloadMaxFields < 0 {
+		invalid("payload_max_fields", "PAYLOAD_MAX_FIELDS", "must not be negative")
+	}
+	if c.PayloadMaxStringLength < 0 {
+		invalid("payload_max_string_length", "PAYLOAD_MAX_STRING_LENGTH", "must not be negative")
+	}
+	if c.KafkaStatsInterval < 0 {
+		invalid("kafka_stats_interval", "KAFKA_STATS_INTERVAL", "must not be negative")
+	}
+	if c.HandlerTimeout < 0 {
+		invalid("handler_timeout", "HANDLER_TIMEOUT", "must not be negative")
+	}
+	if c.ShutdownTimeout <= 0 {
+		invalid("shutdown_timeout", "SHUTDOWN_TIMEOUT", "must be positive")
+	}
+	if c.SpillFlushInterval < 0 {
+		invalid("spill_flush_interval", "SPILL_FLUSH_INTERVAL", "must not be negative")
+	}
+	if c.SinkMaxBytes < 0 {
+		invalid("sink_max_bytes", "SINK_MAX_BYTES", "must not be negative")
+	}
+	if c.SinkRotateInterval < 0 {
+		invalid("sink_rotate_interval", "SINK_ROTATE_INTERVAL", "must not be negative")
+	}
+
+	return errors.Join(errs...)
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// loadEnv overrides cfg with every environment variable that is set and
+// returns an error for each value that cannot be parsed
+func loadEnv(cfg *Config) []error {
+	var env envReader
+	env.string("KAFKA_BROKERS", &cfg.KafkaBrokers)
+	env.string("KAFKA_TOPIC", &cfg.KafkaTopic)
+	env.string("KAFKA_GROUP_ID", &cfg.KafkaGroupID)
+	env.string("KAFKA_GROUP_INSTANCE_ID", &cfg.KafkaGroupInstanceID)
+	env.duration("KAFKA_SESSION_TIMEOUT", &cfg.KafkaSessionTimeout)
+	env.duration("KAFKA_BROKER_WAIT", &cfg.KafkaBrokerWait)
+	env.string("KAFKA_SECURITY_PROTOCOL", &cfg.KafkaSecurityProtocol)
+	env.string("KAFKA_SASL_MECHANISM", &cfg.KafkaSASLMechanism)
+	env.string("KAFKA_SASL_USERNAME", &cfg.KafkaSASLUsername)
+	env.string("KAFKA_SASL_PASSWORD", &cfg.KafkaSASLPassword)
+	env.string("KAFKA_SSL_CA_LOCATION", &cfg.KafkaSSLCALocation)
+	env.string("KAFKA_ASSIGN_PARTITIONS", &cfg.KafkaAssignPartitions)
+	env.time("KAFKA_START_FROM", &cfg.KafkaStartFrom)
+	env.string("KAFKA_SKIP_TO", &cfg.KafkaSkipTo)
+	env.string("KAFKA_TENANT_HEADER", &cfg.KafkaTenantHeader)
+	env.string("KAFKA_SCHEMA_VERSION_HEADER", &cfg.KafkaVersionHeader)
+	env.int("KAFKA_FETCH_MIN_BYTES", &cfg.KafkaFetchMinBytes)
+	env.int("KAFKA_FETCH_MAX_BYTES", &cfg.KafkaFetchMaxBytes)
+	env.duration("KAFKA_FETCH_MAX_WAIT", &cfg.KafkaFetchMaxWait)
+	env.int("KAFKA_MAX_POLL_RECORDS", &cfg.KafkaMaxPollRecords)
+	env.bool("AUTO_CREATE_TOPICS", &cfg.AutoCreateTopics)
+	env.int("KAFKA_TOPIC_PARTITIONS", &cfg.KafkaTopicPartitions)
+	env.int("KAFKA_TOPIC_REPLICATION_FACTOR", &cfg.KafkaTopicReplication)
+	env.string("DB_BACKEND", &cfg.DBBackend)
+	env.string("DB_HOST", &cfg.DBHost)
+	env.int("DB_PORT", &cfg.DBPort)
+	env.string("DB_USER", &cfg.DBUser)
+	env.string("DB_PASSWORD", &cfg.DBPassword)
+	env.string("DB_NAME", &cfg.DBName)
+	env.string("DB_SSLMODE", &cfg.DBSSLMode)
+	env.string("DB_SSLCERT", &cfg.DBSSLCert)
+	env.string("DB_SSLKEY", &cfg.DBSSLKey)
+	env.string("DB_SSLROOTCERT", &cfg.DBSSLRootCert)
+	env.string("DB_TABLE", &cfg.DBTable)
+	env.int("DB_MAX_OPEN_CONNS", &cfg.DBMaxOpenConns)
+	env.int("DB_MAX_IDLE_CONNS", &cfg.DBMaxIdleConns)
+	env.duration("DB_CONN_MAX_LIFETIME", &cfg.DBConnMaxLifetime)
+	env.duration("DB_CONN_MAX_IDLE_TIME", &cfg.DBConnMaxIdleTime)
+	env.int("DB_BREAKER_THRESHOLD", &cfg.DBBreakerThreshold)
+	env.duration("DB_BREAKER_COOLDOWN", &cfg.DBBreakerCooldown)
+	env.int("DB_WRITE_RETRIES", &cfg.DBWriteRetries)
+	env.duration("DB_WRITE_BACKOFF", &cfg.DBWriteBackoff)
+	env.list("UPSERT_EVENT_TYPES", &cfg.UpsertEventTypes)
+	env.list("STORED_HEADERS", &cfg.StoredHeaders)
+	env.int("DEDUP_CACHE_SIZE", &cfg.DedupCacheSize)
+	env.duration("DEDUP_CACHE_TTL", &cfg.DedupCacheTTL)
+	env.string("METRICS_PORT", &cfg.MetricsPort)
+	env.string("METRICS_NAMESPACE", &cfg.MetricsNamespace)
+	env.string("METRICS_SUBSYSTEM", &cfg.MetricsSubsystem)
+	env.string("ADMIN_PORT", &cfg.AdminPort)
+	env.string("ADMIN_TOKEN", &cfg.AdminToken)
+	env.int("MAX_RETRIES", &cfg.MaxRetries)
+	env.duration("RETRY_BACKOFF", &cfg.RetryBackoff)
+	env.duration("HANDLER_TIMEOUT", &cfg.HandlerTimeout)
+	env.string("DEAD_LETTER_TOPIC", &cfg.DeadLetterTopic)
+	env.int("MAX_OFFSET_RETRIES", &cfg.MaxOffsetRetries)
+	env.bool("COMMIT_ON_ERROR", &cfg.CommitOnError)
+	env.list("COMMIT_ON_ERROR_TYPES", &cfg.CommitOnErrorTypes)
+	env.int("MAX_MESSAGE_BYTES", &cfg.MaxMessageBytes)
+	env.bool("CHECK_ORDERING", &cfg.CheckOrdering)
+	env.string("ORDERING_TIMESTAMP", &cfg.OrderingTimestamp)
+	env.list("INCLUDE_EVENT_TYPES", &cfg.IncludeEventTypes)
+	env.list("EXCLUDE_EVENT_TYPES", &cfg.ExcludeEventTypes)
+	env.int("BATCH_SIZE", &cfg.BatchSize)
+	env.duration("BATCH_TIMEOUT", &cfg.BatchTimeout)
+	env.bool("AUTO_COMMIT", &cfg.AutoCommit)
+	env.duration("COMMIT_INTERVAL", &cfg.CommitInterval)
+	env.string("SCHEMA_DIR", &cfg.SchemaDir)
+	env.int("PAYLOAD_MAX_DEPTH", &cfg.PayloadMaxDepth)
+	env.int("PAYLOAD_MAX_FIELDS", &cfg.PayloadMaxFields)
+	env.int("PAYLOAD_MAX_STRING_LENGTH", &cfg.PayloadMaxStringLength)
+	env.duration("LAG_INTERVAL", &cfg.LagInterval)
+	env.duration("KAFKA_STATS_INTERVAL", &cfg.KafkaStatsInterval)
+	env.bool("TRACING_ENABLED", &cfg.TracingEnabled)
+	env.int("CONSUMER_CONCURRENCY", &cfg.Concurrency)
+	env.int("BACKPRESSURE_HIGH_WATER", &cfg.BackpressureHighWater)
+	env.int("BACKPRESSURE_LOW_WATER", &cfg.BackpressureLowWater)
+	env.int("MAX_IN_FLIGHT_PER_PARTITION", &cfg.MaxInFlight)
+	env.float("MAX_EVENTS_PER_SECOND", &cfg.MaxEventsPerSecond)
+	env.string("KAFKA_MESSAGE_FORMAT", &cfg.MessageFormat)
+	env.string("SCHEMA_REGISTRY_URL", &cfg.SchemaRegistryURL)
+	env.string("SCHEMA_REGISTRY_USERNAME", &cfg.SchemaRegistryUsername)
+	env.string("SCHEMA_REGISTRY_PASSWORD", &cfg.SchemaRegistryPassword)
+	env.bool("DRY_RUN", &cfg.DryRun)
+	env.bool("VALIDATE_ONLY", &cfg.ValidateOnly)
+	env.string("UNKNOWN_EVENT_TYPES", &cfg.UnknownEventTypes)
+	env.string("EVENT_TYPE_SOURCE", &cfg.EventTypeSource)
+	env.string("EVENT_TYPE_HEADER", &cfg.EventTypeHeader)
+	env.string("EVENT_TYPE_FIELD", &cfg.EventTypeField)
+	env.bool("SKIP_MIGRATIONS", &cfg.SkipMigrations)
+	env.string("SPILL_DIR", &cfg.SpillDir)
+	env.duration("SPILL_FLUSH_INTERVAL", &cfg.SpillFlushInterval)
+	env.string("SINK_DIR", &cfg.SinkDir)
+	env.int("SINK_MAX_BYTES", &cfg.SinkMaxBytes)
+	env.duration("SINK_ROTATE_INTERVAL", &cfg.SinkRotateInterval)
+	env.bool("SINK_REQUIRED", &cfg.SinkRequired)
+	env.duration("SHUTDOWN_TIMEOUT", &cfg.ShutdownTimeout)
+	env.duration("OFFSET_SNAPSHOT_INTERVAL", &cfg.OffsetSnapshotInterval)
+	env.bool("RAW_LOG", &cfg.RawLog)
+	env.list("LATEST_EVENT_TYPES", &cfg.LatestEventTypes)
+	env.durations("RETENTION", &cfg.Retention)
+	env.pairs("DB_COLUMNS", &cfg.DBColumns)
+	env.pairs("SCHEMA_REGISTRY_SUBJECTS", &cfg.SchemaRegistrySubjects)
+	env.duration("PRUNE_INTERVAL", &cfg.PruneInterval)
+	return env.errs
+}
+
+// envReader reads typed environment variables, collecting parse errors.
+// Unset or empty variables leave the destination unchanged.
+type envReader struct {
+	errs []error
+}
+
+func (r *envReader) string(key string, dst *string) {
+	if value := os.Getenv(key); value != "" {
+		*dst = value
+	}
+}
+
+// list reads a comma-separated list, ignoring empty entries
+func (r *envReader) list(key string, dst *[]string) {
+	if value := os.Getenv(key); value != "" {
+		var items []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		*dst = items
+	}
+}
+
+func (r *envReader) int(key string, dst *int) {
+	if value := os.Getenv(key); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			r.errs = append(r.errs, fmt.Errorf("%s: invalid integer %q", key, value))
+			return
+		}
+		*dst = n
+	}
+}
+
+func (r *envReader) float(key string, dst *float64) {
+	if value := os.Getenv(key); value != "" {
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			r.errs = append(r.errs, fmt.Errorf("%s: invalid number %q", key, value))
+			return
+		}
+		*dst = f
+	}
+}
+
+func (r *envReader) bool(key string, dst *bool) {
+	if value := os.Getenv(key); value != "" {
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			r.errs = append(r.errs, fmt.Errorf("%s: invalid boolean %q", key, value))
+			return
+		}
+		*dst = b
+	}
+}
+
+func (r *envReader) duration(key string, dst *time.Duration) {
+	if value := os.Getenv(key); value != "" {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			r.errs = append(r.errs, fmt.Errorf("%s: invalid duration %q", key, value))
+			return
+		}
+		*dst = d
+	}
+}
+
+// time reads an RFC 3339 timestamp, e.g. "2024-05-01T00:00:00Z"
+func (r *envReader) time(key string, dst *time.Time) {
+	if value := os.Getenv(key); value != "" {
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			r.errs = append(r.errs, fmt.Errorf("%s: invalid RFC 3339 time %q", key, value))
+			return
+		}
+		*dst = t
+	}
+}
+
+// pairs reads a comma-separated list of key=value pairs, e.g.
+// "event_data=payload,timestamp=occurred_at"
+func (r *envReader) pairs(key string, dst *map[string]string) {
+	value := os.Getenv(key)
+	if value == "" {
+		return
+	}
+
+	m := make(map[string]string)
+	for _, pair := range strings.Split(value, ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		name, v, ok := strings.Cut(pair, "=")
+		if !ok || strings.TrimSpace(name) == "" || strings.TrimSpace(v) == "" {
+			r.errs = append(r.errs, fmt.Errorf("%s: invalid key=value pair %q", key, pair))
+			return
+		}
+		m[strings.TrimSpace(name)] = strings.TrimSpace(v)
+	}
+	*dst = m
+}
+
+// durations reads a comma-separated list of key=duration pairs, e.g.
+// "scan.requested=720h,workflow.started=2160h"
+func (r *envReader) durations(key string, dst *map[string]time.Duration) {
+	value := os.Getenv(key)
+	if value == "" {
+		return
+	}
+
+	m := make(map[string]time.Duration)
+	for _, pair := range strings.Split(value, ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		name, raw, ok := strings.Cut(pair, "=")
+		d, err := time.ParseDuration(strings.TrimSpace(raw))
+		if !ok || strings.TrimSpace(name) == "" || err != nil {
+			r.errs = append(r.errs, fmt.Errorf("%s: invalid key=duration pair %q", key, pair))
+			return
+		}
+		m[strings.TrimSpace(name)] = d
+	}
+	*dst = m
+}
@@ -0,0 +1,278 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDefaultConfigValid(t *testing.T) {
+	if err := defaultConfig().Validate(); err != nil {
+		t.Fatalf("default config is invalid: %v", err)
+	}
+}
+
+// Each invalid setting is reported once, naming its config key and
+// environment variable
+func TestConfigValidate(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		modify func(*Config)
+		want   string // "key (ENV)" the error must name
+	}{
+		{"no brokers", func(c *Config) { c.KafkaBrokers = " " }, "kafka_brokers (KAFKA_BROKERS)"},
+		{"empty broker", func(c *Config) { c.KafkaBrokers = "a:9092,,b:9092" }, "kafka_brokers (KAFKA_BROKERS)"},
+		{"no topic", func(c *Config) { c.KafkaTopic = "" }, "kafka_topic (KAFKA_TOPIC)"},
+		{"no group", func(c *Config) { c.KafkaGroupID = "" }, "kafka_group_id (KAFKA_GROUP_ID)"},
+		{"negative session timeout", func(c *Config) { c.KafkaSessionTimeout = -time.Second }, "kafka_session_timeout (KAFKA_SESSION_TIMEOUT)"},
+		{"bad partitions", func(c *Config) { c.KafkaAssignPartitions = "events:x" }, "kafka_assign_partitions (KAFKA_ASSIGN_PARTITIONS)"},
+		{"start from with partitions", func(c *Config) {
+			c.KafkaAssignPartitions = "events:0:0"
+			c.KafkaStartFrom = time.Now()
+		}, "kafka_start_from (KAFKA_START_FROM)"},
+		{"fetch max below min", func(c *Config) {
+			c.KafkaFetchMinBytes = 100
+			c.KafkaFetchMaxBytes = 10
+		}, "kafka_fetch_max_bytes (KAFKA_FETCH_MAX_BYTES)"},
+		{"unknown backend", func(c *Config) { c.DBBackend = "mysql" }, "db_backend (DB_BACKEND)"},
+		{"port zero", func(c *Config) { c.DBPort = 0 }, "db_port (DB_PORT)"},
+		{"port too high", func(c *Config) { c.DBPort = 65536 }, "db_port (DB_PORT)"},
+		{"unknown sslmode", func(c *Config) { c.DBSSLMode = "on" }, "db_sslmode (DB_SSLMODE)"},
+		{"verify-full without root cert", func(c *Config) { c.DBSSLMode = "verify-full" }, "db_sslrootcert (DB_SSLROOTCERT)"},
+		{"cert without key", func(c *Config) { c.DBSSLCert = "client.crt" }, "db_sslkey (DB_SSLKEY)"},
+		{"negative dedup cache", func(c *Config) { c.DedupCacheSize = -1 }, "dedup_cache_size (DEDUP_CACHE_SIZE)"},
+		{"custom table with migrations", func(c *Config) { c.DBTable = "audit_events" }, "skip_migrations (SKIP_MIGRATIONS)"},
+		{"bad metrics port", func(c *Config) { c.MetricsPort = "http" }, "metrics_port (METRICS_PORT)"},
+		{"admin port is metrics port", func(c *Config) {
+			c.AdminToken = "secret"
+			c.AdminPort = c.MetricsPort
+		}, "admin_port (ADMIN_PORT)"},
+		{"bad metrics namespace", func(c *Config) { c.MetricsNamespace = "event-id" }, "metrics_namespace (METRICS_NAMESPACE)"},
+		{"negative retries", func(c *Config) { c.MaxRetries = -1 }, "max_retries (MAX_RETRIES)"},
+		{"no concurrency", func(c *Config) { c.Concurrency = 0 }, "consumer_concurrency (CONSUMER_CONCURRENCY)"},
+		{"low water above high", func(c *Config) {
+			c.BackpressureHighWater = 10
+			c.BackpressureLowWater = 10
+		}, "backpressure_low_water (BACKPRESSURE_LOW_WATER)"},
+		{"unknown format", func(c *Config) { c.MessageFormat = "xml" }, "kafka_message_format (KAFKA_MESSAGE_FORMAT)"},
+		{"avro without registry", func(c *Config) { c.MessageFormat = "avro" }, "schema_registry_url (SCHEMA_REGISTRY_URL)"},
+		{"unknown ordering", func(c *Config) { c.OrderingTimestamp = "kafka" }, "ordering_timestamp (ORDERING_TIMESTAMP)"},
+		{"dead letter without topic", func(c *Config) { c.UnknownEventTypes = "dead_letter" }, "dead_letter_topic (DEAD_LETTER_TOPIC)"},
+		{"field source without field", func(c *Config) { c.EventTypeSource = "field" }, "event_type_field (EVENT_TYPE_FIELD)"},
+		{"subjects without schema dir", func(c *Config) {
+			c.SchemaRegistryURL = "http://registry:8081"
+			c.SchemaRegistrySubjects = map[string]string{"scan.requested": "scan-requested-value"}
+		}, "schema_dir (SCHEMA_DIR)"},
+		{"zero retention", func(c *Config) { c.Retention = map[string]time.Duration{"scan.requested": 0} }, "retention (RETENTION)"},
+		{"retention without prune interval", func(c *Config) {
+			c.Retention = map[string]time.Duration{"scan.requested": time.Hour}
+			c.PruneInterval = 0
+		}, "prune_interval (PRUNE_INTERVAL)"},
+		{"negative payload depth", func(c *Config) { c.PayloadMaxDepth = -1 }, "payload_max_depth (PAYLOAD_MAX_DEPTH)"},
+		{"no shutdown timeout", func(c *Config) { c.ShutdownTimeout = 0 }, "shutdown_timeout (SHUTDOWN_TIMEOUT)"},
+		{"negative sink size", func(c *Config) { c.SinkMaxBytes = -1 }, "sink_max_bytes (SINK_MAX_BYTES)"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := defaultConfig()
+			tc.modify(&cfg)
+			err := cfg.Validate()
+			if err == nil {
+				t.Fatalf("Validate accepted the config, want an error naming %s", tc.want)
+			}
+			lines := strings.Split(err.Error(), "\n")
+			if len(lines) != 1 {
+				t.Errorf("Validate reported %d errors, want 1: %v", len(lines), err)
+			}
+			if !strings.HasPrefix(lines[0], tc.want+": ") {
+				t.Errorf("Validate returned %q, want it to name %s", lines[0], tc.want)
+			}
+		})
+	}
+}
+
+// Validate reports every invalid setting, not just the first
+func TestConfigValidateReportsAll(t *testing.T) {
+	cfg := defaultConfig()
+	cfg.KafkaTopic = ""
+	cfg.DBPort = -1
+	cfg.Concurrency = 0
+	cfg.ShutdownTimeout = 0
+
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("Validate accepted the config")
+	}
+	for _, want := range []string{
+		"kafka_topic (KAFKA_TOPIC)",
+		"db_port (DB_PORT)",
+		"consumer_concurrency (CONSUMER_CONCURRENCY)",
+		"shutdown_timeout (SHUTDOWN_TIMEOUT)",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Validate error does not name %s: %v", want, err)
+		}
+	}
+	if n := len(strings.Split(err.Error(), "\n")); n != 4 {
+		t.Errorf("Validate reported %d errors, want 4: %v", n, err)
+	}
+}
+
+// writeConfig writes content to a config file named name and returns its
+// path
+func writeConfig(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("failed to write config file: %v", err)
+	}
+	return path
+}
+
+func TestLoadConfigFromFile(t *testing.T) {
+	for _, tc := range []struct {
+		name, file, content string
+	}{
+		{"yaml", "config.yaml", `
+kafka_topic: audit-events
+db_port: 6432
+batch_timeout: 250ms
+upsert_event_types: [workflow.completed]
+retention:
+  scan.requested: 720h
+db_columns:
+  event_data: payload
+`},
+		{"json", "config.json", `{
+  "kafka_topic": "audit-events",
+  "db_port": 6432,
+  "batch_timeout": "250ms",
+  "upsert_event_types": ["workflow.completed"],
+  "retention": {"scan.requested": "720h"},
+  "db_columns": {"event_data": "payload"}
+}`},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg, err := LoadConfigFromFile(writeConfig(t, tc.file, tc.content))
+			if err != nil {
+				t.Fatalf("LoadConfigFromFile failed: %v", err)
+			}
+			if cfg.KafkaTopic != "audit-events" || cfg.DBPort != 6432 {
+				t.Errorf("got topic %q and port %d, want audit-events and 6432", cfg.KafkaTopic, cfg.DBPort)
+			}
+			if cfg.BatchTimeout != 250*time.Millisecond {
+				t.Errorf("BatchTimeout = %s, want 250ms", cfg.BatchTimeout)
+			}
+			if len(cfg.UpsertEventTypes) != 1 || cfg.UpsertEventTypes[0] != "workflow.completed" {
+				t.Errorf("UpsertEventTypes = %v, want [workflow.completed]", cfg.UpsertEventTypes)
+			}
+			if got := cfg.Retention["scan.requested"]; got != 720*time.Hour {
+				t.Errorf("Retention[scan.requested] = %s, want 720h", got)
+			}
+			if got := cfg.DBColumns["event_data"]; got != "payload" {
+				t.Errorf("DBColumns[event_data] = %q, want payload", got)
+			}
+			// Unset keys keep their defaults
+			if cfg.KafkaGroupID != defaultConfig().KafkaGroupID {
+				t.Errorf("KafkaGroupID = %q, want the default", cfg.KafkaGroupID)
+			}
+		})
+	}
+}
+
+// Every unknown key and unparseable value in the file is reported
+func TestLoadConfigFromFileRejectsUnknownKeys(t *testing.T) {
+	path := writeConfig(t, "config.yaml", "kafka_topik: audit-events\nbatch_timeout: soon\n")
+	_, err := LoadConfigFromFile(path)
+	if err == nil {
+		t.Fatal("LoadConfigFromFile accepted an unknown key")
+	}
+	for _, want := range []string{"kafka_topik", "soon"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error does not mention %q: %v", want, err)
+		}
+	}
+}
+
+// Environment variables take precedence over the file
+func TestLoadConfigFromFileEnvOverrides(t *testing.T) {
+	path := writeConfig(t, "config.yaml", "kafka_topic: from-file\ndb_port: 6432\nbatch_timeout: 250ms\n")
+	t.Setenv("KAFKA_TOPIC", "from-env")
+	t.Setenv("BATCH_TIMEOUT", "2s")
+
+	cfg, err := LoadConfigFromFile(path)
+	if err != nil {
+		t.Fatalf("LoadConfigFromFile failed: %v", err)
+	}
+	if cfg.KafkaTopic != "from-env" {
+		t.Errorf("KafkaTopic = %q, want from-env", cfg.KafkaTopic)
+	}
+	if cfg.BatchTimeout != 2*time.Second {
+		t.Errorf("BatchTimeout = %s, want 2s", cfg.BatchTimeout)
+	}
+	if cfg.DBPort != 6432 {
+		t.Errorf("DBPort = %d, want 6432 from the file", cfg.DBPort)
+	}
+}
+
+func TestLoadEnv(t *testing.T) {
+	t.Setenv("UPSERT_EVENT_TYPES", " workflow.completed, ,scan.requested ")
+	t.Setenv("RETENTION", "scan.requested=720h, workflow.started=2160h")
+	t.Setenv("DB_COLUMNS", "event_data=payload,timestamp = occurred_at")
+	t.Setenv("DB_BREAKER_COOLDOWN", "1m")
+	t.Setenv("KAFKA_START_FROM", "2024-05-01T00:00:00Z")
+
+	cfg := defaultConfig()
+	if errs := loadEnv(&cfg); len(errs) > 0 {
+		t.Fatalf("loadEnv failed: %v", errs)
+	}
+	if got := strings.Join(cfg.UpsertEventTypes, ","); got != "workflow.completed,scan.requested" {
+		t.Errorf("UpsertEventTypes = %v, want [workflow.completed scan.requested]", cfg.UpsertEventTypes)
+	}
+	if len(cfg.Retention) != 2 || cfg.Retention["scan.requested"] != 720*time.Hour || cfg.Retention["workflow.started"] != 2160*time.Hour {
+		t.Errorf("Retention = %v, want scan.requested=720h and workflow.started=2160h", cfg.Retention)
+	}
+	if len(cfg.DBColumns) != 2 || cfg.DBColumns["event_data"] != "payload" || cfg.DBColumns["timestamp"] != "occurred_at" {
+		t.Errorf("DBColumns = %v, want event_data=payload and timestamp=occurred_at", cfg.DBColumns)
+	}
+	if cfg.DBBreakerCooldown != time.Minute {
+		t.Errorf("DBBreakerCooldown = %s, want 1m", cfg.DBBreakerCooldown)
+	}
+	if want := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC); !cfg.KafkaStartFrom.Equal(want) {
+		t.Errorf("KafkaStartFrom = %s, want %s", cfg.KafkaStartFrom, want)
+	}
+}
+
+// Each unparseable variable is reported by name and leaves its setting
+// unchanged
+func TestLoadEnvErrors(t *testing.T) {
+	for key, value := range map[string]string{
+		"DB_PORT":               "postgres",
+		"DRY_RUN":               "maybe",
+		"BATCH_TIMEOUT":         "250",
+		"MAX_EVENTS_PER_SECOND": "fast",
+		"KAFKA_START_FROM":      "yesterday",
+		"RETENTION":             "scan.requested=720h,workflow.started",
+		"DB_COLUMNS":            "event_data=",
+	} {
+		t.Setenv(key, value)
+	}
+
+	cfg := defaultConfig()
+	errs := loadEnv(&cfg)
+	if len(errs) != 7 {
+		t.Errorf("loadEnv reported %d errors, want 7: %v", len(errs), errs)
+	}
+	joined := errors.Join(errs...).Error()
+	for _, key := range []string{"DB_PORT", "DRY_RUN", "BATCH_TIMEOUT", "MAX_EVENTS_PER_SECOND", "KAFKA_START_FROM", "RETENTION", "DB_COLUMNS"} {
+		if !strings.Contains(joined, key+": ") {
+			t.Errorf("no error names %s: %v", key, joined)
+		}
+	}
+	want := defaultConfig()
+	if cfg.DBPort != want.DBPort || cfg.BatchTimeout != want.BatchTimeout || cfg.Retention != nil || cfg.DBColumns != nil {
+		t.Errorf("unparseable variables changed the config")
+	}
+}
@@ -0,0 +1,176 @@
+-- Convert the events table to monthly range partitions on timestamp
+--
+-- This is a one-off, manual conversion and is deliberately not an embedded
+-- migration: it copies every row and holds an exclusive lock on events for
+-- the duration. Run it during a maintenance window with the consumer
+-- stopped, after every embedded migration (through 0016) has been applied,
+-- e.g. by starting the consumer once. When a migration adds a column or
+-- index to events, add it here too:
+--
+--   psql "$DATABASE_URL" -v ON_ERROR_STOP=1 -f events_partitioning.sql
+--
+-- Requires PostgreSQL 13+ (row triggers on partitioned tables). Partitions
+-- are created for every month that has events plus the next three months;
+-- after that the consumer creates upcoming partitions with
+-- PostgresStore.EnsurePartition. Keep events_legacy until the copy has
+-- been verified, then drop it.
+--
+-- Unique constraints on a partitioned table must include the partition key,
+-- so event_id is unique per (event_id, timestamp), which is also the
+-- ON CONFLICT target used by inserts.
+
+BEGIN;
+
+LOCK TABLE events IN ACCESS EXCLUSIVE MODE;
+
+ALTER TABLE events RENAME TO events_legacy;
+ALTER TRIGGER prevent_event_update ON events_legacy RENAME TO prevent_legacy_event_update;
+ALTER TRIGGER prevent_event_delete ON events_legacy RENAME TO prevent_legacy_event_delete;
+
+CREATE TABLE events (
+    id BIGINT NOT NULL DEFAULT nextval('events_id_seq'),
+    event_id UUID NOT NULL,
+    event_version INTEGER NOT NULL,
+    event_type VARCHAR(100) NOT NULL,
+    platform VARCHAR(50) NOT NULL,
+    timestamp TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    correlation_id VARCHAR(255),
+    user_id VARCHAR(255),
+    event_data JSONB NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    revised_at TIMESTAMP WITH TIME ZONE, -- Set when an upserted event is revised
+    entity_id VARCHAR(255), -- Kafka message key
+    tenant_id VARCHAR(255), -- From the tenant message header
+    headers JSONB, -- Message headers listed in StoredHeaders
+    record_timestamp TIMESTAMP WITH TIME ZONE, -- Kafka record timestamp (ingest time)
+    kafka_topic VARCHAR(255), -- Topic and partition the event was read from
+    kafka_partition INTEGER,
+    causation_id VARCHAR(255), -- event_id of the event that caused this one
+    PRIMARY KEY (id, timestamp)
+) PARTITION BY RANGE (timestamp);
+ALTER SEQUENCE events_id_seq OWNED BY events.id;
+
+-- Monthly partitions covering existing data and the next three months
+DO $$
+DECLARE
+    month DATE;
+    last_month DATE;
+BEGIN
+    SELECT date_trunc('month', COALESCE(MIN(timestamp), NOW()) AT TIME ZONE 'UTC')::date
+      INTO month FROM events_legacy;
+    last_month := (date_trunc('month', NOW() AT TIME ZONE 'UTC') + INTERVAL '3 months')::date;
+    WHILE month <= last_month LOOP
+        EXECUTE format(
+            'CREATE TABLE IF NOT EXISTS %I PARTITION OF events FOR VALUES FROM (%L) TO (%L)',
+            'events_y' || to_char(month, 'YYYY') || 'm' || to_char(month, 'MM'),
+            month::timestamp AT TIME ZONE 'UTC',
+            (month + INTERVAL '1 month')::timestamp AT TIME ZONE 'UTC');
+        month := (month + INTERVAL '1 month')::date;
+    END LOOP;
+END $$;
+
+-- Columns are listed because their order in events_legacy depends on the
+-- order the migrations added them
+INSERT INTO events (
+    id, event_id, event_version, event_type, platform, timestamp,
+    correlation_id, user_id, event_data, created_at, revised_at, entity_id,
+    tenant_id, headers, record_timestamp, kafka_topic, kafka_partition,
+    causation_id
+)
+SELECT
+    id, event_id, event_version, event_type, platform, timestamp,
+    correlation_id, user_id, event_data, created_at, revised_at, entity_id,
+    tenant_id, headers, record_timestamp, kafka_topic, kafka_partition,
+    causation_id
+FROM events_legacy;
+
+-- Indexes (created on every partition)
+CREATE UNIQUE INDEX idx_events_part_event_id_timestamp ON events(event_id, timestamp);
+CREATE INDEX idx_events_part_event_id ON events(event_id);
+CREATE INDEX idx_events_part_platform ON events(platform);
+CREATE INDEX idx_events_part_event_type ON events(event_type);
+CREATE INDEX idx_events_part_timestamp ON events(timestamp DESC);
+CREATE INDEX idx_events_part_correlation_id ON events(correlation_id) WHERE correlation_id IS NOT NULL;
+CREATE INDEX idx_events_part_causation_id ON events(causation_id) WHERE causation_id IS NOT NULL;
+CREATE INDEX idx_events_part_user_id ON events(user_id) WHERE user_id IS NOT NULL;
+CREATE INDEX idx_events_part_type_timestamp ON events(event_type, timestamp DESC);
+CREATE INDEX idx_events_part_entity_timestamp ON events(entity_id, timestamp) WHERE entity_id IS NOT NULL;
+CREATE INDEX idx_events_part_tenant_timestamp ON events(tenant_id, timestamp DESC) WHERE tenant_id IS NOT NULL;
+CREATE INDEX idx_events_part_record_timestamp ON events(record_timestamp DESC) WHERE record_timestamp IS NOT NULL;
+CREATE INDEX idx_events_part_kafka_partition ON events(kafka_topic, kafka_partition, record_timestamp) WHERE kafka_topic IS NOT NULL;
+CREATE INDEX idx_events_part_headers ON events USING GIN (headers jsonb_path_ops) WHERE headers IS NOT NULL;
+CREATE INDEX idx_events_part_data_framework ON events ((event_data->'jurisdiction'->>'framework'));
+CREATE INDEX idx_events_part_data_region ON events ((event_data->'jurisdiction'->>'region'));
+CREATE INDEX idx_events_part_data_severity ON events ((event_data->'risk_context'->>'change_severity'));
+CREATE INDEX idx_events_part_data ON events USING GIN (event_data jsonb_path_ops);
+CREATE INDEX idx_events_part_data_text ON events USING GIN (to_tsvector('english', event_data::text));
+
+-- Row triggers on a partitioned table fire on its partitions, so
+-- TG_TABLE_NAME is the partition's name rather than events. Recognise event
+-- rows as anything but event_revisions, so that revisions and retention
+-- pruning keep working. Pruning needs the privileges of the owner of
+-- events itself, not of the partition, which whoever created it owns.
+CREATE OR REPLACE FUNCTION prevent_event_modification()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'DELETE' AND current_setting('eventid.retention_prune', true) = 'on'
+        AND (SELECT pg_has_role(current_user, relowner, 'USAGE') FROM pg_class
+             WHERE oid = COALESCE(pg_partition_root(TG_RELID), TG_RELID)) THEN
+        IF TG_TABLE_NAME <> 'event_revisions' THEN
+            DELETE FROM event_revisions WHERE event_id = OLD.event_id;
+        END IF;
+        RETURN OLD;
+    END IF;
+    IF TG_OP = 'UPDATE' AND TG_TABLE_NAME <> 'event_revisions'
+        AND current_setting('eventid.event_revision', true) = 'on' THEN
+        INSERT INTO event_revisions (
+            event_id, event_version, correlation_id, user_id, event_data, stored_at
+        ) VALUES (
+            OLD.event_id, OLD.event_version, OLD.correlation_id, OLD.user_id,
+            OLD.event_data, COALESCE(OLD.revised_at, OLD.created_at)
+        );
+        RETURN NEW;
+    END IF;
+    RAISE EXCEPTION 'Events are immutable and cannot be modified or deleted';
+END;
+$$ LANGUAGE plpgsql;
+
+-- Immutability triggers
+CREATE TRIGGER prevent_event_update
+    BEFORE UPDATE ON events
+    FOR EACH ROW
+    EXECUTE FUNCTION prevent_event_modification();
+
+CREATE TRIGGER prevent_event_delete
+    BEFORE DELETE ON events
+    FOR EACH ROW
+    EXECUTE FUNCTION prevent_event_modification();
+
+-- Views are bound to the table they were created on, so re-point them
+CREATE OR REPLACE VIEW event_statistics AS
+SELECT
+    platform,
+    event_type,
+    DATE(timestamp) as event_date,
+    COUNT(*) as event_count,
+    COUNT(DISTINCT correlation_id) FILTER (WHERE correlation_id IS NOT NULL) as workflow_count
+FROM events
+GROUP BY platform, event_type, DATE(timestamp);
+
+CREATE OR REPLACE VIEW recent_events AS
+SELECT
+    event_id,
+    event_type,
+    platform,
+    timestamp,
+    correlation_id,
+    event_data->>'workspace_id' as workspace_id,
+    event_data->'jurisdiction'->>'framework' as framework,
+    event_data->'risk_context'->>'change_severity' as severity
+FROM events
+WHERE timestamp >= NOW() - INTERVAL '7 days'
+ORDER BY timestamp DESC;
+
+COMMENT ON TABLE events IS 'Immutable append-only log of all platform events, partitioned by month';
+
+COMMIT;
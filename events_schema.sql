@@ -1,5 +1,8 @@
 -- Events database schema
 -- Immutable audit log of all platform events
+-- Applied automatically on startup from pkg/storage/migrations; kept here
+-- as a reference for the current table layout. See events_partitioning.sql
+-- for converting the table to monthly partitions.
 
 CREATE EXTENSION IF NOT EXISTS "uuid-ossp";
 CREATE EXTENSION IF NOT EXISTS "pg_trgm"; -- For full-text search
@@ -15,29 +18,81 @@ CREATE TABLE events (
     correlation_id VARCHAR(255),
     user_id VARCHAR(255),
     event_data JSONB NOT NULL,
-    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    revised_at TIMESTAMP WITH TIME ZONE, -- Set when an upserted event is revised
+    entity_id VARCHAR(255), -- Kafka message key
+    tenant_id VARCHAR(255), -- From the tenant message header
+    headers JSONB, -- Message headers listed in StoredHeaders
+    record_timestamp TIMESTAMP WITH TIME ZONE, -- Kafka record timestamp (ingest time)
+    kafka_topic VARCHAR(255), -- Topic and partition the event was read from
+    kafka_partition INTEGER,
+    causation_id VARCHAR(255) -- event_id of the event that caused this one
 );
 
+-- Previous contents of revised events, oldest first per event
+CREATE TABLE event_revisions (
+    id BIGSERIAL PRIMARY KEY,
+    event_id UUID NOT NULL,
+    event_version INTEGER NOT NULL,
+    correlation_id VARCHAR(255),
+    user_id VARCHAR(255),
+    event_data JSONB NOT NULL,
+    stored_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    superseded_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_event_revisions_event_id ON event_revisions(event_id, superseded_at);
+
 -- Indexes for fast queries
+CREATE UNIQUE INDEX idx_events_event_id_timestamp ON events(event_id, timestamp); -- ON CONFLICT target
 CREATE INDEX idx_events_event_id ON events(event_id);
 CREATE INDEX idx_events_platform ON events(platform);
 CREATE INDEX idx_events_event_type ON events(event_type);
 CREATE INDEX idx_events_timestamp ON events(timestamp DESC);
 CREATE INDEX idx_events_correlation_id ON events(correlation_id) WHERE correlation_id IS NOT NULL;
+CREATE INDEX idx_events_causation_id ON events(causation_id) WHERE causation_id IS NOT NULL;
 CREATE INDEX idx_events_user_id ON events(user_id) WHERE user_id IS NOT NULL;
+CREATE INDEX idx_events_type_timestamp ON events(event_type, timestamp DESC); -- QueryEvents by type + time range
+CREATE INDEX idx_events_entity_timestamp ON events(entity_id, timestamp) WHERE entity_id IS NOT NULL; -- Entity timelines
+CREATE INDEX idx_events_tenant_timestamp ON events(tenant_id, timestamp DESC) WHERE tenant_id IS NOT NULL; -- Per-tenant queries
+CREATE INDEX idx_events_record_timestamp ON events(record_timestamp DESC) WHERE record_timestamp IS NOT NULL; -- EventFilter.RecordFrom/RecordTo
+CREATE INDEX idx_events_kafka_partition ON events(kafka_topic, kafka_partition, record_timestamp) WHERE kafka_topic IS NOT NULL; -- reconcile
+CREATE INDEX idx_events_headers ON events USING GIN (headers jsonb_path_ops) WHERE headers IS NOT NULL; -- EventFilter.Headers
 
 -- JSONB indexes for querying event data
-CREATE INDEX idx_events_data_framework ON events USING GIN ((event_data->'jurisdiction'->>'framework'));
-CREATE INDEX idx_events_data_region ON events USING GIN ((event_data->'jurisdiction'->>'region'));
-CREATE INDEX idx_events_data_severity ON events USING GIN ((event_data->'risk_context'->>'change_severity'));
+CREATE INDEX idx_events_data_framework ON events ((event_data->'jurisdiction'->>'framework'));
+CREATE INDEX idx_events_data_region ON events ((event_data->'jurisdiction'->>'region'));
+CREATE INDEX idx_events_data_severity ON events ((event_data->'risk_context'->>'change_severity'));
+CREATE INDEX idx_events_data ON events USING GIN (event_data jsonb_path_ops); -- EventFilter.Payload
 
 -- Full-text search on event data
 CREATE INDEX idx_events_data_text ON events USING GIN (to_tsvector('english', event_data::text));
 
--- Audit trigger to prevent updates/deletes (immutability)
+-- Audit trigger to prevent updates/deletes (immutability). Retention
+-- pruning deletes with eventid.retention_prune set for its transaction and
+-- the privileges of the table's owner, through prune_events, and upserts
+-- revise events with eventid.event_revision set, keeping the previous row
+-- in event_revisions.
 CREATE OR REPLACE FUNCTION prevent_event_modification()
 RETURNS TRIGGER AS $$
 BEGIN
+    IF TG_OP = 'DELETE' AND current_setting('eventid.retention_prune', true) = 'on'
+        AND (SELECT pg_has_role(current_user, relowner, 'USAGE') FROM pg_class WHERE oid = TG_RELID) THEN
+        IF TG_TABLE_NAME = 'events' THEN
+            DELETE FROM event_revisions WHERE event_id = OLD.event_id;
+        END IF;
+        RETURN OLD;
+    END IF;
+    IF TG_OP = 'UPDATE' AND TG_TABLE_NAME = 'events'
+        AND current_setting('eventid.event_revision', true) = 'on' THEN
+        INSERT INTO event_revisions (
+            event_id, event_version, correlation_id, user_id, event_data, stored_at
+        ) VALUES (
+            OLD.event_id, OLD.event_version, OLD.correlation_id, OLD.user_id,
+            OLD.event_data, COALESCE(OLD.revised_at, OLD.created_at)
+        );
+        RETURN NEW;
+    END IF;
     RAISE EXCEPTION 'Events are immutable and cannot be modified or deleted';
 END;
 $$ LANGUAGE plpgsql;
@@ -52,6 +107,33 @@ CREATE TRIGGER prevent_event_delete
     FOR EACH ROW
     EXECUTE FUNCTION prevent_event_modification();
 
+CREATE TRIGGER prevent_event_revision_modification
+    BEFORE UPDATE OR DELETE ON event_revisions
+    FOR EACH ROW
+    EXECUTE FUNCTION prevent_event_modification();
+
+-- Retention pruning, run as the function's owner. Grant EXECUTE to the
+-- role the consumer connects as if it does not own the events table.
+CREATE OR REPLACE FUNCTION prune_events(p_event_type VARCHAR, p_cutoff TIMESTAMPTZ, p_limit INTEGER)
+RETURNS BIGINT AS $$
+DECLARE
+    deleted BIGINT;
+BEGIN
+    PERFORM set_config('eventid.retention_prune', 'on', true);
+    DELETE FROM events
+    WHERE event_id IN (
+        SELECT event_id FROM events
+        WHERE event_type = p_event_type AND timestamp < p_cutoff
+        LIMIT p_limit
+    );
+    GET DIAGNOSTICS deleted = ROW_COUNT;
+    PERFORM set_config('eventid.retention_prune', 'off', true);
+    RETURN deleted;
+END;
+$$ LANGUAGE plpgsql SECURITY DEFINER SET search_path = public, pg_temp;
+
+REVOKE ALL ON FUNCTION prune_events(VARCHAR, TIMESTAMPTZ, INTEGER) FROM PUBLIC;
+
 -- Event statistics view
 CREATE VIEW event_statistics AS
 SELECT
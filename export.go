@@ -0,0 +1,170 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/assure-compliance/eventid/pkg/schema"
+	"github.com/assure-compliance/eventid/pkg/storage"
+)
+
+// exportFlushEvery is how many events are written between flushes of an
+// export stream
+const exportFlushEvery = 500
+
+// exportHandler streams events matching the query parameters as
+// newline-delimited JSON, one stored payload per line, oldest first:
+//
+//	GET /events/export?type=scan.violation_found&from=2024-01-01T00:00:00Z&to=...&source=...&entity_id=...&tenant_id=...&header=service:billing&payload=jurisdiction.region:EU
+//
+// type may be repeated or comma-separated; from and to are RFC 3339 and
+// inclusive and bound the event time, and record_from and record_to
+// likewise bound the Kafka record timestamp (ingest time). entity_id
+// selects one entity's audit timeline and tenant_id one tenant's events.
+// header, which may be repeated, selects events stored with that message
+// header value; see STORED_HEADERS. payload, which may also be repeated,
+// selects events whose payload holds the value at a dot-separated path; a
+// value that parses as a JSON number, bool or null matches that, anything
+// else a string, so quote it ("5") to match the string. The response is
+// flushed as it is written, and a client disconnect cancels the database
+// query.
+func exportHandler(store storage.EventStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		filter, err := exportFilter(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.WriteHeader(http.StatusOK)
+
+		flusher, _ := w.(http.Flusher)
+		out := bufio.NewWriter(w)
+		flush := func() {
+			out.Flush()
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+
+		var line bytes.Buffer
+		count := 0
+		err = store.StreamEvents(r.Context(), filter, func(event schema.Event) error {
+			line.Reset()
+			if err := json.Compact(&line, event.Payload); err != nil {
+				return fmt.Errorf("event %s has invalid payload: %w", event.ID, err)
+			}
+			line.WriteByte('\n')
+			if _, err := out.Write(line.Bytes()); err != nil {
+				return err
+			}
+
+			count++
+			if count%exportFlushEvery == 0 {
+				flush()
+			}
+			return nil
+		})
+		flush()
+
+		// The status has already been sent, so a failure can only truncate
+		// the stream
+		if err != nil && r.Context().Err() == nil {
+			log.Printf("Event export failed after %d events: %v\n", count, err)
+			return
+		}
+		log.Printf("Exported %d events\n", count)
+	}
+}
+
+// exportFilter builds the event filter from the export query parameters
+func exportFilter(r *http.Request) (storage.EventFilter, error) {
+	query := r.URL.Query()
+	filter := storage.EventFilter{
+		Source:   query.Get("source"),
+		EntityID: query.Get("entity_id"),
+		TenantID: query.Get("tenant_id"),
+	}
+
+	for _, value := range query["type"] {
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				filter.Types = append(filter.Types, schema.EventType(name))
+			}
+		}
+	}
+
+	for _, value := range query["header"] {
+		name, headerValue, ok := strings.Cut(value, ":")
+		if !ok || name == "" {
+			return filter, fmt.Errorf("invalid header: %q is not name:value", value)
+		}
+		if filter.Headers == nil {
+			filter.Headers = make(map[string]string)
+		}
+		filter.Headers[name] = headerValue
+	}
+
+	for _, value := range query["payload"] {
+		path, raw, ok := strings.Cut(value, ":")
+		if !ok || path == "" {
+			return filter, fmt.Errorf("invalid payload: %q is not path:value", value)
+		}
+		if filter.Payload == nil {
+			filter.Payload = make(map[string]interface{})
+		}
+		filter.Payload[path] = payloadValue(raw)
+	}
+	if err := filter.Validate(); err != nil {
+		return filter, err
+	}
+
+	for _, p := range []struct {
+		name string
+		dst  *time.Time
+	}{
+		{"from", &filter.From},
+		{"to", &filter.To},
+		{"record_from", &filter.RecordFrom},
+		{"record_to", &filter.RecordTo},
+	} {
+		value := query.Get(p.name)
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return filter, fmt.Errorf("invalid %s: %q is not an RFC 3339 time", p.name, value)
+		}
+		*p.dst = t
+	}
+
+	return filter, nil
+}
+
+// payloadValue interprets a payload query value as a JSON scalar if it is
+// one, and otherwise as a string
+func payloadValue(raw string) interface{} {
+	var value interface{}
+	if err := json.Unmarshal([]byte(raw), &value); err != nil {
+		return raw
+	}
+	switch value.(type) {
+	case map[string]interface{}, []interface{}:
+		return raw
+	}
+	return value
+}
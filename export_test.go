@@ -0,0 +1,79 @@
+package main
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// A payload query value is a JSON scalar if it parses as one, and
+// otherwise a string
+func TestPayloadValue(t *testing.T) {
+	for _, tc := range []struct {
+		raw  string
+		want interface{}
+	}{
+		{"EU", "EU"},
+		{"5", float64(5)},
+		{"2.5", 2.5},
+		{`"5"`, "5"},
+		{"true", true},
+		{"null", nil},
+		{"", ""},
+		{`{"region":"EU"}`, `{"region":"EU"}`},
+		{"[1,2]", "[1,2]"},
+		{"EU:west", "EU:west"},
+	} {
+		if got := payloadValue(tc.raw); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("payloadValue(%q) = %#v, want %#v", tc.raw, got, tc.want)
+		}
+	}
+}
+
+func TestExportFilter(t *testing.T) {
+	r := httptest.NewRequest("GET", "/events/export?type=scan.requested,workflow.started&type=scan.violation_found"+
+		"&header=service:billing&payload=jurisdiction.region:EU&payload=severity:5&payload=code:%225%22"+
+		"&from=2024-01-01T00:00:00Z&entity_id=acct-1", nil)
+	filter, err := exportFilter(r)
+	if err != nil {
+		t.Fatalf("exportFilter failed: %v", err)
+	}
+	if len(filter.Types) != 3 {
+		t.Errorf("Types = %v, want 3 types", filter.Types)
+	}
+	if filter.Headers["service"] != "billing" {
+		t.Errorf("Headers = %v, want service=billing", filter.Headers)
+	}
+	want := map[string]interface{}{"jurisdiction.region": "EU", "severity": float64(5), "code": "5"}
+	if !reflect.DeepEqual(filter.Payload, want) {
+		t.Errorf("Payload = %v, want %v", filter.Payload, want)
+	}
+	if filter.From.IsZero() || filter.EntityID != "acct-1" {
+		t.Errorf("got from %s and entity %q, want both set", filter.From, filter.EntityID)
+	}
+}
+
+// Malformed parameters are rejected before any query runs
+func TestExportFilterErrors(t *testing.T) {
+	for _, tc := range []struct {
+		query string
+		want  string // Substring of the error
+	}{
+		{"header=service", "invalid header"},
+		{"header=:billing", "invalid header"},
+		{"payload=region", "invalid payload"},
+		{"payload=:EU", "invalid payload"},
+		{"payload=jurisdiction..region:EU", "empty key"},
+		{"payload=jurisdiction:EU&payload=jurisdiction.region:EU", "conflicts"},
+		{"from=yesterday", "invalid from"},
+		{"record_to=2024-01-01", "invalid record_to"},
+	} {
+		t.Run(tc.query, func(t *testing.T) {
+			_, err := exportFilter(httptest.NewRequest("GET", "/events/export?"+tc.query, nil))
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Errorf("exportFilter returned %v, want an error containing %q", err, tc.want)
+			}
+		})
+	}
+}
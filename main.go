@@ -1,67 +1,158 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"strings"
 	"syscall"
+	"time"
 
 	"github.com/assure-compliance/eventid/pkg/consumer"
 	"github.com/assure-compliance/eventid/pkg/schema"
 	"github.com/assure-compliance/eventid/pkg/storage"
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
-var (
-	eventsConsumed = promauto.NewCounter(prometheus.CounterOpts{
-		Name: "regulatory_events_consumed_total",
-		Help: "Total number of events consumed from Kafka",
-	})
-	eventsStored = promauto.NewCounter(prometheus.CounterOpts{
-		Name: "regulatory_events_stored_total",
-		Help: "Total number of events stored in database",
-	})
-	errors = promauto.NewCounterVec(
-		prometheus.CounterOpts{
-			Name: "event_consumer_errors_total",
-			Help: "Total number of consumer errors",
-		},
-		[]string{"error_type"},
-	)
-)
-
 func main() {
-	log.Println("Starting EventID Event Consumer (Audit Trail)...")
+	// Route all logging, including the standard log package, through JSON
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+	slog.SetDefault(logger)
 
-	// Load configuration
-	config := loadConfig()
+	// The first argument names the command unless it is a flag
+	name, args := "consume", os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	}
 
-	// Initialize event store
-	storeCfg := storage.Config{
-		Host:     config.DBHost,
-		Port:     config.DBPort,
-		User:     config.DBUser,
-		Password: config.DBPassword,
-		Database: config.DBName,
-		SSLMode:  config.DBSSLMode,
+	cmd, ok := findCommand(name)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n", name)
+		usage()
+		os.Exit(2)
 	}
+	cmd.run(logger, args)
+}
 
-	store, err := storage.NewEventStore(storeCfg)
-	if err != nil {
-		log.Fatalf("Failed to create event store: %v", err)
+// metrics holds the binary's own metrics and those of the consumer and
+// storage packages, all registered with the registry served on /metrics
+type metrics struct {
+	registry *prometheus.Registry
+	consumer *consumer.Metrics
+	storage  *storage.Metrics
+
+	eventsConsumed     *prometheus.CounterVec
+	eventsStored       *prometheus.CounterVec
+	schemaIncompatible *prometheus.GaugeVec
+}
+
+// newMetrics creates a registry with the Go runtime and process collectors
+// and registers every metric with it, with names prefixed by
+// MetricsNamespace and MetricsSubsystem
+func newMetrics(config Config) *metrics {
+	namespace, subsystem := config.MetricsNamespace, config.MetricsSubsystem
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(collectors.NewGoCollector(), collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}))
+
+	f := promauto.With(registry)
+	return &metrics{
+		registry: registry,
+		consumer: consumer.NewMetrics(registry, namespace, subsystem),
+		storage:  storage.NewMetrics(registry, namespace, subsystem),
+
+		eventsConsumed: f.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "regulatory_events_consumed_total",
+				Help:      "Total number of events consumed from Kafka, by event type and tenant",
+			},
+			[]string{"event_type", "tenant"},
+		),
+		eventsStored: f.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "regulatory_events_stored_total",
+				Help:      "Total number of events stored in database, by event type",
+			},
+			[]string{"event_type"},
+		),
+		schemaIncompatible: f.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "regulatory_events_schema_incompatible",
+				Help:      "Whether an event type's schema was incompatible with its Schema Registry subject at startup",
+			},
+			[]string{"event_type", "subject"},
+		),
 	}
+}
+
+// runConsume consumes events and stores them until interrupted
+func runConsume(logger *slog.Logger, args []string) {
+	parseFlags("consume", args)
+	log.Println("Starting EventID Event Consumer (Audit Trail)...")
+
+	config := mustLoadConfig()
+	metrics := newMetrics(config)
+	store := mustOpenStore(config, logger, metrics.storage)
+	store = withSinks(config, withWriteGuards(config, store, logger, metrics.storage), logger, metrics.storage)
 	defer store.Close()
 
+	if config.SkipMigrations {
+		log.Println("Skipping database migrations")
+	} else if err := store.Migrate(context.Background()); err != nil {
+		log.Fatalf("Failed to migrate database: %v", err)
+	}
+	if pgStore, ok := postgresStore(store); ok {
+		go maintainPartitions(pgStore)
+	}
+	if len(config.Retention) > 0 {
+		go pruneEvents(store, config.Retention, config.PruneInterval)
+	}
+
+	// Register JSON schemas used to validate events before storage
+	if config.SchemaDir != "" {
+		if err := registerSchemas(config.SchemaDir); err != nil {
+			log.Fatalf("Failed to register event schemas: %v", err)
+		}
+	}
+	checkSchemaCompatibility(config, logger, metrics.schemaIncompatible)
+	schema.SetPayloadLimits(schema.PayloadLimits{
+		MaxDepth:        config.PayloadMaxDepth,
+		MaxFields:       config.PayloadMaxFields,
+		MaxStringLength: config.PayloadMaxStringLength,
+	})
+
 	// Initialize Kafka consumer
-	consumerCfg := consumer.Config{
-		BootstrapServers: config.KafkaBrokers,
-		GroupID:          "eventid-consumer-audit",
-		Topics:           []string{config.KafkaTopic},
-		AutoOffsetReset:  "earliest", // Process all events from beginning
+	consumerCfg := consumerConfig(config, logger)
+	consumerCfg.Metrics = metrics.consumer
+
+	// Debugging: read the listed partitions and offsets instead of joining
+	// the group. Validate has already checked the list parses.
+	consumerCfg.AssignPartitions, _ = consumer.ParsePartitionOffsets(config.KafkaAssignPartitions)
+	if len(consumerCfg.AssignPartitions) > 0 {
+		log.Printf("Reading assigned partitions %s; offsets will not be committed\n", config.KafkaAssignPartitions)
+	} else {
+		// Fail fast on a fresh environment where the topic is missing
+		consumerCfg.Topics = []string{config.KafkaTopic}
+		consumerCfg.VerifyTopics = true
+		consumerCfg.AutoCreateTopics = config.AutoCreateTopics
+		consumerCfg.TopicPartitions = config.KafkaTopicPartitions
+		consumerCfg.TopicReplicationFactor = config.KafkaTopicReplication
 	}
 
 	eventConsumer, err := consumer.NewEventConsumer(consumerCfg)
@@ -69,52 +160,123 @@ func main() {
 		log.Fatalf("Failed to create consumer: %v", err)
 	}
 	defer eventConsumer.Close()
+	if pgStore, ok := postgresStore(store); ok && config.OffsetSnapshotInterval > 0 && len(consumerCfg.AssignPartitions) == 0 {
+		go snapshotOffsets(eventConsumer, pgStore, groupID(config), config.OffsetSnapshotInterval)
+	}
 
 	// Register event handler (stores all events to database)
-	eventHandler := func(event interface{}) error {
-		eventsConsumed.Inc()
+	eventConsumer.Use(metrics.consumer.Timing(), countConsumed(metrics.eventsConsumed))
+	outage := &outageGuard{consumer: eventConsumer, store: store}
 
-		if err := store.StoreEvent(event); err != nil {
-			errors.WithLabelValues("storage").Inc()
-			return fmt.Errorf("failed to store event: %w", err)
+	// Keep the current state of each entity for the types listed in
+	// latest_event_types. Unkeyed events have no entity and are skipped.
+	latestTypes := make(map[schema.EventType]bool, len(config.LatestEventTypes))
+	for _, eventType := range eventTypes(config.LatestEventTypes) {
+		latestTypes[eventType] = true
+	}
+	storeLatest := func(ctx context.Context, event *schema.Event) error {
+		if !latestTypes[event.Type] || event.EntityID == "" {
+			return nil
+		}
+		if err := store.StoreLatest(ctx, event); err != nil {
+			outage.check(err)
+			metrics.consumer.Errors.WithLabelValues("storage").Inc()
+			return fmt.Errorf("failed to store latest event: %w", storageError(err))
 		}
-
-		eventsStored.Inc()
 		return nil
 	}
 
-	// Register handler for all event types
-	eventTypes := []schema.EventType{
-		schema.EventRegulatoryUpdate,
-		schema.EventLawFetched,
-		schema.EventSpecGenerated,
-		schema.EventSpecUpdated,
-		schema.EventSpecRequested,
-		schema.EventAuditStarted,
-		schema.EventAuditCompleted,
-		schema.EventViolationFound,
-		schema.EventScanRequested,
-		schema.EventDocumentUploaded,
-		schema.EventComplianceCheck,
-		schema.EventGapIdentified,
-		schema.EventReviewRequested,
-		schema.EventWorkflowStarted,
-		schema.EventWorkflowComplete,
-		schema.EventValidationStatus,
+	eventHandler := func(ctx context.Context, event *schema.Event) error {
+		err := store.StoreEvent(ctx, event)
+		if errors.Is(err, storage.ErrDuplicateEvent) {
+			// Already stored by an earlier delivery, which may have failed
+			// before updating the latest state
+			return storeLatest(ctx, event)
+		}
+		if err != nil {
+			outage.check(err)
+			metrics.consumer.Errors.WithLabelValues("storage").Inc()
+			return fmt.Errorf("failed to store event: %w", storageError(err))
+		}
+
+		if !config.DryRun {
+			metrics.eventsStored.WithLabelValues(string(event.Type)).Inc()
+		}
+		return storeLatest(ctx, event)
+	}
+
+	// Store every event type, including ones added to the schema later
+	eventConsumer.RegisterDefaultHandler(eventHandler)
+
+	// Keep undecodable messages for investigation instead of dropping them
+	eventConsumer.SetDecodeErrorHandler(func(ctx context.Context, msg *kafka.Message, err error) error {
+		var topic string
+		if msg.TopicPartition.Topic != nil {
+			topic = *msg.TopicPartition.Topic
+		}
+		return store.StoreRawQuarantine(ctx, topic, msg.TopicPartition.Partition,
+			int64(msg.TopicPartition.Offset), msg.Value, err)
+	})
+
+	// Record every message as received before anything can reject it
+	if config.RawLog {
+		eventConsumer.SetRawLog(func(ctx context.Context, msg *kafka.Message) error {
+			return store.AppendRaw(ctx, rawMessage(msg))
+		})
 	}
 
-	for _, eventType := range eventTypes {
-		eventConsumer.RegisterHandler(eventType, eventHandler)
+	// In batch mode, events are stored with one multi-row INSERT per batch
+	if config.BatchSize > 0 {
+		eventConsumer.RegisterBatchHandler(func(ctx context.Context, events []*schema.Event) error {
+			for _, event := range events {
+				metrics.eventsConsumed.WithLabelValues(string(event.Type), event.TenantID).Inc()
+			}
+
+			err := store.StoreEventBatch(ctx, events)
+			var batchErr *storage.BatchError
+			var failures map[int]error
+			switch {
+			case errors.As(err, &batchErr):
+				metrics.consumer.Errors.WithLabelValues("storage").Add(float64(len(batchErr.Failures)))
+				failures = batchErr.BatchFailures()
+			case err != nil:
+				outage.check(err)
+				metrics.consumer.Errors.WithLabelValues("storage").Inc()
+				return fmt.Errorf("failed to store event batch: %w", storageError(err))
+			}
+
+			if !config.DryRun {
+				countByType(metrics.eventsStored, events, failures)
+			}
+			// Stored events are skipped as duplicates if the batch is
+			// retried, so a failed update is retried with them
+			for idx, event := range events {
+				if _, failed := failures[idx]; failed {
+					continue
+				}
+				if latestErr := storeLatest(ctx, event); latestErr != nil {
+					return latestErr
+				}
+			}
+			return err
+		})
 	}
 
 	// Start metrics server
 	go func() {
-		http.Handle("/metrics", promhttp.Handler())
-		http.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusOK)
-			w.Write([]byte(`{"status":"healthy"}`))
-		})
+		http.Handle("/metrics", promhttp.HandlerFor(metrics.registry, promhttp.HandlerOpts{}))
+		storeCheck := func(ctx context.Context) error { return store.Ping(ctx) }
+		http.HandleFunc("/health", healthHandler(map[string]func(context.Context) error{
+			"kafka":    func(context.Context) error { return eventConsumer.Healthy() },
+			"database": storeCheck,
+		}))
+		// Readiness also requires a partition assignment from the group
+		http.HandleFunc("/ready", healthHandler(map[string]func(context.Context) error{
+			"kafka":    func(context.Context) error { return eventConsumer.Ready() },
+			"database": storeCheck,
+		}))
+		http.HandleFunc("/stats", statsHandler(store))
+		http.HandleFunc("/handlers", handlersHandler(eventConsumer))
 
 		log.Printf("Metrics server listening on :%s\n", config.MetricsPort)
 		if err := http.ListenAndServe(":"+config.MetricsPort, nil); err != nil {
@@ -122,15 +284,53 @@ func main() {
 		}
 	}()
 
-	// Handle shutdown gracefully
-	sigCh := make(chan os.Signal, 1)
+	// Start operator server: endpoints that change consumption or export
+	// event data are kept off the unauthenticated metrics port
+	if config.AdminToken == "" {
+		log.Println("Operator endpoints disabled; set admin_token to serve them")
+	} else {
+		go func() {
+			admin := http.NewServeMux()
+			// Pausing stops consumption, e.g. during database maintenance,
+			// while keeping the group assignment
+			admin.HandleFunc("/pause", pauseHandler(eventConsumer.Pause, eventConsumer.Paused))
+			admin.HandleFunc("/resume", pauseHandler(eventConsumer.Resume, eventConsumer.Paused))
+			// Reprocess (or skip) one partition without touching the others
+			admin.HandleFunc("/seek", seekHandler(eventConsumer.SeekPartition))
+			admin.HandleFunc("/events/export", exportHandler(store))
+
+			log.Printf("Operator server listening on :%s\n", config.AdminPort)
+			if err := http.ListenAndServe(":"+config.AdminPort, requireToken(config.AdminToken, admin)); err != nil {
+				log.Printf("Operator server error: %v\n", err)
+			}
+		}()
+	}
+
+	// Handle shutdown gracefully: the first signal drains within
+	// ShutdownTimeout, a second one exits at once
+	sigCh := make(chan os.Signal, 2)
 	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	shutdownDone := make(chan struct{})
 
 	go func() {
-		<-sigCh
-		log.Println("Shutting down event consumer...")
-		eventConsumer.Close()
-		os.Exit(0)
+		defer close(shutdownDone)
+		sig := <-sigCh
+		logger.Info("Shutting down event consumer", "signal", sig.String(), "timeout", config.ShutdownTimeout.String())
+		go func() {
+			sig := <-sigCh
+			logger.Error("Received second signal, exiting without draining", "signal", sig.String())
+			os.Exit(1)
+		}()
+
+		ctx, cancel := context.WithTimeout(context.Background(), config.ShutdownTimeout)
+		defer cancel()
+		if err := eventConsumer.Shutdown(ctx); err != nil {
+			// Start may never return while a handler is stuck; exit so the
+			// orchestrator sees the failed drain instead of a SIGKILL later
+			logger.Error("Shutdown incomplete, exiting", "error", err)
+			store.Close()
+			os.Exit(1)
+		}
 	}()
 
 	// Start consuming events
@@ -138,47 +338,307 @@ func main() {
 	if err := eventConsumer.Start(); err != nil {
 		log.Fatalf("Consumer failed: %v", err)
 	}
+
+	<-shutdownDone
+	log.Println("Event consumer stopped")
+}
+
+// postgresStore returns the PostgresStore behind any spill buffer or circuit
+// breaker wrapping store
+func postgresStore(store storage.EventStore) (*storage.PostgresStore, bool) {
+	for {
+		switch s := store.(type) {
+		case *storage.PostgresStore:
+			return s, true
+		case *storage.SpillStore:
+			store = s.EventStore
+		case *storage.BreakerStore:
+			store = s.EventStore
+		case *storage.RetryStore:
+			store = s.EventStore
+		case *storage.FanoutStore:
+			store = s.EventStore
+		default:
+			return nil, false
+		}
+	}
+}
+
+// rawMessage copies msg as received for the raw log
+func rawMessage(msg *kafka.Message) storage.RawMessage {
+	raw := storage.RawMessage{
+		Partition: msg.TopicPartition.Partition,
+		Offset:    int64(msg.TopicPartition.Offset),
+		Key:       msg.Key,
+		Value:     msg.Value,
+	}
+	if msg.TopicPartition.Topic != nil {
+		raw.Topic = *msg.TopicPartition.Topic
+	}
+	if msg.TimestampType != kafka.TimestampNotAvailable {
+		raw.Timestamp = msg.Timestamp
+	}
+	for _, header := range msg.Headers {
+		raw.Headers = append(raw.Headers, storage.RawHeader{Key: header.Key, Value: header.Value})
+	}
+	return raw
 }
 
-type Config struct {
-	KafkaBrokers string
-	KafkaTopic   string
-	DBHost       string
-	DBPort       int
-	DBUser       string
-	DBPassword   string
-	DBName       string
-	DBSSLMode    string
-	MetricsPort  string
+// groupID returns the consumer group. Dry runs and validation-only shadow
+// consumers use their own groups so they never move the committed offsets
+// of the real consumer.
+func groupID(config Config) string {
+	switch {
+	case config.ValidateOnly:
+		return config.KafkaGroupID + "-shadow"
+	case config.DryRun:
+		return config.KafkaGroupID + "-dryrun"
+	}
+	return config.KafkaGroupID
 }
 
-func loadConfig() Config {
-	return Config{
-		KafkaBrokers: getEnv("KAFKA_BROKERS", "localhost:9092"),
-		KafkaTopic:   getEnv("KAFKA_TOPIC", "regulatory-events"),
-		DBHost:       getEnv("DB_HOST", "localhost"),
-		DBPort:       getEnvInt("DB_PORT", 5432),
-		DBUser:       getEnv("DB_USER", "eventid"),
-		DBPassword:   getEnv("DB_PASSWORD", "password"),
-		DBName:       getEnv("DB_NAME", "eventid_events"),
-		DBSSLMode:    getEnv("DB_SSLMODE", "disable"),
-		MetricsPort:  getEnv("METRICS_PORT", "9090"),
+// deadLetterTopic returns the dead-letter topic. Shadow consumers never
+// publish to it, as they must not affect what the real consumer handles.
+func deadLetterTopic(config Config) string {
+	if config.ValidateOnly {
+		return ""
 	}
+	return config.DeadLetterTopic
 }
 
-func getEnv(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
-		return value
+// groupInstanceID returns the static membership ID. Dry runs and shadow
+// consumers never use one, so they cannot fence out the real consumer if
+// they share its settings.
+func groupInstanceID(config Config) string {
+	if config.DryRun || config.ValidateOnly {
+		return ""
 	}
-	return defaultValue
+	return config.KafkaGroupInstanceID
 }
 
-func getEnvInt(key string, defaultValue int) int {
-	if value := os.Getenv(key); value != "" {
-		var intVal int
-		if _, err := fmt.Sscanf(value, "%d", &intVal); err == nil {
-			return intVal
+// eventTypes converts configured event type names
+func eventTypes(names []string) []schema.EventType {
+	types := make([]schema.EventType, len(names))
+	for i, name := range names {
+		types[i] = schema.EventType(name)
+	}
+	return types
+}
+
+// storageError marks err as permanent for the consumer's retry policy unless
+// storage classifies it as transient, so that events the database rejects
+// are dead-lettered without waiting out the handler retries
+func storageError(err error) error {
+	if storage.IsTransient(err) {
+		return err
+	}
+	return consumer.Permanent(err)
+}
+
+// countConsumed returns middleware counting each event handed to a handler
+// in counter
+func countConsumed(counter *prometheus.CounterVec) consumer.Middleware {
+	return func(next consumer.EventHandler) consumer.EventHandler {
+		return func(ctx context.Context, event *schema.Event) error {
+			counter.WithLabelValues(string(event.Type), event.TenantID).Inc()
+			return next(ctx, event)
+		}
+	}
+}
+
+// countByType increments counter by event_type for each event, skipping the
+// indexes in skip
+func countByType(counter *prometheus.CounterVec, events []*schema.Event, skip map[int]error) {
+	for i, event := range events {
+		if _, failed := skip[i]; !failed {
+			counter.WithLabelValues(string(event.Type)).Inc()
+		}
+	}
+}
+
+// partitionCheckInterval is how often upcoming events partitions are created
+const partitionCheckInterval = 24 * time.Hour
+
+// maintainPartitions keeps the current and next month's events partitions in
+// place. It returns immediately if the events table is not partitioned.
+func maintainPartitions(store *storage.PostgresStore) {
+	ticker := time.NewTicker(partitionCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		now := time.Now()
+		for _, month := range []time.Time{now, now.AddDate(0, 1, -now.Day()+1)} {
+			err := store.EnsurePartition(context.Background(), month)
+			if errors.Is(err, storage.ErrNotPartitioned) {
+				log.Println("Events table is not partitioned; skipping partition maintenance")
+				return
+			}
+			if err != nil {
+				log.Printf("Failed to ensure events partition: %v\n", err)
+			}
+		}
+		<-ticker.C
+	}
+}
+
+// pruneEvents deletes events past their retention age every interval
+func pruneEvents(store storage.EventStore, retention map[string]time.Duration, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		for eventType, age := range retention {
+			if _, err := store.PruneOlderThan(context.Background(), schema.EventType(eventType), age); err != nil {
+				log.Printf("Failed to prune %s events: %v\n", eventType, err)
+			}
+		}
+		<-ticker.C
+	}
+}
+
+// healthCheckTimeout bounds each dependency check made by a health probe
+const healthCheckTimeout = 2 * time.Second
+
+// healthResponse is the JSON body returned by /health and /ready
+type healthResponse struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks"`
+}
+
+// healthHandler runs each named dependency check and responds 200 when all
+// pass, or 503 naming the failing dependencies
+func healthHandler(checks map[string]func(context.Context) error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), healthCheckTimeout)
+		defer cancel()
+
+		resp := healthResponse{Status: "healthy", Checks: make(map[string]string, len(checks))}
+		status := http.StatusOK
+		for name, check := range checks {
+			if err := check(ctx); err != nil {
+				resp.Checks[name] = err.Error()
+				resp.Status = "unhealthy"
+				status = http.StatusServiceUnavailable
+				continue
+			}
+			resp.Checks[name] = "ok"
 		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(resp)
 	}
-	return defaultValue
-}
\ No newline at end of file
+}
+
+// handlersResponse is the JSON body returned by /handlers
+type handlersResponse struct {
+	Handlers       []consumer.HandlerInfo `json:"handlers"`
+	DefaultHandler bool                   `json:"default_handler"`
+	BatchHandler   bool                   `json:"batch_handler"`
+}
+
+// handlersHandler lists the event types the consumer has handlers for
+func handlersHandler(c *consumer.EventConsumer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		resp := handlersResponse{
+			Handlers:       c.RegisteredTypes(),
+			DefaultHandler: c.HasDefaultHandler(),
+			BatchHandler:   c.HasBatchHandler(),
+		}
+		if resp.Handlers == nil {
+			resp.Handlers = []consumer.HandlerInfo{}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}
+}
+
+// pauseResponse is the JSON body returned by /pause and /resume
+type pauseResponse struct {
+	Paused bool   `json:"paused"`
+	Error  string `json:"error,omitempty"`
+}
+
+// pauseHandler calls action on POST and responds with the resulting paused
+// state
+func pauseHandler(action func() error, paused func() bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		status := http.StatusOK
+		var resp pauseResponse
+		if err := action(); err != nil {
+			log.Printf("Failed to %s consumer: %v\n", strings.TrimPrefix(r.URL.Path, "/"), err)
+			resp.Error = err.Error()
+			status = http.StatusInternalServerError
+		}
+		resp.Paused = paused()
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(resp)
+	}
+}
+
+// registerSchemas registers every <event_type>.json file in dir as the JSON
+// schema for that event type, e.g. scan.violation_found.json
+func registerSchemas(dir string) error {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return err
+	}
+
+	for _, path := range paths {
+		schemaJSON, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		eventType := schema.EventType(strings.TrimSuffix(filepath.Base(path), ".json"))
+		if err := schema.RegisterSchema(eventType, schemaJSON); err != nil {
+			return err
+		}
+		log.Printf("Registered schema for %s\n", eventType)
+	}
+	return nil
+}
+
+// checkSchemaCompatibility compares the schema in SchemaDir for each event
+// type in SchemaRegistrySubjects with its subject's latest version, warning
+// about any that are incompatible. Producers and this consumer drifting
+// apart would otherwise only show up as validation failures. Failing to
+// make the check is logged but does not stop startup. The result for each
+// type is set in incompatible.
+func checkSchemaCompatibility(config Config, logger *slog.Logger, incompatible *prometheus.GaugeVec) {
+	registry := schema.RegistryConfig{
+		URL:      config.SchemaRegistryURL,
+		Username: config.SchemaRegistryUsername,
+		Password: config.SchemaRegistryPassword,
+	}
+	for eventType, subject := range config.SchemaRegistrySubjects {
+		schemaJSON, err := os.ReadFile(filepath.Join(config.SchemaDir, eventType+".json"))
+		if err != nil {
+			logger.Warn("Failed to read schema for compatibility check", "event_type", eventType, "error", err)
+			continue
+		}
+
+		result, err := schema.CheckCompatibility(registry, subject, schemaJSON)
+		if err != nil {
+			logger.Warn("Failed to check schema compatibility", "event_type", eventType, "subject", subject, "error", err)
+			continue
+		}
+		if result.Compatible {
+			incompatible.WithLabelValues(eventType, subject).Set(0)
+			logger.Info("Schema is compatible with registry", "event_type", eventType, "subject", subject, "version", result.Version)
+			continue
+		}
+		incompatible.WithLabelValues(eventType, subject).Set(1)
+		logger.Warn("Schema is incompatible with registry", "event_type", eventType, "subject", subject,
+			"version", result.Version, "reason", result.Reason)
+	}
+}
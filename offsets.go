@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"log/slog"
+	"time"
+
+	"github.com/assure-compliance/eventid/pkg/consumer"
+	"github.com/assure-compliance/eventid/pkg/storage"
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+)
+
+// snapshotTimeout bounds saving each offset snapshot
+const snapshotTimeout = 30 * time.Second
+
+// snapshotOffsets saves the group's committed offsets to the database every
+// interval, so that restore-offsets can bring the group back to one of them
+func snapshotOffsets(c *consumer.EventConsumer, store *storage.PostgresStore, group string, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		offsets, err := c.ExportOffsets()
+		if err != nil {
+			log.Printf("Failed to export committed offsets: %v\n", err)
+			continue
+		}
+		if len(offsets) == 0 {
+			continue
+		}
+		stored := make([]storage.StoredOffset, len(offsets))
+		for i, p := range offsets {
+			stored[i] = storage.StoredOffset{Topic: p.Topic, Partition: p.Partition, Offset: int64(p.Offset)}
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), snapshotTimeout)
+		if _, err := store.SaveOffsets(ctx, group, stored); err != nil {
+			log.Printf("Failed to save offset snapshot: %v\n", err)
+		}
+		cancel()
+	}
+}
+
+// runRestoreOffsets sets the group's committed offsets to a snapshot saved
+// by OFFSET_SNAPSHOT_INTERVAL. Every consumer in the group must be stopped
+// first, as Kafka rejects the change while the group has members.
+func runRestoreOffsets(logger *slog.Logger, args []string) {
+	flags := flag.NewFlagSet("restore-offsets", flag.ExitOnError)
+	at := flags.String("at", "", "restore the latest snapshot taken at or before this RFC 3339 time (default the latest)")
+	group := flags.String("group", "", "consumer group to restore (default KAFKA_GROUP_ID)")
+	flags.Parse(args)
+	before, err := parseFlagTime("at", *at)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	config := mustLoadConfig()
+	if *group == "" {
+		*group = config.KafkaGroupID
+	}
+	store := mustOpenStore(config, logger, nil)
+	defer store.Close()
+	pgStore, ok := postgresStore(store)
+	if !ok {
+		log.Fatal("restore-offsets: offset snapshots are only kept in the postgres backend")
+	}
+
+	snapshot, err := pgStore.LoadOffsets(context.Background(), *group, before)
+	if err != nil {
+		log.Fatalf("Failed to load offset snapshot: %v", err)
+	}
+	offsets := make([]consumer.PartitionOffset, len(snapshot.Offsets))
+	for i, o := range snapshot.Offsets {
+		offsets[i] = consumer.PartitionOffset{Topic: o.Topic, Partition: o.Partition, Offset: kafka.Offset(o.Offset)}
+	}
+
+	// Reading the snapshot's partitions directly keeps this consumer out of
+	// the group, which must have no members for the offsets to be changed
+	consumerCfg := consumerConfig(config, logger)
+	consumerCfg.GroupID = *group
+	consumerCfg.GroupInstanceID = ""
+	consumerCfg.StartFromTimestamp = time.Time{}
+	consumerCfg.SkipTo = nil
+	consumerCfg.AssignPartitions = offsets
+	eventConsumer, err := consumer.NewEventConsumer(consumerCfg)
+	if err != nil {
+		log.Fatalf("Failed to create consumer: %v", err)
+	}
+	defer eventConsumer.Close()
+
+	if err := eventConsumer.RestoreOffsets(offsets); err != nil {
+		log.Fatalf("Failed to restore offsets: %v", err)
+	}
+	log.Printf("Restored %d offsets of group %s from the snapshot taken at %s\n",
+		len(offsets), *group, snapshot.TakenAt.Format(time.RFC3339))
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync/atomic"
+	"time"
+
+	"github.com/assure-compliance/eventid/pkg/consumer"
+	"github.com/assure-compliance/eventid/pkg/storage"
+)
+
+// Backoff between database pings while consumption is paused for an outage
+const (
+	outageInitialBackoff = time.Second
+	outageMaxBackoff     = 30 * time.Second
+)
+
+// outagePingTimeout bounds each ping made while paused for an outage
+const outagePingTimeout = 5 * time.Second
+
+// outageGuard pauses consumption when the database becomes unreachable and
+// resumes it once the database answers again, so that events wait in Kafka
+// instead of failing one after another
+type outageGuard struct {
+	consumer *consumer.EventConsumer
+	store    storage.EventStore
+	active   atomic.Bool // Set while the guard has the consumer paused
+}
+
+// check pauses the consumer if err means the database is unreachable. A
+// consumer already paused, e.g. through /pause, is left alone.
+func (g *outageGuard) check(err error) {
+	if !errors.Is(err, storage.ErrConnClosed) || g.consumer.Paused() || !g.active.CompareAndSwap(false, true) {
+		return
+	}
+	if err := g.consumer.Pause(); err != nil {
+		log.Printf("Failed to pause consumer during database outage: %v\n", err)
+		g.active.Store(false)
+		return
+	}
+	log.Println("Database unreachable; paused consumption until it recovers")
+	go g.resumeWhenAvailable()
+}
+
+// resumeWhenAvailable pings the store with backoff and resumes the consumer
+// once it answers
+func (g *outageGuard) resumeWhenAvailable() {
+	defer g.active.Store(false)
+
+	backoff := outageInitialBackoff
+	for {
+		time.Sleep(backoff)
+
+		ctx, cancel := context.WithTimeout(context.Background(), outagePingTimeout)
+		err := g.store.Ping(ctx)
+		cancel()
+		if err == nil {
+			break
+		}
+		if backoff *= 2; backoff > outageMaxBackoff {
+			backoff = outageMaxBackoff
+		}
+	}
+
+	if err := g.consumer.Resume(); err != nil {
+		log.Printf("Failed to resume consumer after database outage: %v\n", err)
+		return
+	}
+	log.Println("Database reachable again; resumed consumption")
+}
@@ -0,0 +1,80 @@
+package consumer
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+)
+
+// PartitionOffset is a partition to read from and the offset to start at.
+// Offset may be kafka.OffsetBeginning or kafka.OffsetEnd.
+type PartitionOffset struct {
+	Topic     string
+	Partition int32
+	Offset    kafka.Offset
+}
+
+func (p PartitionOffset) String() string {
+	return fmt.Sprintf("%s:%d:%s", p.Topic, p.Partition, p.Offset)
+}
+
+// ParsePartitionOffsets parses a comma-separated list of
+// topic:partition:offset entries, e.g. "regulatory-events:3:1042". The
+// offset may also be "earliest" or "latest".
+func ParsePartitionOffsets(s string) ([]PartitionOffset, error) {
+	var assignments []PartitionOffset
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		fields := strings.Split(entry, ":")
+		if len(fields) != 3 || fields[0] == "" {
+			return nil, fmt.Errorf("invalid partition offset %q: want topic:partition:offset", entry)
+		}
+		partition, err := strconv.ParseInt(fields[1], 10, 32)
+		if err != nil || partition < 0 {
+			return nil, fmt.Errorf("invalid partition in %q", entry)
+		}
+
+		var offset kafka.Offset
+		switch fields[2] {
+		case "earliest":
+			offset = kafka.OffsetBeginning
+		case "latest":
+			offset = kafka.OffsetEnd
+		default:
+			n, err := strconv.ParseInt(fields[2], 10, 64)
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("invalid offset in %q", entry)
+			}
+			offset = kafka.Offset(n)
+		}
+
+		assignments = append(assignments, PartitionOffset{
+			Topic:     fields[0],
+			Partition: int32(partition),
+			Offset:    offset,
+		})
+	}
+	return assignments, nil
+}
+
+// assignPartitions manually assigns the configured partitions in place of a
+// group subscription
+func (c *EventConsumer) assignPartitions(assignments []PartitionOffset) error {
+	partitions := make([]kafka.TopicPartition, len(assignments))
+	for i, a := range assignments {
+		partitions[i] = partitionKey{topic: a.Topic, partition: a.Partition}.at(a.Offset)
+	}
+	if err := c.consumer.Assign(partitions); err != nil {
+		return fmt.Errorf("failed to assign partitions: %w", err)
+	}
+
+	c.joined.Store(true)
+	c.logger.Info("Partitions assigned manually", "partitions", fmt.Sprint(assignments))
+	return nil
+}
@@ -0,0 +1,62 @@
+package consumer
+
+import "errors"
+
+// newBackpressure validates the BackpressureHighWater and
+// BackpressureLowWater settings and returns the marks to use, with the low
+// mark defaulting to half the high one
+func newBackpressure(cfg Config) (high, low int, err error) {
+	high, low = cfg.BackpressureHighWater, cfg.BackpressureLowWater
+	if high < 0 || low < 0 {
+		return 0, 0, errors.New("backpressure marks must not be negative")
+	}
+	if high == 0 {
+		if low > 0 {
+			return 0, 0, errors.New("BackpressureLowWater requires BackpressureHighWater")
+		}
+		return 0, 0, nil
+	}
+	if low == 0 {
+		low = high / 2
+	}
+	if low >= high {
+		return 0, 0, errors.New("BackpressureLowWater must be less than BackpressureHighWater")
+	}
+	return high, low, nil
+}
+
+// buffered returns the number of messages read but not yet handled: those
+// queued on or running in workers, plus the events of the pending batch
+func (c *EventConsumer) buffered() int {
+	return int(c.inFlight.Load()) + len(c.batch.events)
+}
+
+// checkBackpressure pauses fetching once BackpressureHighWater messages are
+// buffered and resumes it at BackpressureLowWater or below. It runs on the
+// Start goroutine.
+func (c *EventConsumer) checkBackpressure() {
+	n := c.buffered()
+	c.metrics.bufferedMessages.Set(float64(n))
+	if c.highWater == 0 {
+		return
+	}
+
+	switch {
+	case n >= c.highWater:
+		if changed, err := c.hold(holdBackpressure); err != nil {
+			c.logger.Error("Failed to pause for backpressure", "buffered", n, "error", err)
+		} else if changed {
+			c.metrics.backpressurePaused.Set(1)
+			c.logger.Warn("Buffered messages reached high-water mark, pausing fetch",
+				"buffered", n, "high_water", c.highWater)
+		}
+	case n <= c.lowWater:
+		if changed, err := c.release(holdBackpressure); err != nil {
+			c.logger.Error("Failed to resume after backpressure", "buffered", n, "error", err)
+		} else if changed {
+			c.metrics.backpressurePaused.Set(0)
+			c.logger.Info("Buffered messages fell to low-water mark, resuming fetch",
+				"buffered", n, "low_water", c.lowWater)
+		}
+	}
+}
@@ -0,0 +1,390 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/assure-compliance/eventid/pkg/schema"
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// DefaultBatchTimeout is how long a partial batch may wait before flushing
+const DefaultBatchTimeout = time.Second
+
+// Reasons a batch is flushed, reported by event_consumer_batch_flushes_total
+const (
+	flushSize      = "size"
+	flushTimeout   = "timeout"
+	flushShutdown  = "shutdown"
+	flushRebalance = "rebalance"
+	flushSeek      = "seek"
+)
+
+// pollTimeout bounds each readMessage so that BatchTimeout and Shutdown are
+// honoured even when no new messages arrive
+const pollTimeout = 100 * time.Millisecond
+
+// BatchHandler is called with a batch of consumed events. ctx is cancelled
+// when the consumer is closed.
+type BatchHandler func(ctx context.Context, events []*schema.Event) error
+
+// PartialBatchError is implemented by batch handler errors that identify
+// which events in the batch failed. Events not listed are treated as handled
+// and the handler is not retried. Failed events are dead-lettered; without a
+// dead-letter handler the whole batch is redelivered, so its stored events
+// are handled again.
+type PartialBatchError interface {
+	error
+	BatchFailures() map[int]error
+}
+
+// pendingBatch accumulates decoded events and the offsets they cover
+type pendingBatch struct {
+	events   []*schema.Event
+	messages []*kafka.Message // Aligned with events
+	started  time.Time
+
+	// first and next offsets seen per partition, including messages that
+	// could not be decoded
+	first map[partitionKey]kafka.Offset
+	next  map[partitionKey]kafka.Offset
+}
+
+func newPendingBatch() *pendingBatch {
+	return &pendingBatch{
+		first: make(map[partitionKey]kafka.Offset),
+		next:  make(map[partitionKey]kafka.Offset),
+	}
+}
+
+// track records that msg is covered by this batch
+func (b *pendingBatch) track(msg *kafka.Message) {
+	if b.started.IsZero() {
+		b.started = time.Now()
+	}
+
+	key := keyOf(msg.TopicPartition)
+	if _, ok := b.first[key]; !ok {
+		b.first[key] = msg.TopicPartition.Offset
+	}
+	b.next[key] = msg.TopicPartition.Offset + 1
+}
+
+// add appends a decoded event to the batch
+func (b *pendingBatch) add(msg *kafka.Message, event *schema.Event) {
+	b.track(msg)
+	b.events = append(b.events, event)
+	b.messages = append(b.messages, msg)
+}
+
+func (b *pendingBatch) empty() bool {
+	return len(b.first) == 0
+}
+
+// commitOffsets returns the offsets to commit once the batch is handled
+func (b *pendingBatch) commitOffsets() []kafka.TopicPartition {
+	offsets := make([]kafka.TopicPartition, 0, len(b.next))
+	for key, offset := range b.next {
+		offsets = append(offsets, key.at(offset))
+	}
+	return offsets
+}
+
+// partitionKey identifies a topic partition by value for use as a map key
+type partitionKey struct {
+	topic     string
+	partition int32
+}
+
+func keyOf(tp kafka.TopicPartition) partitionKey {
+	key := partitionKey{partition: tp.Partition}
+	if tp.Topic != nil {
+		key.topic = *tp.Topic
+	}
+	return key
+}
+
+// at returns the TopicPartition for key positioned at offset
+func (k partitionKey) at(offset kafka.Offset) kafka.TopicPartition {
+	topic := k.topic
+	return kafka.TopicPartition{Topic: &topic, Partition: k.partition, Offset: offset}
+}
+
+// RegisterBatchHandler switches the consumer to batch mode. Decoded events
+// are accumulated and passed to handler once BatchSize events are pending or
+// BatchTimeout has elapsed, instead of being dispatched to per-type handlers.
+// Offsets are committed only after the handler succeeds.
+func (c *EventConsumer) RegisterBatchHandler(handler BatchHandler) {
+	c.handlersMu.Lock()
+	defer c.handlersMu.Unlock()
+	c.batchHandler = handler
+}
+
+// addToBatch decodes msg into the pending batch and flushes it when full
+func (c *EventConsumer) addToBatch(msg *kafka.Message) {
+	ctx, span := c.startProcessSpan(msg)
+	event, commit, err := c.batchEvent(ctx, span, msg)
+	endSpan(span, err)
+
+	switch {
+	case event != nil:
+		c.checkOrdering(msg, event)
+		c.batch.add(msg, event)
+	case commit:
+		c.batch.track(msg)
+	default:
+		c.rewindPending(msg)
+		return
+	}
+
+	if len(c.batch.events) >= c.batchSize {
+		c.flushBatch(flushSize)
+	}
+}
+
+// batchEvent decodes msg for the pending batch, returning nil if it is
+// skipped rather than handled. commit then reports whether its offset may be
+// committed with the batch; if not the pending batch must be rewound so msg
+// is redelivered.
+func (c *EventConsumer) batchEvent(ctx context.Context, span trace.Span, msg *kafka.Message) (event *schema.Event, commit bool, err error) {
+	if isTombstone(msg) {
+		return nil, c.handleBatchTombstone(ctx, msg), nil
+	}
+	c.observeMessageSize(msg)
+	if skip, ok := c.oversized(msg); skip {
+		return nil, ok, nil
+	}
+
+	event, err = c.decodeMessage(msg)
+	if err != nil {
+		return nil, c.handleBatchDecodeError(ctx, msg, err), err
+	}
+	annotateSpan(ctx, span, event)
+	if handle, ok := c.screen(msg, event); !handle {
+		return nil, ok, nil
+	}
+	if err := c.enrich(ctx, msg, event); err != nil {
+		return nil, c.batchFailure(msg, err), err
+	}
+	return event, true, nil
+}
+
+// flushBatchIfDue flushes a partial batch whose timeout has elapsed
+func (c *EventConsumer) flushBatchIfDue() {
+	if !c.batch.empty() && time.Since(c.batch.started) >= c.batchTimeout {
+		c.flushBatch(flushTimeout)
+	}
+}
+
+// flushBatch hands the pending batch to the batch handler and commits its
+// offsets, recording its size and the reason for the flush. If the whole
+// batch fails after retries and no dead-letter handler is set, the
+// partitions are rewound so the batch is redelivered, unless CommitOnError
+// covers every event in it. With MaxOffsetRetries the batch is rewound that
+// many times first, then dead-lettered or skipped. Events failing in a
+// partially stored batch are handled by flushFailures.
+func (c *EventConsumer) flushBatch(reason string) {
+	batch := c.batch
+	c.batch = newPendingBatch()
+	if batch.empty() {
+		return
+	}
+	c.metrics.batchFlushes.WithLabelValues(reason).Inc()
+	c.metrics.batchSizes.Observe(float64(len(batch.events)))
+
+	var partial PartialBatchError
+	err := c.retry.do(c.ctx, func() error {
+		return c.callBatchHandler(batch.events)
+	}, func(err error) bool {
+		return errors.As(err, &partial)
+	}, "batch_size", len(batch.events))
+
+	switch {
+	case err == nil:
+		if c.poison != nil {
+			c.poison.clear(batch.firstOffsets()...)
+			c.poison.clear(batch.messageOffsets()...)
+		}
+		c.observeStored(batch.messages...)
+		c.logger.Info("Flushed batch", "batch_size", len(batch.events), "reason", reason)
+	case partial != nil:
+		failures := partial.BatchFailures()
+		c.logger.Warn("Batch stored with failures", "batch_size", len(batch.events), "failures", len(failures))
+		for idx, msg := range batch.messages {
+			if _, failed := failures[idx]; !failed {
+				c.observeStored(msg)
+			}
+		}
+		if !c.flushFailures(batch, failures) {
+			return
+		}
+	case c.batchCommitsOnError(batch):
+		c.logger.Warn("Batch failed, committing", "batch_size", len(batch.events), "error", err)
+		for _, msg := range batch.messages {
+			if !c.sendDeadLetter(msg, err) {
+				c.rewindUndelivered(batch)
+				return
+			}
+		}
+		for _, event := range batch.events {
+			c.metrics.committedOnError.WithLabelValues(string(event.Type)).Inc()
+		}
+		if c.poison != nil {
+			c.poison.clear(batch.firstOffsets()...)
+		}
+	case c.poison != nil && !c.poison.fail(batch.firstOffsets()...):
+		c.logger.Error("Batch failed, rewinding for redelivery", "batch_size", len(batch.events), "error", err)
+		c.rewind(batch)
+		return
+	case c.poison != nil:
+		// The batch stays over the threshold until its dead letters are
+		// delivered, so a redelivery tries them again
+		for _, msg := range batch.messages {
+			if !c.sendDeadLetter(msg, err) {
+				c.rewindUndelivered(batch)
+				return
+			}
+		}
+		c.metrics.skippedEvents.Add(float64(len(batch.messages)))
+		c.poison.clear(batch.firstOffsets()...)
+		c.logger.Warn("Skipping repeatedly failing batch",
+			"batch_size", len(batch.events), "max_offset_retries", c.poison.max, "error", err)
+	case c.deadLetter != nil:
+		c.logger.Error("Batch failed, dead-lettering", "batch_size", len(batch.events), "error", err)
+		for _, msg := range batch.messages {
+			if !c.sendDeadLetter(msg, err) {
+				c.rewindUndelivered(batch)
+				return
+			}
+		}
+	default:
+		c.logger.Error("Batch failed, rewinding for redelivery", "batch_size", len(batch.events), "error", err)
+		c.rewind(batch)
+		return
+	}
+
+	c.commitOffsets(batch.commitOffsets())
+}
+
+// flushFailures disposes of the failed events of a partially stored batch,
+// reporting whether the batch may be committed. Failed events are
+// dead-lettered if a dead-letter handler is set. Otherwise the batch is
+// rewound so they are redelivered, and with MaxOffsetRetries skipped once one
+// of them has failed more than that many times. A failed event is only
+// committed once its dead letter is delivered or it is skipped.
+func (c *EventConsumer) flushFailures(batch *pendingBatch, failures map[int]error) bool {
+	var failed []int // Indexes into batch
+	var offsets []kafka.TopicPartition
+	for idx, failErr := range failures {
+		if idx < 0 || idx >= len(batch.messages) {
+			continue
+		}
+		c.logger.Error("Batch event failed",
+			append(c.messageAttrs(batch.messages[idx], batch.events[idx]), "error", failErr)...)
+		failed = append(failed, idx)
+		offsets = append(offsets, batch.messages[idx].TopicPartition)
+	}
+
+	switch {
+	case len(failed) == 0:
+		return true
+	case c.deadLetter != nil:
+		for _, idx := range failed {
+			if !c.sendDeadLetter(batch.messages[idx], failures[idx]) {
+				c.rewindUndelivered(batch)
+				return false
+			}
+		}
+		return true
+	case c.poison != nil && c.poison.fail(offsets...):
+		c.metrics.skippedEvents.Add(float64(len(failed)))
+		c.poison.clear(offsets...)
+		c.logger.Warn("Skipping repeatedly failing batch events",
+			"batch_size", len(batch.events), "failures", len(failed), "max_offset_retries", c.poison.max)
+		return true
+	default:
+		c.logger.Error("Batch events failed, rewinding for redelivery",
+			"batch_size", len(batch.events), "failures", len(failed))
+		c.rewind(batch)
+		return false
+	}
+}
+
+// batchCommitsOnError reports whether CommitOnError covers every event in
+// batch
+func (c *EventConsumer) batchCommitsOnError(batch *pendingBatch) bool {
+	for _, event := range batch.events {
+		if !c.commitsOnError(event.Type) {
+			return false
+		}
+	}
+	return true
+}
+
+// callBatchHandler calls the batch handler, recovering a panic as an error
+// and enforcing HandlerTimeout
+func (c *EventConsumer) callBatchHandler(events []*schema.Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+	call := func(ctx context.Context) (err error) {
+		defer recoverPanic(c.metrics, c.logger, &err, "batch_size", len(events))
+		return c.batchHandler(ctx, events)
+	}
+	if c.handlerTimeout > 0 {
+		return callWithTimeout(c.metrics, c.ctx, c.handlerTimeout, call)
+	}
+	return call(c.ctx)
+}
+
+// rewind seeks each partition in the batch back to its first offset
+func (c *EventConsumer) rewind(batch *pendingBatch) {
+	for key, offset := range batch.first {
+		if err := c.consumer.Seek(key.at(offset), 0); err != nil {
+			c.logger.Error("Failed to seek", "topic", key.topic, "partition", key.partition, "error", err)
+		}
+	}
+}
+
+// rewindUndelivered rewinds a batch whose failed events could not be
+// dead-lettered, so they are redelivered rather than committed and lost
+func (c *EventConsumer) rewindUndelivered(batch *pendingBatch) {
+	c.logger.Error("Dead-lettering batch failed, rewinding for redelivery", "batch_size", len(batch.events))
+	c.rewind(batch)
+}
+
+// batchFailure is handleFailure for a message batch mode skips before the
+// batch handler, such as one whose decode error handler or enricher failed.
+// It reports whether the message may be committed with the batch: once its
+// dead letter is delivered, or once it is skipped after MaxOffsetRetries.
+// Otherwise the caller rewinds the pending batch with rewindPending.
+func (c *EventConsumer) batchFailure(msg *kafka.Message, err error) bool {
+	switch {
+	case c.poison == nil && c.deadLetter != nil:
+		return c.sendDeadLetter(msg, err)
+	case c.poison == nil || !c.poison.fail(msg.TopicPartition):
+		return false
+	case !c.sendDeadLetter(msg, err):
+		return false
+	default:
+		c.metrics.skippedEvents.Inc()
+		c.logger.Warn("Skipping repeatedly failing message",
+			append(c.messageAttrs(msg, nil), "max_offset_retries", c.poison.max, "error", err)...)
+		c.poison.clear(msg.TopicPartition)
+		return true
+	}
+}
+
+// rewindPending discards the pending batch and rewinds it, along with msg,
+// so they are redelivered. msg was read after the batch's messages and may
+// not be committed, so committing the batch would commit past it.
+func (c *EventConsumer) rewindPending(msg *kafka.Message) {
+	c.batch.track(msg)
+	batch := c.batch
+	c.batch = newPendingBatch()
+	c.logger.Error("Message could not be committed, rewinding batch for redelivery",
+		append(c.messageAttrs(msg, nil), "batch_size", len(batch.events))...)
+	c.rewind(batch)
+}
@@ -0,0 +1,217 @@
+package consumer_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/assure-compliance/eventid/pkg/consumer/consumertest"
+	"github.com/assure-compliance/eventid/pkg/schema"
+	"github.com/assure-compliance/eventid/pkg/storage"
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+)
+
+// A consumer killed while handling a batch must not have committed it, so
+// the next member of the group resumes from the end of the last batch that
+// was handled, reprocessing the interrupted one and nothing before it
+func TestKillMidBatchReprocessesFromLastCommit(t *testing.T) {
+	t.Parallel()
+	h := consumertest.New(t, consumertest.Config{})
+	cfg := h.ConsumerConfig()
+	cfg.BatchSize = 3
+	cfg.BatchTimeout = time.Minute // Flush on size only
+
+	events := make([]*schema.Event, 6)
+	for i := range events {
+		events[i] = consumertest.NewEvent(t, schema.EventViolationFound)
+		h.Publish(events[i])
+	}
+
+	first := h.NewConsumer(cfg)
+	interrupted := make(chan struct{})
+	var calls int
+	first.RegisterBatchHandler(func(ctx context.Context, batch []*schema.Event) error {
+		if calls++; calls == 1 {
+			for _, event := range batch {
+				if err := h.Store.StoreEvent(ctx, event); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		close(interrupted)
+		<-ctx.Done() // Killed mid-batch
+		return ctx.Err()
+	})
+	h.Start(first)
+
+	select {
+	case <-interrupted:
+	case <-time.After(10 * time.Second):
+		t.Fatal("second batch was not handled")
+	}
+	first.Close()
+
+	var mu sync.Mutex
+	var redelivered []*schema.Event
+	second := h.NewConsumer(cfg)
+	second.RegisterBatchHandler(func(_ context.Context, batch []*schema.Event) error {
+		mu.Lock()
+		defer mu.Unlock()
+		redelivered = append(redelivered, batch...)
+		return nil
+	})
+	h.Start(second)
+
+	err := h.WaitFor(10*time.Second, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(redelivered) >= 3
+	})
+	if err != nil {
+		t.Fatalf("interrupted batch was not redelivered: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(redelivered) != 3 {
+		t.Fatalf("redelivered %d events, want 3", len(redelivered))
+	}
+	for i, event := range redelivered {
+		if want := events[3+i]; event.ID != want.ID {
+			t.Errorf("redelivered event %d is %s, want %s", i, event.ID, want.ID)
+		}
+	}
+	for _, event := range events[:3] {
+		if _, err := h.Store.GetEventByID(event.ID); err != nil {
+			t.Errorf("event %s of the handled batch was not stored: %v", event.ID, err)
+		}
+	}
+}
+
+// An event failing in a partially stored batch is only committed once its
+// dead letter is delivered; without a dead-letter handler the batch is
+// redelivered until the event is stored
+func TestPartialBatchFailure(t *testing.T) {
+	t.Parallel()
+	for _, tc := range []struct {
+		name       string
+		deadLetter bool
+		calls      int32 // Batch handler calls before the batch is committed
+	}{
+		{name: "redelivered without dead letters", calls: 2},
+		{name: "dead-lettered", deadLetter: true, calls: 1},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			h := consumertest.New(t, consumertest.Config{})
+			cfg := h.ConsumerConfig()
+			cfg.BatchSize = 3
+			cfg.BatchTimeout = time.Minute // Flush on size only
+
+			events := make([]*schema.Event, 3)
+			for i := range events {
+				events[i] = consumertest.NewEvent(t, schema.EventViolationFound)
+				h.Publish(events[i])
+			}
+
+			var calls atomic.Int32
+			c := h.NewConsumer(cfg)
+			c.RegisterBatchHandler(func(ctx context.Context, batch []*schema.Event) error {
+				first := calls.Add(1) == 1
+				var failures []storage.BatchFailure
+				for i, event := range batch {
+					if first && i == 1 {
+						failures = append(failures, storage.BatchFailure{Index: i, EventID: event.ID, Err: errors.New("row failed")})
+						continue
+					}
+					if err := h.Store.StoreEvent(ctx, event); err != nil && !errors.Is(err, storage.ErrDuplicateEvent) {
+						return err
+					}
+				}
+				if len(failures) > 0 {
+					return &storage.BatchError{Failures: failures}
+				}
+				return nil
+			})
+			deadLettered := make(chan kafka.Offset, 1)
+			if tc.deadLetter {
+				c.SetDeadLetterHandler(func(msg *kafka.Message, _ error) error {
+					deadLettered <- msg.TopicPartition.Offset
+					return nil
+				})
+			}
+			h.Start(c)
+			h.WaitForCommitted(cfg.GroupID, 0, 3, 30*time.Second)
+
+			if n := calls.Load(); n != tc.calls {
+				t.Errorf("batch handler called %d times, want %d", n, tc.calls)
+			}
+			_, err := h.Store.GetEventByID(events[1].ID)
+			if tc.deadLetter {
+				if err == nil {
+					t.Errorf("dead-lettered event %s was stored", events[1].ID)
+				}
+				select {
+				case offset := <-deadLettered:
+					if offset != 1 {
+						t.Errorf("dead-lettered offset %d, want 1", offset)
+					}
+				default:
+					t.Error("failed event was not dead-lettered")
+				}
+			} else if err != nil {
+				t.Errorf("failed event %s was not stored on redelivery: %v", events[1].ID, err)
+			}
+		})
+	}
+}
+
+// An undecodable message whose decode error handler fails in batch mode is
+// not committed with the batch: the batch is rewound and the handler called
+// again
+func TestBatchDecodeErrorHandlerFailureRewinds(t *testing.T) {
+	t.Parallel()
+	h := consumertest.New(t, consumertest.Config{})
+	cfg := h.ConsumerConfig()
+	cfg.BatchSize = 2
+	cfg.BatchTimeout = time.Minute // Flush on size only
+
+	event := consumertest.NewEvent(t, schema.EventViolationFound)
+	h.Publish(event)
+	h.PublishRaw(nil, []byte("not an event"))
+	next := consumertest.NewEvent(t, schema.EventViolationFound)
+	h.Publish(next)
+
+	c := h.NewConsumer(cfg)
+	c.RegisterBatchHandler(func(ctx context.Context, batch []*schema.Event) error {
+		for _, event := range batch {
+			if err := h.Store.StoreEvent(ctx, event); err != nil && !errors.Is(err, storage.ErrDuplicateEvent) {
+				return err
+			}
+		}
+		return nil
+	})
+	var quarantined atomic.Int32
+	c.SetDecodeErrorHandler(func(context.Context, *kafka.Message, error) error {
+		if quarantined.Add(1) == 1 {
+			return errors.New("quarantine store unavailable")
+		}
+		return nil
+	})
+	h.Start(c)
+	h.WaitForCommitted(cfg.GroupID, 0, 3, 30*time.Second)
+
+	if n := quarantined.Load(); n != 2 {
+		t.Errorf("decode error handler called %d times, want 2", n)
+	}
+	for _, e := range []*schema.Event{event, next} {
+		if _, err := h.Store.GetEventByID(e.ID); err != nil {
+			t.Errorf("event %s was not stored: %v", e.ID, err)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Backoff between the metadata requests made by WaitForBrokers, and the
+// timeout of each request
+const (
+	brokerWaitBackoff    = 500 * time.Millisecond
+	brokerWaitMaxBackoff = 10 * time.Second
+	brokerRequestTimeout = 5 * time.Second
+)
+
+// ErrBrokersUnavailable is wrapped by WaitForBrokers when no broker answered
+// before its context ended
+var ErrBrokersUnavailable = errors.New("kafka brokers unavailable")
+
+// WaitForBrokers blocks until a broker answers a metadata request, retrying
+// with exponential backoff until ctx ends. The request asks for no topics,
+// so it never triggers topic auto-creation. It is called by
+// NewEventConsumer when BrokerWaitTimeout is set.
+func (c *EventConsumer) WaitForBrokers(ctx context.Context) error {
+	backoff := brokerWaitBackoff
+	for attempt := 1; ; attempt++ {
+		timeout := brokerRequestTimeout
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < timeout {
+			timeout = max(time.Until(deadline), time.Millisecond)
+		}
+		_, err := c.consumer.GetMetadata(nil, false, int(timeout.Milliseconds()))
+		if err == nil {
+			if attempt > 1 {
+				c.logger.Info("Kafka brokers reachable", "attempts", attempt)
+			}
+			return nil
+		}
+
+		c.logger.Warn("Kafka brokers unreachable, retrying",
+			"attempt", attempt, "backoff", backoff.String(), "error", err)
+		timer := time.NewTimer(backoff)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("%w after %d attempts: %w", ErrBrokersUnavailable, attempt, err)
+		}
+		if backoff *= 2; backoff > brokerWaitMaxBackoff {
+			backoff = brokerWaitMaxBackoff
+		}
+	}
+}
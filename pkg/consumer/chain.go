@@ -0,0 +1,86 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/assure-compliance/eventid/pkg/schema"
+)
+
+// What a handler chain does when one of its handlers fails, set with
+// Config.ChainFailure
+const (
+	ChainStop     = "stop"     // Skip the remaining handlers (the default)
+	ChainContinue = "continue" // Run the remaining handlers anyway
+)
+
+// orderedHandler is a handler registered with RegisterHandlerOrdered
+type orderedHandler struct {
+	priority int
+	handler  EventHandler
+}
+
+// validateChainFailure checks Config.ChainFailure and returns the policy to
+// apply
+func validateChainFailure(cfg Config) (string, error) {
+	switch cfg.ChainFailure {
+	case "":
+		return ChainStop, nil
+	case ChainStop, ChainContinue:
+		return cfg.ChainFailure, nil
+	}
+	return "", fmt.Errorf("invalid ChainFailure %q: must be %s or %s", cfg.ChainFailure, ChainStop, ChainContinue)
+}
+
+// RegisterHandlerOrdered adds handler to the chain of handlers run for an
+// event type on every subscribed topic. Lower priorities run first and
+// equal ones in registration order; a handler registered with
+// RegisterHandler for the type joins the chain at priority 0, ahead of
+// ordered handlers of that priority. Each handler sees the changes the
+// ones before it made to the event, so e.g. enrichment at a negative
+// priority runs before persistence. Config.ChainFailure decides whether a
+// failing handler stops the chain. Middleware and the retry policy wrap
+// the chain as a whole, so a retry runs it again from the first handler,
+// and handlers must tolerate running again as they do redelivery.
+func (c *EventConsumer) RegisterHandlerOrdered(eventType schema.EventType, priority int, handler EventHandler) {
+	c.handlersMu.Lock()
+	defer c.handlersMu.Unlock()
+	if c.chains == nil {
+		c.chains = make(map[schema.EventType][]orderedHandler)
+	}
+	c.chains[eventType] = append(c.chains[eventType], orderedHandler{priority: priority, handler: handler})
+}
+
+// buildChains replaces the handler of each event type that has ordered
+// handlers with their chain. c.handlersMu must be held.
+func (c *EventConsumer) buildChains() {
+	for eventType, ordered := range c.chains {
+		var chain []orderedHandler
+		if handler, ok := c.handlers[eventType]; ok {
+			chain = append(chain, orderedHandler{handler: handler})
+		}
+		chain = append(chain, ordered...)
+		sort.SliceStable(chain, func(i, j int) bool { return chain[i].priority < chain[j].priority })
+		c.handlers[eventType] = c.runChain(chain)
+	}
+	c.chains = nil
+}
+
+// runChain returns a handler calling each handler of chain in turn
+func (c *EventConsumer) runChain(chain []orderedHandler) EventHandler {
+	stop := c.chainFailure != ChainContinue
+	return func(ctx context.Context, event *schema.Event) error {
+		var errs []error
+		for i, step := range chain {
+			if err := step.handler(ctx, event); err != nil {
+				errs = append(errs, fmt.Errorf("handler %d of %d (priority %d): %w", i+1, len(chain), step.priority, err))
+				if stop {
+					break
+				}
+			}
+		}
+		return errors.Join(errs...)
+	}
+}
@@ -0,0 +1,213 @@
+package consumer
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+)
+
+// A synchronous commit that fails is attempted up to commitAttempts times,
+// waiting commitRetryBackoff after the first failure and doubling after each
+// further one
+const (
+	commitAttempts     = 3
+	commitRetryBackoff = 100 * time.Millisecond
+)
+
+// commitOffsets marks offsets as processed when manual commits are in use.
+// With a CommitInterval the offsets are stored and committed by the client in
+// the background; otherwise they are committed synchronously, with retries.
+// A commit that still fails leaves its messages to be redelivered after the
+// next rebalance or restart, and is reported by Healthy until a later commit
+// succeeds.
+func (c *EventConsumer) commitOffsets(offsets []kafka.TopicPartition) {
+	if !c.manualCommit || len(offsets) == 0 {
+		return
+	}
+
+	if c.commitInterval > 0 {
+		stored, err := c.consumer.StoreOffsets(offsets)
+		if err = partitionError(stored, err); err != nil {
+			c.metrics.Errors.WithLabelValues("commit").Inc()
+			c.logger.Error("Failed to store offsets", "partitions", failedPartitions(stored, offsets), "error", err)
+		}
+		return
+	}
+
+	backoff := commitRetryBackoff
+	for attempt := 1; ; attempt++ {
+		committed, err := c.consumer.CommitOffsets(offsets)
+		err = partitionError(committed, err)
+		if err == nil || isCode(err, kafka.ErrNoOffset) {
+			c.commits.succeeded()
+			return
+		}
+
+		c.metrics.Errors.WithLabelValues("commit").Inc()
+		partitions := failedPartitions(committed, offsets)
+		if attempt == commitAttempts || !retriableCommit(err) {
+			c.commits.failed(err)
+			c.logger.Error("Failed to commit offsets, messages since the last commit may be redelivered",
+				"partitions", partitions, "attempts", attempt, "error", err)
+			return
+		}
+		c.logger.Warn("Failed to commit offsets, retrying",
+			"partitions", partitions, "attempt", attempt, "backoff", backoff.String(), "error", err)
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// commitStoredOffsets synchronously commits offsets stored for background
+// commit. It does nothing unless manual commits use a CommitInterval.
+func (c *EventConsumer) commitStoredOffsets() error {
+	if !c.manualCommit || c.commitInterval <= 0 {
+		return nil
+	}
+	committed, err := c.consumer.Commit()
+	if err = partitionError(committed, err); err != nil {
+		if isCode(err, kafka.ErrNoOffset) {
+			return nil
+		}
+		c.metrics.Errors.WithLabelValues("commit").Inc()
+		c.commits.failed(err)
+		return fmt.Errorf("failed to commit partitions %v: %w", failedPartitions(committed, nil), err)
+	}
+	c.commits.succeeded()
+	return nil
+}
+
+// recordCommit records the result of a commit made by the client in the
+// background, as reported by an OffsetsCommitted event. Synchronous commits
+// are recorded by their callers.
+func (c *EventConsumer) recordCommit(e kafka.OffsetsCommitted) {
+	if c.manualCommit && c.commitInterval <= 0 {
+		return
+	}
+	err := partitionError(e.Offsets, e.Error)
+	switch {
+	case err == nil || isCode(err, kafka.ErrNoOffset):
+		c.commits.succeeded()
+	default:
+		c.metrics.Errors.WithLabelValues("commit").Inc()
+		c.commits.failed(err)
+		c.logger.Error("Background offset commit failed, messages since the last commit may be redelivered",
+			"partitions", failedPartitions(e.Offsets, nil), "error", err)
+	}
+}
+
+// partitionError returns err, or else the first per-partition error in
+// partitions
+func partitionError(partitions []kafka.TopicPartition, err error) error {
+	if err != nil {
+		return err
+	}
+	for _, tp := range partitions {
+		if tp.Error != nil {
+			return tp.Error
+		}
+	}
+	return nil
+}
+
+// failedPartitions lists the partitions in result that carry an error, as
+// topic[partition]@offset. When none do, the error applied to the whole
+// request and every partition in requested is listed.
+func failedPartitions(result, requested []kafka.TopicPartition) []string {
+	var failed []string
+	for _, tp := range result {
+		if tp.Error != nil {
+			failed = append(failed, tp.String())
+		}
+	}
+	if len(failed) == 0 {
+		for _, tp := range requested {
+			failed = append(failed, tp.String())
+		}
+	}
+	return failed
+}
+
+// retriableCommit reports whether a failed commit may succeed if retried.
+// Once the group has moved on without this member, retrying cannot help:
+// the partitions' new owner will re-read from the last committed offsets.
+func retriableCommit(err error) bool {
+	switch {
+	case isCode(err, kafka.ErrUnknownMemberID),
+		isCode(err, kafka.ErrIllegalGeneration),
+		isCode(err, kafka.ErrFencedInstanceID),
+		isCode(err, kafka.ErrState):
+		return false
+	}
+	return true
+}
+
+// isCode reports whether err is a kafka.Error with code
+func isCode(err error, code kafka.ErrorCode) bool {
+	kafkaErr, ok := err.(kafka.Error)
+	return ok && kafkaErr.Code() == code
+}
+
+// ack commits the offset following msg. With concurrent workers the commit
+// waits until every earlier message on the partition has been acked.
+func (c *EventConsumer) ack(msg *kafka.Message) {
+	if c.tracker != nil {
+		c.tracker.complete(msg.TopicPartition, func(next kafka.TopicPartition) {
+			c.commitOffsets([]kafka.TopicPartition{next})
+		})
+		return
+	}
+	c.commitOffsets([]kafka.TopicPartition{keyOf(msg.TopicPartition).at(msg.TopicPartition.Offset + 1)})
+}
+
+// settle disposes of a message skipped without reaching a handler: it is
+// acked if commit is true and otherwise redelivered
+func (c *EventConsumer) settle(msg *kafka.Message, commit bool) {
+	if commit {
+		c.ack(msg)
+		return
+	}
+	c.redeliver(msg)
+}
+
+// redeliver seeks the partition back to msg so it is consumed again. Only
+// meaningful with manual commits; with auto-commit the offset has already
+// been advanced and the message is skipped. Workers leave the seek to the
+// Start goroutine, see applyRewinds.
+func (c *EventConsumer) redeliver(msg *kafka.Message) {
+	if !c.manualCommit {
+		return
+	}
+	if c.tracker != nil {
+		c.tracker.requestRewind(msg.TopicPartition)
+		return
+	}
+	if err := c.consumer.Seek(keyOf(msg.TopicPartition).at(msg.TopicPartition.Offset), 0); err != nil {
+		c.logger.Error("Failed to seek", append(c.messageAttrs(msg, nil), "error", err)...)
+	}
+}
+
+// applyRewinds rewinds the tracker and seeks back each partition workers
+// asked to redeliver. It runs on the Start goroutine between reading msg and
+// beginning it, so no message read before a seek can begin after the rewind
+// and commit past the message being redelivered; it reports whether msg is
+// such a message, which must be dropped as it is read again after the seek.
+// A failed seek is retried by the next call.
+func (c *EventConsumer) applyRewinds(msg *kafka.Message) bool {
+	stale := false
+	for key, offset := range c.tracker.takeRewinds() {
+		tp := key.at(offset)
+		if !c.tracker.rewind(tp) {
+			continue
+		}
+		if msg != nil && keyOf(msg.TopicPartition) == key && msg.TopicPartition.Offset >= offset {
+			stale = true
+		}
+		if err := c.consumer.Seek(tp, 0); err != nil {
+			c.logger.Error("Failed to seek", "topic", key.topic, "partition", key.partition, "offset", offset, "error", err)
+			c.tracker.requestRewind(tp)
+		}
+	}
+	return stale
+}
@@ -0,0 +1,967 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/assure-compliance/eventid/pkg/schema"
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/protobuf/proto"
+)
+
+// DefaultSessionTimeout is how long the group waits for a silent member
+// when Config.SessionTimeout is unset
+const DefaultSessionTimeout = 6 * time.Second
+
+// EventHandler is called for each consumed event. ctx is cancelled when the
+// consumer is closed, including when Shutdown gives up waiting for it.
+type EventHandler func(ctx context.Context, event *schema.Event) error
+
+// LegacyHandler is the previous handler signature, receiving the typed event
+// struct returned by schema.GetEventTypeInterface
+type LegacyHandler func(event interface{}) error
+
+// Legacy adapts a LegacyHandler to EventHandler by decoding the envelope
+// payload before calling it. It exists to ease migration and will be removed.
+func Legacy(handler LegacyHandler) EventHandler {
+	return func(_ context.Context, event *schema.Event) error {
+		typed, err := event.Decode()
+		if err != nil {
+			return err
+		}
+		return handler(typed)
+	}
+}
+
+// DeadLetterHandler is called with the original message when a handler
+// fails after exhausting its retries. It returns an error if the message
+// could not be dead-lettered, in which case the message is redelivered
+// rather than committed.
+type DeadLetterHandler func(msg *kafka.Message, err error) error
+
+// EventConsumer handles consuming events from Kafka
+type EventConsumer struct {
+	consumer   *kafka.Consumer
+	groupID    string
+	topics     []string // Subscribed or assigned topics
+	handlers   map[schema.EventType]EventHandler
+	byTopic    map[string]map[schema.EventType]EventHandler
+	byVersion  map[versionKey]EventHandler
+	fallback   EventHandler
+	chains     map[schema.EventType][]orderedHandler // Until Start builds them
+	retry      RetryPolicy
+	deadLetter DeadLetterHandler
+
+	decodeError DecodeErrorHandler
+	tombstone   TombstoneHandler
+	rawLog      RawLog
+
+	transformers       map[string]Transformer // By topic
+	headerTransformers []headerTransformer
+
+	middleware  []Middleware
+	enrichers   []Enricher
+	prepareOnce sync.Once    // Wraps handlers with middleware and retries
+	handlersMu  sync.RWMutex // Guards handler registration against RegisteredTypes
+
+	dlqProducer     *kafka.Producer
+	deadLetterTopic string
+
+	batchHandler BatchHandler
+	batchSize    int
+	batchTimeout time.Duration
+	batch        *pendingBatch
+
+	manualCommit   bool
+	commitInterval time.Duration
+	commits        commitHealth
+
+	deadLetterInvalid bool
+	validateOnly      bool
+	unknownTypes      string // UnknownTypePolicy
+	filter            typeFilter
+	typeResolver      TypeResolver
+	chainFailure      string // ChainFailure
+	maxMessageBytes   int
+	compression       topicCompression
+	ordering          *orderingCheck // Set when CheckOrdering is enabled
+	startFrom         *startPosition // Set when StartFromTimestamp is configured
+	skipTo            map[partitionKey]kafka.Offset
+	handlerTimeout    time.Duration
+	stats             statsLabels // Last reported client statistics labels
+
+	lagInterval time.Duration
+	done        chan struct{}
+	ctx         context.Context // Passed to handlers; cancelled by Close
+	cancel      context.CancelFunc
+	closeOnce   sync.Once
+	closeErr    error
+
+	// Shutdown signalling: stop ends the Start loop, stopped is closed once
+	// it has drained
+	stop     chan struct{}
+	stopOnce sync.Once
+	stopped  chan struct{}
+	running  atomic.Bool
+
+	drainStage atomic.Value // What drain is waiting on, for Shutdown's logs
+
+	seeks chan seekRequest // SeekPartition requests served by Start
+
+	joined atomic.Bool // Set once the group assigns partitions
+
+	pauseMu sync.Mutex
+	paused  bool  // Set by Pause; applied to partitions assigned while paused
+	held    uint8 // Reasons, such as holdBackpressure, fetching is held back
+
+	highWater int          // BackpressureHighWater; 0 disables backpressure
+	lowWater  int          // BackpressureLowWater
+	inFlight  atomic.Int64 // Messages dispatched to workers and not yet handled
+	limiter   *rateLimiter // Set when MaxEventsPerSecond is configured
+
+	concurrency int
+	tracker     *offsetTracker // Set while Start runs concurrent workers
+
+	maxInFlight int                   // MaxInFlightPerPartition; 0 disables the cap
+	flight      *partitionFlight      // Set while Start runs concurrent workers
+	limited     map[partitionKey]bool // Partitions paused at maxInFlight; guarded by pauseMu
+
+	poison *poisonTracker // Set when MaxOffsetRetries is configured
+
+	commitOnError      bool
+	commitOnErrorTypes map[schema.EventType]bool
+
+	tracer        trace.Tracer
+	deserializers *deserializers
+	metrics       *Metrics
+
+	logger              Logger
+	correlationHeader   string
+	causationHeader     string
+	tenantHeader        string
+	schemaVersionHeader string
+}
+
+// Config holds consumer configuration
+type Config struct {
+	BootstrapServers string
+	GroupID          string
+	Topics           []string
+	AutoOffsetReset  string // "earliest" or "latest"
+
+	// GroupInstanceID enables static group membership: a consumer that
+	// rejoins with the same ID within SessionTimeout gets its partitions
+	// back without a rebalance. It must be unique among the group's live
+	// members, since a member joining with an ID already in use fences the
+	// other one out, and stable across restarts of the same instance.
+	// SessionTimeout is how long the group waits for a silent member
+	// before reassigning its partitions (default DefaultSessionTimeout).
+	GroupInstanceID string
+	SessionTimeout  time.Duration
+
+	// StartFromTimestamp positions each subscribed partition, the first
+	// time it is assigned to this consumer, at the earliest offset whose
+	// timestamp is at or after this time. It takes precedence over both the
+	// group's committed offsets and AutoOffsetReset; partitions with no
+	// message that recent start at the end. Later assignments of the same
+	// partition, e.g. after a rebalance, resume from committed offsets as
+	// usual. Not supported with AssignPartitions.
+	StartFromTimestamp time.Time
+
+	// SkipTo is a recovery control for a known-bad range of offsets, e.g.
+	// garbage from a producer bug: whenever a listed partition is assigned
+	// and would start below the listed offset, it is moved forward to it.
+	// Each skip is logged and counted in
+	// event_consumer_skipped_range_messages_total. Partitions whose
+	// committed offset is already past the listed one are unaffected, so
+	// the setting can stay in place until the next deploy. Offsets must be
+	// numbers. Not supported with AssignPartitions.
+	SkipTo []PartitionOffset
+
+	// Authentication settings, e.g. SecurityProtocol "SASL_SSL" with
+	// SASLMechanism "SCRAM-SHA-512". A SASL mechanism requires both
+	// SASLUsername and SASLPassword.
+	SecurityProtocol string
+	SASLMechanism    string
+	SASLUsername     string
+	SASLPassword     string
+	SSLCALocation    string // Optional CA bundle path for verifying brokers
+
+	// Handler retry settings. Failed handlers are retried up to MaxRetries
+	// times, starting at RetryBackoff and doubling up to DefaultMaxRetryBackoff.
+	MaxRetries   int
+	RetryBackoff time.Duration
+
+	// HandlerTimeout bounds each handler attempt, including batch handlers.
+	// The handler's context is cancelled at the deadline and the attempt
+	// fails with ErrHandlerTimeout, which is retried like any other error.
+	// 0 disables the timeout.
+	HandlerTimeout time.Duration
+
+	// DeadLetterTopic receives messages whose handler exhausted its retries.
+	// Leave empty to disable dead-lettering.
+	DeadLetterTopic string
+
+	// MaxOffsetRetries is how many times a failed message (or batch) is
+	// redelivered before it is treated as poison and skipped, so that one
+	// bad event cannot stall its partition. Skipped messages are
+	// dead-lettered if dead-lettering is enabled, which then only happens
+	// after these redeliveries. 0 disables skipping. Requires manual commits.
+	MaxOffsetRetries int
+
+	// CommitOnError commits the offset of an event whose handler still
+	// fails after MaxRetries instead of redelivering it, for every event
+	// type, and CommitOnErrorTypes does so only for the listed types. The
+	// failed event is counted in regulatory_events_committed_on_error_total
+	// and dead-lettered if dead-lettering is enabled; without a dead-letter
+	// topic it is lost. This trades durability for progress: a failing
+	// advisory event no longer stalls its partition, but it is never
+	// retried, so leave audit-critical types out. A batch that fails as a
+	// whole is only committed when every event in it is covered.
+	CommitOnError      bool
+	CommitOnErrorTypes []schema.EventType
+
+	// Batch settings, used once a handler is set with RegisterBatchHandler.
+	// A batch flushes when it holds BatchSize events or BatchTimeout after
+	// its first message.
+	BatchSize    int
+	BatchTimeout time.Duration
+
+	// AutoCommit lets the Kafka client commit offsets as soon as messages are
+	// read, which can lose events that fail after being read. When false, an
+	// offset is committed only once its handler returns nil (or the message
+	// is dead-lettered); a failed message that is not dead-lettered is
+	// redelivered. CommitInterval > 0 batches those commits in the
+	// background instead of committing each message synchronously.
+	//
+	// Batch mode always uses manual commits regardless of AutoCommit: the
+	// offsets of a whole batch are committed after it is flushed, so a crash
+	// mid-batch redelivers every event since the last flushed batch.
+	AutoCommit     bool
+	CommitInterval time.Duration
+
+	// DeadLetterInvalid routes events that fail schema.Validate to the
+	// dead-letter handler, redelivering any whose dead letter is not
+	// delivered; otherwise they are logged and dropped
+	DeadLetterInvalid bool
+
+	// ValidateOnly decodes and validates events, counting them in
+	// validation_pass_total and validation_fail_total, and then commits
+	// them without running enrichers or handlers. Invalid events are never
+	// dead-lettered. It is meant for a shadow consumer in its own group
+	// that measures a new validation rollout before it is enforced.
+	ValidateOnly bool
+
+	// UnknownTypePolicy decides what happens to events whose type
+	// schema.ParseEventType does not recognize: UnknownTypeStore (the
+	// default) handles them as usual, UnknownTypeDeadLetter dead-letters
+	// them with ErrUnknownEventType, redelivering any whose dead letter is
+	// not delivered, and UnknownTypeDrop skips them. Either way they are
+	// counted in regulatory_events_unknown_type_total. Types are
+	// recognized once declared in schema, or registered with
+	// schema.RegisterEventType or schema.RegisterSchema.
+	UnknownTypePolicy string
+
+	// TypeSource selects where each event's type is read from, for topics
+	// carrying several kinds of event whose payload is not the standard
+	// envelope: TypeSourcePayload (the default) uses the envelope's
+	// event_type field, TypeSourceHeader the TypeHeader header (default
+	// schema.DefaultEventTypeHeader) and TypeSourceField the top-level
+	// payload field named by TypeField. TypeResolver, if set, is used
+	// instead. The resolved type replaces the payload's for handler
+	// dispatch, filtering and storage; the payload itself is unchanged. A
+	// message whose type cannot be resolved fails to decode, with an error
+	// wrapping ErrDeserialize.
+	TypeSource   string
+	TypeHeader   string
+	TypeField    string
+	TypeResolver TypeResolver
+
+	// ChainFailure is what a chain of handlers registered with
+	// RegisterHandlerOrdered does when one of them fails: ChainStop (the
+	// default) skips the rest and ChainContinue runs them anyway. Either
+	// way the chain then fails with every error returned and is retried,
+	// redelivered or dead-lettered like a single handler.
+	ChainFailure string
+
+	// MaxMessageBytes rejects message values larger than this many bytes
+	// before they are decoded. Rejected messages are dead-lettered if
+	// dead-lettering is enabled, and redelivered if their dead letter is
+	// not delivered, otherwise logged and skipped. It also caps
+	// max.partition.fetch.bytes so less is buffered per partition. 0
+	// disables the limit.
+	MaxMessageBytes int
+
+	// Fetch tuning; zero keeps the client defaults. The broker holds each
+	// fetch until FetchMinBytes are available or FetchMaxWait has passed,
+	// so small values favour latency and large ones throughput.
+	// FetchMaxBytes caps the data returned by one fetch across partitions.
+	// MaxPollRecords is how many messages per partition the client
+	// prefetches (queued.min.messages): messages are read one at a time, so
+	// this bounds what is buffered ahead of the handlers rather than the
+	// size of a poll.
+	FetchMinBytes  int
+	FetchMaxBytes  int
+	FetchMaxWait   time.Duration
+	MaxPollRecords int
+
+	// CheckOrdering counts events in regulatory_events_out_of_order_total
+	// when their timestamp is earlier than the previous event with the same
+	// source and message key. It only reports regressions; events are
+	// handled as usual.
+	CheckOrdering bool
+
+	// OrderingTimestamp is the timestamp CheckOrdering compares:
+	// OrderingEventTime (the default), the payload's timestamp of when the
+	// event occurred, or OrderingRecordTime, the Kafka record timestamp of
+	// when it was produced. Events without that timestamp are not checked.
+	OrderingTimestamp string
+
+	// IncludeTypes limits handling to the listed event types and
+	// ExcludeTypes drops the listed types. Dropped events are never passed
+	// to handlers but their offsets are still committed. Empty lists
+	// exclude nothing.
+	IncludeTypes []schema.EventType
+	ExcludeTypes []schema.EventType
+
+	// LagInterval is how often regulatory_events_consumer_lag is refreshed
+	// while Start is running (default DefaultLagInterval)
+	LagInterval time.Duration
+
+	// StatsInterval is how often librdkafka reports its internal
+	// statistics, which update the event_consumer_kafka_* gauges (broker
+	// round-trip and throttle times, fetch queue depth). Statistics are
+	// only read while Start is running. 0 disables them.
+	StatsInterval time.Duration
+
+	// Metrics receives the consumer's metrics (default DefaultMetrics(),
+	// registered with the default registry). Consumers sharing a process
+	// can share one set or each register their own with NewMetrics.
+	Metrics *Metrics
+
+	// Logger receives structured logs; defaults to JSON on stderr.
+	// CorrelationHeader names the Kafka header whose value is logged as
+	// correlation_id and copied into schema.Event.CorrelationID when the
+	// payload has none (default DefaultCorrelationHeader).
+	Logger            Logger
+	CorrelationHeader string
+
+	// CausationHeader names the Kafka header carrying the ID of the event
+	// that caused each event, copied into schema.Event.CausationID when the
+	// payload has no causation_id (default DefaultCausationHeader)
+	CausationHeader string
+
+	// TenantHeader names the Kafka header carrying each event's tenant ID,
+	// copied into schema.Event.TenantID (default DefaultTenantHeader).
+	// Messages without it have no tenant.
+	TenantHeader string
+
+	// SchemaVersionHeader names the Kafka header carrying the schema
+	// version of events whose payload has no event_version, copied into
+	// schema.Event.Version (default DefaultSchemaVersionHeader). The
+	// version selects handlers registered with RegisterVersionHandler.
+	SchemaVersionHeader string
+
+	// Tracing enables OpenTelemetry spans for each processed message,
+	// continuing W3C trace context from the message headers. Spans are
+	// created from TracerProvider, or the global provider when nil.
+	Tracing        bool
+	TracerProvider trace.TracerProvider
+
+	// Concurrency is the number of workers handling messages in parallel.
+	// Messages are assigned to workers by key, so events sharing a key are
+	// still handled in order; an offset is committed only once the message
+	// and every earlier message on its partition have been handled. Values
+	// above 1 imply manual commits. Ignored in batch mode (default 1).
+	Concurrency int
+
+	// BackpressureHighWater pauses fetching from every assigned partition
+	// once this many messages are buffered, i.e. read but not yet handled,
+	// such as when a slow database holds up the handlers. Fetching resumes
+	// once the count falls to BackpressureLowWater (default half the high
+	// mark). The count covers messages queued for Concurrency workers and
+	// the events of a pending batch; without either, messages are handled
+	// one at a time as they are read. With workers, keep the high mark
+	// below 64 per worker, the size of each worker's queue, or reading
+	// blocks on a full queue first. 0 disables backpressure.
+	BackpressureHighWater int
+	BackpressureLowWater  int
+
+	// MaxInFlightPerPartition caps the messages of each partition that are
+	// read but not yet handled by Concurrency workers. A partition reaching
+	// the cap is paused on its own, leaving the others fetching, and resumed
+	// once a worker finishes one of its messages, so a slow handler cannot
+	// buffer thousands of its records. MaxPollRecords separately bounds what
+	// the client prefetches. event_consumer_partition_in_flight_messages
+	// reports the count. Without workers messages are handled one at a time
+	// as they are read and batches are bounded by BatchSize, so the cap has
+	// no effect. 0 disables the cap.
+	MaxInFlightPerPartition int
+
+	// MaxEventsPerSecond caps how many messages are read per second, e.g.
+	// to protect a fragile downstream or throttle reprocessing, including
+	// messages that are filtered or fail to decode. Bursts are limited to
+	// one second's worth. When the limit is reached fetching is paused
+	// rather than the loop spinning, and event_consumer_processing_rate
+	// reports the rate achieved. A Replayer applies the same limit to
+	// publishing. 0 disables the limit.
+	MaxEventsPerSecond float64
+
+	// Format is the wire format of message values: FormatJSON (default),
+	// FormatAvro or FormatProtobuf. TopicFormats overrides it per topic, e.g.
+	// while producers migrate. Avro values use the Schema Registry wire
+	// format and their schemas are fetched from SchemaRegistryURL.
+	Format                 string
+	TopicFormats           map[string]string
+	SchemaRegistryURL      string
+	SchemaRegistryUsername string
+	SchemaRegistryPassword string
+
+	// VerifyTopics checks that every topic in Topics exists before
+	// subscribing, so a missing topic fails NewEventConsumer instead of
+	// leaving the consumer waiting for an assignment. With AutoCreateTopics,
+	// missing topics are created instead, with TopicPartitions partitions
+	// and TopicReplicationFactor replicas (0 uses the broker defaults).
+	VerifyTopics           bool
+	AutoCreateTopics       bool
+	TopicPartitions        int
+	TopicReplicationFactor int
+
+	// BrokerWaitTimeout makes NewEventConsumer wait up to this long for a
+	// broker to answer, retrying with backoff, before verifying topics or
+	// assigning partitions, so that brokers restarting at the same time as
+	// the consumer do not fail it. NewEventConsumer returns an error
+	// wrapping ErrBrokersUnavailable if none answers in time. 0 does not
+	// wait.
+	BrokerWaitTimeout time.Duration
+
+	// AssignPartitions is a debugging mode that reads exactly these
+	// partitions from the given offsets, using manual assignment instead of
+	// subscribing to Topics (which must be empty). No offsets are committed,
+	// so the group's position is unchanged and failed messages are not
+	// redelivered.
+	AssignPartitions []PartitionOffset
+
+	// ProtobufTypes maps each event type to the message decoded for it in
+	// FormatProtobuf topics. The type is read from the
+	// schema.DefaultEventTypeHeader header.
+	ProtobufTypes map[schema.EventType]proto.Message
+}
+
+// NewEventConsumer creates a new Kafka consumer
+func NewEventConsumer(cfg Config) (*EventConsumer, error) {
+	if cfg.AutoOffsetReset == "" {
+		cfg.AutoOffsetReset = "earliest"
+	}
+	if cfg.BatchTimeout <= 0 {
+		cfg.BatchTimeout = DefaultBatchTimeout
+	}
+	if cfg.LagInterval <= 0 {
+		cfg.LagInterval = DefaultLagInterval
+	}
+	if cfg.SessionTimeout <= 0 {
+		cfg.SessionTimeout = DefaultSessionTimeout
+	}
+	if cfg.Logger == nil {
+		cfg.Logger = defaultLogger()
+	}
+	if cfg.CorrelationHeader == "" {
+		cfg.CorrelationHeader = DefaultCorrelationHeader
+	}
+	if cfg.TenantHeader == "" {
+		cfg.TenantHeader = DefaultTenantHeader
+	}
+	if cfg.CausationHeader == "" {
+		cfg.CausationHeader = DefaultCausationHeader
+	}
+	if cfg.SchemaVersionHeader == "" {
+		cfg.SchemaVersionHeader = DefaultSchemaVersionHeader
+	}
+
+	if cfg.Concurrency <= 0 {
+		cfg.Concurrency = 1
+	}
+
+	highWater, lowWater, err := newBackpressure(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateMaxInFlight(cfg); err != nil {
+		return nil, err
+	}
+	skipTo, err := newSkipTo(cfg)
+	if err != nil {
+		return nil, err
+	}
+	unknownTypes, err := newUnknownTypePolicy(cfg)
+	if err != nil {
+		return nil, err
+	}
+	ordering, err := newOrderingCheck(cfg)
+	if err != nil {
+		return nil, err
+	}
+	typeResolver, err := newTypeResolver(cfg)
+	if err != nil {
+		return nil, err
+	}
+	chainFailure, err := validateChainFailure(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	manualCommit := !cfg.AutoCommit || cfg.BatchSize > 0 || cfg.Concurrency > 1
+
+	assigned := len(cfg.AssignPartitions) > 0
+	if assigned && len(cfg.Topics) > 0 {
+		return nil, errors.New("AssignPartitions and Topics are mutually exclusive")
+	}
+	if assigned && !cfg.StartFromTimestamp.IsZero() {
+		return nil, errors.New("StartFromTimestamp cannot be used with AssignPartitions")
+	}
+	if assigned {
+		// Never move the group's committed offsets while debugging
+		manualCommit = false
+	}
+
+	config := &kafka.ConfigMap{
+		"bootstrap.servers":        cfg.BootstrapServers,
+		"group.id":                 cfg.GroupID,
+		"auto.offset.reset":        cfg.AutoOffsetReset,
+		"enable.auto.commit":       !assigned && (!manualCommit || cfg.CommitInterval > 0),
+		"enable.auto.offset.store": !manualCommit,
+		"session.timeout.ms":       int(cfg.SessionTimeout.Milliseconds()),
+	}
+	if cfg.GroupInstanceID != "" {
+		config.SetKey("group.instance.id", cfg.GroupInstanceID)
+	}
+	if cfg.CommitInterval > 0 {
+		config.SetKey("auto.commit.interval.ms", int(cfg.CommitInterval.Milliseconds()))
+	}
+	if cfg.MaxMessageBytes > 0 {
+		config.SetKey("max.partition.fetch.bytes", cfg.MaxMessageBytes)
+	}
+	if cfg.StatsInterval > 0 {
+		config.SetKey("statistics.interval.ms", int(cfg.StatsInterval.Milliseconds()))
+	}
+	if err := applyFetch(cfg, config); err != nil {
+		return nil, err
+	}
+	if err := applySecurity(cfg, config); err != nil {
+		return nil, err
+	}
+
+	deserializers, err := newDeserializers(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	consumer, err := kafka.NewConsumer(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create consumer: %w", err)
+	}
+
+	metrics := configMetrics(cfg)
+	ctx, cancel := context.WithCancel(context.Background())
+	c := &EventConsumer{
+		consumer:  consumer,
+		groupID:   cfg.GroupID,
+		topics:    configTopics(cfg),
+		handlers:  make(map[schema.EventType]EventHandler),
+		byTopic:   make(map[string]map[schema.EventType]EventHandler),
+		byVersion: make(map[versionKey]EventHandler),
+		retry: RetryPolicy{
+			MaxRetries:     cfg.MaxRetries,
+			InitialBackoff: cfg.RetryBackoff,
+			MaxBackoff:     DefaultMaxRetryBackoff,
+			Logger:         cfg.Logger,
+			Metrics:        metrics,
+		},
+		batchSize:    cfg.BatchSize,
+		batchTimeout: cfg.BatchTimeout,
+		batch:        newPendingBatch(),
+
+		manualCommit:   manualCommit,
+		commitInterval: cfg.CommitInterval,
+
+		deadLetterInvalid: cfg.DeadLetterInvalid,
+		validateOnly:      cfg.ValidateOnly,
+		unknownTypes:      unknownTypes,
+		ordering:          ordering,
+		typeResolver:      typeResolver,
+		chainFailure:      chainFailure,
+		filter:            newTypeFilter(cfg.IncludeTypes, cfg.ExcludeTypes),
+		maxMessageBytes:   cfg.MaxMessageBytes,
+		handlerTimeout:    cfg.HandlerTimeout,
+		skipTo:            skipTo,
+
+		commitOnError:      cfg.CommitOnError,
+		commitOnErrorTypes: typeSet(cfg.CommitOnErrorTypes),
+
+		lagInterval: cfg.LagInterval,
+		done:        make(chan struct{}),
+		ctx:         ctx,
+		cancel:      cancel,
+		stop:        make(chan struct{}),
+		stopped:     make(chan struct{}),
+		seeks:       make(chan seekRequest),
+
+		logger:              cfg.Logger,
+		correlationHeader:   cfg.CorrelationHeader,
+		causationHeader:     cfg.CausationHeader,
+		tenantHeader:        cfg.TenantHeader,
+		schemaVersionHeader: cfg.SchemaVersionHeader,
+
+		concurrency: cfg.Concurrency,
+		highWater:   highWater,
+		lowWater:    lowWater,
+		limiter:     newRateLimiter(cfg.MaxEventsPerSecond, metrics),
+
+		maxInFlight: cfg.MaxInFlightPerPartition,
+		limited:     make(map[partitionKey]bool),
+
+		tracer:        newTracer(cfg),
+		deserializers: deserializers,
+		metrics:       metrics,
+	}
+
+	if !cfg.StartFromTimestamp.IsZero() {
+		c.startFrom = newStartPosition(cfg.StartFromTimestamp)
+	}
+
+	if cfg.MaxOffsetRetries > 0 && manualCommit {
+		c.poison = newPoisonTracker(cfg.MaxOffsetRetries)
+	}
+
+	if cfg.DeadLetterTopic != "" {
+		producer, err := newDeadLetterProducer(cfg, c.logger)
+		if err != nil {
+			c.Close()
+			return nil, err
+		}
+		c.dlqProducer = producer
+		c.deadLetterTopic = cfg.DeadLetterTopic
+		c.deadLetter = c.publishDeadLetter
+	}
+
+	if cfg.BrokerWaitTimeout > 0 {
+		waitCtx, cancelWait := context.WithTimeout(context.Background(), cfg.BrokerWaitTimeout)
+		err := c.WaitForBrokers(waitCtx)
+		cancelWait()
+		if err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+
+	if assigned {
+		if err := c.assignPartitions(cfg.AssignPartitions); err != nil {
+			c.Close()
+			return nil, err
+		}
+		return c, nil
+	}
+
+	if cfg.VerifyTopics || cfg.AutoCreateTopics {
+		if err := c.verifyTopics(cfg); err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+
+	// Subscribe to topics
+	err = consumer.SubscribeTopics(cfg.Topics, c.onRebalance)
+	if err != nil {
+		c.Close()
+		return nil, fmt.Errorf("failed to subscribe to topics: %w", err)
+	}
+
+	return c, nil
+}
+
+// RegisterHandler registers a handler for a specific event type on every
+// subscribed topic. When Start is called the handler is wrapped with any
+// middleware added by Use and with the consumer's retry policy.
+func (c *EventConsumer) RegisterHandler(eventType schema.EventType, handler EventHandler) {
+	c.handlersMu.Lock()
+	defer c.handlersMu.Unlock()
+	c.handlers[eventType] = handler
+}
+
+// RegisterHandlerForTopic registers a handler for an event type consumed
+// from a single topic. It takes precedence over a handler registered with
+// RegisterHandler for the same type.
+func (c *EventConsumer) RegisterHandlerForTopic(topic string, eventType schema.EventType, handler EventHandler) {
+	c.handlersMu.Lock()
+	defer c.handlersMu.Unlock()
+	if c.byTopic[topic] == nil {
+		c.byTopic[topic] = make(map[schema.EventType]EventHandler)
+	}
+	c.byTopic[topic][eventType] = handler
+}
+
+// handlerFor resolves the handler for a version of an event type on a topic:
+// a topic-specific handler first, then one for the version, then a global
+// one for the type, then the default handler. The second result names which
+// kind matched for metrics.
+func (c *EventConsumer) handlerFor(topic string, eventType schema.EventType, version int) (EventHandler, string) {
+	if handler, ok := c.byTopic[topic][eventType]; ok {
+		return handler, "topic"
+	}
+	if handler, ok := c.byVersion[versionKey{eventType, version}]; ok {
+		return handler, "version"
+	}
+	if handler, ok := c.handlers[eventType]; ok {
+		return handler, "registered"
+	}
+	if c.fallback != nil {
+		return c.fallback, "default"
+	}
+	return nil, "none"
+}
+
+// RegisterDefaultHandler registers a handler for event types that have no
+// specific handler registered. It is wrapped like RegisterHandler.
+func (c *EventConsumer) RegisterDefaultHandler(handler EventHandler) {
+	c.handlersMu.Lock()
+	defer c.handlersMu.Unlock()
+	c.fallback = handler
+}
+
+// SetDeadLetterHandler registers a handler for messages whose handler failed
+// after all retries, replacing the DeadLetterTopic publisher if configured
+func (c *EventConsumer) SetDeadLetterHandler(handler DeadLetterHandler) {
+	c.deadLetter = handler
+}
+
+// Start begins consuming events. It blocks until Shutdown or Close is
+// called.
+func (c *EventConsumer) Start() error {
+	c.logger.Info("Starting event consumer")
+	c.prepareHandlers()
+	c.running.Store(true)
+	defer close(c.stopped)
+	go c.monitorLag(c.lagInterval)
+
+	batching := c.batchHandler != nil && c.batchSize > 0
+
+	var workers *workerPool
+	if !batching && c.concurrency > 1 {
+		c.tracker = newOffsetTracker()
+		c.flight = newPartitionFlight(c.metrics)
+		workers = c.startWorkers(c.concurrency)
+	}
+
+	for {
+		select {
+		case <-c.stop:
+			if workers != nil {
+				c.drainStage.Store(stageWorkers)
+				workers.stop()
+			}
+			c.drain()
+			return nil
+		case <-c.done:
+			// Closed without draining; nothing more can be read or committed
+			if workers != nil {
+				workers.stop()
+			}
+			return nil
+		case req := <-c.seeks:
+			req.reply <- c.seek(req.tp)
+			continue
+		default:
+		}
+
+		c.checkBackpressure()
+		c.checkInFlight()
+		if c.limiter != nil {
+			c.throttleRate()
+		}
+		msg, err := c.readMessage(pollTimeout)
+		if workers != nil && c.applyRewinds(msg) {
+			continue
+		}
+		if err != nil {
+			if kafkaErr, ok := err.(kafka.Error); ok && kafkaErr.Code() == kafka.ErrTimedOut {
+				c.flushBatchIfDue()
+				continue
+			}
+			c.logger.Error("Consumer error", "error", err)
+			continue
+		}
+		if c.limiter != nil {
+			c.limiter.take(time.Now())
+		}
+		c.observeConsumed(msg)
+		if c.rawLog != nil && !c.appendRaw(msg) {
+			continue
+		}
+
+		if batching {
+			c.addToBatch(msg)
+			c.flushBatchIfDue()
+			continue
+		}
+
+		if workers != nil {
+			seq := c.tracker.begin(msg.TopicPartition)
+			c.inFlight.Add(1)
+			c.flight.add(msg.TopicPartition)
+			workers.dispatch(msg, seq)
+			continue
+		}
+
+		// Errors are logged by processMessage; continue processing
+		c.processMessage(msg)
+	}
+}
+
+// decodeMessage parses a Kafka message into an event envelope. Errors wrap
+// ErrDeserialize.
+func (c *EventConsumer) decodeMessage(msg *kafka.Message) (*schema.Event, error) {
+	raw := rawMessage(msg)
+	if err := c.transform(&raw); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrDeserialize, err)
+	}
+	event, err := c.deserializers.forTopic(raw.Topic).Deserialize(raw)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrDeserialize, err)
+	}
+	if c.typeResolver != nil {
+		if event.Type, err = c.typeResolver(raw, event); err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrDeserialize, err)
+		}
+	}
+	if event.EntityID == "" {
+		event.EntityID = string(msg.Key)
+	}
+	if event.CorrelationID == "" {
+		event.CorrelationID = headerValue(msg, c.correlationHeader)
+	}
+	if event.CausationID == "" {
+		event.CausationID = headerValue(msg, c.causationHeader)
+	}
+	if event.TenantID == "" {
+		event.TenantID = headerValue(msg, c.tenantHeader)
+	}
+	if event.Headers == nil {
+		event.Headers = raw.Headers
+	}
+	if event.RecordTimestamp.IsZero() && msg.TimestampType != kafka.TimestampNotAvailable {
+		event.RecordTimestamp = msg.Timestamp
+	}
+	if event.Topic == "" && msg.TopicPartition.Topic != nil {
+		event.Topic, event.Partition = *msg.TopicPartition.Topic, msg.TopicPartition.Partition
+	}
+	if event.Version == 0 {
+		if event.Version, err = c.schemaVersion(msg); err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrDeserialize, err)
+		}
+	}
+	return event, nil
+}
+
+// screen applies IncludeTypes/ExcludeTypes, the unknown type policy,
+// validation and ValidateOnly to a decoded event, reporting whether it
+// should be handled. commit reports whether a skipped event may be
+// committed: false means its dead letter was not delivered and it must be
+// redelivered.
+func (c *EventConsumer) screen(msg *kafka.Message, event *schema.Event) (handle, commit bool) {
+	if c.filtered(msg, event) {
+		return false, true
+	}
+	if known, ok := c.knownType(msg, event); !known {
+		return false, ok
+	}
+	if valid, ok := c.validate(msg, event); !valid {
+		return false, ok
+	}
+	return !c.validateOnly, true
+}
+
+// processMessage handles a single Kafka message
+func (c *EventConsumer) processMessage(msg *kafka.Message) (err error) {
+	ctx, span := c.startProcessSpan(msg)
+	defer func() { endSpan(span, err) }()
+
+	if isTombstone(msg) {
+		c.handleTombstone(ctx, msg)
+		return nil
+	}
+	c.observeMessageSize(msg)
+	if skip, commit := c.oversized(msg); skip {
+		c.settle(msg, commit)
+		return nil
+	}
+
+	event, err := c.decodeMessage(msg)
+	if err != nil {
+		c.handleDecodeError(ctx, msg, err)
+		return err
+	}
+	attrs := c.messageAttrs(msg, event)
+	annotateSpan(ctx, span, event)
+	c.checkOrdering(msg, event)
+
+	if handle, commit := c.screen(msg, event); !handle {
+		c.settle(msg, commit)
+		return nil
+	}
+	if err := c.enrich(ctx, msg, event); err != nil {
+		c.handleFailure(msg, err)
+		return err
+	}
+
+	c.logger.Debug("Processing event", append(attrs, "platform", event.Source)...)
+
+	// Get the appropriate handler
+	handler, kind := c.handlerFor(keyOf(msg.TopicPartition).topic, event.Type, event.Version)
+	c.metrics.eventsDispatched.WithLabelValues(kind).Inc()
+	if handler == nil {
+		c.logger.Warn("No handler registered for event type", attrs...)
+		c.ack(msg)
+		return nil // Not an error, just skip
+	}
+
+	// Call the handler
+	if err := handler(ctx, event); err != nil {
+		c.logger.Error("Handler failed", append(attrs, "error", err)...)
+		if c.commitsOnError(event.Type) {
+			c.commitFailed(msg, event, err)
+		} else {
+			c.handleFailure(msg, err)
+		}
+		return fmt.Errorf("handler failed for event %s: %w", event.ID, err)
+	}
+
+	if c.poison != nil {
+		c.poison.clear(msg.TopicPartition)
+	}
+	c.observeStored(msg)
+	c.ack(msg)
+
+	c.logger.Info("Processed event", attrs...)
+	return nil
+}
+
+// Close shuts down the consumer immediately, without draining. Prefer
+// Shutdown while Start is running. Close is safe to call more than once.
+func (c *EventConsumer) Close() error {
+	c.closeOnce.Do(func() {
+		c.cancel()
+		close(c.done)
+		c.stopCompression()
+		if c.dlqProducer != nil {
+			c.dlqProducer.Flush(5000)
+			c.dlqProducer.Close()
+		}
+		c.closeErr = c.consumer.Close()
+	})
+	return c.closeErr
+}
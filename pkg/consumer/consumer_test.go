@@ -0,0 +1,55 @@
+package consumer_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/assure-compliance/eventid/pkg/consumer/consumertest"
+	"github.com/assure-compliance/eventid/pkg/schema"
+)
+
+// Events published to the topic are consumed, stored and committed, and a
+// later member of the group resumes after them
+func TestConsumeStoreCommit(t *testing.T) {
+	t.Parallel()
+	h := consumertest.New(t, consumertest.Config{})
+	cfg := h.ConsumerConfig()
+
+	events := make([]*schema.Event, 3)
+	for i := range events {
+		events[i] = consumertest.NewEvent(t, schema.EventViolationFound)
+		h.Publish(events[i])
+	}
+
+	first := h.NewConsumer(cfg)
+	first.RegisterDefaultHandler(h.StoreHandler())
+	h.Start(first)
+	for _, event := range events {
+		h.WaitForStored(event.ID, 30*time.Second)
+	}
+	h.WaitForCommitted(cfg.GroupID, 0, 3, 30*time.Second)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := first.Shutdown(ctx); err != nil {
+		t.Fatalf("failed to shut down: %v", err)
+	}
+
+	next := consumertest.NewEvent(t, schema.EventViolationFound)
+	h.Publish(next)
+	second := h.NewConsumer(cfg)
+	second.RegisterDefaultHandler(h.StoreHandler())
+	h.Start(second)
+	h.WaitForHandled(next.ID, 30*time.Second)
+	h.WaitForCommitted(cfg.GroupID, 0, 4, 30*time.Second)
+
+	for _, event := range events {
+		if n := h.Handled(event.ID); n != 1 {
+			t.Errorf("event %s handled %d times, want 1", event.ID, n)
+		}
+	}
+	if n := len(h.Store.Events()); n != 4 {
+		t.Errorf("stored %d events, want 4", n)
+	}
+}
@@ -0,0 +1,393 @@
+// Package consumertest runs an EventConsumer against an in-process mock
+// Kafka cluster, so that tests can exercise the whole path from publishing
+// an event to storing it without a real broker:
+//
+//	h := consumertest.New(t, consumertest.Config{})
+//	c := h.NewConsumer(h.ConsumerConfig())
+//	c.RegisterDefaultHandler(h.StoreHandler())
+//	h.Start(c)
+//	h.Publish(event)
+//	h.WaitForStored(event.ID, 10*time.Second)
+package consumertest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/assure-compliance/eventid/pkg/consumer"
+	"github.com/assure-compliance/eventid/pkg/producer"
+	"github.com/assure-compliance/eventid/pkg/schema"
+	"github.com/assure-compliance/eventid/pkg/storage"
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Defaults used when Config fields are unset
+const (
+	DefaultTopic           = "events"
+	DefaultPartitions      = 1
+	DefaultShutdownTimeout = 10 * time.Second
+)
+
+// pollInterval is how often the Wait methods check their condition
+const pollInterval = 10 * time.Millisecond
+
+// Config configures a Harness
+type Config struct {
+	Topic      string // Created on the mock cluster (default DefaultTopic)
+	Partitions int    // Partitions of Topic (default DefaultPartitions)
+
+	// ShutdownTimeout bounds the drain of consumers started with Start when
+	// the test ends (default DefaultShutdownTimeout)
+	ShutdownTimeout time.Duration
+}
+
+// Harness owns a mock cluster, a producer for its topic and an in-memory
+// store. Everything it creates is closed when the test ends. The metrics of
+// the store, the producer and consumers made from ConsumerConfig are
+// registered with Registry, so they start at zero and tests can read them.
+type Harness struct {
+	t               testing.TB
+	Cluster         *kafka.MockCluster
+	Topic           string
+	Partitions      int
+	Store           *storage.InMemoryStore
+	Registry        *prometheus.Registry
+	shutdownTimeout time.Duration
+
+	consumerMetrics *consumer.Metrics
+
+	logger *slog.Logger
+	done   atomic.Bool // Set once the harness is cleaned up; later logs are dropped
+
+	producerOnce sync.Once
+	producer     *producer.EventProducer
+
+	rawOnce     sync.Once
+	rawProducer *kafka.Producer // For PublishRaw
+
+	probesMu sync.Mutex
+	probes   map[string]*kafka.Consumer // By group, for Committed
+
+	mu      sync.Mutex
+	handled map[string]int // Calls that returned nil, by event ID
+}
+
+// New starts a single-broker mock cluster with cfg.Topic and returns a
+// harness for it
+func New(t testing.TB, cfg Config) *Harness {
+	t.Helper()
+	if cfg.Topic == "" {
+		cfg.Topic = DefaultTopic
+	}
+	if cfg.Partitions <= 0 {
+		cfg.Partitions = DefaultPartitions
+	}
+	if cfg.ShutdownTimeout <= 0 {
+		cfg.ShutdownTimeout = DefaultShutdownTimeout
+	}
+
+	cluster, err := kafka.NewMockCluster(1)
+	if err != nil {
+		t.Fatalf("failed to start mock cluster: %v", err)
+	}
+	if err := cluster.CreateTopic(cfg.Topic, cfg.Partitions, 1); err != nil {
+		cluster.Close()
+		t.Fatalf("failed to create topic %s: %v", cfg.Topic, err)
+	}
+
+	registry := prometheus.NewRegistry()
+	h := &Harness{
+		t:               t,
+		Cluster:         cluster,
+		Topic:           cfg.Topic,
+		Partitions:      cfg.Partitions,
+		Store:           storage.NewInMemoryStoreWithMetrics(storage.NewMetrics(registry, "", "")),
+		Registry:        registry,
+		shutdownTimeout: cfg.ShutdownTimeout,
+		consumerMetrics: consumer.NewMetrics(registry, "", ""),
+		handled:         make(map[string]int),
+	}
+	h.logger = slog.New(slog.NewTextHandler(testWriter{h}, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	t.Cleanup(func() {
+		if h.producer != nil {
+			h.producer.Close()
+		}
+		if h.rawProducer != nil {
+			h.rawProducer.Close()
+		}
+		for _, probe := range h.probes {
+			probe.Close()
+		}
+		cluster.Close()
+		h.done.Store(true)
+	})
+	return h
+}
+
+// Logger returns a logger writing to the test log
+func (h *Harness) Logger() *slog.Logger {
+	return h.logger
+}
+
+// ConsumerConfig returns settings for a consumer of the harness topic in a
+// group of its own, reading from the earliest offset
+func (h *Harness) ConsumerConfig() consumer.Config {
+	return consumer.Config{
+		BootstrapServers: h.Cluster.BootstrapServers(),
+		GroupID:          "consumertest-" + strings.ReplaceAll(h.t.Name(), "/", "-"),
+		Topics:           []string{h.Topic},
+		AutoOffsetReset:  "earliest",
+		Logger:           h.logger,
+		Metrics:          h.consumerMetrics,
+	}
+}
+
+// NewConsumer creates a consumer from cfg, failing the test on error. It
+// is closed when the test ends.
+func (h *Harness) NewConsumer(cfg consumer.Config) *consumer.EventConsumer {
+	h.t.Helper()
+	c, err := consumer.NewEventConsumer(cfg)
+	if err != nil {
+		h.t.Fatalf("failed to create consumer: %v", err)
+	}
+	h.t.Cleanup(func() { c.Close() })
+	return c
+}
+
+// Start runs c.Start in the background. When the test ends c is shut
+// down, and the test fails if Start returned an error or the drain timed
+// out.
+func (h *Harness) Start(c *consumer.EventConsumer) {
+	started := make(chan error, 1)
+	go func() { started <- c.Start() }()
+
+	h.t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), h.shutdownTimeout)
+		defer cancel()
+		if err := c.Shutdown(ctx); err != nil {
+			h.t.Errorf("consumer shutdown failed: %v", err)
+		}
+		select {
+		case err := <-started:
+			if err != nil {
+				h.t.Errorf("consumer Start failed: %v", err)
+			}
+		case <-ctx.Done():
+			h.t.Errorf("consumer Start did not return after shutdown")
+		}
+	})
+}
+
+// StoreHandler returns a handler storing events in h.Store, treating
+// duplicates as stored, and recording them for WaitForHandled
+func (h *Harness) StoreHandler() consumer.EventHandler {
+	return h.Record(func(ctx context.Context, event *schema.Event) error {
+		if err := h.Store.StoreEvent(ctx, event); err != nil && !errors.Is(err, storage.ErrDuplicateEvent) {
+			return err
+		}
+		return nil
+	})
+}
+
+// Record wraps handler so that every call returning nil is recorded for
+// WaitForHandled and Handled
+func (h *Harness) Record(handler consumer.EventHandler) consumer.EventHandler {
+	return func(ctx context.Context, event *schema.Event) error {
+		if err := handler(ctx, event); err != nil {
+			return err
+		}
+		h.mu.Lock()
+		h.handled[event.ID]++
+		h.mu.Unlock()
+		return nil
+	}
+}
+
+// Handled returns how many times a recorded handler succeeded for eventID
+func (h *Harness) Handled(eventID string) int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.handled[eventID]
+}
+
+// NewEvent returns an event of eventType with a new ID, timestamped now and
+// keyed by its ID, failing the test if no ID can be generated
+func NewEvent(t testing.TB, eventType schema.EventType) *schema.Event {
+	t.Helper()
+	id, err := schema.GenerateUUIDv7()
+	if err != nil {
+		t.Fatalf("failed to generate event ID: %v", err)
+	}
+	return &schema.Event{
+		Type:      eventType,
+		ID:        id,
+		Version:   1,
+		Timestamp: time.Now().UTC().Truncate(time.Microsecond),
+		Source:    string(schema.PlatformScan),
+		EntityID:  id,
+	}
+}
+
+// Publish publishes event to the harness topic with producer.EventProducer,
+// failing the test on error
+func (h *Harness) Publish(event *schema.Event) {
+	h.t.Helper()
+	h.producerOnce.Do(func() {
+		p, err := producer.NewEventProducer(producer.Config{
+			BootstrapServers: h.Cluster.BootstrapServers(),
+			Topic:            h.Topic,
+			Logger:           h.logger,
+			Metrics:          producer.NewMetrics(h.Registry, "", ""),
+		})
+		if err != nil {
+			h.t.Fatalf("failed to create producer: %v", err)
+		}
+		h.producer = p
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), h.shutdownTimeout)
+	defer cancel()
+	if err := h.producer.Publish(ctx, event); err != nil {
+		h.t.Fatalf("failed to publish event %s: %v", event.ID, err)
+	}
+}
+
+// PublishRaw publishes value to partition 0 of the harness topic as is,
+// e.g. an undecodable or empty message, failing the test unless it is
+// delivered
+func (h *Harness) PublishRaw(key, value []byte) {
+	h.t.Helper()
+	h.rawOnce.Do(func() {
+		p, err := kafka.NewProducer(&kafka.ConfigMap{"bootstrap.servers": h.Cluster.BootstrapServers()})
+		if err != nil {
+			h.t.Fatalf("failed to create raw producer: %v", err)
+		}
+		h.rawProducer = p
+	})
+
+	deliveryChan := make(chan kafka.Event, 1)
+	err := h.rawProducer.Produce(&kafka.Message{
+		TopicPartition: kafka.TopicPartition{Topic: &h.Topic, Partition: 0},
+		Key:            key,
+		Value:          value,
+	}, deliveryChan)
+	if err != nil {
+		h.t.Fatalf("failed to publish raw message: %v", err)
+	}
+	select {
+	case e := <-deliveryChan:
+		if err := e.(*kafka.Message).TopicPartition.Error; err != nil {
+			h.t.Fatalf("failed to deliver raw message: %v", err)
+		}
+	case <-time.After(h.shutdownTimeout):
+		h.t.Fatalf("raw message was not delivered within %s", h.shutdownTimeout)
+	}
+}
+
+// WaitForStored fails the test unless h.Store holds eventID within timeout
+func (h *Harness) WaitForStored(eventID string, timeout time.Duration) {
+	h.t.Helper()
+	err := h.WaitFor(timeout, func() bool {
+		_, err := h.Store.GetEventByID(eventID)
+		return err == nil
+	})
+	if err != nil {
+		h.t.Fatalf("event %s was not stored: %v", eventID, err)
+	}
+}
+
+// WaitForHandled fails the test unless a recorded handler succeeds for
+// eventID within timeout
+func (h *Harness) WaitForHandled(eventID string, timeout time.Duration) {
+	h.t.Helper()
+	if err := h.WaitFor(timeout, func() bool { return h.Handled(eventID) > 0 }); err != nil {
+		h.t.Fatalf("event %s was not handled: %v", eventID, err)
+	}
+}
+
+// Committed returns the offset group has committed on each partition of the
+// harness topic, in partition order, with kafka.OffsetInvalid where it has
+// committed none, failing the test on error
+func (h *Harness) Committed(groupID string) []kafka.Offset {
+	h.t.Helper()
+	h.probesMu.Lock()
+	defer h.probesMu.Unlock()
+
+	probe, ok := h.probes[groupID]
+	if !ok {
+		var err error
+		probe, err = kafka.NewConsumer(&kafka.ConfigMap{
+			"bootstrap.servers": h.Cluster.BootstrapServers(),
+			"group.id":          groupID,
+		})
+		if err != nil {
+			h.t.Fatalf("failed to create offset probe: %v", err)
+		}
+		if h.probes == nil {
+			h.probes = make(map[string]*kafka.Consumer)
+		}
+		h.probes[groupID] = probe
+	}
+
+	partitions := make([]kafka.TopicPartition, h.Partitions)
+	for i := range partitions {
+		partitions[i] = kafka.TopicPartition{Topic: &h.Topic, Partition: int32(i)}
+	}
+	committed, err := probe.Committed(partitions, int(h.shutdownTimeout.Milliseconds()))
+	if err != nil {
+		h.t.Fatalf("failed to read committed offsets of %s: %v", groupID, err)
+	}
+	offsets := make([]kafka.Offset, h.Partitions)
+	for _, tp := range committed {
+		offsets[tp.Partition] = tp.Offset
+	}
+	return offsets
+}
+
+// WaitForCommitted fails the test unless group commits offset, or later,
+// on partition within timeout
+func (h *Harness) WaitForCommitted(groupID string, partition int32, offset kafka.Offset, timeout time.Duration) {
+	h.t.Helper()
+	var committed kafka.Offset
+	err := h.WaitFor(timeout, func() bool {
+		committed = h.Committed(groupID)[partition]
+		return committed >= offset
+	})
+	if err != nil {
+		h.t.Fatalf("group %s committed offset %d on partition %d, want %d: %v", groupID, committed, partition, offset, err)
+	}
+}
+
+// WaitFor polls cond until it returns true, returning an error if timeout
+// passes first
+func (h *Harness) WaitFor(timeout time.Duration, cond func() bool) error {
+	deadline := time.Now().Add(timeout)
+	for !cond() {
+		if time.Now().After(deadline) {
+			return fmt.Errorf("timed out after %s", timeout)
+		}
+		time.Sleep(pollInterval)
+	}
+	return nil
+}
+
+// testWriter sends log lines to the test log until the harness is cleaned
+// up, after which the testing package no longer accepts them
+type testWriter struct {
+	h *Harness
+}
+
+func (w testWriter) Write(p []byte) (int, error) {
+	if !w.h.done.Load() {
+		w.h.t.Log(strings.TrimRight(string(p), "\n"))
+	}
+	return len(p), nil
+}
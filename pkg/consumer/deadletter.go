@@ -0,0 +1,164 @@
+package consumer
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+)
+
+// Headers attached to dead-lettered messages. The message value and key are
+// copied unchanged from the original record so it can be replayed as-is.
+const (
+	HeaderDLQError             = "dlq-error"
+	HeaderDLQRetryCount        = "dlq-retry-count"
+	HeaderDLQOriginalTopic     = "dlq-original-topic"
+	HeaderDLQOriginalPartition = "dlq-original-partition"
+	HeaderDLQOriginalOffset    = "dlq-original-offset"
+	HeaderDLQFailedAt          = "dlq-failed-at"
+)
+
+// DeadLetter describes a message read back from the dead-letter topic
+type DeadLetter struct {
+	Key               []byte
+	Value             []byte // Original message bytes
+	Error             string
+	RetryCount        int
+	OriginalTopic     string
+	OriginalPartition int32
+	OriginalOffset    int64
+	FailedAt          time.Time
+}
+
+// ParseDeadLetter extracts the original payload and failure metadata from a
+// message consumed from the dead-letter topic
+func ParseDeadLetter(msg *kafka.Message) (*DeadLetter, error) {
+	dl := &DeadLetter{
+		Key:   msg.Key,
+		Value: msg.Value,
+	}
+
+	for _, h := range msg.Headers {
+		value := string(h.Value)
+		var err error
+		switch h.Key {
+		case HeaderDLQError:
+			dl.Error = value
+		case HeaderDLQRetryCount:
+			dl.RetryCount, err = strconv.Atoi(value)
+		case HeaderDLQOriginalTopic:
+			dl.OriginalTopic = value
+		case HeaderDLQOriginalPartition:
+			var partition int64
+			partition, err = strconv.ParseInt(value, 10, 32)
+			dl.OriginalPartition = int32(partition)
+		case HeaderDLQOriginalOffset:
+			dl.OriginalOffset, err = strconv.ParseInt(value, 10, 64)
+		case HeaderDLQFailedAt:
+			dl.FailedAt, err = time.Parse(time.RFC3339Nano, value)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid dead-letter header %s: %w", h.Key, err)
+		}
+	}
+
+	if dl.OriginalTopic == "" {
+		return nil, fmt.Errorf("message is missing header %s", HeaderDLQOriginalTopic)
+	}
+
+	return dl, nil
+}
+
+// newDeadLetterProducer creates the producer used to publish to the
+// dead-letter topic
+func newDeadLetterProducer(cfg Config, logger Logger) (*kafka.Producer, error) {
+	config := &kafka.ConfigMap{
+		"bootstrap.servers":  cfg.BootstrapServers,
+		"acks":               "all",
+		"enable.idempotence": true,
+	}
+	if err := applySecurity(cfg, config); err != nil {
+		return nil, err
+	}
+
+	producer, err := kafka.NewProducer(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create dead-letter producer: %w", err)
+	}
+
+	// Delivery reports are read synchronously in publishDeadLetter; this
+	// drains client-level errors
+	go func() {
+		for e := range producer.Events() {
+			if kafkaErr, ok := e.(kafka.Error); ok {
+				logger.Error("Dead-letter producer error", "error", kafkaErr)
+			}
+		}
+	}()
+
+	return producer, nil
+}
+
+// publishDeadLetter produces the original message plus failure metadata to
+// the dead-letter topic and waits for the delivery report
+func (c *EventConsumer) publishDeadLetter(msg *kafka.Message, handlerErr error) error {
+	retryCount := 0
+	var retryErr *RetryError
+	if errors.As(handlerErr, &retryErr) {
+		retryCount = retryErr.Attempts - 1
+	}
+
+	originalTopic := ""
+	if msg.TopicPartition.Topic != nil {
+		originalTopic = *msg.TopicPartition.Topic
+	}
+
+	headers := make([]kafka.Header, 0, len(msg.Headers)+6)
+	headers = append(headers, msg.Headers...)
+	dlMsg := &kafka.Message{
+		TopicPartition: kafka.TopicPartition{Topic: &c.deadLetterTopic, Partition: kafka.PartitionAny},
+		Key:            msg.Key,
+		Value:          msg.Value,
+		Headers: append(headers,
+			kafka.Header{Key: HeaderDLQError, Value: []byte(handlerErr.Error())},
+			kafka.Header{Key: HeaderDLQRetryCount, Value: []byte(strconv.Itoa(retryCount))},
+			kafka.Header{Key: HeaderDLQOriginalTopic, Value: []byte(originalTopic)},
+			kafka.Header{Key: HeaderDLQOriginalPartition, Value: []byte(strconv.FormatInt(int64(msg.TopicPartition.Partition), 10))},
+			kafka.Header{Key: HeaderDLQOriginalOffset, Value: []byte(strconv.FormatInt(int64(msg.TopicPartition.Offset), 10))},
+			kafka.Header{Key: HeaderDLQFailedAt, Value: []byte(time.Now().UTC().Format(time.RFC3339Nano))},
+		),
+	}
+
+	deliveryChan := make(chan kafka.Event, 1)
+	if err := c.dlqProducer.Produce(dlMsg, deliveryChan); err != nil {
+		c.metrics.Errors.WithLabelValues("dead_letter").Inc()
+		return fmt.Errorf("failed to produce dead letter: %w", err)
+	}
+
+	delivered := (<-deliveryChan).(*kafka.Message)
+	if delivered.TopicPartition.Error != nil {
+		c.metrics.Errors.WithLabelValues("dead_letter").Inc()
+		return fmt.Errorf("failed to deliver dead letter: %w", delivered.TopicPartition.Error)
+	}
+
+	c.metrics.deadLettered.Inc()
+	c.logger.Warn("Dead-lettered message", append(c.messageAttrs(msg, nil), "dead_letter_topic", c.deadLetterTopic)...)
+	return nil
+}
+
+// sendDeadLetter passes msg to the dead-letter handler, if one is set, and
+// reports whether it may be committed: false means dead-lettering failed and
+// the caller must redeliver msg, or in batch mode rewind its batch, so it is
+// not lost
+func (c *EventConsumer) sendDeadLetter(msg *kafka.Message, err error) bool {
+	if c.deadLetter == nil {
+		return true
+	}
+	if dlqErr := c.deadLetter(msg, err); dlqErr != nil {
+		c.logger.Error("Failed to dead-letter message", append(c.messageAttrs(msg, nil), "error", dlqErr)...)
+		return false
+	}
+	return true
+}
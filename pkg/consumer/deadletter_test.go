@@ -0,0 +1,192 @@
+package consumer_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/assure-compliance/eventid/pkg/consumer"
+	"github.com/assure-compliance/eventid/pkg/consumer/consumertest"
+	"github.com/assure-compliance/eventid/pkg/schema"
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+)
+
+// A message whose dead letter cannot be delivered must be redelivered, not
+// committed, so it is dead-lettered once the dead-letter topic is back
+func TestDeadLetterFailureRedelivers(t *testing.T) {
+	t.Parallel()
+	h := consumertest.New(t, consumertest.Config{})
+	c := h.NewConsumer(h.ConsumerConfig())
+	c.RegisterDefaultHandler(func(context.Context, *schema.Event) error {
+		return errors.New("handler failed")
+	})
+
+	deadLetter, delivered, attempts := flakyDeadLetter()
+	c.SetDeadLetterHandler(deadLetter)
+	h.Start(c)
+
+	h.Publish(consumertest.NewEvent(t, schema.EventViolationFound))
+	select {
+	case offset := <-delivered:
+		if offset != 0 {
+			t.Errorf("dead-lettered offset %d, want 0", offset)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatalf("message was not dead-lettered after %d attempts", attempts.Load())
+	}
+	if n := attempts.Load(); n != 2 {
+		t.Errorf("dead-letter handler called %d times, want 2", n)
+	}
+}
+
+// flakyDeadLetter returns a dead-letter handler whose first call fails,
+// sending the offsets of later calls to the returned channel, and a count
+// of its calls
+func flakyDeadLetter() (consumer.DeadLetterHandler, <-chan kafka.Offset, *atomic.Int32) {
+	var attempts atomic.Int32
+	delivered := make(chan kafka.Offset, 1)
+	return func(msg *kafka.Message, _ error) error {
+		if attempts.Add(1) == 1 {
+			return errors.New("dead-letter topic unavailable")
+		}
+		delivered <- msg.TopicPartition.Offset
+		return nil
+	}, delivered, &attempts
+}
+
+// Messages dead-lettered before reaching a handler are redelivered, not
+// committed, when their dead letter cannot be delivered
+func TestDeadLetterFailureBeforeHandlerRedelivers(t *testing.T) {
+	t.Parallel()
+	validated := schema.EventType("deadletter_test.validated")
+	if err := schema.RegisterSchema(validated, []byte(`{"required": ["approved_by"]}`)); err != nil {
+		t.Fatalf("failed to register schema: %v", err)
+	}
+
+	for _, tc := range []struct {
+		name      string
+		eventType schema.EventType
+		config    func(*consumer.Config)
+		enricher  consumer.Enricher
+	}{
+		{
+			name:      "oversized",
+			eventType: schema.EventViolationFound,
+			config:    func(cfg *consumer.Config) { cfg.MaxMessageBytes = 10 },
+		},
+		{
+			name:      "invalid",
+			eventType: validated,
+			config:    func(cfg *consumer.Config) { cfg.DeadLetterInvalid = true },
+		},
+		{
+			name:      "unknown type",
+			eventType: "deadletter_test.unknown",
+			config:    func(cfg *consumer.Config) { cfg.UnknownTypePolicy = consumer.UnknownTypeDeadLetter },
+		},
+		{
+			name:      "enrichment failed",
+			eventType: schema.EventViolationFound,
+			enricher: func(context.Context, *schema.Event) error {
+				return errors.New("geo lookup timed out")
+			},
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			h := consumertest.New(t, consumertest.Config{})
+			cfg := h.ConsumerConfig()
+			if tc.config != nil {
+				tc.config(&cfg)
+			}
+			c := h.NewConsumer(cfg)
+			if tc.enricher != nil {
+				c.RegisterEnricher(tc.enricher)
+			}
+			c.RegisterDefaultHandler(h.StoreHandler())
+			deadLetter, delivered, attempts := flakyDeadLetter()
+			c.SetDeadLetterHandler(deadLetter)
+			h.Start(c)
+
+			event := consumertest.NewEvent(t, tc.eventType)
+			h.Publish(event)
+			select {
+			case offset := <-delivered:
+				if offset != 0 {
+					t.Errorf("dead-lettered offset %d, want 0", offset)
+				}
+			case <-time.After(10 * time.Second):
+				t.Fatalf("message was not dead-lettered after %d attempts", attempts.Load())
+			}
+			h.WaitForCommitted(cfg.GroupID, 0, 1, 10*time.Second)
+			if n := attempts.Load(); n != 2 {
+				t.Errorf("dead-letter handler called %d times, want 2", n)
+			}
+			if n := h.Handled(event.ID); n != 0 {
+				t.Errorf("event handled %d times, want 0", n)
+			}
+		})
+	}
+}
+
+// In batch mode a tombstone or undecodable message whose handler fails is
+// not committed with the batch until its dead letter is delivered
+func TestBatchDeadLetterFailureRewinds(t *testing.T) {
+	t.Parallel()
+	for _, tc := range []struct {
+		name    string
+		value   []byte
+		handler func(*consumer.EventConsumer)
+	}{
+		{
+			name: "tombstone",
+			handler: func(c *consumer.EventConsumer) {
+				c.SetTombstoneHandler(func(context.Context, string, []byte) error {
+					return errors.New("delete failed")
+				})
+			},
+		},
+		{
+			name:  "decode error",
+			value: []byte("not an event"),
+			handler: func(c *consumer.EventConsumer) {
+				c.SetDecodeErrorHandler(func(context.Context, *kafka.Message, error) error {
+					return errors.New("quarantine store unavailable")
+				})
+			},
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			h := consumertest.New(t, consumertest.Config{})
+			cfg := h.ConsumerConfig()
+			cfg.BatchSize = 10
+			cfg.BatchTimeout = 100 * time.Millisecond
+
+			c := h.NewConsumer(cfg)
+			c.RegisterBatchHandler(func(context.Context, []*schema.Event) error { return nil })
+			tc.handler(c)
+			deadLetter, delivered, attempts := flakyDeadLetter()
+			c.SetDeadLetterHandler(deadLetter)
+			h.Start(c)
+
+			h.PublishRaw([]byte("key"), tc.value)
+			select {
+			case offset := <-delivered:
+				if offset != 0 {
+					t.Errorf("dead-lettered offset %d, want 0", offset)
+				}
+			case <-time.After(10 * time.Second):
+				t.Fatalf("message was not dead-lettered after %d attempts", attempts.Load())
+			}
+			h.WaitForCommitted(cfg.GroupID, 0, 1, 10*time.Second)
+			if n := attempts.Load(); n != 2 {
+				t.Errorf("dead-letter handler called %d times, want 2", n)
+			}
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package consumer
+
+import (
+	"context"
+
+	"github.com/assure-compliance/eventid/pkg/schema"
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+)
+
+// Enricher adds derived data to a decoded event, such as a hashed tenant
+// ID, by modifying it in place. Fields added to the payload are stored with
+// it; see schema.Event.SetField.
+type Enricher func(ctx context.Context, event *schema.Event) error
+
+// RegisterEnricher adds an enricher run on every event that passes
+// filtering and validation, before it reaches a handler or a batch.
+// Enrichers run in registration order. A failing enricher is retried under
+// the handler retry policy; if it still fails or panics the remaining
+// enrichers are skipped and the message is handled like a failed event
+// handler: dead-lettered, redelivered or skipped after MaxOffsetRetries.
+// RegisterEnricher must be called before Start.
+func (c *EventConsumer) RegisterEnricher(enricher Enricher) {
+	c.enrichers = append(c.enrichers, enricher)
+}
+
+// enrich runs the enrichers on event, returning the error of one that still
+// failed after retries. The caller should then skip the event without
+// invoking handlers and dispose of it as a handler failure.
+func (c *EventConsumer) enrich(ctx context.Context, msg *kafka.Message, event *schema.Event) error {
+	for _, enricher := range c.enrichers {
+		err := c.retry.do(ctx, func() error {
+			return c.callEnricher(ctx, enricher, msg, event)
+		}, nil, c.messageAttrs(msg, event)...)
+		if err != nil {
+			c.metrics.Errors.WithLabelValues("enrichment").Inc()
+			c.logger.Error("Failed to enrich event", append(c.messageAttrs(msg, event), "error", err)...)
+			return err
+		}
+	}
+	return nil
+}
+
+// callEnricher calls enricher, recovering a panic as an error
+func (c *EventConsumer) callEnricher(ctx context.Context, enricher Enricher, msg *kafka.Message, event *schema.Event) (err error) {
+	defer recoverPanic(c.metrics, c.logger, &err, c.messageAttrs(msg, event)...)
+	return enricher(ctx, event)
+}
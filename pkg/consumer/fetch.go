@@ -0,0 +1,57 @@
+package consumer
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+)
+
+// Ranges librdkafka accepts for the fetch settings
+const (
+	maxFetchMinBytes  = 100000000
+	maxFetchMaxBytes  = 2147483135
+	maxFetchMaxWait   = 300 * time.Second
+	maxPollRecords    = 10000000
+	receiveOverhead   = 512       // receive.message.max.bytes must exceed fetch.max.bytes by this much
+	defaultReceiveMax = 100000000 // librdkafka's default receive.message.max.bytes
+)
+
+// applyFetch copies the fetch tuning settings from cfg into config. Zero
+// values keep the client defaults; values librdkafka would reject are
+// returned as errors.
+func applyFetch(cfg Config, config *kafka.ConfigMap) error {
+	if cfg.FetchMinBytes < 0 || cfg.FetchMinBytes > maxFetchMinBytes {
+		return fmt.Errorf("invalid FetchMinBytes %d: must be between 1 and %d", cfg.FetchMinBytes, maxFetchMinBytes)
+	}
+	if cfg.FetchMaxBytes < 0 || cfg.FetchMaxBytes > maxFetchMaxBytes {
+		return fmt.Errorf("invalid FetchMaxBytes %d: must be between 1 and %d", cfg.FetchMaxBytes, maxFetchMaxBytes)
+	}
+	if cfg.FetchMaxBytes > 0 && cfg.FetchMaxBytes < cfg.FetchMinBytes {
+		return fmt.Errorf("invalid FetchMaxBytes %d: must not be less than FetchMinBytes %d",
+			cfg.FetchMaxBytes, cfg.FetchMinBytes)
+	}
+	if cfg.FetchMaxWait < 0 || cfg.FetchMaxWait > maxFetchMaxWait {
+		return fmt.Errorf("invalid FetchMaxWait %s: must be between 1ms and %s", cfg.FetchMaxWait, maxFetchMaxWait)
+	}
+	if cfg.MaxPollRecords < 0 || cfg.MaxPollRecords > maxPollRecords {
+		return fmt.Errorf("invalid MaxPollRecords %d: must be between 1 and %d", cfg.MaxPollRecords, maxPollRecords)
+	}
+
+	if cfg.FetchMinBytes > 0 {
+		config.SetKey("fetch.min.bytes", cfg.FetchMinBytes)
+	}
+	if cfg.FetchMaxBytes > 0 {
+		config.SetKey("fetch.max.bytes", cfg.FetchMaxBytes)
+		if cfg.FetchMaxBytes+receiveOverhead > defaultReceiveMax {
+			config.SetKey("receive.message.max.bytes", cfg.FetchMaxBytes+receiveOverhead)
+		}
+	}
+	if cfg.FetchMaxWait > 0 {
+		config.SetKey("fetch.wait.max.ms", int(cfg.FetchMaxWait.Milliseconds()))
+	}
+	if cfg.MaxPollRecords > 0 {
+		config.SetKey("queued.min.messages", cfg.MaxPollRecords)
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package consumer
+
+import (
+	"github.com/assure-compliance/eventid/pkg/schema"
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+)
+
+// typeFilter decides which event types are handled. A nil include set
+// admits every type not excluded.
+type typeFilter struct {
+	include map[schema.EventType]bool
+	exclude map[schema.EventType]bool
+}
+
+func newTypeFilter(include, exclude []schema.EventType) typeFilter {
+	return typeFilter{include: typeSet(include), exclude: typeSet(exclude)}
+}
+
+func typeSet(types []schema.EventType) map[schema.EventType]bool {
+	if len(types) == 0 {
+		return nil
+	}
+	set := make(map[schema.EventType]bool, len(types))
+	for _, t := range types {
+		set[t] = true
+	}
+	return set
+}
+
+func (f typeFilter) allows(eventType schema.EventType) bool {
+	if f.include != nil && !f.include[eventType] {
+		return false
+	}
+	return !f.exclude[eventType]
+}
+
+// filtered reports whether event is dropped by IncludeTypes/ExcludeTypes.
+// Dropped events are counted; the caller should skip them without invoking
+// handlers but still commit their offsets.
+func (c *EventConsumer) filtered(msg *kafka.Message, event *schema.Event) bool {
+	if c.filter.allows(event.Type) {
+		return false
+	}
+
+	c.metrics.filteredEvents.WithLabelValues(string(event.Type)).Inc()
+	c.logger.Debug("Filtered event", c.messageAttrs(msg, event)...)
+	return true
+}
@@ -0,0 +1,98 @@
+package consumer
+
+import (
+	"fmt"
+
+	"github.com/assure-compliance/eventid/pkg/schema"
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+)
+
+// Wire formats accepted by Config.Format and Config.TopicFormats
+const (
+	FormatJSON     = "json"
+	FormatAvro     = "avro"
+	FormatProtobuf = "protobuf"
+)
+
+// deserializers selects the deserializer for each topic
+type deserializers struct {
+	byTopic  map[string]schema.Deserializer
+	fallback schema.Deserializer
+}
+
+// newDeserializers builds a deserializer for cfg.Format and each distinct
+// format in cfg.TopicFormats, sharing one instance per format
+func newDeserializers(cfg Config) (*deserializers, error) {
+	built := make(map[string]schema.Deserializer)
+	get := func(format string) (schema.Deserializer, error) {
+		if format == "" {
+			format = FormatJSON
+		}
+		if d, ok := built[format]; ok {
+			return d, nil
+		}
+		d, err := newDeserializer(format, cfg)
+		if err != nil {
+			return nil, err
+		}
+		built[format] = d
+		return d, nil
+	}
+
+	fallback, err := get(cfg.Format)
+	if err != nil {
+		return nil, err
+	}
+	d := &deserializers{byTopic: make(map[string]schema.Deserializer), fallback: fallback}
+	for topic, format := range cfg.TopicFormats {
+		if d.byTopic[topic], err = get(format); err != nil {
+			return nil, fmt.Errorf("topic %s: %w", topic, err)
+		}
+	}
+	return d, nil
+}
+
+// newDeserializer returns the deserializer for format
+func newDeserializer(format string, cfg Config) (schema.Deserializer, error) {
+	switch format {
+	case FormatJSON:
+		return schema.JSONDeserializer{}, nil
+	case FormatAvro:
+		return schema.NewAvroDeserializer(schema.RegistryConfig{
+			URL:      cfg.SchemaRegistryURL,
+			Username: cfg.SchemaRegistryUsername,
+			Password: cfg.SchemaRegistryPassword,
+		})
+	case FormatProtobuf:
+		d := schema.NewProtobufDeserializer()
+		for eventType, msg := range cfg.ProtobufTypes {
+			d.Register(eventType, msg)
+		}
+		return d, nil
+	default:
+		return nil, fmt.Errorf("unsupported message format %q", format)
+	}
+}
+
+// forTopic returns the deserializer for topic
+func (d *deserializers) forTopic(topic string) schema.Deserializer {
+	if deserializer, ok := d.byTopic[topic]; ok {
+		return deserializer
+	}
+	return d.fallback
+}
+
+// rawMessage converts a Kafka message for a schema.Deserializer
+func rawMessage(msg *kafka.Message) schema.Message {
+	raw := schema.Message{Value: msg.Value}
+	if msg.TopicPartition.Topic != nil {
+		raw.Topic = *msg.TopicPartition.Topic
+	}
+	if len(msg.Headers) > 0 {
+		raw.Headers = make(map[string]string, len(msg.Headers))
+		for _, h := range msg.Headers {
+			raw.Headers[h.Key] = string(h.Value)
+		}
+	}
+	return raw
+}
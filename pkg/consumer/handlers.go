@@ -0,0 +1,67 @@
+package consumer
+
+import (
+	"sort"
+
+	"github.com/assure-compliance/eventid/pkg/schema"
+)
+
+// HandlerInfo describes a registered event handler
+type HandlerInfo struct {
+	EventType schema.EventType `json:"event_type"`
+	Topic     string           `json:"topic,omitempty"`    // Empty for handlers on every topic
+	Version   int              `json:"version,omitempty"`  // Schema version; 0 for every version
+	Filtered  bool             `json:"filtered,omitempty"` // Dropped by IncludeTypes/ExcludeTypes
+}
+
+// RegisteredTypes returns the event types with a registered handler, sorted
+// by topic, type and version. Handlers from RegisterHandler have no Topic or
+// Version. It is safe to call while the consumer is running.
+func (c *EventConsumer) RegisteredTypes() []HandlerInfo {
+	c.handlersMu.RLock()
+	defer c.handlersMu.RUnlock()
+
+	var infos []HandlerInfo
+	for eventType := range c.handlers {
+		infos = append(infos, HandlerInfo{EventType: eventType, Filtered: !c.filter.allows(eventType)})
+	}
+	for eventType := range c.chains {
+		if _, ok := c.handlers[eventType]; !ok {
+			infos = append(infos, HandlerInfo{EventType: eventType, Filtered: !c.filter.allows(eventType)})
+		}
+	}
+	for key := range c.byVersion {
+		infos = append(infos, HandlerInfo{EventType: key.eventType, Version: key.version, Filtered: !c.filter.allows(key.eventType)})
+	}
+	for topic, handlers := range c.byTopic {
+		for eventType := range handlers {
+			infos = append(infos, HandlerInfo{EventType: eventType, Topic: topic, Filtered: !c.filter.allows(eventType)})
+		}
+	}
+
+	sort.Slice(infos, func(i, j int) bool {
+		if infos[i].Topic != infos[j].Topic {
+			return infos[i].Topic < infos[j].Topic
+		}
+		if infos[i].EventType != infos[j].EventType {
+			return infos[i].EventType < infos[j].EventType
+		}
+		return infos[i].Version < infos[j].Version
+	})
+	return infos
+}
+
+// HasDefaultHandler reports whether a default handler is registered for
+// event types without a specific handler
+func (c *EventConsumer) HasDefaultHandler() bool {
+	c.handlersMu.RLock()
+	defer c.handlersMu.RUnlock()
+	return c.fallback != nil
+}
+
+// HasBatchHandler reports whether the consumer is in batch mode
+func (c *EventConsumer) HasBatchHandler() bool {
+	c.handlersMu.RLock()
+	defer c.handlersMu.RUnlock()
+	return c.batchHandler != nil && c.batchSize > 0
+}
@@ -0,0 +1,78 @@
+package consumer
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// healthCheckTimeoutMs bounds the broker metadata request made by Healthy
+const healthCheckTimeoutMs = 2000
+
+// commitHealth tracks whether offset commits are failing
+type commitHealth struct {
+	mu    sync.Mutex
+	err   error     // Latest failure, nil once a commit succeeds
+	since time.Time // First failure since the last successful commit
+}
+
+func (h *commitHealth) succeeded() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.err = nil
+}
+
+func (h *commitHealth) failed(err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.err == nil {
+		h.since = time.Now()
+	}
+	h.err = err
+}
+
+// check returns an error if no commit has succeeded since the last one that
+// failed
+func (h *commitHealth) check() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.err == nil {
+		return nil
+	}
+	return fmt.Errorf("offset commits failing since %s: %w", h.since.UTC().Format(time.RFC3339), h.err)
+}
+
+// Healthy reports an error if the consume loop is not running, the brokers
+// cannot be reached or offset commits have been failing since the last
+// successful one
+func (c *EventConsumer) Healthy() error {
+	if !c.running.Load() {
+		return errors.New("consumer is not running")
+	}
+	select {
+	case <-c.stopped:
+		return errors.New("consumer has stopped")
+	default:
+	}
+	if err := c.commits.check(); err != nil {
+		return err
+	}
+
+	if _, err := c.consumer.GetMetadata(nil, false, healthCheckTimeoutMs); err != nil {
+		return fmt.Errorf("failed to reach Kafka brokers: %w", err)
+	}
+	return nil
+}
+
+// Ready reports an error until the consumer is healthy and has joined its
+// consumer group and received a partition assignment
+func (c *EventConsumer) Ready() error {
+	if err := c.Healthy(); err != nil {
+		return err
+	}
+	if !c.joined.Load() {
+		return errors.New("consumer has not joined the group")
+	}
+	return nil
+}
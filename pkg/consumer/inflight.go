@@ -0,0 +1,185 @@
+package consumer
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+)
+
+// validateMaxInFlight checks the MaxInFlightPerPartition setting
+func validateMaxInFlight(cfg Config) error {
+	if cfg.MaxInFlightPerPartition < 0 {
+		return errors.New("MaxInFlightPerPartition must not be negative")
+	}
+	return nil
+}
+
+// partitionFlight counts the messages of each partition dispatched to
+// workers and not yet handled
+type partitionFlight struct {
+	mu      sync.Mutex
+	counts  map[partitionKey]int
+	waiters []flightWaiter // See drained
+	metrics *Metrics
+}
+
+// flightWaiter is a channel to close once partitions have nothing in flight
+type flightWaiter struct {
+	partitions []partitionKey
+	drained    chan struct{}
+}
+
+func newPartitionFlight(metrics *Metrics) *partitionFlight {
+	return &partitionFlight{counts: make(map[partitionKey]int), metrics: metrics}
+}
+
+// add counts a message of tp as in flight
+func (f *partitionFlight) add(tp kafka.TopicPartition) {
+	f.update(keyOf(tp), 1)
+}
+
+// done counts a message of tp as handled. Revoked partitions are not
+// forgotten, as their workers still finish or skip each queued message.
+func (f *partitionFlight) done(tp kafka.TopicPartition) {
+	f.update(keyOf(tp), -1)
+}
+
+func (f *partitionFlight) update(key partitionKey, delta int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	n := f.counts[key] + delta
+	if n <= 0 {
+		n = 0
+		delete(f.counts, key)
+	} else {
+		f.counts[key] = n
+	}
+	f.metrics.partitionInFlight.WithLabelValues(key.topic, strconv.Itoa(int(key.partition))).Set(float64(n))
+
+	if n > 0 || len(f.waiters) == 0 {
+		return
+	}
+	waiting := f.waiters[:0]
+	for _, w := range f.waiters {
+		if f.idle(w.partitions) {
+			close(w.drained)
+		} else {
+			waiting = append(waiting, w)
+		}
+	}
+	f.waiters = waiting
+}
+
+// drained returns a channel closed once no message of partitions is in
+// flight, including messages still queued for a worker
+func (f *partitionFlight) drained(partitions []kafka.TopicPartition) <-chan struct{} {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	w := flightWaiter{partitions: make([]partitionKey, len(partitions)), drained: make(chan struct{})}
+	for i, tp := range partitions {
+		w.partitions[i] = keyOf(tp)
+	}
+	if f.idle(w.partitions) {
+		close(w.drained)
+	} else {
+		f.waiters = append(f.waiters, w)
+	}
+	return w.drained
+}
+
+// idle reports whether partitions have nothing in flight; f.mu must be held
+func (f *partitionFlight) idle(partitions []partitionKey) bool {
+	for _, key := range partitions {
+		if f.counts[key] > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// atLeast returns the partitions with max or more messages in flight
+func (f *partitionFlight) atLeast(max int) map[partitionKey]bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	over := make(map[partitionKey]bool)
+	for key, n := range f.counts {
+		if n >= max {
+			over[key] = true
+		}
+	}
+	return over
+}
+
+// checkInFlight pauses each partition with MaxInFlightPerPartition messages
+// in flight and resumes it once one of them is handled. Unlike backpressure
+// only the partition at the cap is held back, so a slow key does not stall
+// the rest of the assignment. It runs on the Start goroutine.
+func (c *EventConsumer) checkInFlight() {
+	if c.maxInFlight == 0 || c.flight == nil {
+		return
+	}
+	over := c.flight.atLeast(c.maxInFlight)
+
+	c.pauseMu.Lock()
+	defer c.pauseMu.Unlock()
+	var pause, resume []kafka.TopicPartition
+	for key := range over {
+		if !c.limited[key] {
+			c.limited[key] = true
+			pause = append(pause, key.at(kafka.OffsetInvalid))
+		}
+	}
+	for key := range c.limited {
+		if !over[key] {
+			delete(c.limited, key)
+			resume = append(resume, key.at(kafka.OffsetInvalid))
+		}
+	}
+
+	// While paused or held every partition is already paused, and is resumed
+	// with the rest unless still at the cap
+	if c.paused || c.held != 0 {
+		return
+	}
+	if len(pause) > 0 {
+		if err := c.consumer.Pause(pause); err != nil {
+			c.logger.Error("Failed to pause partitions at in-flight limit", "partitions", partitionList(pause), "error", err)
+		} else {
+			c.logger.Debug("In-flight limit reached, pausing partitions", "partitions", partitionList(pause), "max_in_flight", c.maxInFlight)
+		}
+	}
+	if len(resume) > 0 {
+		if err := c.consumer.Resume(resume); err != nil {
+			c.logger.Error("Failed to resume partitions below in-flight limit", "partitions", partitionList(resume), "error", err)
+		} else {
+			c.logger.Debug("In-flight limit cleared, resuming partitions", "partitions", partitionList(resume))
+		}
+	}
+}
+
+// unlimited returns partitions without those paused at the in-flight limit.
+// pauseMu must be held.
+func (c *EventConsumer) unlimited(partitions []kafka.TopicPartition) []kafka.TopicPartition {
+	if len(c.limited) == 0 {
+		return partitions
+	}
+	kept := make([]kafka.TopicPartition, 0, len(partitions))
+	for _, tp := range partitions {
+		if !c.limited[keyOf(tp)] {
+			kept = append(kept, tp)
+		}
+	}
+	return kept
+}
+
+// forgetLimited drops revoked partitions from those paused at the in-flight
+// limit; a partition assigned again starts unpaused
+func (c *EventConsumer) forgetLimited(partitions []kafka.TopicPartition) {
+	c.pauseMu.Lock()
+	defer c.pauseMu.Unlock()
+	for _, tp := range partitions {
+		delete(c.limited, keyOf(tp))
+	}
+}
@@ -0,0 +1,54 @@
+package consumer
+
+import (
+	"testing"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// A revoke waits on drained until the last message of every revoked
+// partition is done, whatever other partitions still have in flight
+func TestPartitionFlightDrained(t *testing.T) {
+	t.Parallel()
+	flight := newPartitionFlight(NewMetrics(prometheus.NewRegistry(), "", ""))
+	topic := "events"
+	tp := func(partition int32) kafka.TopicPartition {
+		return kafka.TopicPartition{Topic: &topic, Partition: partition}
+	}
+	isClosed := func(ch <-chan struct{}) bool {
+		select {
+		case <-ch:
+			return true
+		default:
+			return false
+		}
+	}
+
+	if !isClosed(flight.drained([]kafka.TopicPartition{tp(0)})) {
+		t.Error("idle partition not drained")
+	}
+
+	flight.add(tp(0))
+	flight.add(tp(0))
+	flight.add(tp(1))
+	flight.add(tp(2))
+	revoked := flight.drained([]kafka.TopicPartition{tp(0), tp(1)})
+
+	flight.done(tp(0))
+	flight.done(tp(2))
+	if isClosed(revoked) {
+		t.Fatal("drained with messages of revoked partitions in flight")
+	}
+	flight.done(tp(1))
+	if isClosed(revoked) {
+		t.Fatal("drained with a message of partition 0 in flight")
+	}
+	flight.done(tp(0))
+	if !isClosed(revoked) {
+		t.Error("not drained after the last message of the revoked partitions")
+	}
+	if len(flight.waiters) != 0 {
+		t.Errorf("%d waiters left", len(flight.waiters))
+	}
+}
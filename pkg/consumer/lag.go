@@ -0,0 +1,76 @@
+package consumer
+
+import (
+	"strconv"
+	"time"
+)
+
+// DefaultLagInterval is how often consumer lag is sampled
+const DefaultLagInterval = 30 * time.Second
+
+// lagQueryTimeoutMs bounds each broker offset query made while sampling lag
+const lagQueryTimeoutMs = 5000
+
+// monitorLag samples lag for the assigned partitions every interval until
+// the consumer is closed
+func (c *EventConsumer) monitorLag(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	reported := make(map[partitionKey]bool)
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+			reported = c.updateLag(reported)
+		}
+	}
+}
+
+// updateLag sets the lag gauge to the high-water mark minus the committed
+// offset for each assigned partition, and removes gauges for partitions that
+// are no longer assigned. It returns the set of partitions reported.
+func (c *EventConsumer) updateLag(previous map[partitionKey]bool) map[partitionKey]bool {
+	assigned, err := c.consumer.Assignment()
+	if err != nil {
+		c.logger.Warn("Failed to read partition assignment", "error", err)
+		return previous
+	}
+
+	committed, err := c.consumer.Committed(assigned, lagQueryTimeoutMs)
+	if err != nil {
+		c.logger.Warn("Failed to read committed offsets", "error", err)
+		return previous
+	}
+
+	current := make(map[partitionKey]bool, len(committed))
+	for _, tp := range committed {
+		key := keyOf(tp)
+		low, high, err := c.consumer.QueryWatermarkOffsets(key.topic, key.partition, lagQueryTimeoutMs)
+		if err != nil {
+			c.logger.Warn("Failed to query watermarks", "topic", key.topic, "partition", key.partition, "error", err)
+			continue
+		}
+
+		// With no committed offset the group would start from the low mark
+		position := int64(tp.Offset)
+		if position < 0 {
+			position = low
+		}
+		lag := high - position
+		if lag < 0 {
+			lag = 0
+		}
+
+		c.metrics.consumerLag.WithLabelValues(key.topic, strconv.Itoa(int(key.partition))).Set(float64(lag))
+		current[key] = true
+	}
+
+	for key := range previous {
+		if !current[key] {
+			c.metrics.consumerLag.DeleteLabelValues(key.topic, strconv.Itoa(int(key.partition)))
+		}
+	}
+	return current
+}
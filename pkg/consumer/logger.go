@@ -0,0 +1,70 @@
+package consumer
+
+import (
+	"log/slog"
+	"os"
+
+	"github.com/assure-compliance/eventid/pkg/schema"
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+)
+
+// DefaultCorrelationHeader is the Kafka header read for correlation IDs
+const DefaultCorrelationHeader = "correlation-id"
+
+// DefaultCausationHeader is the Kafka header read for causation IDs
+const DefaultCausationHeader = "causation-id"
+
+// DefaultTenantHeader is the Kafka header read for event tenant IDs
+const DefaultTenantHeader = "tenant-id"
+
+// Logger is the structured logger used by the consumer. Arguments after the
+// message are alternating keys and values, as with log/slog; *slog.Logger
+// satisfies this interface.
+type Logger interface {
+	Debug(msg string, args ...any)
+	Info(msg string, args ...any)
+	Warn(msg string, args ...any)
+	Error(msg string, args ...any)
+}
+
+// defaultLogger writes JSON log lines to stderr
+func defaultLogger() Logger {
+	return slog.New(slog.NewJSONHandler(os.Stderr, nil))
+}
+
+// messageAttrs returns log attributes identifying msg and, when it has been
+// decoded, its event. The correlation ID comes from the configured header,
+// falling back to the event's correlation_id field.
+func (c *EventConsumer) messageAttrs(msg *kafka.Message, event *schema.Event) []any {
+	key := keyOf(msg.TopicPartition)
+	attrs := []any{
+		"topic", key.topic,
+		"partition", key.partition,
+		"offset", int64(msg.TopicPartition.Offset),
+	}
+
+	correlationID := headerValue(msg, c.correlationHeader)
+	if event != nil {
+		attrs = append(attrs, "event_id", event.ID, "event_type", string(event.Type))
+		if event.TenantID != "" {
+			attrs = append(attrs, "tenant_id", event.TenantID)
+		}
+		if correlationID == "" {
+			correlationID = event.CorrelationID
+		}
+	}
+	if correlationID != "" {
+		attrs = append(attrs, "correlation_id", correlationID)
+	}
+	return attrs
+}
+
+// headerValue returns the value of the first header named key, or ""
+func headerValue(msg *kafka.Message, key string) string {
+	for _, h := range msg.Headers {
+		if h.Key == key {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
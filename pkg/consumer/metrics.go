@@ -0,0 +1,380 @@
+package consumer
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Metrics holds the package's Prometheus metrics. Create them with
+// NewMetrics and pass them in Config.Metrics to register a consumer's
+// metrics with a registry of your own, e.g. to run several consumers in one
+// process or to read them in tests.
+type Metrics struct {
+	// Errors counts consumer errors by type. It is exported so that handlers
+	// can record their own failures under the same metric.
+	Errors *prometheus.CounterVec
+
+	handlerRetries          prometheus.Counter
+	deadLettered            prometheus.Counter
+	eventsDispatched        *prometheus.CounterVec
+	rebalances              *prometheus.CounterVec
+	filteredEvents          *prometheus.CounterVec
+	messageBytes            *prometheus.HistogramVec
+	oversizedMessages       prometheus.Counter
+	outOfOrderEvents        *prometheus.CounterVec
+	skippedEvents           prometheus.Counter
+	unknownTypeEvents       *prometheus.CounterVec
+	validationPassed        *prometheus.CounterVec
+	validationFailed        *prometheus.CounterVec
+	tombstones              *prometheus.CounterVec
+	transformedMessages     *prometheus.CounterVec
+	committedOnError        *prometheus.CounterVec
+	skippedRange            *prometheus.CounterVec
+	consumerPaused          prometheus.Gauge
+	bufferedMessages        prometheus.Gauge
+	backpressurePaused      prometheus.Gauge
+	processingRate          prometheus.Gauge
+	batchSizes              prometheus.Histogram
+	batchFlushes            *prometheus.CounterVec
+	handlerDuration         *prometheus.HistogramVec
+	kafkaReplyQueue         prometheus.Gauge
+	kafkaBrokerRTT          *prometheus.GaugeVec
+	kafkaBrokerThrottle     *prometheus.GaugeVec
+	kafkaBrokerOutbuf       *prometheus.GaugeVec
+	kafkaFetchQueueMessages *prometheus.GaugeVec
+	kafkaFetchQueueBytes    *prometheus.GaugeVec
+	consumerLag             *prometheus.GaugeVec
+
+	// Per-partition series, removed when the partition is revoked
+	partitionConsumed *prometheus.CounterVec
+	partitionStored   *prometheus.CounterVec
+	partitionLag      *prometheus.GaugeVec
+	partitionInFlight *prometheus.GaugeVec
+}
+
+var (
+	defaultMetrics     *Metrics
+	defaultMetricsOnce sync.Once
+)
+
+// DefaultMetrics returns the metrics registered with the default registry,
+// used where none are configured. They are created on first use.
+func DefaultMetrics() *Metrics {
+	defaultMetricsOnce.Do(func() {
+		defaultMetrics = NewMetrics(prometheus.DefaultRegisterer, "", "")
+	})
+	return defaultMetrics
+}
+
+// configMetrics returns cfg.Metrics, or DefaultMetrics if unset
+func configMetrics(cfg Config) *Metrics {
+	if cfg.Metrics == nil {
+		return DefaultMetrics()
+	}
+	return cfg.Metrics
+}
+
+// NewMetrics creates the package's metrics and registers them with reg.
+// Their names are prefixed by namespace and subsystem through
+// prometheus.Opts, so that namespace "billing" turns
+// event_consumer_errors_total into billing_event_consumer_errors_total;
+// either may be empty. Like promauto, it panics if the metrics are already
+// registered with reg.
+func NewMetrics(reg prometheus.Registerer, namespace, subsystem string) *Metrics {
+	f := promauto.With(reg)
+	m := &Metrics{}
+	m.Errors = f.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "event_consumer_errors_total",
+			Help:      "Total number of consumer errors",
+		},
+		[]string{"error_type"},
+	)
+	m.handlerRetries = f.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "event_consumer_handler_retries_total",
+		Help:      "Total number of handler retries after a failed attempt",
+	})
+	m.deadLettered = f.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "event_consumer_dead_lettered_total",
+		Help:      "Total number of messages published to the dead-letter topic",
+	})
+	m.eventsDispatched = f.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "event_consumer_dispatched_total",
+			Help:      "Total number of events dispatched, by handler kind (topic, registered, default, none)",
+		},
+		[]string{"handler"},
+	)
+	m.rebalances = f.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "regulatory_events_rebalances_total",
+			Help:      "Total number of consumer group rebalance callbacks, by type (assigned, revoked)",
+		},
+		[]string{"type"},
+	)
+	m.filteredEvents = f.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "regulatory_events_filtered_total",
+			Help:      "Total number of events dropped by IncludeTypes/ExcludeTypes, by event type",
+		},
+		[]string{"event_type"},
+	)
+	m.messageBytes = f.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "regulatory_event_message_bytes",
+			Help:      "Size of consumed message values in bytes, by topic and the topic's compression.type",
+			// 64B to 16MiB
+			Buckets: prometheus.ExponentialBuckets(64, 4, 10),
+		},
+		[]string{"topic", "compression"},
+	)
+	m.oversizedMessages = f.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "regulatory_events_oversized_total",
+		Help:      "Total number of messages rejected for exceeding MaxMessageBytes",
+	})
+	m.outOfOrderEvents = f.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "regulatory_events_out_of_order_total",
+			Help:      "Total number of events older than the previous event with the same source and key, by source",
+		},
+		[]string{"source"},
+	)
+	m.skippedEvents = f.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "regulatory_events_skipped_total",
+		Help:      "Total number of messages skipped after failing more than MaxOffsetRetries times",
+	})
+	m.unknownTypeEvents = f.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "regulatory_events_unknown_type_total",
+			Help:      "Total number of events of a type the schema package does not recognize, by action (stored, dead_lettered, dropped)",
+		},
+		[]string{"action"},
+	)
+	m.validationPassed = f.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "validation_pass_total",
+			Help:      "Total number of events that passed schema.Validate, by event type",
+		},
+		[]string{"event_type"},
+	)
+	m.validationFailed = f.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "validation_fail_total",
+			Help:      "Total number of events that failed schema.Validate, by event type",
+		},
+		[]string{"event_type"},
+	)
+	m.tombstones = f.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "event_consumer_tombstones_total",
+			Help:      "Total number of messages with a null or empty value, by topic",
+		},
+		[]string{"topic"},
+	)
+	m.transformedMessages = f.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "event_consumer_transformed_messages_total",
+			Help:      "Total number of messages rewritten by a Transformer before deserialization, by topic",
+		},
+		[]string{"topic"},
+	)
+	m.committedOnError = f.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "regulatory_events_committed_on_error_total",
+			Help:      "Total number of failed events whose offsets were committed under CommitOnError, by event type",
+		},
+		[]string{"event_type"},
+	)
+	m.skippedRange = f.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "event_consumer_skipped_range_messages_total",
+			Help:      "Total number of offsets skipped by SkipTo, by topic",
+		},
+		[]string{"topic"},
+	)
+	m.consumerPaused = f.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "event_consumer_paused",
+		Help:      "1 while the consumer is paused, 0 otherwise",
+	})
+	m.bufferedMessages = f.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "event_consumer_buffered_messages",
+		Help:      "Messages read but not yet handled, queued for workers or waiting in a batch",
+	})
+	m.backpressurePaused = f.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "event_consumer_backpressure_paused",
+		Help:      "1 while fetching is paused because buffered messages reached BackpressureHighWater, 0 otherwise",
+	})
+	m.processingRate = f.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "event_consumer_processing_rate",
+		Help:      "Messages read (or replayed) per second over the last second, while MaxEventsPerSecond is set",
+	})
+	m.batchSizes = f.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "event_consumer_batch_size",
+		Help:      "Number of events in each batch when it is flushed",
+		// 1 to 4096
+		Buckets: prometheus.ExponentialBuckets(1, 2, 13),
+	})
+	m.batchFlushes = f.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "event_consumer_batch_flushes_total",
+			Help:      "Total number of batch flushes, by reason (size, timeout, shutdown, rebalance, seek)",
+		},
+		[]string{"reason"},
+	)
+	m.handlerDuration = f.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "event_consumer_handler_duration_seconds",
+			Help:      "Time taken by each handler call, by event type and result (success, failure)",
+			// 1ms to ~4s
+			Buckets: prometheus.ExponentialBuckets(0.001, 2, 13),
+		},
+		[]string{"event_type", "result"},
+	)
+	m.kafkaReplyQueue = f.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "event_consumer_kafka_replyq",
+		Help:      "Kafka client operations waiting to be served by Poll, from librdkafka statistics",
+	})
+	m.kafkaBrokerRTT = f.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "event_consumer_kafka_broker_rtt_seconds",
+			Help:      "Broker request round-trip time over the last statistics interval, by broker and stat (avg, p99)",
+		},
+		[]string{"broker", "stat"},
+	)
+	m.kafkaBrokerThrottle = f.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "event_consumer_kafka_broker_throttle_seconds",
+			Help:      "Broker throttling time over the last statistics interval, by broker and stat (avg, p99)",
+		},
+		[]string{"broker", "stat"},
+	)
+	m.kafkaBrokerOutbuf = f.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "event_consumer_kafka_broker_outbuf_requests",
+			Help:      "Requests waiting to be sent to each broker",
+		},
+		[]string{"broker"},
+	)
+	m.kafkaFetchQueueMessages = f.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "event_consumer_kafka_fetchq_messages",
+			Help:      "Messages fetched from the broker and waiting to be consumed, by topic and partition",
+		},
+		[]string{"topic", "partition"},
+	)
+	m.kafkaFetchQueueBytes = f.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "event_consumer_kafka_fetchq_bytes",
+			Help:      "Bytes fetched from the broker and waiting to be consumed, by topic and partition",
+		},
+		[]string{"topic", "partition"},
+	)
+	m.consumerLag = f.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "regulatory_events_consumer_lag",
+			Help:      "Messages between the high-water mark and the committed offset",
+		},
+		[]string{"topic", "partition"},
+	)
+	m.partitionConsumed = f.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "event_consumer_partition_consumed_total",
+			Help:      "Total number of messages read from each assigned partition",
+		},
+		[]string{"topic", "partition"},
+	)
+	m.partitionStored = f.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "event_consumer_partition_stored_total",
+			Help:      "Total number of messages from each assigned partition whose handler succeeded",
+		},
+		[]string{"topic", "partition"},
+	)
+	m.partitionLag = f.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "event_consumer_partition_lag",
+			Help:      "Messages between the last message read from each assigned partition and its high-water mark as last fetched",
+		},
+		[]string{"topic", "partition"},
+	)
+	m.partitionInFlight = f.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "event_consumer_partition_in_flight_messages",
+			Help:      "Messages from each assigned partition dispatched to workers and not yet handled",
+		},
+		[]string{"topic", "partition"},
+	)
+	return m
+}
@@ -0,0 +1,181 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"time"
+
+	"github.com/assure-compliance/eventid/pkg/schema"
+)
+
+// Middleware wraps an EventHandler to add behaviour around every call, such
+// as logging, metrics or tracing
+type Middleware func(EventHandler) EventHandler
+
+// Use adds middleware applied to every registered handler, including the
+// default handler. Middleware added first is outermost. Each attempt made by
+// the retry policy passes through the whole chain, and panics anywhere in it
+// are recovered as with Recover. Use must be called before Start.
+func (c *EventConsumer) Use(middleware ...Middleware) {
+	c.middleware = append(c.middleware, middleware...)
+}
+
+// wrap applies the middleware chain, panic recovery and retry policy to
+// handler, so a panic is retried and dead-lettered like any handler error
+func (c *EventConsumer) wrap(handler EventHandler) EventHandler {
+	for i := len(c.middleware) - 1; i >= 0; i-- {
+		handler = c.middleware[i](handler)
+	}
+	handler = c.metrics.Recover(c.logger)(handler)
+	if c.handlerTimeout > 0 {
+		handler = c.metrics.Timeout(c.handlerTimeout)(handler)
+	}
+	return WithRetry(handler, c.retry)
+}
+
+// prepareHandlers wraps every registered handler once, when Start is called
+func (c *EventConsumer) prepareHandlers() {
+	c.prepareOnce.Do(func() {
+		c.handlersMu.Lock()
+		defer c.handlersMu.Unlock()
+
+		c.buildChains()
+		for eventType, handler := range c.handlers {
+			c.handlers[eventType] = c.wrap(handler)
+		}
+		for _, handlers := range c.byTopic {
+			for eventType, handler := range handlers {
+				handlers[eventType] = c.wrap(handler)
+			}
+		}
+		for key, handler := range c.byVersion {
+			c.byVersion[key] = c.wrap(handler)
+		}
+		if c.fallback != nil {
+			c.fallback = c.wrap(c.fallback)
+		}
+	})
+}
+
+// PanicError is returned by a handler wrapped with Recover that panicked
+type PanicError struct {
+	Value interface{}
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("handler panicked: %v", e.Value)
+}
+
+// Recover returns middleware that turns a panic in the handler into a
+// *PanicError, logging the stack and counting it as a "panic" error. The
+// consumer applies it to every handler; it is exported for handlers used
+// outside a consumer, and counts panics in DefaultMetrics.
+func Recover(logger Logger) Middleware {
+	return DefaultMetrics().Recover(logger)
+}
+
+// Recover is like the package-level Recover but counts panics in m
+func (m *Metrics) Recover(logger Logger) Middleware {
+	if logger == nil {
+		logger = defaultLogger()
+	}
+	return func(next EventHandler) EventHandler {
+		return func(ctx context.Context, event *schema.Event) (err error) {
+			defer recoverPanic(m, logger, &err, "event_id", event.ID, "event_type", string(event.Type))
+			return next(ctx, event)
+		}
+	}
+}
+
+// recoverPanic must be deferred directly. It recovers a panic, logs it with
+// attrs, counts it in m and sets *err to a *PanicError.
+func recoverPanic(m *Metrics, logger Logger, err *error, attrs ...any) {
+	r := recover()
+	if r == nil {
+		return
+	}
+
+	panicErr := &PanicError{Value: r, Stack: debug.Stack()}
+	m.Errors.WithLabelValues("panic").Inc()
+	logger.Error("Handler panicked", append(attrs, "panic", fmt.Sprint(r), "stack", string(panicErr.Stack))...)
+	*err = panicErr
+}
+
+// ErrHandlerTimeout is returned, wrapped, by a handler that did not finish
+// within its timeout
+var ErrHandlerTimeout = errors.New("handler timed out")
+
+// Timeout returns middleware that gives each handler call a context with
+// deadline d and returns an error wrapping ErrHandlerTimeout, counted as a
+// "timeout" error, if the call has not returned by then. The consumer
+// applies it inside the retry policy when HandlerTimeout is set, so each
+// attempt gets its own deadline. A handler that ignores its context keeps
+// running in the background after the timeout, so handlers should stop
+// promptly once ctx is done. Timeouts are counted in DefaultMetrics.
+func Timeout(d time.Duration) Middleware {
+	return DefaultMetrics().Timeout(d)
+}
+
+// Timeout is like the package-level Timeout but counts timeouts in m
+func (m *Metrics) Timeout(d time.Duration) Middleware {
+	return func(next EventHandler) EventHandler {
+		return func(ctx context.Context, event *schema.Event) error {
+			return callWithTimeout(m, ctx, d, func(ctx context.Context) error {
+				return next(ctx, event)
+			})
+		}
+	}
+}
+
+// callWithTimeout calls fn with a context that expires after d, returning
+// once fn does or the deadline passes. Cancellation of the parent context
+// is returned as is rather than as a timeout. Timeouts are counted in m.
+func callWithTimeout(m *Metrics, parent context.Context, d time.Duration, fn func(ctx context.Context) error) error {
+	ctx, cancel := context.WithTimeout(parent, d)
+	defer cancel()
+
+	result := make(chan error, 1)
+	go func() { result <- fn(ctx) }()
+
+	select {
+	case err := <-result:
+		if err != nil && parent.Err() == nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			m.Errors.WithLabelValues("timeout").Inc()
+			return fmt.Errorf("%w after %s: %w", ErrHandlerTimeout, d, err)
+		}
+		return err
+	case <-ctx.Done():
+		if parent.Err() != nil {
+			return parent.Err()
+		}
+		m.Errors.WithLabelValues("timeout").Inc()
+		return fmt.Errorf("%w after %s", ErrHandlerTimeout, d)
+	}
+}
+
+// Timing returns middleware that records each handler call in
+// event_consumer_handler_duration_seconds of DefaultMetrics by event type
+// and result
+func Timing() Middleware {
+	return DefaultMetrics().Timing()
+}
+
+// Timing is like the package-level Timing but records the calls in m
+func (m *Metrics) Timing() Middleware {
+	return func(next EventHandler) EventHandler {
+		return func(ctx context.Context, event *schema.Event) error {
+			started := time.Now()
+			err := next(ctx, event)
+
+			result := "success"
+			if err != nil {
+				result = "failure"
+			}
+			m.handlerDuration.WithLabelValues(string(event.Type), result).Observe(time.Since(started).Seconds())
+			return err
+		}
+	}
+}
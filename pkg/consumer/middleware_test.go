@@ -0,0 +1,82 @@
+package consumer_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/assure-compliance/eventid/pkg/consumer/consumertest"
+	"github.com/assure-compliance/eventid/pkg/schema"
+)
+
+// A handler slower than HandlerTimeout has its context cancelled at the
+// deadline and is retried, and the timeout is counted
+func TestHandlerTimeoutRetriesSlowHandler(t *testing.T) {
+	t.Parallel()
+	h := consumertest.New(t, consumertest.Config{})
+	cfg := h.ConsumerConfig()
+	cfg.HandlerTimeout = 100 * time.Millisecond
+	cfg.MaxRetries = 1
+	cfg.RetryBackoff = 10 * time.Millisecond
+
+	var attempts atomic.Int32
+	timedOut := make(chan error, 1)
+	store := h.StoreHandler()
+	c := h.NewConsumer(cfg)
+	c.RegisterDefaultHandler(func(ctx context.Context, event *schema.Event) error {
+		if attempts.Add(1) == 1 {
+			<-ctx.Done() // Deliberately slow: hangs until the deadline
+			timedOut <- ctx.Err()
+			return ctx.Err()
+		}
+		return store(ctx, event)
+	})
+	h.Start(c)
+
+	event := consumertest.NewEvent(t, schema.EventViolationFound)
+	started := time.Now()
+	h.Publish(event)
+	h.WaitForStored(event.ID, 10*time.Second)
+
+	select {
+	case err := <-timedOut:
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("slow attempt's context ended with %v, want deadline exceeded", err)
+		}
+	default:
+		t.Fatal("slow attempt did not return")
+	}
+	if n := attempts.Load(); n != 2 {
+		t.Errorf("handler called %d times, want 2", n)
+	}
+	if elapsed := time.Since(started); elapsed < cfg.HandlerTimeout {
+		t.Errorf("stored after %s, before the %s timeout", elapsed, cfg.HandlerTimeout)
+	}
+	if n := errorCount(t, h, "timeout"); n != 1 {
+		t.Errorf("counted %v timeouts, want 1", n)
+	}
+}
+
+// errorCount returns event_consumer_errors_total for errorType
+func errorCount(t *testing.T, h *consumertest.Harness, errorType string) float64 {
+	t.Helper()
+	families, err := h.Registry.Gather()
+	if err != nil {
+		t.Fatalf("failed to gather metrics: %v", err)
+	}
+	for _, family := range families {
+		if family.GetName() != "event_consumer_errors_total" {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			for _, label := range metric.GetLabel() {
+				if label.GetName() == "error_type" && label.GetValue() == errorType {
+					return metric.GetCounter().GetValue()
+				}
+			}
+		}
+	}
+	return 0
+}
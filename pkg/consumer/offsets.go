@@ -0,0 +1,181 @@
+package consumer
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+)
+
+// offsetTracker computes the committable offset of each partition when
+// messages complete out of order. A partition's offset only advances past a
+// message once it and every message read before it have completed.
+type offsetTracker struct {
+	mu         sync.Mutex
+	partitions map[partitionKey]*partitionOffsets
+	rewinds    map[partitionKey]kafka.Offset // Requested by workers, see requestRewind
+	seq        atomic.Uint64                 // Numbers the messages passed to begin
+}
+
+// partitionOffsets tracks the in-flight messages of one partition. Its mutex
+// is also held while committing so commits for a partition never regress.
+type partitionOffsets struct {
+	mu      sync.Mutex
+	pending []kafka.Offset // In read order
+	done    map[kafka.Offset]bool
+	begun   map[kafka.Offset]uint64 // Sequence number of each pending offset
+	next    kafka.Offset            // Offset read next, unless rewound
+}
+
+func newOffsetTracker() *offsetTracker {
+	return &offsetTracker{
+		partitions: make(map[partitionKey]*partitionOffsets),
+		rewinds:    make(map[partitionKey]kafka.Offset),
+	}
+}
+
+func (t *offsetTracker) partition(key partitionKey) *partitionOffsets {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	p, ok := t.partitions[key]
+	if !ok {
+		p = &partitionOffsets{
+			done:  make(map[kafka.Offset]bool),
+			begun: make(map[kafka.Offset]uint64),
+		}
+		t.partitions[key] = p
+	}
+	return p
+}
+
+// begin records that the message at tp was read and returns its sequence
+// number, for superseded; it must be called in read order before the
+// message is handed to a worker
+func (t *offsetTracker) begin(tp kafka.TopicPartition) uint64 {
+	seq := t.seq.Add(1)
+	p := t.partition(keyOf(tp))
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.pending = append(p.pending, tp.Offset)
+	p.begun[tp.Offset] = seq
+	p.next = tp.Offset + 1
+	return seq
+}
+
+// superseded reports whether the message begun as seq at tp is to be read
+// again, so a worker must drop it rather than handle it ahead of the
+// redelivered messages before it: a rewind of its partition to it or an
+// earlier offset is pending or was applied after it was begun, or the
+// partition was revoked or sought.
+func (t *offsetTracker) superseded(tp kafka.TopicPartition, seq uint64) bool {
+	key := keyOf(tp)
+	t.mu.Lock()
+	p, ok := t.partitions[key]
+	rewind, rewinding := t.rewinds[key]
+	t.mu.Unlock()
+	if !ok || rewinding && rewind <= tp.Offset {
+		return true
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.begun[tp.Offset] != seq
+}
+
+// complete marks the message at tp as handled and calls commit with the next
+// offset to consume if the partition's committable offset advanced. Messages
+// forgotten by rewind or revoke are ignored.
+func (t *offsetTracker) complete(tp kafka.TopicPartition, commit func(kafka.TopicPartition)) {
+	key := keyOf(tp)
+	p := t.partition(key)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !p.isPending(tp.Offset) {
+		return
+	}
+	p.done[tp.Offset] = true
+	advanced := false
+	var next kafka.Offset
+	for len(p.pending) > 0 && p.done[p.pending[0]] {
+		delete(p.done, p.pending[0])
+		delete(p.begun, p.pending[0])
+		next = p.pending[0] + 1
+		p.pending = p.pending[1:]
+		advanced = true
+	}
+	if advanced {
+		commit(key.at(next))
+	}
+}
+
+func (p *partitionOffsets) isPending(offset kafka.Offset) bool {
+	for _, pending := range p.pending {
+		if pending == offset {
+			return true
+		}
+	}
+	return false
+}
+
+// rewind forgets the message at tp and everything read after it on the same
+// partition, ahead of seeking back so they are consumed again. It returns
+// false, changing nothing, when tp is past the partition's read position
+// after an earlier rewind: the message is still to be read, and seeking to
+// it would skip those before it.
+func (t *offsetTracker) rewind(tp kafka.TopicPartition) bool {
+	p := t.partition(keyOf(tp))
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if tp.Offset > p.next {
+		return false
+	}
+	p.next = tp.Offset
+	for i, offset := range p.pending {
+		if offset >= tp.Offset {
+			for _, dropped := range p.pending[i:] {
+				delete(p.done, dropped)
+				delete(p.begun, dropped)
+			}
+			p.pending = p.pending[:i]
+			return true
+		}
+	}
+	return true
+}
+
+// requestRewind asks for the partition of tp to be rewound to it by the
+// goroutine calling begin, which takes the request with takeRewinds. Of
+// several requests for a partition the earliest offset is kept.
+func (t *offsetTracker) requestRewind(tp kafka.TopicPartition) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	key := keyOf(tp)
+	if offset, ok := t.rewinds[key]; !ok || tp.Offset < offset {
+		t.rewinds[key] = tp.Offset
+	}
+}
+
+// takeRewinds returns and clears the requested rewinds
+func (t *offsetTracker) takeRewinds() map[partitionKey]kafka.Offset {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.rewinds) == 0 {
+		return nil
+	}
+	rewinds := t.rewinds
+	t.rewinds = make(map[partitionKey]kafka.Offset)
+	return rewinds
+}
+
+// revoke forgets partitions that are no longer assigned
+func (t *offsetTracker) revoke(partitions []kafka.TopicPartition) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, tp := range partitions {
+		delete(t.partitions, keyOf(tp))
+		delete(t.rewinds, keyOf(tp))
+	}
+}
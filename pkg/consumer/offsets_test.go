@@ -0,0 +1,39 @@
+package consumer
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+)
+
+// BenchmarkOffsetTracker measures the bookkeeping added to each message by
+// concurrent workers, with window messages of a partition in flight and
+// completing in reverse order, the worst case for the tracker
+func BenchmarkOffsetTracker(b *testing.B) {
+	for _, window := range []int{1, 8, workerQueueSize} {
+		b.Run(fmt.Sprintf("in_flight=%d", window), func(b *testing.B) {
+			tracker := newOffsetTracker()
+			topic := "events"
+			tp := kafka.TopicPartition{Topic: &topic}
+			commits := 0
+			commit := func(kafka.TopicPartition) { commits++ }
+
+			b.ReportAllocs()
+			for i := 0; i < b.N; i += window {
+				n := min(window, b.N-i)
+				for j := 0; j < n; j++ {
+					tp.Offset = kafka.Offset(i + j)
+					tracker.begin(tp)
+				}
+				for j := n - 1; j >= 0; j-- {
+					tp.Offset = kafka.Offset(i + j)
+					tracker.complete(tp, commit)
+				}
+			}
+			if want := (b.N + window - 1) / window; commits != want {
+				b.Fatalf("committed %d times, want %d", commits, want)
+			}
+		})
+	}
+}
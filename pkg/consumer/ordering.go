@@ -0,0 +1,119 @@
+package consumer
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/assure-compliance/eventid/pkg/schema"
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+)
+
+// Timestamps compared by the ordering check, set with
+// Config.OrderingTimestamp
+const (
+	OrderingEventTime  = "event"  // The payload's timestamp (the default)
+	OrderingRecordTime = "record" // The Kafka record timestamp
+)
+
+// orderingMaxKeys bounds the keys remembered by the ordering check; the
+// history is reset once it is exceeded
+const orderingMaxKeys = 100000
+
+// orderingKey groups events expected to have non-decreasing timestamps
+type orderingKey struct {
+	source string
+	key    string // Kafka message key
+}
+
+// lastSeen is the newest event seen for an orderingKey and where it was read
+type lastSeen struct {
+	timestamp time.Time
+	partition partitionKey
+	offset    kafka.Offset
+}
+
+// orderingCheck detects events whose timestamp is earlier than the last
+// event seen for the same source and message key
+type orderingCheck struct {
+	compared string // OrderingEventTime or OrderingRecordTime
+
+	mu   sync.Mutex
+	last map[orderingKey]lastSeen
+}
+
+// newOrderingCheck validates Config.OrderingTimestamp and returns the check,
+// or nil if CheckOrdering is disabled
+func newOrderingCheck(cfg Config) (*orderingCheck, error) {
+	switch cfg.OrderingTimestamp {
+	case "", OrderingEventTime, OrderingRecordTime:
+	default:
+		return nil, fmt.Errorf("unknown OrderingTimestamp %q", cfg.OrderingTimestamp)
+	}
+	if !cfg.CheckOrdering {
+		return nil, nil
+	}
+	compared := OrderingEventTime
+	if cfg.OrderingTimestamp != "" {
+		compared = cfg.OrderingTimestamp
+	}
+	return &orderingCheck{compared: compared, last: make(map[orderingKey]lastSeen)}, nil
+}
+
+// timestamp returns the timestamp of event that is compared
+func (o *orderingCheck) timestamp(event *schema.Event) time.Time {
+	if o.compared == OrderingRecordTime {
+		return event.RecordTimestamp
+	}
+	return event.Timestamp
+}
+
+// observe records event and reports whether its timestamp regressed.
+// Messages at or before the last offset seen on the same partition are
+// redeliveries and are ignored, as are events without the timestamp
+// compared.
+func (o *orderingCheck) observe(msg *kafka.Message, event *schema.Event) (regressed bool, previous time.Time) {
+	timestamp := o.timestamp(event)
+	if timestamp.IsZero() {
+		return false, time.Time{}
+	}
+	key := orderingKey{source: event.Source, key: string(msg.Key)}
+	partition := keyOf(msg.TopicPartition)
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	last, ok := o.last[key]
+	if ok && last.partition == partition && msg.TopicPartition.Offset <= last.offset {
+		return false, time.Time{}
+	}
+	if !ok && len(o.last) >= orderingMaxKeys {
+		o.last = make(map[orderingKey]lastSeen)
+	}
+
+	// The newest timestamp is kept, so one late event is counted once
+	next := lastSeen{timestamp: timestamp, partition: partition, offset: msg.TopicPartition.Offset}
+	if ok && timestamp.Before(last.timestamp) {
+		regressed, previous = true, last.timestamp
+		next.timestamp = last.timestamp
+	}
+	o.last[key] = next
+	return regressed, previous
+}
+
+// checkOrdering counts and logs event if CheckOrdering is enabled and its
+// timestamp is earlier than the last event from the same source and key.
+// It never affects how the event is handled.
+func (c *EventConsumer) checkOrdering(msg *kafka.Message, event *schema.Event) {
+	if c.ordering == nil {
+		return
+	}
+	if regressed, previous := c.ordering.observe(msg, event); regressed {
+		timestamp := c.ordering.timestamp(event)
+		c.metrics.outOfOrderEvents.WithLabelValues(event.Source).Inc()
+		c.logger.Warn("Event timestamp regressed",
+			append(c.messageAttrs(msg, event),
+				"timestamp", timestamp, "previous_timestamp", previous,
+				"regression", previous.Sub(timestamp).String(), "ordering_timestamp", c.ordering.compared)...)
+	}
+}
@@ -0,0 +1,48 @@
+package consumer
+
+import (
+	"strconv"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+)
+
+// observeConsumed counts msg as read from its partition and sets the
+// partition's lag behind the high-water mark. The mark is the one cached
+// from the last fetch, so this does not query the broker. It runs on the
+// Start goroutine for every message read.
+func (c *EventConsumer) observeConsumed(msg *kafka.Message) {
+	key := keyOf(msg.TopicPartition)
+	partition := strconv.Itoa(int(key.partition))
+	c.metrics.partitionConsumed.WithLabelValues(key.topic, partition).Inc()
+
+	_, high, err := c.consumer.GetWatermarkOffsets(key.topic, key.partition)
+	if err != nil || high < 0 {
+		return
+	}
+	lag := high - int64(msg.TopicPartition.Offset) - 1
+	if lag < 0 {
+		lag = 0
+	}
+	c.metrics.partitionLag.WithLabelValues(key.topic, partition).Set(float64(lag))
+}
+
+// observeStored counts messages whose handler succeeded by partition
+func (c *EventConsumer) observeStored(msgs ...*kafka.Message) {
+	for _, msg := range msgs {
+		key := keyOf(msg.TopicPartition)
+		c.metrics.partitionStored.WithLabelValues(key.topic, strconv.Itoa(int(key.partition))).Inc()
+	}
+}
+
+// forgetPartitions removes the per-partition series of revoked partitions,
+// so that only assigned partitions are labeled
+func (c *EventConsumer) forgetPartitions(partitions []kafka.TopicPartition) {
+	for _, tp := range partitions {
+		key := keyOf(tp)
+		partition := strconv.Itoa(int(key.partition))
+		c.metrics.partitionConsumed.DeleteLabelValues(key.topic, partition)
+		c.metrics.partitionStored.DeleteLabelValues(key.topic, partition)
+		c.metrics.partitionLag.DeleteLabelValues(key.topic, partition)
+		c.metrics.partitionInFlight.DeleteLabelValues(key.topic, partition)
+	}
+}
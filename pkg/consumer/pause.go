@@ -0,0 +1,154 @@
+package consumer
+
+import (
+	"fmt"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+)
+
+// Pause stops fetching from every assigned partition without leaving the
+// consumer group. The consume loop keeps polling, so the group session and
+// health checks are unaffected, and partitions assigned by a rebalance while
+// paused are paused too. Messages already handed to a handler still finish.
+// Pause is a no-op if the consumer is already paused.
+func (c *EventConsumer) Pause() error {
+	c.pauseMu.Lock()
+	defer c.pauseMu.Unlock()
+	if c.paused {
+		return nil
+	}
+
+	// Partitions held back by backpressure or the rate limit are already
+	// paused
+	partitions := 0
+	if c.held == 0 {
+		var err error
+		if partitions, err = c.pausePartitions(true); err != nil {
+			return err
+		}
+	}
+
+	c.paused = true
+	c.metrics.consumerPaused.Set(1)
+	c.logger.Info("Consumer paused", "partitions", partitions)
+	return nil
+}
+
+// Resume restarts fetching from every assigned partition after Pause. It is
+// a no-op if the consumer is not paused. While backpressure or the rate
+// limit is holding fetching back, the partitions stay paused until it clears,
+// as do partitions at MaxInFlightPerPartition.
+func (c *EventConsumer) Resume() error {
+	c.pauseMu.Lock()
+	defer c.pauseMu.Unlock()
+	if !c.paused {
+		return nil
+	}
+
+	partitions := 0
+	if c.held == 0 {
+		var err error
+		if partitions, err = c.pausePartitions(false); err != nil {
+			return err
+		}
+	}
+
+	c.paused = false
+	c.metrics.consumerPaused.Set(0)
+	c.logger.Info("Consumer resumed", "partitions", partitions)
+	return nil
+}
+
+// Reasons other than Pause for holding fetching back. Partitions stay paused
+// while any reason, or Pause, applies.
+const (
+	holdBackpressure uint8 = 1 << iota
+	holdRateLimit
+)
+
+// hold pauses fetching for reason, reporting whether reason was newly held
+func (c *EventConsumer) hold(reason uint8) (bool, error) {
+	c.pauseMu.Lock()
+	defer c.pauseMu.Unlock()
+	if c.held&reason != 0 {
+		return false, nil
+	}
+	if c.held == 0 && !c.paused {
+		if _, err := c.pausePartitions(true); err != nil {
+			return false, err
+		}
+	}
+	c.held |= reason
+	return true, nil
+}
+
+// release lifts a hold, resuming fetching if nothing else holds it back,
+// and reports whether reason was held
+func (c *EventConsumer) release(reason uint8) (bool, error) {
+	c.pauseMu.Lock()
+	defer c.pauseMu.Unlock()
+	if c.held&reason == 0 {
+		return false, nil
+	}
+	if c.held == reason && !c.paused {
+		if _, err := c.pausePartitions(false); err != nil {
+			return false, err
+		}
+	}
+	c.held &^= reason
+	return true, nil
+}
+
+// pausePartitions pauses or resumes every assigned partition, returning how
+// many were changed. pauseMu must be held.
+func (c *EventConsumer) pausePartitions(pause bool) (int, error) {
+	partitions, err := c.consumer.Assignment()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get assignment: %w", err)
+	}
+	if len(partitions) == 0 {
+		return 0, nil
+	}
+	if pause {
+		if err := c.consumer.Pause(partitions); err != nil {
+			return 0, fmt.Errorf("failed to pause partitions: %w", err)
+		}
+		return len(partitions), nil
+	}
+
+	// Partitions at the in-flight limit stay paused until they catch up
+	if partitions = c.unlimited(partitions); len(partitions) == 0 {
+		return 0, nil
+	}
+	if err := c.consumer.Resume(partitions); err != nil {
+		return 0, fmt.Errorf("failed to resume partitions: %w", err)
+	}
+	return len(partitions), nil
+}
+
+// Paused reports whether the consumer is paused
+func (c *EventConsumer) Paused() bool {
+	c.pauseMu.Lock()
+	defer c.pauseMu.Unlock()
+	return c.paused
+}
+
+// assignPaused applies an assignment received while paused, by Pause or a
+// hold, and pauses the new partitions before any of them are
+// fetched. It reports whether the assignment was applied; otherwise the
+// client applies it as usual.
+func (c *EventConsumer) assignPaused(consumer *kafka.Consumer, partitions []kafka.TopicPartition) (bool, error) {
+	c.pauseMu.Lock()
+	defer c.pauseMu.Unlock()
+	if !c.paused && c.held == 0 {
+		return false, nil
+	}
+
+	if err := assign(consumer, partitions); err != nil {
+		return true, err
+	}
+	if err := consumer.Pause(partitions); err != nil {
+		return true, fmt.Errorf("failed to pause partitions: %w", err)
+	}
+	return true, nil
+}
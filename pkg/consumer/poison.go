@@ -0,0 +1,148 @@
+package consumer
+
+import (
+	"sync"
+
+	"github.com/assure-compliance/eventid/pkg/schema"
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+)
+
+// offsetKey identifies a single message by partition and offset
+type offsetKey struct {
+	partition partitionKey
+	offset    kafka.Offset
+}
+
+// poisonTracker counts handler failures per offset so that a message which
+// keeps failing can be skipped instead of redelivered forever. Entries are
+// removed once the offset succeeds, is skipped or its partition is revoked.
+type poisonTracker struct {
+	mu       sync.Mutex
+	max      int // Redeliveries allowed before an offset is skipped
+	failures map[offsetKey]int
+}
+
+func newPoisonTracker(max int) *poisonTracker {
+	return &poisonTracker{max: max, failures: make(map[offsetKey]int)}
+}
+
+// fail records a failure at each offset and reports whether any of them has
+// now failed more than max times
+func (t *poisonTracker) fail(offsets ...kafka.TopicPartition) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	poison := false
+	for _, tp := range offsets {
+		key := offsetKey{keyOf(tp), tp.Offset}
+		t.failures[key]++
+		if t.failures[key] > t.max {
+			poison = true
+		}
+	}
+	return poison
+}
+
+// clear forgets the failures recorded at each offset
+func (t *poisonTracker) clear(offsets ...kafka.TopicPartition) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if len(t.failures) == 0 {
+		return
+	}
+	for _, tp := range offsets {
+		delete(t.failures, offsetKey{keyOf(tp), tp.Offset})
+	}
+}
+
+// revoke forgets every failure recorded on the given partitions
+func (t *poisonTracker) revoke(partitions []kafka.TopicPartition) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, tp := range partitions {
+		partition := keyOf(tp)
+		for key := range t.failures {
+			if key.partition == partition {
+				delete(t.failures, key)
+			}
+		}
+	}
+}
+
+// handleFailure disposes of a message whose handler returned err. Without a
+// MaxOffsetRetries threshold the message is dead-lettered if possible and
+// otherwise redelivered. With a threshold it is redelivered until it has
+// failed more than MaxOffsetRetries times, then skipped. A message is only
+// committed once its dead letter is delivered.
+func (c *EventConsumer) handleFailure(msg *kafka.Message, err error) {
+	switch {
+	case c.poison == nil && c.deadLetter != nil:
+		if !c.sendDeadLetter(msg, err) {
+			c.redeliver(msg)
+			return
+		}
+		c.ack(msg)
+	case c.poison == nil || !c.poison.fail(msg.TopicPartition):
+		c.redeliver(msg)
+	default:
+		c.skipPoison(msg, err)
+	}
+}
+
+// commitsOnError reports whether a failed event of type t is committed
+// rather than redelivered
+func (c *EventConsumer) commitsOnError(t schema.EventType) bool {
+	return c.commitOnError || c.commitOnErrorTypes[t]
+}
+
+// commitFailed advances past a failed event under CommitOnError,
+// dead-lettering it if a dead-letter handler is set. If dead-lettering fails
+// the event is redelivered instead.
+func (c *EventConsumer) commitFailed(msg *kafka.Message, event *schema.Event, err error) {
+	if !c.sendDeadLetter(msg, err) {
+		c.redeliver(msg)
+		return
+	}
+	c.metrics.committedOnError.WithLabelValues(string(event.Type)).Inc()
+	c.logger.Warn("Committing failed event", append(c.messageAttrs(msg, event), "error", err)...)
+	if c.poison != nil {
+		c.poison.clear(msg.TopicPartition)
+	}
+	c.ack(msg)
+}
+
+// skipPoison advances past a message that failed more than MaxOffsetRetries
+// times, dead-lettering it if a dead-letter handler is set. If dead-lettering
+// fails the message is redelivered, still over the threshold, so the next
+// failure tries the dead letter again.
+func (c *EventConsumer) skipPoison(msg *kafka.Message, err error) {
+	if !c.sendDeadLetter(msg, err) {
+		c.redeliver(msg)
+		return
+	}
+	c.metrics.skippedEvents.Inc()
+	c.logger.Warn("Skipping repeatedly failing message",
+		append(c.messageAttrs(msg, nil), "max_offset_retries", c.poison.max, "error", err)...)
+	c.poison.clear(msg.TopicPartition)
+	c.ack(msg)
+}
+
+// messageOffsets returns the offset of each decoded event in the batch
+func (b *pendingBatch) messageOffsets() []kafka.TopicPartition {
+	offsets := make([]kafka.TopicPartition, len(b.messages))
+	for i, msg := range b.messages {
+		offsets[i] = msg.TopicPartition
+	}
+	return offsets
+}
+
+// firstOffsets returns the first offset of each partition in the batch
+func (b *pendingBatch) firstOffsets() []kafka.TopicPartition {
+	offsets := make([]kafka.TopicPartition, 0, len(b.first))
+	for key, offset := range b.first {
+		offsets = append(offsets, key.at(offset))
+	}
+	return offsets
+}
@@ -0,0 +1,64 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+)
+
+// ErrDeserialize is wrapped by every error from decoding a message into an
+// event, including those passed to a DecodeErrorHandler or dead-lettered
+var ErrDeserialize = errors.New("failed to deserialize message")
+
+// DecodeErrorHandler is called with a message that could not be decoded into
+// an event, e.g. to quarantine its raw bytes. err wraps ErrDeserialize. ctx
+// is cancelled when the consumer is closed.
+type DecodeErrorHandler func(ctx context.Context, msg *kafka.Message, err error) error
+
+// SetDecodeErrorHandler registers a handler for messages that fail to
+// decode. Once it returns nil the offset is committed. If it fails the
+// message is retried like a failed event handler: redelivered, skipped
+// after MaxOffsetRetries or dead-lettered; in batch mode redelivery rewinds
+// the pending batch with it. Without a handler undecodable messages are
+// logged and skipped.
+func (c *EventConsumer) SetDecodeErrorHandler(handler DecodeErrorHandler) {
+	c.decodeError = handler
+}
+
+// handleDecodeError disposes of a message that failed to decode with err
+func (c *EventConsumer) handleDecodeError(ctx context.Context, msg *kafka.Message, err error) {
+	c.metrics.Errors.WithLabelValues("deserialize").Inc()
+	c.logger.Error("Failed to decode message", append(c.messageAttrs(msg, nil), "error", err)...)
+
+	if c.decodeError == nil {
+		// A malformed message will never decode, so don't redeliver it
+		c.ack(msg)
+		return
+	}
+
+	if handlerErr := c.decodeError(ctx, msg, err); handlerErr != nil {
+		c.logger.Error("Decode error handler failed", append(c.messageAttrs(msg, nil), "error", handlerErr)...)
+		c.handleFailure(msg, handlerErr)
+		return
+	}
+	c.ack(msg)
+}
+
+// handleBatchDecodeError is handleDecodeError for batch mode, reporting
+// whether the message may be committed with the batch. If the handler fails
+// it is disposed of by batchFailure; false means the caller must rewind the
+// pending batch so the message is redelivered.
+func (c *EventConsumer) handleBatchDecodeError(ctx context.Context, msg *kafka.Message, err error) bool {
+	c.metrics.Errors.WithLabelValues("deserialize").Inc()
+	c.logger.Error("Failed to decode message", append(c.messageAttrs(msg, nil), "error", err)...)
+
+	if c.decodeError == nil {
+		return true
+	}
+	if handlerErr := c.decodeError(ctx, msg, err); handlerErr != nil {
+		c.logger.Error("Decode error handler failed", append(c.messageAttrs(msg, nil), "error", handlerErr)...)
+		return c.batchFailure(msg, handlerErr)
+	}
+	return true
+}
@@ -0,0 +1,103 @@
+package consumer
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// rateWindow is the interval over which event_consumer_processing_rate is
+// measured
+const rateWindow = time.Second
+
+// rateLimiter is a token bucket holding up to one second of tokens, so
+// bursts never exceed the per-second rate. It also measures the rate
+// actually achieved.
+type rateLimiter struct {
+	mu     sync.Mutex
+	rate   float64 // Tokens added per second
+	burst  float64
+	tokens float64
+	last   time.Time // When tokens was last refilled
+
+	metrics *Metrics
+
+	windowStart time.Time
+	windowCount int
+}
+
+// newRateLimiter returns a limiter for rate events per second, reporting the
+// rate achieved in metrics, or nil if rate is not positive
+func newRateLimiter(rate float64, metrics *Metrics) *rateLimiter {
+	if rate <= 0 {
+		return nil
+	}
+	burst := math.Max(1, math.Floor(rate))
+	now := time.Now()
+	return &rateLimiter{rate: rate, burst: burst, tokens: burst, last: now, metrics: metrics, windowStart: now}
+}
+
+// reserve returns how long until a token is available, 0 if one is now
+func (l *rateLimiter) reserve(now time.Time) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.refill(now)
+	l.observe(now)
+	if l.tokens >= 1 {
+		return 0
+	}
+	return time.Duration((1 - l.tokens) / l.rate * float64(time.Second))
+}
+
+// take spends a token. The balance may go negative when an event arrives
+// without one, which delays the following events instead.
+func (l *rateLimiter) take(now time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.refill(now)
+	l.tokens--
+	l.windowCount++
+}
+
+func (l *rateLimiter) refill(now time.Time) {
+	if elapsed := now.Sub(l.last); elapsed > 0 {
+		l.tokens = math.Min(l.burst, l.tokens+elapsed.Seconds()*l.rate)
+		l.last = now
+	}
+}
+
+// observe updates the processing rate gauge once per rateWindow
+func (l *rateLimiter) observe(now time.Time) {
+	if elapsed := now.Sub(l.windowStart); elapsed >= rateWindow {
+		l.metrics.processingRate.Set(float64(l.windowCount) / elapsed.Seconds())
+		l.windowStart = now
+		l.windowCount = 0
+	}
+}
+
+// throttleRate holds fetching back while no token is available for the
+// next message. Waits shorter than pollTimeout are slept through instead, as
+// pausing discards the client's prefetched messages.
+func (c *EventConsumer) throttleRate() {
+	wait := c.limiter.reserve(time.Now())
+	if wait > pollTimeout {
+		if changed, err := c.hold(holdRateLimit); err != nil {
+			c.logger.Error("Failed to pause for rate limit", "error", err)
+		} else if changed {
+			c.logger.Debug("Rate limit reached, pausing fetch", "wait", wait)
+		}
+		return
+	}
+
+	if changed, err := c.release(holdRateLimit); err != nil {
+		c.logger.Error("Failed to resume after rate limit", "error", err)
+	} else if changed {
+		c.logger.Debug("Rate limit cleared, resuming fetch")
+	}
+	if wait > 0 {
+		select {
+		case <-c.stop:
+		case <-time.After(wait):
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package consumer
+
+import (
+	"context"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+)
+
+// RawLog records a message exactly as received, e.g. in an append-only
+// table, so that receiving a message is on record apart from whether it
+// could be processed. It must return only once the record is durable. ctx
+// is cancelled when the consumer is closed.
+type RawLog func(ctx context.Context, msg *kafka.Message) error
+
+// SetRawLog registers log to be called with every message read, tombstones
+// and undecodable messages included, before it is decoded or handled. A
+// failed call is retried under the handler retry policy; if it still fails
+// the partition is rewound so the message is read again, and nothing else
+// is done with it. With auto-commit its offset may meanwhile be committed,
+// so use manual commits to be sure every processed message was logged.
+func (c *EventConsumer) SetRawLog(log RawLog) {
+	c.rawLog = log
+}
+
+// appendRaw passes msg to the raw log, reporting whether it was recorded.
+// If not, the partition is rewound to msg.
+func (c *EventConsumer) appendRaw(msg *kafka.Message) bool {
+	attrs := c.messageAttrs(msg, nil)
+	err := c.retry.do(c.ctx, func() error {
+		return c.rawLog(c.ctx, msg)
+	}, nil, attrs...)
+	if err == nil {
+		return true
+	}
+
+	c.metrics.Errors.WithLabelValues("raw_log").Inc()
+	c.logger.Error("Failed to record raw message, rewinding", append(attrs, "error", err)...)
+	if c.tracker != nil {
+		c.tracker.rewind(msg.TopicPartition)
+	}
+	if err := c.consumer.Seek(keyOf(msg.TopicPartition).at(msg.TopicPartition.Offset), 0); err != nil {
+		c.logger.Error("Failed to seek", append(attrs, "error", err)...)
+	}
+	return false
+}
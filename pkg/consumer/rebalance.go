@@ -0,0 +1,105 @@
+package consumer
+
+import (
+	"fmt"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+)
+
+// onRebalance records group membership for Ready and counts rebalances.
+// Returning without calling Assign/Unassign lets the client apply the
+// assignment itself, except while paused, when new partitions are assigned
+// and paused here. It runs on the Start goroutine, inside readMessage.
+func (c *EventConsumer) onRebalance(consumer *kafka.Consumer, ev kafka.Event) error {
+	switch e := ev.(type) {
+	case kafka.AssignedPartitions:
+		c.metrics.rebalances.WithLabelValues("assigned").Inc()
+		partitions, positioned := c.startOffsets(consumer, e.Partitions)
+		partitions, skipped := c.skipOffsets(consumer, partitions)
+		positioned = positioned || skipped
+		if applied, err := c.assignPaused(consumer, partitions); applied {
+			if err != nil {
+				c.logger.Error("Failed to pause assigned partitions", "error", err)
+				return err
+			}
+			c.logger.Info("Paused newly assigned partitions", "partitions", len(e.Partitions))
+		} else if positioned {
+			if err := assign(consumer, partitions); err != nil {
+				c.logger.Error("Failed to assign partitions at start offsets", "error", err)
+				return err
+			}
+		}
+		c.joined.Store(true)
+		c.watchCompression(e.Partitions)
+		c.logger.Info("Partitions assigned",
+			"partitions", partitionList(e.Partitions),
+			"protocol", consumer.GetRebalanceProtocol())
+	case kafka.RevokedPartitions:
+		c.metrics.rebalances.WithLabelValues("revoked").Inc()
+		lost := consumer.AssignmentLost()
+		if lost {
+			c.joined.Store(false)
+		} else {
+			c.commitBeforeRevoke(e.Partitions)
+		}
+		if c.tracker != nil {
+			c.tracker.revoke(e.Partitions)
+		}
+		if c.poison != nil {
+			c.poison.revoke(e.Partitions)
+		}
+		c.forgetPartitions(e.Partitions)
+		c.forgetLimited(e.Partitions)
+		c.logger.Info("Partitions revoked",
+			"partitions", partitionList(e.Partitions),
+			"assignment_lost", lost)
+	}
+	return nil
+}
+
+// commitBeforeRevoke flushes any pending batch, waits for workers to finish
+// the messages of partitions they are handling or have queued, and commits
+// stored offsets while the partitions are still owned, so the next owner
+// does not reprocess events that were already handled
+func (c *EventConsumer) commitBeforeRevoke(partitions []kafka.TopicPartition) {
+	if !c.batch.empty() {
+		c.flushBatch(flushRebalance)
+	}
+	if c.flight != nil {
+		c.logger.Debug("Waiting for in-flight messages of revoked partitions", "partitions", partitionList(partitions))
+		select {
+		case <-c.flight.drained(partitions):
+		case <-c.ctx.Done():
+			// Closed; nothing more can be committed
+			return
+		}
+	}
+	if err := c.commitStoredOffsets(); err != nil {
+		c.logger.Error("Failed to commit offsets before revoke", "error", err)
+	}
+}
+
+// assign applies a rebalance assignment using the call matching the group's
+// rebalance protocol
+func assign(consumer *kafka.Consumer, partitions []kafka.TopicPartition) error {
+	var err error
+	if consumer.GetRebalanceProtocol() == "COOPERATIVE" {
+		err = consumer.IncrementalAssign(partitions)
+	} else {
+		err = consumer.Assign(partitions)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to assign partitions: %w", err)
+	}
+	return nil
+}
+
+// partitionList formats partitions as topic[partition] for logging
+func partitionList(partitions []kafka.TopicPartition) []string {
+	list := make([]string, len(partitions))
+	for i, tp := range partitions {
+		key := keyOf(tp)
+		list[i] = fmt.Sprintf("%s[%d]", key.topic, key.partition)
+	}
+	return list
+}
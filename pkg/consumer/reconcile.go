@@ -0,0 +1,123 @@
+package consumer
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+)
+
+// reconcileTimeout bounds each admin request made by CountRecords
+const reconcileTimeout = 30 * time.Second
+
+// PartitionCount is the number of records in one partition within a time
+// window, as found by CountRecords
+type PartitionCount struct {
+	Topic     string
+	Partition int32
+	Start     kafka.Offset // First offset in the window
+	End       kafka.Offset // Offset after the last one in the window
+}
+
+// Records returns how many offsets lie in the window
+func (p PartitionCount) Records() int64 {
+	return int64(p.End - p.Start)
+}
+
+// CountRecords returns, for every partition of topic, the offsets of the
+// records whose timestamp is at or after from and before to, sorted by
+// partition. A zero from starts at the earliest retained record and a zero
+// to ends at the latest. The offsets are looked up by timestamp, so only
+// the brokers and security settings in cfg are used; nothing is consumed
+// and no group is joined. On compacted or transactional topics
+// the offset range includes removed records and transaction markers, so
+// Records overstates what can be consumed.
+func CountRecords(cfg Config, topic string, from, to time.Time) ([]PartitionCount, error) {
+	config := &kafka.ConfigMap{"bootstrap.servers": cfg.BootstrapServers}
+	if err := applySecurity(cfg, config); err != nil {
+		return nil, err
+	}
+	admin, err := kafka.NewAdminClient(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create admin client: %w", err)
+	}
+	defer admin.Close()
+
+	// Asking for every topic never auto-creates the one named
+	metadata, err := admin.GetMetadata(nil, true, int(reconcileTimeout.Milliseconds()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get topic metadata: %w", err)
+	}
+	info, ok := metadata.Topics[topic]
+	if !ok || len(info.Partitions) == 0 {
+		return nil, fmt.Errorf("topic %s does not exist", topic)
+	}
+	var partitions []int32
+	for _, p := range info.Partitions {
+		partitions = append(partitions, p.ID)
+	}
+
+	start := kafka.EarliestOffsetSpec
+	if !from.IsZero() {
+		start = kafka.NewOffsetSpecForTimestamp(from.UnixMilli())
+	}
+	end := kafka.LatestOffsetSpec
+	if !to.IsZero() {
+		end = kafka.NewOffsetSpecForTimestamp(to.UnixMilli())
+	}
+	latest, err := listOffsets(admin, topic, partitions, kafka.LatestOffsetSpec)
+	if err != nil {
+		return nil, err
+	}
+	starts, err := listOffsets(admin, topic, partitions, start)
+	if err != nil {
+		return nil, err
+	}
+	ends, err := listOffsets(admin, topic, partitions, end)
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make([]PartitionCount, 0, len(partitions))
+	for _, partition := range partitions {
+		// A timestamp after every record yields -1: the window ends there
+		count := PartitionCount{Topic: topic, Partition: partition, Start: starts[partition], End: ends[partition]}
+		if count.Start < 0 {
+			count.Start = latest[partition]
+		}
+		if count.End < 0 {
+			count.End = latest[partition]
+		}
+		if count.End < count.Start {
+			count.End = count.Start
+		}
+		counts = append(counts, count)
+	}
+	sort.Slice(counts, func(i, j int) bool { return counts[i].Partition < counts[j].Partition })
+	return counts, nil
+}
+
+// listOffsets looks up spec for each of topic's partitions
+func listOffsets(admin *kafka.AdminClient, topic string, partitions []int32, spec kafka.OffsetSpec) (map[int32]kafka.Offset, error) {
+	request := make(map[kafka.TopicPartition]kafka.OffsetSpec, len(partitions))
+	for _, partition := range partitions {
+		request[kafka.TopicPartition{Topic: &topic, Partition: partition}] = spec
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), reconcileTimeout)
+	defer cancel()
+	result, err := admin.ListOffsets(ctx, request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list offsets of topic %s: %w", topic, err)
+	}
+	offsets := make(map[int32]kafka.Offset, len(result.ResultInfos))
+	for tp, info := range result.ResultInfos {
+		if info.Error.Code() != kafka.ErrNoError {
+			return nil, fmt.Errorf("failed to list offset of %s[%d]: %w", topic, tp.Partition, info.Error)
+		}
+		offsets[tp.Partition] = info.Offset
+	}
+	return offsets, nil
+}
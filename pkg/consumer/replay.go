@@ -0,0 +1,181 @@
+package consumer
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/assure-compliance/eventid/pkg/schema"
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+)
+
+// Headers attached to replayed messages
+const (
+	HeaderReplayOriginalTimestamp = "replay-original-timestamp"
+	HeaderReplayedAt              = "replayed-at"
+)
+
+// replayFlushTimeoutMs bounds each flush while a Replayer is closed
+const replayFlushTimeoutMs = 1000
+
+// Replayer re-publishes stored events to a Kafka topic, e.g. with events
+// read by storage.EventStore.StreamEvents. Messages are produced
+// asynchronously; Close waits for every delivery and reports the first
+// failure.
+type Replayer struct {
+	producer          *kafka.Producer
+	topic             string
+	correlationHeader string
+	causationHeader   string
+	tenantHeader      string
+	logger            Logger
+	limiter           *rateLimiter // Set when MaxEventsPerSecond is configured
+
+	wg        sync.WaitGroup
+	mu        sync.Mutex
+	published int
+	failed    int
+	err       error // First delivery failure
+}
+
+// NewReplayer creates a Replayer publishing to topic using the brokers,
+// security settings, Logger, CorrelationHeader, CausationHeader,
+// TenantHeader and MaxEventsPerSecond in cfg
+func NewReplayer(cfg Config, topic string) (*Replayer, error) {
+	if topic == "" {
+		return nil, errors.New("replay topic is required")
+	}
+	if cfg.Logger == nil {
+		cfg.Logger = defaultLogger()
+	}
+	if cfg.CorrelationHeader == "" {
+		cfg.CorrelationHeader = DefaultCorrelationHeader
+	}
+	if cfg.CausationHeader == "" {
+		cfg.CausationHeader = DefaultCausationHeader
+	}
+	if cfg.TenantHeader == "" {
+		cfg.TenantHeader = DefaultTenantHeader
+	}
+
+	config := &kafka.ConfigMap{
+		"bootstrap.servers":  cfg.BootstrapServers,
+		"acks":               "all",
+		"enable.idempotence": true,
+	}
+	if err := applySecurity(cfg, config); err != nil {
+		return nil, err
+	}
+
+	producer, err := kafka.NewProducer(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create replay producer: %w", err)
+	}
+
+	r := &Replayer{
+		producer:          producer,
+		topic:             topic,
+		correlationHeader: cfg.CorrelationHeader,
+		causationHeader:   cfg.CausationHeader,
+		tenantHeader:      cfg.TenantHeader,
+		logger:            cfg.Logger,
+		limiter:           newRateLimiter(cfg.MaxEventsPerSecond, configMetrics(cfg)),
+	}
+	r.wg.Add(1)
+	go r.handleDeliveries()
+	return r, nil
+}
+
+// Publish queues event for delivery to the replay topic. The stored payload
+// is sent unchanged, keyed by entity ID (or event ID when it has none), with
+// the original event timestamp in the HeaderReplayOriginalTimestamp header
+// and any message headers stored with the event. With MaxEventsPerSecond
+// set, Publish blocks as needed to stay within the rate.
+func (r *Replayer) Publish(event schema.Event) error {
+	if r.limiter != nil {
+		time.Sleep(r.limiter.reserve(time.Now()))
+		r.limiter.take(time.Now())
+	}
+
+	headers := []kafka.Header{
+		{Key: HeaderReplayOriginalTimestamp, Value: []byte(event.Timestamp.UTC().Format(time.RFC3339Nano))},
+		{Key: HeaderReplayedAt, Value: []byte(time.Now().UTC().Format(time.RFC3339Nano))},
+	}
+	if event.CorrelationID != "" {
+		headers = append(headers, kafka.Header{Key: r.correlationHeader, Value: []byte(event.CorrelationID)})
+	}
+	if event.CausationID != "" {
+		headers = append(headers, kafka.Header{Key: r.causationHeader, Value: []byte(event.CausationID)})
+	}
+	if event.TenantID != "" {
+		headers = append(headers, kafka.Header{Key: r.tenantHeader, Value: []byte(event.TenantID)})
+	}
+	for key, value := range event.Headers {
+		switch key {
+		case HeaderReplayOriginalTimestamp, HeaderReplayedAt, r.correlationHeader, r.causationHeader, r.tenantHeader:
+		default:
+			headers = append(headers, kafka.Header{Key: key, Value: []byte(value)})
+		}
+	}
+
+	err := r.producer.Produce(&kafka.Message{
+		TopicPartition: kafka.TopicPartition{Topic: &r.topic, Partition: kafka.PartitionAny},
+		Key:            messageKey(event),
+		Value:          event.Payload,
+		Headers:        headers,
+		Opaque:         event.ID,
+	}, nil)
+	if err != nil {
+		return fmt.Errorf("failed to queue event %s for replay: %w", event.ID, err)
+	}
+	return nil
+}
+
+// messageKey returns the key events are published with: their entity ID,
+// so that an entity's events share a partition. Events without one are
+// replayed unkeyed rather than under a key they were never published with.
+func messageKey(event schema.Event) []byte {
+	if event.EntityID == "" {
+		return nil
+	}
+	return []byte(event.EntityID)
+}
+
+// handleDeliveries records delivery reports until the producer is closed
+func (r *Replayer) handleDeliveries() {
+	defer r.wg.Done()
+
+	for e := range r.producer.Events() {
+		switch ev := e.(type) {
+		case *kafka.Message:
+			r.mu.Lock()
+			if ev.TopicPartition.Error != nil {
+				r.failed++
+				if r.err == nil {
+					r.err = fmt.Errorf("failed to replay event %s: %w", ev.Opaque, ev.TopicPartition.Error)
+				}
+				r.logger.Error("Failed to replay event", "event_id", ev.Opaque, "error", ev.TopicPartition.Error)
+			} else {
+				r.published++
+			}
+			r.mu.Unlock()
+		case kafka.Error:
+			r.logger.Error("Replay producer error", "error", ev)
+		}
+	}
+}
+
+// Close waits for outstanding deliveries, closes the producer and returns
+// the first delivery failure, if any
+func (r *Replayer) Close() error {
+	for r.producer.Flush(replayFlushTimeoutMs) > 0 {
+	}
+	r.producer.Close()
+	r.wg.Wait()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.logger.Info("Replay finished", "topic", r.topic, "published", r.published, "failed", r.failed)
+	return r.err
+}
@@ -0,0 +1,145 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/assure-compliance/eventid/pkg/schema"
+)
+
+const (
+	// DefaultRetryBackoff is the initial delay between handler retries
+	DefaultRetryBackoff = 100 * time.Millisecond
+
+	// DefaultMaxRetryBackoff caps the exponential backoff between retries
+	DefaultMaxRetryBackoff = 30 * time.Second
+)
+
+// RetryPolicy controls how a failing handler is retried
+type RetryPolicy struct {
+	MaxRetries     int           // Retries after the first attempt; 0 disables retrying
+	InitialBackoff time.Duration // Delay before the first retry
+	MaxBackoff     time.Duration // Upper bound for the delay between retries
+	Logger         Logger        // Defaults to JSON on stderr
+	Metrics        *Metrics      // Defaults to DefaultMetrics()
+}
+
+// ErrPermanent marks handler errors that retrying cannot fix, such as an
+// event the database rejects. An error wrapping it is not retried: the
+// failure is handled at once, e.g. dead-lettered.
+var ErrPermanent = errors.New("permanent failure")
+
+// Permanent marks err with ErrPermanent so the retry policy gives up on it
+// immediately. It returns nil if err is nil.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return fmt.Errorf("%w: %w", ErrPermanent, err)
+}
+
+// RetryError is returned by a retrying handler once all attempts have failed
+type RetryError struct {
+	Attempts int
+	Err      error
+}
+
+func (e *RetryError) Error() string {
+	return fmt.Sprintf("handler failed after %d attempts: %v", e.Attempts, e.Err)
+}
+
+func (e *RetryError) Unwrap() error {
+	return e.Err
+}
+
+// Backoff returns the delay before the given retry (1-based), doubling on
+// each attempt and capped at MaxBackoff
+func (p RetryPolicy) Backoff(retry int) time.Duration {
+	initial := p.InitialBackoff
+	if initial <= 0 {
+		initial = DefaultRetryBackoff
+	}
+	max := p.MaxBackoff
+	if max <= 0 {
+		max = DefaultMaxRetryBackoff
+	}
+
+	delay := initial
+	for i := 1; i < retry; i++ {
+		delay *= 2
+		if delay >= max {
+			return max
+		}
+	}
+	if delay > max {
+		return max
+	}
+	return delay
+}
+
+// WithRetry wraps a handler so that failures are retried with exponential
+// backoff. When every attempt fails the last error is returned as a
+// *RetryError and the exhausted-retries counter is incremented. Errors
+// wrapping ErrPermanent are returned without retrying.
+func WithRetry(handler EventHandler, policy RetryPolicy) EventHandler {
+	if policy.MaxRetries <= 0 {
+		return handler
+	}
+
+	return func(ctx context.Context, event *schema.Event) error {
+		return policy.do(ctx, func() error { return handler(ctx, event) }, nil,
+			"event_id", event.ID, "event_type", string(event.Type))
+	}
+}
+
+// metrics returns the metrics retries are counted in
+func (p RetryPolicy) metrics() *Metrics {
+	if p.Metrics == nil {
+		return DefaultMetrics()
+	}
+	return p.Metrics
+}
+
+// do calls fn until it succeeds, returns an error for which stop reports
+// true or that wraps ErrPermanent, or the policy's retries are exhausted.
+// If ctx is cancelled while waiting to retry, the last error is returned
+// without retrying again. attrs are added to retry logs.
+func (p RetryPolicy) do(ctx context.Context, fn func() error, stop func(error) bool, attrs ...any) error {
+	logger := p.Logger
+	if logger == nil && p.MaxRetries > 0 {
+		logger = defaultLogger()
+	}
+
+	var err error
+	for attempt := 0; attempt <= p.MaxRetries; attempt++ {
+		if attempt > 0 {
+			delay := p.Backoff(attempt)
+			logger.Warn("Retrying handler", append(attrs,
+				"attempt", attempt, "max_retries", p.MaxRetries, "backoff", delay.String(), "error", err)...)
+			p.metrics().handlerRetries.Inc()
+
+			timer := time.NewTimer(delay)
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+				return err
+			}
+		}
+
+		if err = fn(); err == nil {
+			return nil
+		}
+		if errors.Is(err, ErrPermanent) || (stop != nil && stop(err)) {
+			return err
+		}
+	}
+
+	if p.MaxRetries <= 0 {
+		return err
+	}
+	p.metrics().Errors.WithLabelValues("retries_exhausted").Inc()
+	return &RetryError{Attempts: p.MaxRetries + 1, Err: err}
+}
@@ -0,0 +1,151 @@
+package consumer_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/assure-compliance/eventid/pkg/consumer"
+	"github.com/assure-compliance/eventid/pkg/schema"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Backoff doubles from InitialBackoff on each retry and is capped at
+// MaxBackoff, with defaults for unset fields
+func TestRetryPolicyBackoff(t *testing.T) {
+	t.Parallel()
+	for _, tc := range []struct {
+		name   string
+		policy consumer.RetryPolicy
+		retry  int
+		want   time.Duration
+	}{
+		{name: "first retry", policy: consumer.RetryPolicy{InitialBackoff: time.Second, MaxBackoff: time.Minute}, retry: 1, want: time.Second},
+		{name: "doubles", policy: consumer.RetryPolicy{InitialBackoff: time.Second, MaxBackoff: time.Minute}, retry: 2, want: 2 * time.Second},
+		{name: "doubles again", policy: consumer.RetryPolicy{InitialBackoff: time.Second, MaxBackoff: time.Minute}, retry: 4, want: 8 * time.Second},
+		{name: "capped", policy: consumer.RetryPolicy{InitialBackoff: time.Second, MaxBackoff: 5 * time.Second}, retry: 4, want: 5 * time.Second},
+		{name: "capped far out", policy: consumer.RetryPolicy{InitialBackoff: time.Second, MaxBackoff: 5 * time.Second}, retry: 100, want: 5 * time.Second},
+		{name: "initial above cap", policy: consumer.RetryPolicy{InitialBackoff: time.Minute, MaxBackoff: time.Second}, retry: 1, want: time.Second},
+		{name: "default initial", policy: consumer.RetryPolicy{}, retry: 1, want: consumer.DefaultRetryBackoff},
+		{name: "default cap", policy: consumer.RetryPolicy{}, retry: 100, want: consumer.DefaultMaxRetryBackoff},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			if got := tc.policy.Backoff(tc.retry); got != tc.want {
+				t.Errorf("Backoff(%d) = %s, want %s", tc.retry, got, tc.want)
+			}
+		})
+	}
+}
+
+// testPolicy returns a policy with maxRetries and millisecond backoffs that
+// logs nowhere and counts into metrics of its own
+func testPolicy(maxRetries int) consumer.RetryPolicy {
+	return consumer.RetryPolicy{
+		MaxRetries:     maxRetries,
+		InitialBackoff: time.Millisecond,
+		MaxBackoff:     time.Millisecond,
+		Logger:         slog.New(slog.NewTextHandler(io.Discard, nil)),
+		Metrics:        consumer.NewMetrics(prometheus.NewRegistry(), "", ""),
+	}
+}
+
+// WithRetry calls a handler until it succeeds or MaxRetries retries have
+// failed, returning a *RetryError counting every attempt, and gives up at
+// once on errors wrapping ErrPermanent
+func TestWithRetry(t *testing.T) {
+	t.Parallel()
+	failure := errors.New("storage unavailable")
+	for _, tc := range []struct {
+		name       string
+		maxRetries int
+		failures   int   // Calls that fail before the handler succeeds
+		err        error // Returned by failing calls (default failure)
+		calls      int
+		attempts   int // RetryError.Attempts; 0 if none is expected
+		wantErr    bool
+	}{
+		{name: "succeeds first time", maxRetries: 3, failures: 0, calls: 1},
+		{name: "succeeds on retry", maxRetries: 3, failures: 2, calls: 3},
+		{name: "succeeds on last retry", maxRetries: 3, failures: 3, calls: 4},
+		{name: "retries exhausted", maxRetries: 3, failures: 10, calls: 4, attempts: 4, wantErr: true},
+		{name: "retrying disabled", maxRetries: 0, failures: 10, calls: 1, wantErr: true},
+		{name: "permanent", maxRetries: 3, failures: 10, err: consumer.Permanent(failure), calls: 1, wantErr: true},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			returned := tc.err
+			if returned == nil {
+				returned = failure
+			}
+			calls := 0
+			handler := consumer.WithRetry(func(context.Context, *schema.Event) error {
+				calls++
+				if calls <= tc.failures {
+					return returned
+				}
+				return nil
+			}, testPolicy(tc.maxRetries))
+
+			err := handler(context.Background(), &schema.Event{ID: "evt-1"})
+			if calls != tc.calls {
+				t.Errorf("handler called %d times, want %d", calls, tc.calls)
+			}
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("WithRetry returned %v, want error: %t", err, tc.wantErr)
+			}
+			if err == nil {
+				return
+			}
+			if !errors.Is(err, failure) {
+				t.Errorf("error %v does not wrap the handler's error", err)
+			}
+			var retryErr *consumer.RetryError
+			switch {
+			case tc.attempts == 0 && errors.As(err, &retryErr):
+				t.Errorf("got %v, want the handler's error without a RetryError", err)
+			case tc.attempts > 0 && !errors.As(err, &retryErr):
+				t.Errorf("got %v, want a RetryError", err)
+			case tc.attempts > 0 && retryErr.Attempts != tc.attempts:
+				t.Errorf("RetryError.Attempts = %d, want %d", retryErr.Attempts, tc.attempts)
+			}
+		})
+	}
+}
+
+// Cancelling the context while waiting to retry returns the last error at
+// once instead of retrying again
+func TestWithRetryCancelledDuringBackoff(t *testing.T) {
+	t.Parallel()
+	policy := testPolicy(5)
+	policy.InitialBackoff = time.Hour
+	policy.MaxBackoff = time.Hour
+
+	failure := errors.New("storage unavailable")
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := 0
+	handler := consumer.WithRetry(func(context.Context, *schema.Event) error {
+		calls++
+		cancel()
+		return failure
+	}, policy)
+
+	returned := make(chan error, 1)
+	go func() { returned <- handler(ctx, &schema.Event{ID: "evt-1"}) }()
+	select {
+	case err := <-returned:
+		if !errors.Is(err, failure) {
+			t.Errorf("WithRetry returned %v, want %v", err, failure)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("WithRetry kept waiting after its context was cancelled")
+	}
+	if calls != 1 {
+		t.Errorf("handler called %d times, want 1", calls)
+	}
+}
@@ -0,0 +1,43 @@
+package consumer
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+)
+
+// validSecurityProtocols are the values librdkafka accepts for security.protocol
+var validSecurityProtocols = map[string]bool{
+	"plaintext":      true,
+	"ssl":            true,
+	"sasl_plaintext": true,
+	"sasl_ssl":       true,
+}
+
+// applySecurity copies the authentication settings from cfg into config.
+// It returns an error when a SASL mechanism is chosen without credentials.
+func applySecurity(cfg Config, config *kafka.ConfigMap) error {
+	if cfg.SecurityProtocol != "" {
+		if !validSecurityProtocols[strings.ToLower(cfg.SecurityProtocol)] {
+			return fmt.Errorf("invalid security protocol %q: must be one of PLAINTEXT, SSL, SASL_PLAINTEXT, SASL_SSL",
+				cfg.SecurityProtocol)
+		}
+		config.SetKey("security.protocol", cfg.SecurityProtocol)
+	}
+
+	if cfg.SASLMechanism != "" {
+		if cfg.SASLUsername == "" || cfg.SASLPassword == "" {
+			return fmt.Errorf("SASL mechanism %s requires both a username and a password", cfg.SASLMechanism)
+		}
+		config.SetKey("sasl.mechanisms", cfg.SASLMechanism)
+		config.SetKey("sasl.username", cfg.SASLUsername)
+		config.SetKey("sasl.password", cfg.SASLPassword)
+	}
+
+	if cfg.SSLCALocation != "" {
+		config.SetKey("ssl.ca.location", cfg.SSLCALocation)
+	}
+
+	return nil
+}
@@ -0,0 +1,86 @@
+package consumer
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+)
+
+// ErrPartitionNotAssigned is returned by SeekPartition when another member
+// of the group owns the partition. Seek on the instance it is assigned to.
+var ErrPartitionNotAssigned = errors.New("partition is not assigned to this consumer")
+
+// ErrNotRunning is returned by SeekPartition when Start is not running
+var ErrNotRunning = errors.New("consumer is not running")
+
+// seekRequest asks the Start goroutine to seek a partition
+type seekRequest struct {
+	tp    kafka.TopicPartition
+	reply chan error
+}
+
+// SeekPartition repositions one assigned partition so that consumption
+// continues from offset, which may also be kafka.OffsetBeginning or
+// kafka.OffsetEnd, leaving other partitions untouched. It is carried out by
+// the Start goroutine between messages, so it cannot race a rebalance: if
+// the partition is not assigned to this consumer when the seek runs,
+// ErrPartitionNotAssigned is returned. A pending batch is flushed first.
+// Messages from the partition already being handled still finish, but no
+// longer move its committed offset; the group's committed offset follows
+// once messages from the new position are handled.
+func (c *EventConsumer) SeekPartition(topic string, partition int32, offset int64) error {
+	if offset < 0 && kafka.Offset(offset) != kafka.OffsetBeginning && kafka.Offset(offset) != kafka.OffsetEnd {
+		return fmt.Errorf("invalid offset %d", offset)
+	}
+	if !c.running.Load() {
+		return ErrNotRunning
+	}
+
+	req := seekRequest{
+		tp:    partitionKey{topic: topic, partition: partition}.at(kafka.Offset(offset)),
+		reply: make(chan error, 1),
+	}
+	select {
+	case c.seeks <- req:
+	case <-c.stopped:
+		return ErrNotRunning
+	}
+	return <-req.reply
+}
+
+// seek runs a SeekPartition request on the Start goroutine
+func (c *EventConsumer) seek(tp kafka.TopicPartition) error {
+	key := keyOf(tp)
+	assigned, err := c.consumer.Assignment()
+	if err != nil {
+		return fmt.Errorf("failed to get assignment: %w", err)
+	}
+	owned := false
+	for _, a := range assigned {
+		if keyOf(a) == key {
+			owned = true
+			break
+		}
+	}
+	if !owned {
+		return fmt.Errorf("%w: %s[%d]", ErrPartitionNotAssigned, key.topic, key.partition)
+	}
+
+	if !c.batch.empty() {
+		c.flushBatch(flushSeek)
+	}
+	if err := c.consumer.Seek(tp, 0); err != nil {
+		return fmt.Errorf("failed to seek %s[%d]: %w", key.topic, key.partition, err)
+	}
+
+	// Forget in-flight state so earlier messages cannot commit past the seek
+	if c.tracker != nil {
+		c.tracker.revoke([]kafka.TopicPartition{tp})
+	}
+	if c.poison != nil {
+		c.poison.revoke([]kafka.TopicPartition{tp})
+	}
+	c.logger.Warn("Seeked partition", "topic", key.topic, "partition", key.partition, "offset", tp.Offset.String())
+	return nil
+}
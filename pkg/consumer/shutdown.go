@@ -0,0 +1,88 @@
+package consumer
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// shutdownProgressInterval is how often Shutdown logs what it is waiting on
+const shutdownProgressInterval = 5 * time.Second
+
+// Drain stages reported while Shutdown waits
+const (
+	stageHandler = "handling current message"
+	stageWorkers = "waiting for workers"
+	stageBatch   = "flushing batch"
+	stageCommit  = "committing offsets"
+)
+
+// Shutdown stops fetching new messages, waits for the in-flight message to
+// finish, flushes any pending batch, commits offsets and closes the consumer.
+// Messages fetched by the client but not yet handed to Start are never
+// committed, so they are redelivered to the next group member. If ctx expires
+// first the consumer is closed without draining, which cancels the context
+// passed to in-flight handlers, and an error wrapping ctx.Err() is returned.
+// While waiting it periodically logs the drain stage and the number of
+// messages still being handled, and logs them once more if the deadline
+// passes.
+func (c *EventConsumer) Shutdown(ctx context.Context) error {
+	c.stopOnce.Do(func() {
+		c.drainStage.Store(stageHandler)
+		close(c.stop)
+	})
+
+	if c.running.Load() {
+		if err := c.waitDrained(ctx); err != nil {
+			c.Close()
+			return err
+		}
+	}
+
+	return c.Close()
+}
+
+// waitDrained waits for Start to return, logging progress, until ctx expires
+func (c *EventConsumer) waitDrained(ctx context.Context) error {
+	ticker := time.NewTicker(shutdownProgressInterval)
+	defer ticker.Stop()
+	started := time.Now()
+
+	for {
+		select {
+		case <-c.stopped:
+			return nil
+		case <-ticker.C:
+			c.logger.Info("Waiting for consumer to drain", append(c.drainAttrs(),
+				"elapsed", time.Since(started).Round(time.Second).String())...)
+		case <-ctx.Done():
+			c.logger.Error("Consumer did not drain before deadline", c.drainAttrs()...)
+			return fmt.Errorf("consumer did not drain before deadline: %w", ctx.Err())
+		}
+	}
+}
+
+// drainAttrs returns log attributes describing what a drain is waiting on
+func (c *EventConsumer) drainAttrs() []any {
+	stage, _ := c.drainStage.Load().(string)
+	return []any{"stage", stage, "in_flight", c.inFlight.Load()}
+}
+
+// drain runs on the Start goroutine once a shutdown is requested
+func (c *EventConsumer) drain() {
+	c.logger.Info("Draining event consumer")
+
+	if !c.batch.empty() {
+		c.drainStage.Store(stageBatch)
+		c.flushBatch(flushShutdown)
+	}
+
+	// Offsets stored for background commit are committed synchronously so
+	// nothing handled is redelivered
+	c.drainStage.Store(stageCommit)
+	if err := c.commitStoredOffsets(); err != nil {
+		c.logger.Error("Failed to commit offsets on shutdown", "error", err)
+	}
+
+	c.logger.Info("Event consumer drained")
+}
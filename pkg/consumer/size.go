@@ -0,0 +1,175 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+)
+
+// compressionLookupTimeout bounds each topic config request
+const compressionLookupTimeout = 5 * time.Second
+
+// Failed compression lookups are retried in the background, backing off
+// from compressionRetryMin to compressionRetryMax between attempts
+const (
+	compressionRetryMin = time.Second
+	compressionRetryMax = time.Minute
+)
+
+// compressionUnknown labels sizes whose topic compression has not been read
+// yet, or could not be
+const compressionUnknown = "unknown"
+
+// ErrMessageTooLarge is passed to the dead-letter handler for messages
+// larger than MaxMessageBytes
+var ErrMessageTooLarge = errors.New("message exceeds max message size")
+
+// oversized reports whether msg's value exceeds MaxMessageBytes. Oversized
+// messages are counted, logged and dead-lettered if a dead-letter handler is
+// set; the caller should skip them without decoding. commit reports whether
+// the message may be committed: false means its dead letter was not
+// delivered and it must be redelivered.
+func (c *EventConsumer) oversized(msg *kafka.Message) (skip, commit bool) {
+	if c.maxMessageBytes <= 0 || len(msg.Value) <= c.maxMessageBytes {
+		return false, true
+	}
+
+	c.metrics.oversizedMessages.Inc()
+	err := fmt.Errorf("%w: %d bytes, limit %d", ErrMessageTooLarge, len(msg.Value), c.maxMessageBytes)
+	c.logger.Warn("Rejected oversized message", append(c.messageAttrs(msg, nil), "error", err)...)
+	return true, c.sendDeadLetter(msg, err)
+}
+
+// topicCompression caches each topic's compression.type config. The Kafka
+// client decompresses record batches transparently and does not report the
+// codec of each record, so message sizes are labelled with the topic's
+// setting instead: "producer" means the broker keeps whatever codec each
+// producer used. Topics are looked up in the background, starting when they
+// are assigned, so measuring a message never waits on the broker.
+type topicCompression struct {
+	mu      sync.Mutex
+	codec   map[string]string
+	pending map[string]bool // Topics being looked up
+	closed  bool            // Set by Close; no lookups are started after
+	wg      sync.WaitGroup
+}
+
+// observeMessageSize records the size of msg's value, as delivered to the
+// consumer after decompression
+func (c *EventConsumer) observeMessageSize(msg *kafka.Message) {
+	topic := keyOf(msg.TopicPartition).topic
+	c.metrics.messageBytes.WithLabelValues(topic, c.compressionOf(topic)).Observe(float64(len(msg.Value)))
+}
+
+// compressionOf returns topic's compression.type, or compressionUnknown
+// while it is being looked up
+func (c *EventConsumer) compressionOf(topic string) string {
+	c.compression.mu.Lock()
+	defer c.compression.mu.Unlock()
+
+	if codec, ok := c.compression.codec[topic]; ok {
+		return codec
+	}
+	c.lookupCompression(topic)
+	return compressionUnknown
+}
+
+// watchCompression starts looking up the compression of the topics of newly
+// assigned partitions
+func (c *EventConsumer) watchCompression(partitions []kafka.TopicPartition) {
+	c.compression.mu.Lock()
+	defer c.compression.mu.Unlock()
+	for _, tp := range partitions {
+		if topic := keyOf(tp).topic; c.compression.codec[topic] == "" {
+			c.lookupCompression(topic)
+		}
+	}
+}
+
+// lookupCompression starts resolveCompression for topic unless it is
+// already running. compression.mu must be held.
+func (c *EventConsumer) lookupCompression(topic string) {
+	if c.compression.closed || c.compression.pending[topic] {
+		return
+	}
+	if c.compression.pending == nil {
+		c.compression.pending = make(map[string]bool)
+	}
+	c.compression.pending[topic] = true
+	c.compression.wg.Add(1)
+	go c.resolveCompression(topic)
+}
+
+// resolveCompression looks up topic's compression until it succeeds or the
+// consumer is closed, and caches it
+func (c *EventConsumer) resolveCompression(topic string) {
+	defer c.compression.wg.Done()
+
+	backoff := compressionRetryMin
+	for {
+		codec, err := c.describeCompression(topic)
+		if err == nil {
+			c.compression.mu.Lock()
+			if c.compression.codec == nil {
+				c.compression.codec = make(map[string]string)
+			}
+			c.compression.codec[topic] = codec
+			delete(c.compression.pending, topic)
+			c.compression.mu.Unlock()
+			return
+		}
+		if c.ctx.Err() != nil {
+			return
+		}
+		c.logger.Warn("Failed to read topic compression", "topic", topic, "backoff", backoff.String(), "error", err)
+
+		select {
+		case <-c.ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		if backoff > compressionRetryMax {
+			backoff = compressionRetryMax
+		}
+	}
+}
+
+// stopCompression waits for running lookups, which use the consumer's
+// handle, once the consumer context is cancelled
+func (c *EventConsumer) stopCompression() {
+	c.compression.mu.Lock()
+	c.compression.closed = true
+	c.compression.mu.Unlock()
+	c.compression.wg.Wait()
+}
+
+// describeCompression reads the compression.type config of topic
+func (c *EventConsumer) describeCompression(topic string) (string, error) {
+	admin, err := kafka.NewAdminClientFromConsumer(c.consumer)
+	if err != nil {
+		return "", fmt.Errorf("failed to create admin client: %w", err)
+	}
+	defer admin.Close()
+
+	ctx, cancel := context.WithTimeout(c.ctx, compressionLookupTimeout)
+	defer cancel()
+
+	results, err := admin.DescribeConfigs(ctx, []kafka.ConfigResource{{Type: kafka.ResourceTopic, Name: topic}})
+	if err != nil {
+		return "", fmt.Errorf("failed to describe topic config: %w", err)
+	}
+	for _, result := range results {
+		if result.Error.Code() != kafka.ErrNoError {
+			return "", result.Error
+		}
+		if entry, ok := result.Config["compression.type"]; ok {
+			return entry.Value, nil
+		}
+	}
+	return compressionUnknown, nil
+}
@@ -0,0 +1,88 @@
+package consumer
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+)
+
+// committedTimeoutMs bounds the committed offsets lookup made for SkipTo
+// when partitions are assigned
+const committedTimeoutMs = 10000
+
+// newSkipTo validates SkipTo and indexes it by partition
+func newSkipTo(cfg Config) (map[partitionKey]kafka.Offset, error) {
+	if len(cfg.SkipTo) == 0 {
+		return nil, nil
+	}
+	if len(cfg.AssignPartitions) > 0 {
+		return nil, errors.New("SkipTo cannot be used with AssignPartitions")
+	}
+	skipTo := make(map[partitionKey]kafka.Offset, len(cfg.SkipTo))
+	for _, p := range cfg.SkipTo {
+		if p.Offset < 0 {
+			return nil, fmt.Errorf("SkipTo %s: offset must be a number", p)
+		}
+		skipTo[partitionKey{topic: p.Topic, partition: p.Partition}] = p.Offset
+	}
+	return skipTo, nil
+}
+
+// skipOffsets moves each assigned partition listed in SkipTo forward to its
+// offset when it would otherwise start below it, and reports whether any
+// were moved. A partition already positioned by StartFromTimestamp is
+// compared at that offset, others at their committed offset; a partition
+// with no committed offset starts at the SkipTo offset. If the committed
+// offsets cannot be read the assignment is left unchanged.
+func (c *EventConsumer) skipOffsets(consumer *kafka.Consumer, partitions []kafka.TopicPartition) ([]kafka.TopicPartition, bool) {
+	if len(c.skipTo) == 0 {
+		return partitions, false
+	}
+
+	var lookup []kafka.TopicPartition
+	for _, tp := range partitions {
+		if _, ok := c.skipTo[keyOf(tp)]; ok && tp.Offset < 0 {
+			lookup = append(lookup, keyOf(tp).at(kafka.OffsetInvalid))
+		}
+	}
+	committed := make(map[partitionKey]kafka.Offset, len(lookup))
+	if len(lookup) > 0 {
+		offsets, err := consumer.Committed(lookup, committedTimeoutMs)
+		if err != nil {
+			c.logger.Error("Failed to read committed offsets for SkipTo", "partitions", partitionList(lookup), "error", err)
+			return partitions, false
+		}
+		for _, tp := range offsets {
+			committed[keyOf(tp)] = tp.Offset
+		}
+	}
+
+	positioned := make([]kafka.TopicPartition, len(partitions))
+	copy(positioned, partitions)
+	moved := false
+	for i, tp := range positioned {
+		key := keyOf(tp)
+		target, ok := c.skipTo[key]
+		if !ok {
+			continue
+		}
+		from := tp.Offset
+		if from < 0 {
+			from = committed[key]
+		}
+		if from >= target {
+			continue
+		}
+
+		positioned[i].Offset = target
+		moved = true
+		attrs := []any{"topic", key.topic, "partition", key.partition, "to", target.String()}
+		if from >= 0 {
+			c.metrics.skippedRange.WithLabelValues(key.topic).Add(float64(target - from))
+			attrs = append(attrs, "from", from.String(), "skipped", int64(target-from))
+		}
+		c.logger.Warn("Skipping offset range", attrs...)
+	}
+	return positioned, moved
+}
@@ -0,0 +1,139 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+)
+
+// snapshotTimeout bounds the admin requests made by ExportOffsets and
+// RestoreOffsets
+const snapshotTimeout = 30 * time.Second
+
+// ErrRunning is returned by RestoreOffsets while Start is running
+var ErrRunning = errors.New("consumer is running")
+
+// ExportOffsets returns the group's committed offset for every partition of
+// the consumer's topics that has one, sorted by topic and partition. They
+// are read from the group coordinator, so they cover partitions assigned to
+// other members too, and can be saved outside Kafka and passed to
+// RestoreOffsets later.
+func (c *EventConsumer) ExportOffsets() ([]PartitionOffset, error) {
+	admin, err := kafka.NewAdminClientFromConsumer(c.consumer)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create admin client: %w", err)
+	}
+	defer admin.Close()
+
+	var partitions []kafka.TopicPartition
+	for _, topic := range c.topics {
+		topic := topic
+		metadata, err := admin.GetMetadata(&topic, false, int(snapshotTimeout.Milliseconds()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to get metadata of topic %s: %w", topic, err)
+		}
+		for _, p := range metadata.Topics[topic].Partitions {
+			partitions = append(partitions, kafka.TopicPartition{Topic: &topic, Partition: p.ID})
+		}
+	}
+	if len(partitions) == 0 {
+		return nil, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), snapshotTimeout)
+	defer cancel()
+	result, err := admin.ListConsumerGroupOffsets(ctx,
+		[]kafka.ConsumerGroupTopicPartitions{{Group: c.groupID, Partitions: partitions}},
+		kafka.SetAdminRequireStableOffsets(true))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list committed offsets: %w", err)
+	}
+
+	var offsets []PartitionOffset
+	for _, group := range result.ConsumerGroupsTopicPartitions {
+		for _, tp := range group.Partitions {
+			if tp.Error != nil {
+				return nil, fmt.Errorf("failed to list committed offset of %s[%d]: %w", *tp.Topic, tp.Partition, tp.Error)
+			}
+			if tp.Offset < 0 {
+				continue
+			}
+			offsets = append(offsets, PartitionOffset{Topic: *tp.Topic, Partition: tp.Partition, Offset: tp.Offset})
+		}
+	}
+	sort.Slice(offsets, func(i, j int) bool {
+		if offsets[i].Topic != offsets[j].Topic {
+			return offsets[i].Topic < offsets[j].Topic
+		}
+		return offsets[i].Partition < offsets[j].Partition
+	})
+	return offsets, nil
+}
+
+// RestoreOffsets sets the group's committed offsets to offsets, such as
+// those returned earlier by ExportOffsets, so that the group resumes from
+// them. Partitions not listed keep their committed offsets. Kafka only
+// allows this while the group has no active members: stop every instance
+// first, and call it on a consumer created with AssignPartitions, which
+// does not join the group, rather than one subscribed to Topics. It fails
+// with ErrRunning while Start is running.
+func (c *EventConsumer) RestoreOffsets(offsets []PartitionOffset) error {
+	if c.running.Load() {
+		return ErrRunning
+	}
+	if len(offsets) == 0 {
+		return nil
+	}
+	partitions := make([]kafka.TopicPartition, len(offsets))
+	restored := make([]string, len(offsets))
+	for i, p := range offsets {
+		if p.Offset < 0 {
+			return fmt.Errorf("invalid offset %s: offset must be a number", p)
+		}
+		partitions[i] = partitionKey{topic: p.Topic, partition: p.Partition}.at(p.Offset)
+		restored[i] = p.String()
+	}
+
+	admin, err := kafka.NewAdminClientFromConsumer(c.consumer)
+	if err != nil {
+		return fmt.Errorf("failed to create admin client: %w", err)
+	}
+	defer admin.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), snapshotTimeout)
+	defer cancel()
+	result, err := admin.AlterConsumerGroupOffsets(ctx,
+		[]kafka.ConsumerGroupTopicPartitions{{Group: c.groupID, Partitions: partitions}})
+	if err != nil {
+		return fmt.Errorf("failed to restore committed offsets: %w", err)
+	}
+	for _, group := range result.ConsumerGroupsTopicPartitions {
+		for _, tp := range group.Partitions {
+			if tp.Error != nil {
+				return fmt.Errorf("failed to restore committed offset of %s[%d]: %w", *tp.Topic, tp.Partition, tp.Error)
+			}
+		}
+	}
+	c.logger.Warn("Restored committed offsets", "group", c.groupID, "offsets", restored)
+	return nil
+}
+
+// configTopics returns the topics cfg subscribes to or assigns partitions of
+func configTopics(cfg Config) []string {
+	if len(cfg.AssignPartitions) == 0 {
+		return cfg.Topics
+	}
+	seen := make(map[string]bool)
+	var topics []string
+	for _, p := range cfg.AssignPartitions {
+		if !seen[p.Topic] {
+			seen[p.Topic] = true
+			topics = append(topics, p.Topic)
+		}
+	}
+	return topics
+}
@@ -0,0 +1,81 @@
+package consumer
+
+import (
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+)
+
+// offsetsForTimesTimeoutMs bounds the offsets-for-times lookup made when
+// partitions are assigned
+const offsetsForTimesTimeoutMs = 10000
+
+// startPosition tracks which partitions have already been positioned at
+// StartFromTimestamp. It is only used from the rebalance callback, which
+// runs on the Start goroutine.
+type startPosition struct {
+	at   time.Time
+	done map[partitionKey]bool
+}
+
+func newStartPosition(at time.Time) *startPosition {
+	return &startPosition{at: at, done: make(map[partitionKey]bool)}
+}
+
+// startOffsets returns partitions with the offset for StartFromTimestamp
+// filled in for each one not positioned before, and whether any were. If
+// the lookup fails the assignment is returned unchanged, so those
+// partitions resume from their committed offsets and are retried on the
+// next assignment.
+func (c *EventConsumer) startOffsets(consumer *kafka.Consumer, partitions []kafka.TopicPartition) ([]kafka.TopicPartition, bool) {
+	if c.startFrom == nil {
+		return partitions, false
+	}
+
+	var lookup []kafka.TopicPartition
+	for _, tp := range partitions {
+		if key := keyOf(tp); !c.startFrom.done[key] {
+			lookup = append(lookup, key.at(kafka.Offset(c.startFrom.at.UnixMilli())))
+		}
+	}
+	if len(lookup) == 0 {
+		return partitions, false
+	}
+
+	offsets, err := consumer.OffsetsForTimes(lookup, offsetsForTimesTimeoutMs)
+	if err != nil {
+		c.logger.Error("Failed to look up offsets for start timestamp",
+			"start_from", c.startFrom.at, "partitions", partitionList(lookup), "error", err)
+		return partitions, false
+	}
+
+	found := make(map[partitionKey]kafka.Offset, len(offsets))
+	for _, tp := range offsets {
+		if tp.Error != nil {
+			c.logger.Error("Failed to look up offset for start timestamp",
+				"topic", keyOf(tp).topic, "partition", tp.Partition, "error", tp.Error)
+			continue
+		}
+		found[keyOf(tp)] = tp.Offset
+	}
+
+	positioned := make([]kafka.TopicPartition, len(partitions))
+	copy(positioned, partitions)
+	for i, tp := range positioned {
+		key := keyOf(tp)
+		offset, ok := found[key]
+		if !ok {
+			continue
+		}
+		// No message at or after the timestamp yields -1: start at the end
+		if offset < 0 {
+			offset = kafka.OffsetEnd
+		}
+		positioned[i].Offset = offset
+		c.startFrom.done[key] = true
+		c.logger.Info("Starting partition from timestamp",
+			"topic", key.topic, "partition", key.partition,
+			"start_from", c.startFrom.at, "offset", offset.String())
+	}
+	return positioned, len(found) > 0
+}
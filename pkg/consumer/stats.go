@@ -0,0 +1,130 @@
+package consumer
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+)
+
+// clientStats is the subset of librdkafka's statistics JSON exported as
+// metrics. Latencies are in microseconds.
+type clientStats struct {
+	ReplyQ  int64                  `json:"replyq"`
+	Brokers map[string]brokerStats `json:"brokers"`
+	Topics  map[string]struct {
+		Partitions map[string]partitionStats `json:"partitions"`
+	} `json:"topics"`
+}
+
+type brokerStats struct {
+	Source    string      `json:"source"` // "internal" for librdkafka's own pseudo-brokers
+	OutbufCnt int64       `json:"outbuf_cnt"`
+	RTT       windowStats `json:"rtt"`
+	Throttle  windowStats `json:"throttle"`
+}
+
+type windowStats struct {
+	Avg int64 `json:"avg"`
+	P99 int64 `json:"p99"`
+}
+
+type partitionStats struct {
+	FetchqCnt  int64 `json:"fetchq_cnt"`
+	FetchqSize int64 `json:"fetchq_size"`
+}
+
+// statsLabels remembers the label values last reported so that brokers and
+// partitions dropped from the statistics are removed from the gauges
+type statsLabels struct {
+	brokers    map[string]bool
+	partitions map[[2]string]bool // topic, partition
+}
+
+// readMessage behaves like kafka.Consumer.ReadMessage, but also records the
+// statistics and background commit events that ReadMessage discards
+func (c *EventConsumer) readMessage(timeout time.Duration) (*kafka.Message, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		remaining := time.Until(deadline)
+		if remaining < 0 {
+			remaining = 0
+		}
+
+		switch e := c.consumer.Poll(int(remaining.Milliseconds())).(type) {
+		case *kafka.Message:
+			if e.TopicPartition.Error != nil {
+				return e, e.TopicPartition.Error
+			}
+			return e, nil
+		case kafka.Error:
+			return nil, e
+		case *kafka.Stats:
+			c.recordStats(e.String())
+		case kafka.OffsetsCommitted:
+			c.recordCommit(e)
+		}
+
+		if remaining == 0 {
+			return nil, kafka.NewError(kafka.ErrTimedOut, "", false)
+		}
+	}
+}
+
+// recordStats updates the event_consumer_kafka_* gauges from a librdkafka
+// statistics report
+func (c *EventConsumer) recordStats(report string) {
+	var stats clientStats
+	if err := json.Unmarshal([]byte(report), &stats); err != nil {
+		c.logger.Warn("Failed to parse Kafka client statistics", "error", err)
+		return
+	}
+
+	c.metrics.kafkaReplyQueue.Set(float64(stats.ReplyQ))
+
+	brokers := make(map[string]bool, len(stats.Brokers))
+	for name, broker := range stats.Brokers {
+		if broker.Source == "internal" {
+			continue
+		}
+		brokers[name] = true
+		c.metrics.kafkaBrokerRTT.WithLabelValues(name, "avg").Set(microseconds(broker.RTT.Avg))
+		c.metrics.kafkaBrokerRTT.WithLabelValues(name, "p99").Set(microseconds(broker.RTT.P99))
+		c.metrics.kafkaBrokerThrottle.WithLabelValues(name, "avg").Set(microseconds(broker.Throttle.Avg))
+		c.metrics.kafkaBrokerThrottle.WithLabelValues(name, "p99").Set(microseconds(broker.Throttle.P99))
+		c.metrics.kafkaBrokerOutbuf.WithLabelValues(name).Set(float64(broker.OutbufCnt))
+	}
+
+	partitions := make(map[[2]string]bool)
+	for topic, t := range stats.Topics {
+		for partition, p := range t.Partitions {
+			if partition == "-1" { // Unassigned-partition bookkeeping
+				continue
+			}
+			partitions[[2]string{topic, partition}] = true
+			c.metrics.kafkaFetchQueueMessages.WithLabelValues(topic, partition).Set(float64(p.FetchqCnt))
+			c.metrics.kafkaFetchQueueBytes.WithLabelValues(topic, partition).Set(float64(p.FetchqSize))
+		}
+	}
+
+	for name := range c.stats.brokers {
+		if !brokers[name] {
+			for _, stat := range []string{"avg", "p99"} {
+				c.metrics.kafkaBrokerRTT.DeleteLabelValues(name, stat)
+				c.metrics.kafkaBrokerThrottle.DeleteLabelValues(name, stat)
+			}
+			c.metrics.kafkaBrokerOutbuf.DeleteLabelValues(name)
+		}
+	}
+	for key := range c.stats.partitions {
+		if !partitions[key] {
+			c.metrics.kafkaFetchQueueMessages.DeleteLabelValues(key[0], key[1])
+			c.metrics.kafkaFetchQueueBytes.DeleteLabelValues(key[0], key[1])
+		}
+	}
+	c.stats = statsLabels{brokers: brokers, partitions: partitions}
+}
+
+func microseconds(us int64) float64 {
+	return float64(us) / float64(time.Second/time.Microsecond)
+}
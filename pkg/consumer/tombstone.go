@@ -0,0 +1,66 @@
+package consumer
+
+import (
+	"context"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+)
+
+// TombstoneHandler is called with the topic and key of a tombstone: a
+// message with a null or empty value, which compacted topics use to mark a
+// key as deleted. ctx is cancelled when the consumer is closed.
+type TombstoneHandler func(ctx context.Context, topic string, key []byte) error
+
+// SetTombstoneHandler registers a handler for tombstones, e.g. to apply
+// delete semantics in storage. Once it returns nil the offset is committed.
+// If it fails the message is retried like a failed event handler. Without a
+// handler tombstones are counted and skipped. Either way they never reach
+// event handlers or count as decode errors.
+func (c *EventConsumer) SetTombstoneHandler(handler TombstoneHandler) {
+	c.tombstone = handler
+}
+
+// isTombstone reports whether msg carries no value
+func isTombstone(msg *kafka.Message) bool {
+	return len(msg.Value) == 0
+}
+
+// handleTombstone disposes of a tombstone
+func (c *EventConsumer) handleTombstone(ctx context.Context, msg *kafka.Message) {
+	if err := c.callTombstone(ctx, msg); err != nil {
+		c.handleFailure(msg, err)
+		return
+	}
+	c.ack(msg)
+}
+
+// handleBatchTombstone is handleTombstone for batch mode, reporting whether
+// the message may be committed with the batch. If the handler fails it is
+// disposed of by batchFailure; false means the caller must rewind the
+// pending batch so the tombstone is redelivered.
+func (c *EventConsumer) handleBatchTombstone(ctx context.Context, msg *kafka.Message) bool {
+	if err := c.callTombstone(ctx, msg); err != nil {
+		return c.batchFailure(msg, err)
+	}
+	return true
+}
+
+// callTombstone counts a tombstone and passes it to the tombstone handler,
+// recovering a panic as an error
+func (c *EventConsumer) callTombstone(ctx context.Context, msg *kafka.Message) (err error) {
+	topic := keyOf(msg.TopicPartition).topic
+	c.metrics.tombstones.WithLabelValues(topic).Inc()
+	c.logger.Debug("Received tombstone", c.messageAttrs(msg, nil)...)
+	if c.tombstone == nil {
+		return nil
+	}
+
+	defer func() {
+		if err != nil {
+			c.metrics.Errors.WithLabelValues("tombstone").Inc()
+			c.logger.Error("Tombstone handler failed", append(c.messageAttrs(msg, nil), "error", err)...)
+		}
+	}()
+	defer recoverPanic(c.metrics, c.logger, &err, c.messageAttrs(msg, nil)...)
+	return c.tombstone(ctx, topic, msg.Key)
+}
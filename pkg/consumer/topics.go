@@ -0,0 +1,88 @@
+package consumer
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+)
+
+// topicCheckTimeout bounds the metadata and create requests made by
+// VerifyTopics
+const topicCheckTimeout = 10 * time.Second
+
+// verifyTopics checks that every topic exists before subscribing, logging
+// each one's partition count, and creates missing topics when
+// AutoCreateTopics is set
+func (c *EventConsumer) verifyTopics(cfg Config) error {
+	admin, err := kafka.NewAdminClientFromConsumer(c.consumer)
+	if err != nil {
+		return fmt.Errorf("failed to create admin client: %w", err)
+	}
+	defer admin.Close()
+
+	// Listing every topic, rather than asking for each by name, never
+	// triggers the broker's own auto-creation
+	metadata, err := admin.GetMetadata(nil, true, int(topicCheckTimeout.Milliseconds()))
+	if err != nil {
+		return fmt.Errorf("failed to get topic metadata: %w", err)
+	}
+
+	var missing []string
+	for _, topic := range cfg.Topics {
+		meta, ok := metadata.Topics[topic]
+		if !ok || meta.Error.Code() == kafka.ErrUnknownTopicOrPart || meta.Error.Code() == kafka.ErrUnknownTopic {
+			missing = append(missing, topic)
+			continue
+		}
+		if meta.Error.Code() != kafka.ErrNoError {
+			return fmt.Errorf("failed to get metadata for topic %s: %w", topic, meta.Error)
+		}
+		c.logger.Info("Found topic", "topic", topic, "partitions", len(meta.Partitions))
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	if !cfg.AutoCreateTopics {
+		return fmt.Errorf("topics do not exist: %s", strings.Join(missing, ", "))
+	}
+	return c.createTopics(admin, cfg, missing)
+}
+
+// createTopics creates topics with TopicPartitions partitions and
+// TopicReplicationFactor replicas. A topic created concurrently, e.g. by
+// another instance starting at the same time, is not an error.
+func (c *EventConsumer) createTopics(admin *kafka.AdminClient, cfg Config, topics []string) error {
+	partitions, replicas := cfg.TopicPartitions, cfg.TopicReplicationFactor
+	if partitions <= 0 {
+		partitions = -1 // Broker default
+	}
+	if replicas <= 0 {
+		replicas = -1
+	}
+
+	specs := make([]kafka.TopicSpecification, len(topics))
+	for i, topic := range topics {
+		specs[i] = kafka.TopicSpecification{Topic: topic, NumPartitions: partitions, ReplicationFactor: replicas}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), topicCheckTimeout)
+	defer cancel()
+	results, err := admin.CreateTopics(ctx, specs)
+	if err != nil {
+		return fmt.Errorf("failed to create topics: %w", err)
+	}
+	for _, result := range results {
+		switch result.Error.Code() {
+		case kafka.ErrNoError:
+			c.logger.Info("Created topic", "topic", result.Topic, "partitions", partitions, "replication_factor", replicas)
+		case kafka.ErrTopicAlreadyExists:
+			c.logger.Info("Topic created concurrently", "topic", result.Topic)
+		default:
+			return fmt.Errorf("failed to create topic %s: %w", result.Topic, result.Error)
+		}
+	}
+	return nil
+}
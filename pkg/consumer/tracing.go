@@ -0,0 +1,103 @@
+package consumer
+
+import (
+	"context"
+
+	"github.com/assure-compliance/eventid/pkg/schema"
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// tracerName identifies spans created by this package
+const tracerName = "github.com/assure-compliance/eventid/pkg/consumer"
+
+// propagator reads and writes W3C traceparent/tracestate
+var propagator = propagation.TraceContext{}
+
+// headerCarrier adapts Kafka message headers to a propagation.TextMapCarrier
+type headerCarrier struct {
+	msg *kafka.Message
+}
+
+func (h headerCarrier) Get(key string) string {
+	return headerValue(h.msg, key)
+}
+
+func (h headerCarrier) Set(key, value string) {
+	for i := range h.msg.Headers {
+		if h.msg.Headers[i].Key == key {
+			h.msg.Headers[i].Value = []byte(value)
+			return
+		}
+	}
+	h.msg.Headers = append(h.msg.Headers, kafka.Header{Key: key, Value: []byte(value)})
+}
+
+func (h headerCarrier) Keys() []string {
+	keys := make([]string, len(h.msg.Headers))
+	for i, header := range h.msg.Headers {
+		keys[i] = header.Key
+	}
+	return keys
+}
+
+// newTracer returns the tracer for cfg, or a no-op tracer when tracing is
+// disabled
+func newTracer(cfg Config) trace.Tracer {
+	if !cfg.Tracing {
+		return noop.NewTracerProvider().Tracer(tracerName)
+	}
+	provider := cfg.TracerProvider
+	if provider == nil {
+		provider = otel.GetTracerProvider()
+	}
+	return provider.Tracer(tracerName)
+}
+
+// startProcessSpan starts a consumer span for msg, continuing any trace
+// context carried in its headers. The returned context derives from the
+// consumer's lifecycle context.
+func (c *EventConsumer) startProcessSpan(msg *kafka.Message) (context.Context, trace.Span) {
+	ctx := propagator.Extract(c.ctx, headerCarrier{msg})
+
+	key := keyOf(msg.TopicPartition)
+	return c.tracer.Start(ctx, key.topic+" process",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			attribute.String("messaging.system", "kafka"),
+			attribute.String("messaging.operation", "process"),
+			attribute.String("messaging.destination.name", key.topic),
+			attribute.Int("messaging.kafka.destination.partition", int(key.partition)),
+			attribute.Int64("messaging.kafka.message.offset", int64(msg.TopicPartition.Offset)),
+		),
+	)
+}
+
+// annotateSpan records the decoded event on span and injects the span's
+// context into the envelope so storage can continue the trace
+func annotateSpan(ctx context.Context, span trace.Span, event *schema.Event) {
+	span.SetAttributes(
+		attribute.String("event.id", event.ID),
+		attribute.String("event.type", string(event.Type)),
+		attribute.String("event.source", event.Source),
+	)
+
+	if span.SpanContext().IsValid() {
+		event.TraceContext = propagation.MapCarrier{}
+		propagator.Inject(ctx, propagation.MapCarrier(event.TraceContext))
+	}
+}
+
+// endSpan records err, if any, and ends span
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,67 @@
+package consumer
+
+import (
+	"fmt"
+
+	"github.com/assure-compliance/eventid/pkg/schema"
+)
+
+// Transformer rewrites a message value into the canonical event shape
+// before it is deserialized, so that producers emitting a legacy format can
+// be consumed without changing the schema package. eventType is taken from
+// the schema.DefaultEventTypeHeader header and is empty if the message has
+// none. A returned error makes the message undecodable.
+type Transformer func(eventType schema.EventType, raw []byte) ([]byte, error)
+
+// headerTransformer applies to messages carrying a header with a value
+type headerTransformer struct {
+	name, value string
+	transform   Transformer
+}
+
+// RegisterTransformer sets the transformer applied to every message from
+// topic, replacing any set before. RegisterTransformer must be called before
+// Start.
+func (c *EventConsumer) RegisterTransformer(topic string, transformer Transformer) {
+	if c.transformers == nil {
+		c.transformers = make(map[string]Transformer)
+	}
+	c.transformers[topic] = transformer
+}
+
+// RegisterHeaderTransformer adds a transformer applied to messages whose
+// header name has the given value, e.g. a producer marking its messages
+// with format=v1. It takes precedence over one set for the topic with
+// RegisterTransformer, and the first registered wins when several match.
+// RegisterHeaderTransformer must be called before Start.
+func (c *EventConsumer) RegisterHeaderTransformer(name, value string, transformer Transformer) {
+	c.headerTransformers = append(c.headerTransformers, headerTransformer{name: name, value: value, transform: transformer})
+}
+
+// transformerFor returns the transformer selected for msg, if any
+func (c *EventConsumer) transformerFor(msg schema.Message) Transformer {
+	for _, t := range c.headerTransformers {
+		if value, ok := msg.Headers[t.name]; ok && value == t.value {
+			return t.transform
+		}
+	}
+	return c.transformers[msg.Topic]
+}
+
+// transform applies the transformer selected for msg to its value,
+// recovering a panic as an error
+func (c *EventConsumer) transform(msg *schema.Message) (err error) {
+	transformer := c.transformerFor(*msg)
+	if transformer == nil {
+		return nil
+	}
+	defer recoverPanic(c.metrics, c.logger, &err, "topic", msg.Topic)
+
+	value, err := transformer(schema.EventType(msg.Headers[schema.DefaultEventTypeHeader]), msg.Value)
+	if err != nil {
+		return fmt.Errorf("failed to transform message: %w", err)
+	}
+	c.metrics.transformedMessages.WithLabelValues(msg.Topic).Inc()
+	msg.Value = value
+	return nil
+}
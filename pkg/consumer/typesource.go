@@ -0,0 +1,88 @@
+package consumer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/assure-compliance/eventid/pkg/schema"
+)
+
+// Where event types are read from, set with Config.TypeSource
+const (
+	TypeSourcePayload = "payload" // The envelope's event_type field (the default)
+	TypeSourceHeader  = "header"  // The TypeHeader message header
+	TypeSourceField   = "field"   // The top-level payload field named by TypeField
+)
+
+// TypeResolver returns the event type of a consumed message. msg is the
+// message as passed to the deserializer, after any Transformer, and event
+// the envelope decoded from it, whose Type is the payload's event_type if
+// it has one.
+type TypeResolver func(msg schema.Message, event *schema.Event) (schema.EventType, error)
+
+// ErrNoEventType is returned, wrapped, by the resolvers in this package for
+// messages that do not carry a type where one is expected
+var ErrNoEventType = errors.New("no event type")
+
+// newTypeResolver validates the TypeSource settings and returns the
+// resolver to apply, or nil if types are read from the payload envelope
+func newTypeResolver(cfg Config) (TypeResolver, error) {
+	if cfg.TypeResolver != nil {
+		if cfg.TypeSource != "" {
+			return nil, errors.New("TypeSource cannot be used with TypeResolver")
+		}
+		return cfg.TypeResolver, nil
+	}
+
+	switch cfg.TypeSource {
+	case "", TypeSourcePayload:
+		return nil, nil
+	case TypeSourceHeader:
+		header := cfg.TypeHeader
+		if header == "" {
+			header = schema.DefaultEventTypeHeader
+		}
+		return HeaderType(header), nil
+	case TypeSourceField:
+		if cfg.TypeField == "" {
+			return nil, errors.New("TypeSource field requires TypeField")
+		}
+		return FieldType(cfg.TypeField), nil
+	default:
+		return nil, fmt.Errorf("unknown TypeSource %q", cfg.TypeSource)
+	}
+}
+
+// HeaderType returns a TypeResolver reading the type from the named
+// message header
+func HeaderType(name string) TypeResolver {
+	return func(msg schema.Message, _ *schema.Event) (schema.EventType, error) {
+		value := msg.Headers[name]
+		if value == "" {
+			return "", fmt.Errorf("%w: message has no %s header", ErrNoEventType, name)
+		}
+		return schema.EventType(value), nil
+	}
+}
+
+// FieldType returns a TypeResolver reading the type from the named
+// top-level string field of the event's JSON payload
+func FieldType(name string) TypeResolver {
+	return func(_ schema.Message, event *schema.Event) (schema.EventType, error) {
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(event.Payload, &fields); err != nil {
+			return "", fmt.Errorf("failed to unmarshal event payload: %w", err)
+		}
+		var value string
+		if raw, ok := fields[name]; ok {
+			if err := json.Unmarshal(raw, &value); err != nil {
+				return "", fmt.Errorf("%w: payload field %s is not a string", ErrNoEventType, name)
+			}
+		}
+		if value == "" {
+			return "", fmt.Errorf("%w: payload has no %s field", ErrNoEventType, name)
+		}
+		return schema.EventType(value), nil
+	}
+}
@@ -0,0 +1,63 @@
+package consumer
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/assure-compliance/eventid/pkg/schema"
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+)
+
+// Policies for events whose type schema.ParseEventType does not recognize,
+// set with Config.UnknownTypePolicy
+const (
+	UnknownTypeStore      = "store"       // Handle as usual (the default)
+	UnknownTypeDeadLetter = "dead_letter" // Dead-letter and skip
+	UnknownTypeDrop       = "drop"        // Log and skip
+)
+
+// ErrUnknownEventType is passed to the dead-letter handler for events
+// skipped by the UnknownTypeDeadLetter policy
+var ErrUnknownEventType = errors.New("unknown event type")
+
+// newUnknownTypePolicy validates Config.UnknownTypePolicy
+func newUnknownTypePolicy(cfg Config) (string, error) {
+	switch cfg.UnknownTypePolicy {
+	case "":
+		return UnknownTypeStore, nil
+	case UnknownTypeStore, UnknownTypeDeadLetter, UnknownTypeDrop:
+		return cfg.UnknownTypePolicy, nil
+	default:
+		return "", fmt.Errorf("unknown UnknownTypePolicy %q", cfg.UnknownTypePolicy)
+	}
+}
+
+// knownType applies the unknown type policy to event, returning false if
+// the caller should skip it without invoking handlers. commit reports
+// whether a skipped event may be committed: false means its dead letter was
+// not delivered and it must be redelivered. Every unknown event is counted
+// by the action taken.
+func (c *EventConsumer) knownType(msg *kafka.Message, event *schema.Event) (known, commit bool) {
+	if _, ok := schema.ParseEventType(string(event.Type)); ok {
+		return true, true
+	}
+
+	attrs := c.messageAttrs(msg, event)
+	switch {
+	case c.unknownTypes == UnknownTypeStore:
+		c.metrics.unknownTypeEvents.WithLabelValues("stored").Inc()
+		c.logger.Debug("Handling event of unknown type", attrs...)
+		return true, true
+	case c.unknownTypes == UnknownTypeDeadLetter && c.deadLetter != nil:
+		c.metrics.unknownTypeEvents.WithLabelValues("dead_lettered").Inc()
+		c.logger.Warn("Dead-lettering event of unknown type", attrs...)
+		return false, c.sendDeadLetter(msg, fmt.Errorf("%w %q", ErrUnknownEventType, event.Type))
+	case c.unknownTypes == UnknownTypeDeadLetter:
+		c.metrics.unknownTypeEvents.WithLabelValues("dropped").Inc()
+		c.logger.Error("Dropping event of unknown type, no dead-letter handler is set", attrs...)
+	default:
+		c.metrics.unknownTypeEvents.WithLabelValues("dropped").Inc()
+		c.logger.Warn("Dropping event of unknown type", attrs...)
+	}
+	return false, true
+}
@@ -0,0 +1,36 @@
+package consumer
+
+import (
+	"errors"
+
+	"github.com/assure-compliance/eventid/pkg/schema"
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+)
+
+// validate checks event against the payload limits and its registered JSON
+// schema, counting the result. Invalid events are logged and, if
+// DeadLetterInvalid is set outside ValidateOnly mode, dead-lettered; the
+// caller should skip them without invoking handlers. commit reports whether
+// an invalid event may be committed: false means its dead letter was not
+// delivered and it must be redelivered.
+func (c *EventConsumer) validate(msg *kafka.Message, event *schema.Event) (valid, commit bool) {
+	err := schema.Validate(event)
+	if err == nil {
+		c.metrics.validationPassed.WithLabelValues(string(event.Type)).Inc()
+		return true, true
+	}
+	c.metrics.validationFailed.WithLabelValues(string(event.Type)).Inc()
+
+	var limitErr *schema.PayloadLimitError
+	if errors.As(err, &limitErr) {
+		c.metrics.Errors.WithLabelValues("payload_limit").Inc()
+	} else {
+		c.metrics.Errors.WithLabelValues("validation").Inc()
+	}
+	c.logger.Warn("Rejected invalid event", append(c.messageAttrs(msg, event), "error", err)...)
+
+	if c.deadLetterInvalid && !c.validateOnly {
+		return false, c.sendDeadLetter(msg, err)
+	}
+	return false, true
+}
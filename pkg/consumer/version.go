@@ -0,0 +1,45 @@
+package consumer
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/assure-compliance/eventid/pkg/schema"
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+)
+
+// DefaultSchemaVersionHeader is the Kafka header read for the schema version
+// of events whose payload has no event_version
+const DefaultSchemaVersionHeader = "schema-version"
+
+// versionKey identifies the handler for one schema version of an event type
+type versionKey struct {
+	eventType schema.EventType
+	version   int
+}
+
+// RegisterVersionHandler registers a handler for one schema version of an
+// event type, so that payload versions can be handled differently during a
+// migration. Handlers registered with RegisterHandler act as the wildcard
+// for versions without their own handler; a RegisterHandlerForTopic handler
+// for the type still takes precedence. Handlers are wrapped like
+// RegisterHandler.
+func (c *EventConsumer) RegisterVersionHandler(eventType schema.EventType, version int, handler EventHandler) {
+	c.handlersMu.Lock()
+	defer c.handlersMu.Unlock()
+	c.byVersion[versionKey{eventType, version}] = handler
+}
+
+// schemaVersion returns the version in the schema version header of msg,
+// or 0 if it has none
+func (c *EventConsumer) schemaVersion(msg *kafka.Message) (int, error) {
+	value := headerValue(msg, c.schemaVersionHeader)
+	if value == "" {
+		return 0, nil
+	}
+	version, err := strconv.Atoi(value)
+	if err != nil || version < 1 {
+		return 0, fmt.Errorf("invalid %s header %q", c.schemaVersionHeader, value)
+	}
+	return version, nil
+}
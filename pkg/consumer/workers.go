@@ -0,0 +1,84 @@
+package consumer
+
+import (
+	"hash/fnv"
+	"sync"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+)
+
+// workerQueueSize is the number of messages buffered for each worker
+const workerQueueSize = 64
+
+// workerPool processes messages on Concurrency goroutines. Messages with the
+// same key always go to the same worker, so they are handled in order.
+type workerPool struct {
+	queues []chan queuedMessage
+	wg     sync.WaitGroup
+}
+
+// queuedMessage is a message waiting for a worker, with the sequence number
+// the offset tracker gave it in begin
+type queuedMessage struct {
+	msg *kafka.Message
+	seq uint64
+}
+
+// startWorkers starts n workers running processMessage
+func (c *EventConsumer) startWorkers(n int) *workerPool {
+	p := &workerPool{queues: make([]chan queuedMessage, n)}
+	for i := range p.queues {
+		queue := make(chan queuedMessage, workerQueueSize)
+		p.queues[i] = queue
+
+		p.wg.Add(1)
+		go func() {
+			defer p.wg.Done()
+			for queued := range queue {
+				msg := queued.msg
+				// Once stopping or closed, leave queued messages uncommitted
+				// so they are redelivered rather than delaying shutdown or
+				// touching a closed consumer. Messages read before their
+				// partition was rewound are dropped, as they are read again
+				// after the redelivered ones.
+				select {
+				case <-c.stop:
+				case <-c.done:
+				default:
+					if c.tracker.superseded(msg.TopicPartition, queued.seq) {
+						c.logger.Debug("Dropping message to be read again", c.messageAttrs(msg, nil)...)
+					} else {
+						// Errors are logged by processMessage
+						c.processMessage(msg)
+					}
+				}
+				c.inFlight.Add(-1)
+				c.flight.done(msg.TopicPartition)
+			}
+		}()
+	}
+	return p
+}
+
+// dispatch queues msg, begun as seq, on the worker for its key. Messages
+// without a key are spread by partition, keeping each partition's unkeyed
+// messages in order.
+func (p *workerPool) dispatch(msg *kafka.Message, seq uint64) {
+	h := fnv.New32a()
+	if len(msg.Key) > 0 {
+		h.Write(msg.Key)
+	} else {
+		key := keyOf(msg.TopicPartition)
+		h.Write([]byte(key.topic))
+		h.Write([]byte{byte(key.partition >> 24), byte(key.partition >> 16), byte(key.partition >> 8), byte(key.partition)})
+	}
+	p.queues[h.Sum32()%uint32(len(p.queues))] <- queuedMessage{msg, seq}
+}
+
+// stop closes the queues and waits for the workers to exit
+func (p *workerPool) stop() {
+	for _, queue := range p.queues {
+		close(queue)
+	}
+	p.wg.Wait()
+}
@@ -0,0 +1,175 @@
+package consumer_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/assure-compliance/eventid/pkg/consumer/consumertest"
+	"github.com/assure-compliance/eventid/pkg/schema"
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+)
+
+// Workers redeliver failed messages while the read loop keeps beginning new
+// ones; the committed offset must never pass a message that has not been
+// handled yet
+func TestRedeliveryNeverCommitsPastUnfinished(t *testing.T) {
+	t.Parallel()
+	const count = 300
+	h := consumertest.New(t, consumertest.Config{})
+	cfg := h.ConsumerConfig()
+	cfg.Concurrency = 8
+
+	// One partition, so the i-th event published is at offset i
+	index := make(map[string]int, count)
+	events := make([]*schema.Event, count)
+	for i := range events {
+		events[i] = consumertest.NewEvent(t, schema.EventViolationFound)
+		index[events[i].ID] = i
+		h.Publish(events[i])
+	}
+
+	var handled [count]atomic.Bool
+	var mu sync.Mutex
+	failed := make(map[int]bool)
+	c := h.NewConsumer(cfg)
+	c.RegisterDefaultHandler(func(_ context.Context, event *schema.Event) error {
+		i := index[event.ID]
+		time.Sleep(time.Duration(rand.Intn(2000)) * time.Microsecond)
+		mu.Lock()
+		retry := i%5 == 0 && !failed[i]
+		failed[i] = true
+		mu.Unlock()
+		if retry {
+			return errors.New("first attempt fails")
+		}
+		handled[i].Store(true)
+		return nil
+	})
+
+	// committed checks the group's committed offset against the handled
+	// events, returning the offset
+	var violations []string
+	committed := func() kafka.Offset {
+		next := h.Committed(cfg.GroupID)[0]
+		// Events are marked handled before their offsets are committed
+		for i := 0; i < int(next) && i < count; i++ {
+			if !handled[i].Load() {
+				violations = append(violations, fmt.Sprintf("committed %d before offset %d was handled", next, i))
+				break
+			}
+		}
+		return next
+	}
+
+	h.Start(c)
+	err := h.WaitFor(30*time.Second, func() bool { return committed() >= count })
+	if err != nil {
+		t.Errorf("offset %d of %d committed: %v", committed(), count, err)
+	}
+	for _, violation := range violations {
+		t.Error(violation)
+	}
+}
+
+// Events sharing a key are handled in order across a redelivery: those
+// queued behind a failed event are dropped and read again after it, not
+// handled ahead of it
+func TestRedeliveryKeepsKeyOrder(t *testing.T) {
+	t.Parallel()
+	const count = 50
+	const failing = 5
+	h := consumertest.New(t, consumertest.Config{})
+	cfg := h.ConsumerConfig()
+	cfg.Concurrency = 4
+
+	index := make(map[string]int, count)
+	for i := 0; i < count; i++ {
+		event := consumertest.NewEvent(t, schema.EventViolationFound)
+		event.EntityID = "entity" // One key, so one worker
+		index[event.ID] = i
+		h.Publish(event)
+	}
+
+	var mu sync.Mutex
+	var order []int
+	var failed atomic.Bool
+	c := h.NewConsumer(cfg)
+	c.RegisterDefaultHandler(func(_ context.Context, event *schema.Event) error {
+		i := index[event.ID]
+		if i == failing && !failed.Swap(true) {
+			return errors.New("first attempt fails")
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		order = append(order, i)
+		return nil
+	})
+	h.Start(c)
+	h.WaitForCommitted(cfg.GroupID, 0, count, 30*time.Second)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(order) != count {
+		t.Errorf("handled %d events, want %d", len(order), count)
+	}
+	for i, handled := range order {
+		if handled != i {
+			t.Fatalf("handled event %d in position %d: %v", handled, i, order)
+		}
+	}
+}
+
+// BenchmarkConcurrency compares the throughput of the serial path with
+// Concurrency workers, for a handler that waits a millisecond on each event
+// as a database write would. Group join is excluded from the timing. The
+// mock cluster fetches slowly from a single partition, so events are spread
+// over 16 partitions to keep fetching from being the bottleneck.
+func BenchmarkConcurrency(b *testing.B) {
+	for _, concurrency := range []int{1, 4, 16} {
+		b.Run(fmt.Sprintf("concurrency=%d", concurrency), func(b *testing.B) {
+			h := consumertest.New(b, consumertest.Config{Partitions: 16})
+			cfg := h.ConsumerConfig()
+			cfg.Concurrency = concurrency
+			cfg.Logger = slog.New(slog.NewTextHandler(io.Discard, nil)) // Per-event logs would swamp the output
+
+			var handled atomic.Int64
+			c := h.NewConsumer(cfg)
+			c.RegisterDefaultHandler(func(context.Context, *schema.Event) error {
+				time.Sleep(time.Millisecond)
+				handled.Add(1)
+				return nil
+			})
+			h.Start(c)
+
+			warmup := consumertest.NewEvent(b, schema.EventViolationFound)
+			h.Publish(warmup)
+			if err := h.WaitFor(30*time.Second, func() bool { return handled.Load() == 1 }); err != nil {
+				b.Fatalf("consumer did not start: %v", err)
+			}
+			if err := c.Pause(); err != nil {
+				b.Fatalf("failed to pause: %v", err)
+			}
+			for i := 0; i < b.N; i++ {
+				h.Publish(consumertest.NewEvent(b, schema.EventViolationFound))
+			}
+
+			b.ResetTimer()
+			if err := c.Resume(); err != nil {
+				b.Fatalf("failed to resume: %v", err)
+			}
+			err := h.WaitFor(time.Minute, func() bool { return handled.Load() == int64(b.N)+1 })
+			b.StopTimer()
+			if err != nil {
+				b.Fatalf("handled %d of %d events: %v", handled.Load()-1, b.N, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,21 @@
+package producer
+
+import (
+	"log/slog"
+	"os"
+)
+
+// Logger is the structured logger used by the producer. Arguments after the
+// message are alternating keys and values, as with log/slog; *slog.Logger
+// satisfies this interface.
+type Logger interface {
+	Debug(msg string, args ...any)
+	Info(msg string, args ...any)
+	Warn(msg string, args ...any)
+	Error(msg string, args ...any)
+}
+
+// defaultLogger writes JSON log lines to stderr
+func defaultLogger() Logger {
+	return slog.New(slog.NewJSONHandler(os.Stderr, nil))
+}
@@ -0,0 +1,58 @@
+package producer
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Metrics holds the package's Prometheus metrics. Create them with
+// NewMetrics and pass them in Config.Metrics to register a producer's
+// metrics with a registry of your own.
+type Metrics struct {
+	published       *prometheus.CounterVec
+	publishDuration prometheus.Histogram
+}
+
+var (
+	defaultMetrics     *Metrics
+	defaultMetricsOnce sync.Once
+)
+
+// DefaultMetrics returns the metrics registered with the default registry,
+// used where none are configured. They are created on first use.
+func DefaultMetrics() *Metrics {
+	defaultMetricsOnce.Do(func() {
+		defaultMetrics = NewMetrics(prometheus.DefaultRegisterer, "", "")
+	})
+	return defaultMetrics
+}
+
+// NewMetrics creates the package's metrics and registers them with reg.
+// Their names are prefixed by namespace and subsystem through
+// prometheus.Opts, so that namespace "billing" turns
+// event_producer_published_total into
+// billing_event_producer_published_total; either may be empty. Like
+// promauto, it panics if the metrics are already registered with reg.
+func NewMetrics(reg prometheus.Registerer, namespace, subsystem string) *Metrics {
+	f := promauto.With(reg)
+	m := &Metrics{}
+	m.published = f.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "event_producer_published_total",
+			Help:      "Total number of events published, by event type and status (delivered, failed)",
+		},
+		[]string{"event_type", "status"},
+	)
+	m.publishDuration = f.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "event_producer_publish_duration_seconds",
+		Help:      "Time from queueing an event to its delivery report",
+		Buckets:   prometheus.DefBuckets,
+	})
+	return m
+}
@@ -0,0 +1,278 @@
+// Package producer publishes events to Kafka in the form EventConsumer
+// reads, so that other services can emit them without depending on the
+// consumer's internals
+package producer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/assure-compliance/eventid/pkg/schema"
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// DefaultCorrelationHeader, DefaultCausationHeader and DefaultTenantHeader
+// match the consumer package's defaults
+const (
+	DefaultCorrelationHeader = "correlation-id"
+	DefaultCausationHeader   = "causation-id"
+	DefaultTenantHeader      = "tenant-id"
+)
+
+// flushTimeoutMs bounds each flush while an EventProducer is closed
+const flushTimeoutMs = 1000
+
+// propagator writes W3C traceparent/tracestate headers
+var propagator = propagation.TraceContext{}
+
+// validSecurityProtocols are the values librdkafka accepts for security.protocol
+var validSecurityProtocols = map[string]bool{
+	"plaintext":      true,
+	"ssl":            true,
+	"sasl_plaintext": true,
+	"sasl_ssl":       true,
+}
+
+// Config configures an EventProducer
+type Config struct {
+	BootstrapServers string
+	Topic            string // Topic events are published to
+
+	// Authentication settings, as in consumer.Config
+	SecurityProtocol string
+	SASLMechanism    string
+	SASLUsername     string
+	SASLPassword     string
+	SSLCALocation    string
+
+	// Serializer encodes event envelopes (default schema.JSONSerializer). It
+	// must match the Deserializer used by consumers of Topic.
+	Serializer schema.Serializer
+
+	// TypeHeader, CorrelationHeader, CausationHeader and TenantHeader name
+	// the headers carrying the event type, correlation ID, causation ID and
+	// tenant ID (default schema.DefaultEventTypeHeader,
+	// DefaultCorrelationHeader, DefaultCausationHeader and
+	// DefaultTenantHeader)
+	TypeHeader        string
+	CorrelationHeader string
+	CausationHeader   string
+	TenantHeader      string
+
+	Logger  Logger   // Defaults to JSON on stderr
+	Metrics *Metrics // Defaults to DefaultMetrics()
+}
+
+// EventProducer publishes event envelopes to a Kafka topic with idempotent,
+// fully acknowledged writes
+type EventProducer struct {
+	producer          *kafka.Producer
+	topic             string
+	serializer        schema.Serializer
+	typeHeader        string
+	correlationHeader string
+	causationHeader   string
+	tenantHeader      string
+	logger            Logger
+	metrics           *Metrics
+
+	wg        sync.WaitGroup
+	closeOnce sync.Once
+}
+
+// NewEventProducer creates an EventProducer from cfg
+func NewEventProducer(cfg Config) (*EventProducer, error) {
+	if cfg.Topic == "" {
+		return nil, errors.New("producer topic is required")
+	}
+	if cfg.Serializer == nil {
+		cfg.Serializer = schema.JSONSerializer{}
+	}
+	if cfg.TypeHeader == "" {
+		cfg.TypeHeader = schema.DefaultEventTypeHeader
+	}
+	if cfg.CorrelationHeader == "" {
+		cfg.CorrelationHeader = DefaultCorrelationHeader
+	}
+	if cfg.CausationHeader == "" {
+		cfg.CausationHeader = DefaultCausationHeader
+	}
+	if cfg.TenantHeader == "" {
+		cfg.TenantHeader = DefaultTenantHeader
+	}
+	if cfg.Logger == nil {
+		cfg.Logger = defaultLogger()
+	}
+	if cfg.Metrics == nil {
+		cfg.Metrics = DefaultMetrics()
+	}
+
+	config := &kafka.ConfigMap{
+		"bootstrap.servers":  cfg.BootstrapServers,
+		"acks":               "all",
+		"enable.idempotence": true,
+	}
+	if err := applySecurity(cfg, config); err != nil {
+		return nil, err
+	}
+
+	producer, err := kafka.NewProducer(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create producer: %w", err)
+	}
+
+	p := &EventProducer{
+		producer:          producer,
+		topic:             cfg.Topic,
+		serializer:        cfg.Serializer,
+		typeHeader:        cfg.TypeHeader,
+		correlationHeader: cfg.CorrelationHeader,
+		causationHeader:   cfg.CausationHeader,
+		tenantHeader:      cfg.TenantHeader,
+		logger:            cfg.Logger,
+		metrics:           cfg.Metrics,
+	}
+	p.wg.Add(1)
+	go p.handleEvents()
+	return p, nil
+}
+
+// Publish serializes event, sends it with the event type, correlation ID,
+// causation ID, tenant ID and the trace context of ctx as headers, and waits for the broker to
+// acknowledge it. The message is keyed by event.EntityID, so every event for
+// an entity goes to the same partition, or by event ID when it is empty. If ctx ends first, Publish returns its error and
+// the message may still be delivered.
+func (p *EventProducer) Publish(ctx context.Context, event *schema.Event) error {
+	value, err := p.serializer.Serialize(event)
+	if err != nil {
+		return fmt.Errorf("failed to serialize event %s: %w", event.ID, err)
+	}
+
+	msg := &kafka.Message{
+		TopicPartition: kafka.TopicPartition{Topic: &p.topic, Partition: kafka.PartitionAny},
+		Key:            []byte(key(event)),
+		Value:          value,
+		Headers:        p.headers(ctx, event),
+	}
+
+	delivery := make(chan kafka.Event, 1)
+	started := time.Now()
+	if err := p.producer.Produce(msg, delivery); err != nil {
+		p.metrics.published.WithLabelValues(string(event.Type), "failed").Inc()
+		return fmt.Errorf("failed to queue event %s: %w", event.ID, err)
+	}
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case e := <-delivery:
+		p.metrics.publishDuration.Observe(time.Since(started).Seconds())
+		report := e.(*kafka.Message)
+		if err := report.TopicPartition.Error; err != nil {
+			p.metrics.published.WithLabelValues(string(event.Type), "failed").Inc()
+			return fmt.Errorf("failed to publish event %s: %w", event.ID, err)
+		}
+		p.metrics.published.WithLabelValues(string(event.Type), "delivered").Inc()
+		p.logger.Debug("Published event",
+			"event_id", event.ID,
+			"entity_id", event.EntityID,
+			"event_type", string(event.Type),
+			"partition", report.TopicPartition.Partition,
+			"offset", int64(report.TopicPartition.Offset))
+		return nil
+	}
+}
+
+// key returns the message key for event
+func key(event *schema.Event) string {
+	if event.EntityID != "" {
+		return event.EntityID
+	}
+	return event.ID
+}
+
+// headers returns the message headers for event: event.Headers, overridden
+// by the standard headers
+func (p *EventProducer) headers(ctx context.Context, event *schema.Event) []kafka.Header {
+	carrier := propagation.MapCarrier{}
+	propagator.Inject(ctx, carrier)
+	standard := map[string]bool{p.typeHeader: true, p.correlationHeader: true, p.causationHeader: true, p.tenantHeader: true}
+	for key := range carrier {
+		standard[key] = true
+	}
+
+	var headers []kafka.Header
+	for key, value := range event.Headers {
+		if !standard[key] {
+			headers = append(headers, kafka.Header{Key: key, Value: []byte(value)})
+		}
+	}
+	headers = append(headers, kafka.Header{Key: p.typeHeader, Value: []byte(event.Type)})
+	if event.CorrelationID != "" {
+		headers = append(headers, kafka.Header{Key: p.correlationHeader, Value: []byte(event.CorrelationID)})
+	}
+	if event.CausationID != "" {
+		headers = append(headers, kafka.Header{Key: p.causationHeader, Value: []byte(event.CausationID)})
+	}
+	if event.TenantID != "" {
+		headers = append(headers, kafka.Header{Key: p.tenantHeader, Value: []byte(event.TenantID)})
+	}
+	for key, value := range carrier {
+		headers = append(headers, kafka.Header{Key: key, Value: []byte(value)})
+	}
+	return headers
+}
+
+// handleEvents logs client errors until the producer is closed. Delivery
+// reports go to each Publish call's own channel.
+func (p *EventProducer) handleEvents() {
+	defer p.wg.Done()
+
+	for e := range p.producer.Events() {
+		if err, ok := e.(kafka.Error); ok {
+			p.logger.Error("Producer error", "error", err)
+		}
+	}
+}
+
+// Close waits for outstanding deliveries and closes the producer
+func (p *EventProducer) Close() {
+	p.closeOnce.Do(func() {
+		for p.producer.Flush(flushTimeoutMs) > 0 {
+		}
+		p.producer.Close()
+		p.wg.Wait()
+	})
+}
+
+// applySecurity copies the authentication settings from cfg into config.
+// It returns an error when a SASL mechanism is chosen without credentials.
+func applySecurity(cfg Config, config *kafka.ConfigMap) error {
+	if cfg.SecurityProtocol != "" {
+		if !validSecurityProtocols[strings.ToLower(cfg.SecurityProtocol)] {
+			return fmt.Errorf("invalid security protocol %q: must be one of PLAINTEXT, SSL, SASL_PLAINTEXT, SASL_SSL",
+				cfg.SecurityProtocol)
+		}
+		config.SetKey("security.protocol", cfg.SecurityProtocol)
+	}
+
+	if cfg.SASLMechanism != "" {
+		if cfg.SASLUsername == "" || cfg.SASLPassword == "" {
+			return fmt.Errorf("SASL mechanism %s requires both a username and a password", cfg.SASLMechanism)
+		}
+		config.SetKey("sasl.mechanisms", cfg.SASLMechanism)
+		config.SetKey("sasl.username", cfg.SASLUsername)
+		config.SetKey("sasl.password", cfg.SASLPassword)
+	}
+
+	if cfg.SSLCALocation != "" {
+		config.SetKey("ssl.ca.location", cfg.SSLCALocation)
+	}
+
+	return nil
+}
@@ -0,0 +1,259 @@
+package schema
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/schemaregistry"
+	"github.com/linkedin/goavro/v2"
+)
+
+// wireMagicByte starts every value in the Confluent wire format, followed by
+// a 4-byte big-endian schema ID
+const wireMagicByte = 0
+
+// wireHeaderSize is the length of the magic byte and schema ID
+const wireHeaderSize = 5
+
+// RegistryConfig locates a Confluent Schema Registry
+type RegistryConfig struct {
+	URL      string
+	Username string // Optional basic auth credentials
+	Password string
+}
+
+// AvroDeserializer decodes Avro values in the Confluent wire format. The
+// writer schema is fetched from the Schema Registry by the ID in each value
+// and cached. Records are converted to JSON with the same field names as the
+// JSON events, so the envelope is built exactly as for JSON.
+type AvroDeserializer struct {
+	registry schemaregistry.Client
+
+	mu     sync.RWMutex
+	codecs map[int]*avroCodec
+}
+
+// avroCodec pairs a compiled schema with its parsed form and named types,
+// which are needed to unwrap union values when converting to JSON
+type avroCodec struct {
+	codec  *goavro.Codec
+	schema interface{}
+	names  map[string]interface{}
+}
+
+// NewAvroDeserializer creates an Avro deserializer backed by the registry
+// described by cfg
+func NewAvroDeserializer(cfg RegistryConfig) (*AvroDeserializer, error) {
+	if cfg.URL == "" {
+		return nil, errors.New("schema registry URL is required for Avro")
+	}
+
+	registry, err := newRegistryClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &AvroDeserializer{registry: registry, codecs: make(map[int]*avroCodec)}, nil
+}
+
+func newRegistryClient(cfg RegistryConfig) (schemaregistry.Client, error) {
+	conf := schemaregistry.NewConfig(cfg.URL)
+	if cfg.Username != "" || cfg.Password != "" {
+		conf = schemaregistry.NewConfigWithAuthentication(cfg.URL, cfg.Username, cfg.Password)
+	}
+	registry, err := schemaregistry.NewClient(conf)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create schema registry client: %w", err)
+	}
+	return registry, nil
+}
+
+// Deserialize decodes an Avro value into an event envelope
+func (d *AvroDeserializer) Deserialize(msg Message) (*Event, error) {
+	id, body, err := splitWireFormat(msg.Value)
+	if err != nil {
+		return nil, err
+	}
+
+	codec, err := d.codec(id)
+	if err != nil {
+		return nil, err
+	}
+
+	native, _, err := codec.codec.NativeFromBinary(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode Avro value with schema %d: %w", id, err)
+	}
+
+	data, err := json.Marshal(codec.toJSON(codec.schema, native, ""))
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert Avro value to JSON: %w", err)
+	}
+	return ParseEvent(data)
+}
+
+// codec returns the cached codec for schema id, fetching it if needed
+func (d *AvroDeserializer) codec(id int) (*avroCodec, error) {
+	d.mu.RLock()
+	codec, ok := d.codecs[id]
+	d.mu.RUnlock()
+	if ok {
+		return codec, nil
+	}
+
+	info, err := d.registry.GetBySubjectAndID("", id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch schema %d: %w", id, err)
+	}
+
+	compiled, err := goavro.NewCodec(info.Schema)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse Avro schema %d: %w", id, err)
+	}
+	var parsed interface{}
+	if err := json.Unmarshal([]byte(info.Schema), &parsed); err != nil {
+		// Bare primitive schemas such as "string" are not valid JSON documents
+		parsed = strings.Trim(info.Schema, `" `)
+	}
+
+	codec = &avroCodec{codec: compiled, schema: parsed, names: make(map[string]interface{})}
+	codec.collectNames(parsed, "")
+	d.mu.Lock()
+	d.codecs[id] = codec
+	d.mu.Unlock()
+	return codec, nil
+}
+
+// splitWireFormat returns the schema ID and body of a Confluent wire-format
+// value
+func splitWireFormat(value []byte) (int, []byte, error) {
+	if len(value) < wireHeaderSize || value[0] != wireMagicByte {
+		return 0, nil, errors.New("value is not in Schema Registry wire format")
+	}
+	return int(binary.BigEndian.Uint32(value[1:wireHeaderSize])), value[wireHeaderSize:], nil
+}
+
+// collectNames records every named type defined in schema by full name
+func (c *avroCodec) collectNames(schema interface{}, namespace string) {
+	switch s := schema.(type) {
+	case []interface{}:
+		for _, branch := range s {
+			c.collectNames(branch, namespace)
+		}
+	case map[string]interface{}:
+		if _, ok := s["name"].(string); ok {
+			c.names[avroTypeName(s, namespace)] = s
+		}
+		if ns, ok := s["namespace"].(string); ok {
+			namespace = ns
+		}
+		if fields, ok := s["fields"].([]interface{}); ok {
+			for _, f := range fields {
+				if field, ok := f.(map[string]interface{}); ok {
+					c.collectNames(field["type"], namespace)
+				}
+			}
+		}
+		c.collectNames(s["items"], namespace)
+		c.collectNames(s["values"], namespace)
+	}
+}
+
+// toJSON converts a goavro native value to plain JSON-compatible data.
+// goavro represents a non-null union value as a single-entry map keyed by
+// the branch type name; this unwraps it using the schema.
+func (c *avroCodec) toJSON(schema interface{}, value interface{}, namespace string) interface{} {
+	switch s := schema.(type) {
+	case string: // Primitive or reference to a named type
+		if named, ok := c.names[s]; ok {
+			return c.toJSON(named, value, namespace)
+		}
+		if named, ok := c.names[namespace+"."+s]; ok {
+			return c.toJSON(named, value, namespace)
+		}
+	case []interface{}: // Union
+		wrapped, ok := value.(map[string]interface{})
+		if !ok || len(wrapped) != 1 {
+			return value
+		}
+		for name, inner := range wrapped {
+			for _, branch := range s {
+				if avroTypeName(branch, namespace) == name || strings.HasSuffix(name, "."+avroTypeName(branch, "")) {
+					return c.toJSON(branch, inner, namespace)
+				}
+			}
+			return inner
+		}
+	case map[string]interface{}:
+		if ns, ok := s["namespace"].(string); ok {
+			namespace = ns
+		}
+		switch s["type"] {
+		case "record":
+			record, ok := value.(map[string]interface{})
+			if !ok {
+				return value
+			}
+			fields, _ := s["fields"].([]interface{})
+			out := make(map[string]interface{}, len(record))
+			for _, f := range fields {
+				field, _ := f.(map[string]interface{})
+				name, _ := field["name"].(string)
+				if v, ok := record[name]; ok {
+					out[name] = c.toJSON(field["type"], v, namespace)
+				}
+			}
+			return out
+		case "array":
+			items, ok := value.([]interface{})
+			if !ok {
+				return value
+			}
+			out := make([]interface{}, len(items))
+			for i, item := range items {
+				out[i] = c.toJSON(s["items"], item, namespace)
+			}
+			return out
+		case "map":
+			entries, ok := value.(map[string]interface{})
+			if !ok {
+				return value
+			}
+			out := make(map[string]interface{}, len(entries))
+			for k, v := range entries {
+				out[k] = c.toJSON(s["values"], v, namespace)
+			}
+			return out
+		}
+	}
+	return value
+}
+
+// avroTypeName returns the name goavro uses for schema as a union branch
+func avroTypeName(schema interface{}, namespace string) string {
+	switch s := schema.(type) {
+	case string:
+		return s
+	case map[string]interface{}:
+		name, _ := s["name"].(string)
+		if name == "" {
+			typ, _ := s["type"].(string)
+			if logical, ok := s["logicalType"].(string); ok {
+				return typ + "." + logical
+			}
+			return typ
+		}
+		if ns, ok := s["namespace"].(string); ok {
+			namespace = ns
+		}
+		if namespace != "" && !strings.Contains(name, ".") {
+			return namespace + "." + name
+		}
+		return name
+	}
+	return ""
+}
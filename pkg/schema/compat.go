@@ -0,0 +1,60 @@
+package schema
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/schemaregistry"
+)
+
+// registrySchemaTypeJSON is how the Schema Registry names JSON Schema
+const registrySchemaTypeJSON = "JSON"
+
+// Compatibility is the outcome of checking a JSON schema against a Schema
+// Registry subject
+type Compatibility struct {
+	Subject    string
+	Version    int  // Latest version of the subject
+	Compatible bool // Whether the schema passes the subject's compatibility policy
+	Reason     string
+}
+
+// CheckCompatibility asks the registry described by cfg whether schemaJSON
+// is compatible with the latest version of subject under the subject's
+// compatibility policy. A subject whose latest schema is not JSON Schema is
+// reported as incompatible. An error means the check could not be made.
+func CheckCompatibility(cfg RegistryConfig, subject string, schemaJSON []byte) (Compatibility, error) {
+	result := Compatibility{Subject: subject}
+	if cfg.URL == "" {
+		return result, errors.New("schema registry URL is required for compatibility checks")
+	}
+	registry, err := newRegistryClient(cfg)
+	if err != nil {
+		return result, err
+	}
+
+	latest, err := registry.GetLatestSchemaMetadata(subject)
+	if err != nil {
+		return result, fmt.Errorf("failed to fetch latest schema for subject %s: %w", subject, err)
+	}
+	result.Version = latest.Version
+	if latest.SchemaType != registrySchemaTypeJSON {
+		// The registry omits the type for Avro, its default
+		schemaType := latest.SchemaType
+		if schemaType == "" {
+			schemaType = "AVRO"
+		}
+		result.Reason = fmt.Sprintf("subject holds a %s schema, not JSON Schema", schemaType)
+		return result, nil
+	}
+
+	info := schemaregistry.SchemaInfo{Schema: string(schemaJSON), SchemaType: registrySchemaTypeJSON}
+	result.Compatible, err = registry.TestCompatibility(subject, latest.Version, info)
+	if err != nil {
+		return result, fmt.Errorf("failed to test compatibility with subject %s: %w", subject, err)
+	}
+	if !result.Compatible {
+		result.Reason = fmt.Sprintf("schema is incompatible with version %d", latest.Version)
+	}
+	return result, nil
+}
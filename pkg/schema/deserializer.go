@@ -0,0 +1,23 @@
+package schema
+
+// Message is the raw form of a consumed event as read from the broker
+type Message struct {
+	Topic   string
+	Value   []byte
+	Headers map[string]string
+}
+
+// Deserializer decodes a raw message into an event envelope. Every
+// implementation produces the same envelope, with Payload holding the event
+// as JSON, so handlers and storage are independent of the wire format.
+type Deserializer interface {
+	Deserialize(msg Message) (*Event, error)
+}
+
+// JSONDeserializer decodes messages whose value is a JSON event
+type JSONDeserializer struct{}
+
+// Deserialize parses msg.Value with ParseEvent
+func (JSONDeserializer) Deserialize(msg Message) (*Event, error) {
+	return ParseEvent(msg.Value)
+}
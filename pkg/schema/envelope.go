@@ -0,0 +1,133 @@
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Event is the typed envelope for a consumed event. The common fields are
+// lifted out of the payload; Payload holds the complete original JSON.
+type Event struct {
+	Type          EventType
+	ID            string // UUIDv7
+	Version       int    // Payload schema version (event_version)
+	Timestamp     time.Time
+	Source        string // Source platform
+	CorrelationID string
+	CausationID   string // event_id of the event that caused this one
+	UserID        string
+	Payload       json.RawMessage
+
+	// EntityID is the Kafka message key, which identifies the entity the
+	// event is about. Upstream keys every event for an entity alike, so an
+	// entity's events share a partition and stay in order. It is empty for
+	// unkeyed messages and is not part of the payload.
+	EntityID string
+
+	// RecordTimestamp is the Kafka record timestamp: when the event was
+	// produced, or appended to the log on topics using LogAppendTime. It is
+	// ingest time, as opposed to Timestamp, and is zero if the record has
+	// none. It is not part of the payload.
+	RecordTimestamp time.Time
+
+	// Topic and Partition identify the Kafka partition the event was read
+	// from, so stored events can be reconciled against it. Topic is empty
+	// for events that did not come from Kafka. Neither is part of the
+	// payload.
+	Topic     string
+	Partition int32
+
+	// TenantID identifies the customer the event belongs to when several
+	// tenants share a topic. It is read from a message header, not the
+	// payload, and is empty for untenanted events.
+	TenantID string
+
+	// Headers holds the message headers by name, such as the producing
+	// service or a request ID. Events read back from storage carry only the
+	// headers it was configured to keep.
+	Headers map[string]string
+
+	// TraceContext carries W3C trace context (traceparent, tracestate) from
+	// the consumer's span so downstream spans, such as storage, join the
+	// same trace. It is not part of the stored payload.
+	TraceContext map[string]string
+}
+
+// ParseEvent builds an envelope from a raw JSON event
+func ParseEvent(data []byte) (*Event, error) {
+	var base BaseEvent
+	if err := json.Unmarshal(data, &base); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal base event: %w", err)
+	}
+
+	return &Event{
+		Type:          base.EventType,
+		ID:            base.EventID,
+		Version:       base.EventVersion,
+		Timestamp:     base.Timestamp,
+		Source:        string(base.Platform),
+		CorrelationID: base.CorrelationID,
+		CausationID:   base.CausationID,
+		UserID:        base.UserID,
+		Payload:       json.RawMessage(data),
+	}, nil
+}
+
+// WrapEvent builds an envelope from one of the typed event structs, such as
+// *RegulatoryEvent
+func WrapEvent(event interface{}) (*Event, error) {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal event: %w", err)
+	}
+	return ParseEvent(data)
+}
+
+// Base returns the envelope's common fields as a BaseEvent
+func (e *Event) Base() BaseEvent {
+	return BaseEvent{
+		EventID:       e.ID,
+		EventVersion:  e.Version,
+		EventType:     e.Type,
+		Platform:      Platform(e.Source),
+		Timestamp:     e.Timestamp,
+		CorrelationID: e.CorrelationID,
+		CausationID:   e.CausationID,
+		UserID:        e.UserID,
+	}
+}
+
+// SetField sets a top-level field of the JSON payload to value, adding it if
+// missing, e.g. to store a field derived from the rest of the event
+func (e *Event) SetField(name string, value interface{}) error {
+	fields := make(map[string]json.RawMessage)
+	if len(e.Payload) > 0 {
+		if err := json.Unmarshal(e.Payload, &fields); err != nil {
+			return fmt.Errorf("failed to unmarshal event payload: %w", err)
+		}
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("failed to marshal field %s: %w", name, err)
+	}
+	fields[name] = data
+
+	payload, err := json.Marshal(fields)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event payload: %w", err)
+	}
+	e.Payload = payload
+	return nil
+}
+
+// Decode unmarshals the payload into the struct for the event's type, as
+// returned by GetEventTypeInterface
+func (e *Event) Decode() (interface{}, error) {
+	typed := GetEventTypeInterface(e.Type)
+	if err := json.Unmarshal(e.Payload, typed); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal event to type %s: %w", e.Type, err)
+	}
+	return typed, nil
+}
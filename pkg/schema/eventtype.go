@@ -0,0 +1,35 @@
+package schema
+
+import "sync"
+
+// knownTypes starts with the event types declared in this package
+var (
+	knownTypesMu sync.RWMutex
+	knownTypes   = map[EventType]bool{
+		EventRegulatoryUpdate: true, EventLawFetched: true,
+		EventSpecGenerated: true, EventSpecUpdated: true, EventSpecRequested: true,
+		EventAuditStarted: true, EventAuditCompleted: true, EventViolationFound: true, EventScanRequested: true,
+		EventDocumentUploaded: true, EventComplianceCheck: true, EventGapIdentified: true, EventReviewRequested: true,
+		EventWorkflowStarted: true, EventWorkflowComplete: true, EventValidationStatus: true,
+	}
+)
+
+// RegisterEventType makes ParseEventType recognize an event type declared
+// outside this package. Types given a schema with RegisterSchema are
+// recognized too.
+func RegisterEventType(eventType EventType) {
+	knownTypesMu.Lock()
+	knownTypes[eventType] = true
+	knownTypesMu.Unlock()
+}
+
+// ParseEventType converts s to an EventType and reports whether it is a
+// recognized type: one declared in this package or registered with
+// RegisterEventType or RegisterSchema
+func ParseEventType(s string) (EventType, bool) {
+	eventType := EventType(s)
+	knownTypesMu.RLock()
+	known := knownTypes[eventType]
+	knownTypesMu.RUnlock()
+	return eventType, known
+}
@@ -0,0 +1,110 @@
+package schema
+
+import (
+	"fmt"
+	"sync"
+)
+
+// PayloadLimits bounds the shape of event payloads checked by Validate.
+// Zero fields are not enforced.
+type PayloadLimits struct {
+	MaxDepth        int // Nesting depth of objects and arrays
+	MaxFields       int // Object members across the whole payload
+	MaxStringLength int // Bytes in any string value or object key
+}
+
+// PayloadLimitError reports that an event payload exceeds one of the
+// configured PayloadLimits
+type PayloadLimitError struct {
+	EventType EventType
+	EventID   string
+	Limit     string // "depth", "fields" or "string_length"
+	Max       int
+}
+
+func (e *PayloadLimitError) Error() string {
+	return fmt.Sprintf("event %s (%s) exceeds payload limit: %s > %d", e.EventID, e.EventType, e.Limit, e.Max)
+}
+
+var (
+	limitsMu sync.RWMutex
+	limits   PayloadLimits
+)
+
+// SetPayloadLimits sets the limits Validate enforces on every event,
+// whether or not a schema is registered for its type
+func SetPayloadLimits(l PayloadLimits) {
+	limitsMu.Lock()
+	limits = l
+	limitsMu.Unlock()
+}
+
+func payloadLimits() PayloadLimits {
+	limitsMu.RLock()
+	defer limitsMu.RUnlock()
+	return limits
+}
+
+// limitWalker checks a decoded payload against PayloadLimits
+type limitWalker struct {
+	limits PayloadLimits
+	fields int
+}
+
+// walk returns the name of the first limit exceeded within v, found at
+// nesting depth, or "" if none is
+func (w *limitWalker) walk(v interface{}, depth int) string {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		if w.limits.MaxDepth > 0 && depth+1 > w.limits.MaxDepth {
+			return "depth"
+		}
+		w.fields += len(v)
+		if w.limits.MaxFields > 0 && w.fields > w.limits.MaxFields {
+			return "fields"
+		}
+		for key, child := range v {
+			if w.tooLong(key) {
+				return "string_length"
+			}
+			if limit := w.walk(child, depth+1); limit != "" {
+				return limit
+			}
+		}
+	case []interface{}:
+		if w.limits.MaxDepth > 0 && depth+1 > w.limits.MaxDepth {
+			return "depth"
+		}
+		for _, child := range v {
+			if limit := w.walk(child, depth+1); limit != "" {
+				return limit
+			}
+		}
+	case string:
+		if w.tooLong(v) {
+			return "string_length"
+		}
+	}
+	return ""
+}
+
+func (w *limitWalker) tooLong(s string) bool {
+	return w.limits.MaxStringLength > 0 && len(s) > w.limits.MaxStringLength
+}
+
+// checkLimits returns a *PayloadLimitError if doc, the decoded payload of
+// event, exceeds the configured limits
+func checkLimits(event *Event, doc interface{}, l PayloadLimits) error {
+	w := limitWalker{limits: l}
+	limit := w.walk(doc, 0)
+	if limit == "" {
+		return nil
+	}
+
+	max := map[string]int{
+		"depth":         l.MaxDepth,
+		"fields":        l.MaxFields,
+		"string_length": l.MaxStringLength,
+	}[limit]
+	return &PayloadLimitError{EventType: event.Type, EventID: event.ID, Limit: limit, Max: max}
+}
@@ -0,0 +1,59 @@
+package schema
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+// Each limit accepts a payload exactly at it and rejects one just over it
+func TestCheckLimits(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		limits  PayloadLimits
+		payload string
+		want    string // Limit exceeded, or "" if none
+	}{
+		{"depth at limit", PayloadLimits{MaxDepth: 2}, `{"a":{"b":1}}`, ""},
+		{"depth over limit", PayloadLimits{MaxDepth: 2}, `{"a":{"b":{"c":1}}}`, "depth"},
+		{"array depth at limit", PayloadLimits{MaxDepth: 2}, `{"a":[1,2]}`, ""},
+		{"array depth over limit", PayloadLimits{MaxDepth: 2}, `{"a":[[1]]}`, "depth"},
+		{"scalar payload", PayloadLimits{MaxDepth: 1}, `"scan"`, ""},
+		{"fields at limit", PayloadLimits{MaxFields: 3}, `{"a":1,"b":{"c":2}}`, ""},
+		{"fields over limit", PayloadLimits{MaxFields: 3}, `{"a":1,"b":{"c":2,"d":3}}`, "fields"},
+		{"fields counted across arrays", PayloadLimits{MaxFields: 3}, `[{"a":1,"b":2},{"c":3,"d":4}]`, "fields"},
+		{"string at limit", PayloadLimits{MaxStringLength: 4}, `{"a":"abcd"}`, ""},
+		{"string over limit", PayloadLimits{MaxStringLength: 4}, `{"a":"abcde"}`, "string_length"},
+		{"string length in bytes", PayloadLimits{MaxStringLength: 4}, `{"a":"ééé"}`, "string_length"},
+		{"key at limit", PayloadLimits{MaxStringLength: 4}, `{"abcd":1}`, ""},
+		{"key over limit", PayloadLimits{MaxStringLength: 4}, `{"abcde":1}`, "string_length"},
+		{"string in array over limit", PayloadLimits{MaxStringLength: 4}, `["abcde"]`, "string_length"},
+		{"zero limits not enforced", PayloadLimits{}, `{"abcdefgh":{"b":{"c":[["x"]]}}}`, ""},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var doc interface{}
+			if err := json.Unmarshal([]byte(tc.payload), &doc); err != nil {
+				t.Fatalf("invalid test payload: %v", err)
+			}
+			event := &Event{ID: "evt-1", Type: EventScanRequested}
+
+			err := checkLimits(event, doc, tc.limits)
+			if tc.want == "" {
+				if err != nil {
+					t.Errorf("checkLimits returned %v, want nil", err)
+				}
+				return
+			}
+			var limitErr *PayloadLimitError
+			if !errors.As(err, &limitErr) {
+				t.Fatalf("checkLimits returned %v, want a *PayloadLimitError", err)
+			}
+			if limitErr.Limit != tc.want {
+				t.Errorf("Limit = %q, want %q", limitErr.Limit, tc.want)
+			}
+			if limitErr.EventID != event.ID || limitErr.EventType != event.Type {
+				t.Errorf("error names event %s (%s), want %s (%s)", limitErr.EventID, limitErr.EventType, event.ID, event.Type)
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package schema
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// Payload types, one per event type. Each shares the fields of its event
+// family struct (RegulatoryEvent, SpecEvent, ...) but is a distinct type so
+// that handlers can type-switch on the result of Decode:
+//
+//	switch p := payload.(type) {
+//	case *schema.ViolationFoundPayload:
+//		...
+//	case *schema.AuditCompletedPayload:
+//		...
+//	}
+type (
+	RegulatoryUpdatePayload RegulatoryEvent
+	LawFetchedPayload       RegulatoryEvent
+
+	SpecGeneratedPayload SpecEvent
+	SpecUpdatedPayload   SpecEvent
+	SpecRequestedPayload SpecEvent
+
+	AuditStartedPayload   ScanEvent
+	AuditCompletedPayload ScanEvent
+	ViolationFoundPayload ScanEvent
+	ScanRequestedPayload  ScanEvent
+
+	DocumentUploadedPayload ReviewEvent
+	ComplianceCheckPayload  ReviewEvent
+	GapIdentifiedPayload    ReviewEvent
+	ReviewRequestedPayload  ReviewEvent
+
+	WorkflowStartedPayload   WorkflowEvent
+	WorkflowCompletedPayload WorkflowEvent
+
+	ValidationStatusPayload ValidationEvent
+)
+
+// payloadTypes returns a new payload struct for each known event type
+var payloadTypes = map[EventType]func() interface{}{
+	EventRegulatoryUpdate: func() interface{} { return &RegulatoryUpdatePayload{} },
+	EventLawFetched:       func() interface{} { return &LawFetchedPayload{} },
+
+	EventSpecGenerated: func() interface{} { return &SpecGeneratedPayload{} },
+	EventSpecUpdated:   func() interface{} { return &SpecUpdatedPayload{} },
+	EventSpecRequested: func() interface{} { return &SpecRequestedPayload{} },
+
+	EventAuditStarted:   func() interface{} { return &AuditStartedPayload{} },
+	EventAuditCompleted: func() interface{} { return &AuditCompletedPayload{} },
+	EventViolationFound: func() interface{} { return &ViolationFoundPayload{} },
+	EventScanRequested:  func() interface{} { return &ScanRequestedPayload{} },
+
+	EventDocumentUploaded: func() interface{} { return &DocumentUploadedPayload{} },
+	EventComplianceCheck:  func() interface{} { return &ComplianceCheckPayload{} },
+	EventGapIdentified:    func() interface{} { return &GapIdentifiedPayload{} },
+	EventReviewRequested:  func() interface{} { return &ReviewRequestedPayload{} },
+
+	EventWorkflowStarted:  func() interface{} { return &WorkflowStartedPayload{} },
+	EventWorkflowComplete: func() interface{} { return &WorkflowCompletedPayload{} },
+
+	EventValidationStatus: func() interface{} { return &ValidationStatusPayload{} },
+}
+
+// ErrUnknownEventType is returned by Decode for an event type with no
+// payload struct
+var ErrUnknownEventType = errors.New("unknown event type")
+
+// Decode unmarshals raw into the payload struct for eventType, e.g.
+// *ViolationFoundPayload for EventViolationFound
+func Decode(eventType EventType, raw []byte) (interface{}, error) {
+	newPayload, ok := payloadTypes[eventType]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownEventType, eventType)
+	}
+
+	payload := newPayload()
+	if err := json.Unmarshal(raw, payload); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal %s payload: %w", eventType, err)
+	}
+	return payload, nil
+}
+
+// DecodePayload unmarshals the event's payload with Decode
+func (e *Event) DecodePayload() (interface{}, error) {
+	return Decode(e.Type, e.Payload)
+}
@@ -0,0 +1,112 @@
+package schema
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// DefaultEventTypeHeader is the message header naming the event type of a
+// Protobuf value
+const DefaultEventTypeHeader = "event-type"
+
+// ProtobufDeserializer decodes Protobuf values into the message type
+// registered for their event type, which is read from a message header since
+// the binary encoding does not identify its type. Values in the Schema
+// Registry wire format are also accepted. The message is converted to JSON
+// using the proto field names, so messages whose fields mirror the JSON
+// events produce the same envelope.
+type ProtobufDeserializer struct {
+	// TypeHeader names the header carrying the event type
+	// (default DefaultEventTypeHeader)
+	TypeHeader string
+
+	mu    sync.RWMutex
+	types map[EventType]proto.Message
+}
+
+// NewProtobufDeserializer creates a Protobuf deserializer with no registered
+// types
+func NewProtobufDeserializer() *ProtobufDeserializer {
+	return &ProtobufDeserializer{
+		TypeHeader: DefaultEventTypeHeader,
+		types:      make(map[EventType]proto.Message),
+	}
+}
+
+// Register sets the message type decoded for eventType; msg is used only as
+// a prototype
+func (d *ProtobufDeserializer) Register(eventType EventType, msg proto.Message) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.types[eventType] = msg
+}
+
+// Deserialize decodes a Protobuf value into an event envelope
+func (d *ProtobufDeserializer) Deserialize(msg Message) (*Event, error) {
+	eventType := EventType(msg.Headers[d.TypeHeader])
+	if eventType == "" {
+		return nil, fmt.Errorf("missing %s header on Protobuf message", d.TypeHeader)
+	}
+
+	d.mu.RLock()
+	prototype, ok := d.types[eventType]
+	d.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("no Protobuf type registered for event type: %s", eventType)
+	}
+
+	body, err := stripProtobufWireFormat(msg.Value)
+	if err != nil {
+		return nil, err
+	}
+
+	decoded := prototype.ProtoReflect().New().Interface()
+	if err := proto.Unmarshal(body, decoded); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal Protobuf event %s: %w", eventType, err)
+	}
+
+	data, err := protojson.MarshalOptions{UseProtoNames: true}.Marshal(decoded)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert Protobuf event to JSON: %w", err)
+	}
+
+	event, err := ParseEvent(data)
+	if err != nil {
+		return nil, err
+	}
+	if event.Type == "" {
+		event.Type = eventType
+	}
+	return event, nil
+}
+
+// stripProtobufWireFormat removes the Schema Registry header, if present,
+// from a Protobuf value: the magic byte and schema ID followed by the
+// message index path. A plain Protobuf value never starts with a zero byte.
+func stripProtobufWireFormat(value []byte) ([]byte, error) {
+	if len(value) == 0 || value[0] != wireMagicByte {
+		return value, nil
+	}
+	if len(value) < wireHeaderSize {
+		return nil, errors.New("truncated Schema Registry header")
+	}
+
+	rest := value[wireHeaderSize:]
+	count, n := binary.Varint(rest)
+	if n <= 0 || count < 0 {
+		return nil, errors.New("invalid Protobuf message index")
+	}
+	rest = rest[n:]
+	for i := int64(0); i < count; i++ {
+		if _, n = binary.Varint(rest); n <= 0 {
+			return nil, errors.New("invalid Protobuf message index")
+		}
+		rest = rest[n:]
+	}
+	return rest, nil
+}
@@ -0,0 +1,46 @@
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Serializer encodes an event envelope as a message value; it is the
+// inverse of Deserializer
+type Serializer interface {
+	Serialize(event *Event) ([]byte, error)
+}
+
+// JSONSerializer encodes events as JSON values readable by JSONDeserializer
+type JSONSerializer struct{}
+
+// Serialize returns event's payload with its base fields set from the
+// envelope, so that ParseEvent on the result yields the same envelope. An
+// empty payload is encoded as the base fields alone.
+func (JSONSerializer) Serialize(event *Event) ([]byte, error) {
+	base, err := json.Marshal(event.Base())
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal base event: %w", err)
+	}
+	if len(event.Payload) == 0 {
+		return base, nil
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(event.Payload, &fields); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal event payload: %w", err)
+	}
+	var baseFields map[string]json.RawMessage
+	if err := json.Unmarshal(base, &baseFields); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal base event: %w", err)
+	}
+	for key, value := range baseFields {
+		fields[key] = value
+	}
+
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal event: %w", err)
+	}
+	return data, nil
+}
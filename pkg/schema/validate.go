@@ -0,0 +1,81 @@
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+// ValidationError reports that an event payload does not match the JSON
+// schema registered for its type
+type ValidationError struct {
+	EventType EventType
+	EventID   string
+	Err       error
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("event %s failed %s schema validation: %v", e.EventID, e.EventType, e.Err)
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+var (
+	schemasMu sync.RWMutex
+	schemas   = make(map[EventType]*jsonschema.Schema)
+)
+
+// RegisterSchema compiles a JSON schema and registers it for an event type,
+// replacing any schema previously registered for that type
+func RegisterSchema(eventType EventType, schemaJSON []byte) error {
+	url := fmt.Sprintf("eventid://schemas/%s.json", eventType)
+
+	compiler := jsonschema.NewCompiler()
+	if err := compiler.AddResource(url, bytes.NewReader(schemaJSON)); err != nil {
+		return fmt.Errorf("failed to load schema for %s: %w", eventType, err)
+	}
+	compiled, err := compiler.Compile(url)
+	if err != nil {
+		return fmt.Errorf("failed to compile schema for %s: %w", eventType, err)
+	}
+
+	schemasMu.Lock()
+	schemas[eventType] = compiled
+	schemasMu.Unlock()
+	RegisterEventType(eventType)
+	return nil
+}
+
+// Validate checks an event's payload against the limits set with
+// SetPayloadLimits, returning a *PayloadLimitError if it exceeds them, and
+// then against the schema registered for its type. Events within the limits
+// whose type has no registered schema are considered valid.
+func Validate(event *Event) error {
+	schemasMu.RLock()
+	compiled, ok := schemas[event.Type]
+	schemasMu.RUnlock()
+	limits := payloadLimits()
+	if !ok && limits == (PayloadLimits{}) {
+		return nil
+	}
+
+	var doc interface{}
+	if err := json.Unmarshal(event.Payload, &doc); err != nil {
+		return &ValidationError{EventType: event.Type, EventID: event.ID, Err: err}
+	}
+	if err := checkLimits(event, doc, limits); err != nil {
+		return err
+	}
+	if !ok {
+		return nil
+	}
+	if err := compiled.Validate(doc); err != nil {
+		return &ValidationError{EventType: event.Type, EventID: event.ID, Err: err}
+	}
+	return nil
+}
@@ -0,0 +1,272 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/assure-compliance/eventid/pkg/schema"
+)
+
+// eventColumns is the number of columns written per events row
+const eventColumns = 15
+
+// maxBatchRows keeps a multi-row INSERT under PostgreSQL's 65535 bind
+// parameter limit; larger batches are chunked within the same transaction
+const maxBatchRows = 65535 / eventColumns
+
+// BatchFailure identifies an event in a batch that could not be stored
+type BatchFailure struct {
+	Index   int // Position in the batch passed to StoreEventBatch
+	EventID string
+	Err     error
+}
+
+// BatchError is returned by StoreEventBatch when some events in the batch
+// could not be stored. Every event not listed in Failures was committed.
+type BatchError struct {
+	Failures []BatchFailure
+}
+
+func (e *BatchError) Error() string {
+	first := e.Failures[0]
+	return fmt.Sprintf("%d event(s) in batch failed to store; first failure: event %s: %v",
+		len(e.Failures), first.EventID, first.Err)
+}
+
+// BatchFailures returns the failing batch indexes and their errors
+func (e *BatchError) BatchFailures() map[int]error {
+	failures := make(map[int]error, len(e.Failures))
+	for _, f := range e.Failures {
+		failures[f.Index] = f.Err
+	}
+	return failures
+}
+
+// StoreEventBatch persists a batch of events in a single transaction using a
+// multi-row INSERT. Events whose ID is already stored are skipped and counted
+// as duplicates rather than failures. If the INSERT fails (e.g. one row
+// violates a constraint) the batch is retried row by row under savepoints so
+// that valid events are still committed and the failing ones are reported
+// in a *BatchError. Any other error means nothing in the batch was stored.
+// Events of upserted types are stored one at a time after the rest of the
+// batch, as with StoreEvent.
+func (s *PostgresStore) StoreEventBatch(ctx context.Context, events []*schema.Event) (err error) {
+	if len(events) == 0 {
+		return nil
+	}
+
+	span := s.startBatchSpan(ctx, events)
+	defer func(started time.Time) {
+		err = s.checkConn(err)
+		s.metrics.observeStore("insert_batch", started, err)
+		if err == nil {
+			s.markStored()
+		}
+		endSpan(span, started, err)
+	}(time.Now())
+
+	var rows, upserts []*eventRow
+	var rowIndex, upsertIndex []int // Positions in events
+	cached := 0
+	for i, event := range events {
+		if s.upsert[event.Type] {
+			upserts = append(upserts, newEventRow(event, s.storedHeaders))
+			upsertIndex = append(upsertIndex, i)
+			continue
+		}
+		if s.dedup.seen(event) {
+			cached++
+			continue
+		}
+		rows = append(rows, newEventRow(event, s.storedHeaders))
+		rowIndex = append(rowIndex, i)
+	}
+	if cached > 0 {
+		s.metrics.duplicateEvents.Add(float64(cached))
+		s.logger.Info("Skipped recently stored duplicate events in batch", "batch_size", len(events), "duplicates", cached)
+	}
+
+	failures, err := s.insertRows(ctx, rows)
+	if err != nil {
+		return err
+	}
+	failed := make(map[int]bool, len(failures))
+	for i := range failures {
+		failed[failures[i].Index] = true
+		failures[i].Index = rowIndex[failures[i].Index]
+	}
+	for i, index := range rowIndex {
+		if !failed[i] {
+			s.dedup.add(events[index])
+		}
+	}
+
+	for i, row := range upserts {
+		err := s.upsertRow(ctx, row)
+		if err == nil || errors.Is(err, ErrDuplicateEvent) {
+			continue
+		}
+		if isConnectionError(err) || ctx.Err() != nil {
+			return err
+		}
+		s.logger.Error("Failed to store event", append(row.logAttrs(), "error", err)...)
+		failures = append(failures, BatchFailure{Index: upsertIndex[i], EventID: row.base.EventID, Err: err})
+	}
+
+	if len(failures) > 0 {
+		return &BatchError{Failures: failures}
+	}
+	return nil
+}
+
+// insertRows inserts rows with insertBatch, falling back to insertRowsIsolated
+// to report the rows that failed
+func (s *PostgresStore) insertRows(ctx context.Context, rows []*eventRow) ([]BatchFailure, error) {
+	if len(rows) == 0 {
+		return nil, nil
+	}
+
+	err := s.insertBatch(ctx, rows)
+	if err == nil {
+		return nil, nil
+	}
+	if isConnectionError(err) || ctx.Err() != nil {
+		return nil, err
+	}
+
+	// Fall back to per-row inserts to isolate the failing events
+	s.logger.Warn("Batch insert failed, retrying row by row", "batch_size", len(rows), "error", err)
+	failures, err := s.insertRowsIsolated(ctx, rows)
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range failures {
+		s.logger.Error("Failed to store event", append(rows[f.Index].logAttrs(), "error", f.Err)...)
+	}
+	return failures, nil
+}
+
+// insertBatch writes all rows with multi-row INSERTs inside one transaction
+func (s *PostgresStore) insertBatch(ctx context.Context, rows []*eventRow) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin batch transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var inserted int64
+	for start := 0; start < len(rows); start += maxBatchRows {
+		end := start + maxBatchRows
+		if end > len(rows) {
+			end = len(rows)
+		}
+
+		query, args := buildBatchInsert(s.names, rows[start:end])
+		result, err := tx.ExecContext(ctx, query, args...)
+		if err != nil {
+			return fmt.Errorf("failed to insert event batch: %w", err)
+		}
+		if n, err := result.RowsAffected(); err == nil {
+			inserted += n
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit event batch: %w", err)
+	}
+
+	if duplicates := int64(len(rows)) - inserted; duplicates > 0 {
+		s.metrics.duplicateEvents.Add(float64(duplicates))
+		s.logger.Info("Skipped duplicate events in batch", "batch_size", len(rows), "duplicates", duplicates)
+	}
+	return nil
+}
+
+// insertRowsIsolated inserts rows one at a time in a single transaction,
+// rolling back to a savepoint for each row that fails
+func (s *PostgresStore) insertRowsIsolated(ctx context.Context, rows []*eventRow) ([]BatchFailure, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin batch transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var failures []BatchFailure
+	var duplicates int
+	for i, row := range rows {
+		if _, err := tx.ExecContext(ctx, "SAVEPOINT batch_row"); err != nil {
+			return nil, fmt.Errorf("failed to create savepoint: %w", err)
+		}
+
+		result, err := tx.ExecContext(ctx, s.names.render(insertEventSQL), row.args()...)
+		if err != nil {
+			failures = append(failures, BatchFailure{
+				Index:   i,
+				EventID: row.base.EventID,
+				Err:     fmt.Errorf("failed to insert event: %w", err),
+			})
+			if _, err := tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT batch_row"); err != nil {
+				return nil, fmt.Errorf("failed to roll back savepoint: %w", err)
+			}
+			continue
+		}
+		if n, err := result.RowsAffected(); err == nil && n == 0 {
+			duplicates++
+		}
+
+		if _, err := tx.ExecContext(ctx, "RELEASE SAVEPOINT batch_row"); err != nil {
+			return nil, fmt.Errorf("failed to release savepoint: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit event batch: %w", err)
+	}
+
+	if duplicates > 0 {
+		s.metrics.duplicateEvents.Add(float64(duplicates))
+		s.logger.Info("Skipped duplicate events in batch", "batch_size", len(rows), "duplicates", duplicates)
+	}
+	return failures, nil
+}
+
+// buildBatchInsert builds a multi-row INSERT for the given rows
+func buildBatchInsert(names *sqlNames, rows []*eventRow) (string, []interface{}) {
+	var sb strings.Builder
+	sb.WriteString(`INSERT INTO {events} (` + insertColumns + `
+		) VALUES `)
+
+	args := make([]interface{}, 0, len(rows)*eventColumns)
+	for i, row := range rows {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		sb.WriteString("(")
+		for col := 0; col < eventColumns; col++ {
+			if col > 0 {
+				sb.WriteString(", ")
+			}
+			fmt.Fprintf(&sb, "$%d", len(args)+col+1)
+		}
+		sb.WriteString(")")
+		args = append(args, row.args()...)
+	}
+	sb.WriteString(" ON CONFLICT ({event_id}, {timestamp}) DO NOTHING")
+
+	return names.render(sb.String()), args
+}
+
+// isConnectionError reports whether err means the database could not be
+// reached, in which case retrying row by row is pointless
+func isConnectionError(err error) bool {
+	var netErr net.Error
+	return errors.Is(err, driver.ErrBadConn) ||
+		errors.Is(err, sql.ErrConnDone) ||
+		errors.As(err, &netErr)
+}
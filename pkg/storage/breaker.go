@@ -0,0 +1,199 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/assure-compliance/eventid/pkg/schema"
+)
+
+// Defaults used when BreakerConfig fields are unset
+const (
+	DefaultBreakerThreshold = 5
+	DefaultBreakerCooldown  = 30 * time.Second
+)
+
+// ErrCircuitOpen is returned by a BreakerStore while its circuit is open. It
+// wraps ErrConnClosed, so callers treat it like the database being
+// unreachable and retry once it is back.
+var ErrCircuitOpen = fmt.Errorf("%w: storage circuit breaker open", ErrConnClosed)
+
+// Circuit breaker states, as reported by event_store_circuit_breaker_state
+type breakerState int
+
+const (
+	breakerClosed breakerState = iota
+	breakerOpen
+	breakerHalfOpen
+)
+
+// BreakerConfig configures a BreakerStore
+type BreakerConfig struct {
+	// Threshold is how many consecutive failed writes open the circuit
+	// (default DefaultBreakerThreshold)
+	Threshold int
+
+	// Cooldown is how long the circuit stays open before a trial write is
+	// let through (default DefaultBreakerCooldown)
+	Cooldown time.Duration
+
+	Logger  Logger   // Defaults to JSON on stderr
+	Metrics *Metrics // Defaults to DefaultMetrics()
+}
+
+// BreakerStore wraps an EventStore with a circuit breaker on writes. Only
+// failures meaning the database is unavailable or too slow count, i.e.
+// errors wrapping ErrConnClosed or context.DeadlineExceeded; a rejected
+// event shows the database is answering. After Threshold consecutive
+// failures the circuit opens and writes fail fast with ErrCircuitOpen for
+// Cooldown, rather than adding load while the database recovers. Then one
+// write at a time is let through (half-open): success closes the circuit,
+// failure opens it for another Cooldown. Ping fails with ErrCircuitOpen
+// until the cooldown has passed, so callers waiting for the database to
+// answer also wait for the breaker. Reads are not affected.
+type BreakerStore struct {
+	EventStore
+
+	threshold int
+	cooldown  time.Duration
+	logger    Logger
+	metrics   *Metrics
+
+	mu       sync.Mutex
+	state    breakerState
+	failures int       // Consecutive failures while closed
+	openedAt time.Time // When the circuit last opened
+	trial    bool      // Set while a half-open trial write is running
+}
+
+// NewBreakerStore wraps store with a circuit breaker
+func NewBreakerStore(store EventStore, cfg BreakerConfig) *BreakerStore {
+	if cfg.Threshold <= 0 {
+		cfg.Threshold = DefaultBreakerThreshold
+	}
+	if cfg.Cooldown <= 0 {
+		cfg.Cooldown = DefaultBreakerCooldown
+	}
+	if cfg.Logger == nil {
+		cfg.Logger = defaultLogger()
+	}
+	if cfg.Metrics == nil {
+		cfg.Metrics = DefaultMetrics()
+	}
+	cfg.Metrics.breakerStateGauge.Set(float64(breakerClosed))
+	return &BreakerStore{EventStore: store, threshold: cfg.Threshold, cooldown: cfg.Cooldown, logger: cfg.Logger, metrics: cfg.Metrics}
+}
+
+// StoreEvent stores event unless the circuit is open
+func (b *BreakerStore) StoreEvent(ctx context.Context, event *schema.Event) error {
+	trial, err := b.allow()
+	if err != nil {
+		return err
+	}
+	err = b.EventStore.StoreEvent(ctx, event)
+	b.record(trial, err)
+	return err
+}
+
+// StoreEventBatch stores events unless the circuit is open
+func (b *BreakerStore) StoreEventBatch(ctx context.Context, events []*schema.Event) error {
+	trial, err := b.allow()
+	if err != nil {
+		return err
+	}
+	err = b.EventStore.StoreEventBatch(ctx, events)
+	b.record(trial, err)
+	return err
+}
+
+// StoreLatest updates the latest state of event's entity unless the circuit
+// is open
+func (b *BreakerStore) StoreLatest(ctx context.Context, event *schema.Event) error {
+	trial, err := b.allow()
+	if err != nil {
+		return err
+	}
+	err = b.EventStore.StoreLatest(ctx, event)
+	b.record(trial, err)
+	return err
+}
+
+// Ping fails with ErrCircuitOpen during the cooldown and otherwise pings the
+// underlying store
+func (b *BreakerStore) Ping(ctx context.Context) error {
+	b.mu.Lock()
+	cooling := b.state == breakerOpen && time.Since(b.openedAt) < b.cooldown
+	b.mu.Unlock()
+	if cooling {
+		return ErrCircuitOpen
+	}
+	return b.EventStore.Ping(ctx)
+}
+
+// allow reports ErrCircuitOpen unless a write may go ahead, moving an open
+// circuit whose cooldown has passed to half-open. It reports whether the
+// write is the half-open trial.
+func (b *BreakerStore) allow() (bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case breakerOpen:
+		if time.Since(b.openedAt) < b.cooldown {
+			b.metrics.breakerRejections.Inc()
+			return false, ErrCircuitOpen
+		}
+		b.setState(breakerHalfOpen)
+		fallthrough
+	case breakerHalfOpen:
+		if b.trial {
+			b.metrics.breakerRejections.Inc()
+			return false, ErrCircuitOpen
+		}
+		b.trial = true
+		return true, nil
+	}
+	return false, nil
+}
+
+// record updates the circuit with the outcome of a write
+func (b *BreakerStore) record(trial bool, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if trial {
+		b.trial = false
+	}
+
+	switch {
+	case errors.Is(err, context.Canceled):
+		// The caller gave up; this says nothing about the database
+	case !errors.Is(classify(err), ErrConnClosed) && !errors.Is(err, context.DeadlineExceeded):
+		b.failures = 0
+		if b.state != breakerClosed {
+			b.setState(breakerClosed)
+			b.logger.Info("Storage circuit breaker closed")
+		}
+	case trial:
+		b.open(err)
+	default:
+		if b.failures++; b.failures >= b.threshold && b.state == breakerClosed {
+			b.open(err)
+		}
+	}
+}
+
+// open trips the circuit for a cooldown. b.mu must be held.
+func (b *BreakerStore) open(err error) {
+	b.failures = 0
+	b.openedAt = time.Now()
+	b.setState(breakerOpen)
+	b.logger.Warn("Storage circuit breaker opened", "cooldown", b.cooldown.String(), "error", err)
+}
+
+func (b *BreakerStore) setState(state breakerState) {
+	b.state = state
+	b.metrics.breakerStateGauge.Set(float64(state))
+}
@@ -0,0 +1,180 @@
+package storage_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/assure-compliance/eventid/pkg/schema"
+	"github.com/assure-compliance/eventid/pkg/storage"
+)
+
+// testCooldown is the breaker cooldown in tests, short enough to wait out
+const testCooldown = 50 * time.Millisecond
+
+func newBreakerStore(inner storage.EventStore) *storage.BreakerStore {
+	return storage.NewBreakerStore(inner, storage.BreakerConfig{
+		Threshold: 3,
+		Cooldown:  testCooldown,
+		Logger:    discardLogger(),
+		Metrics:   newMetrics(),
+	})
+}
+
+// openBreaker fails enough writes through breaker to open it
+func openBreaker(t *testing.T, breaker *storage.BreakerStore, inner *failingStore) {
+	t.Helper()
+	inner.fail(storage.ErrConnClosed)
+	for i := 0; i < 3; i++ {
+		if err := breaker.StoreEvent(context.Background(), testEvent("evt-open")); !errors.Is(err, storage.ErrConnClosed) || errors.Is(err, storage.ErrCircuitOpen) {
+			t.Fatalf("write %d returned %v, want the store's error", i+1, err)
+		}
+	}
+}
+
+// The circuit opens after Threshold consecutive unavailable errors, and
+// writes then fail fast with ErrCircuitOpen during the cooldown
+func TestBreakerOpensAfterThreshold(t *testing.T) {
+	inner := newFailingStore()
+	breaker := newBreakerStore(inner)
+
+	inner.fail(storage.ErrConnClosed)
+	for i := 0; i < 2; i++ {
+		breaker.StoreEvent(context.Background(), testEvent("evt-1"))
+	}
+	// A success resets the count
+	inner.fail(nil)
+	if err := breaker.StoreEvent(context.Background(), testEvent("evt-2")); err != nil {
+		t.Fatalf("StoreEvent failed: %v", err)
+	}
+	inner.fail(storage.ErrConnClosed)
+	for i := 0; i < 2; i++ {
+		if err := breaker.StoreEvent(context.Background(), testEvent("evt-3")); errors.Is(err, storage.ErrCircuitOpen) {
+			t.Fatalf("circuit opened after %d failures following a success", i+1)
+		}
+	}
+
+	breaker.StoreEvent(context.Background(), testEvent("evt-3"))
+	inner.fail(nil)
+	if err := breaker.StoreEvent(context.Background(), testEvent("evt-4")); !errors.Is(err, storage.ErrCircuitOpen) {
+		t.Errorf("StoreEvent returned %v while open, want ErrCircuitOpen", err)
+	}
+	if err := breaker.Ping(context.Background()); !errors.Is(err, storage.ErrCircuitOpen) {
+		t.Errorf("Ping returned %v while open, want ErrCircuitOpen", err)
+	}
+	if _, err := inner.GetEventByID("evt-4"); err == nil {
+		t.Error("write reached the store while the circuit was open")
+	}
+}
+
+// Rejected events show the database is answering and do not open the
+// circuit
+func TestBreakerIgnoresRejections(t *testing.T) {
+	inner := newFailingStore()
+	breaker := newBreakerStore(inner)
+
+	inner.reject("evt-bad")
+	for i := 0; i < 5; i++ {
+		if err := breaker.StoreEvent(context.Background(), testEvent("evt-bad")); !errors.Is(err, errRejected) {
+			t.Fatalf("StoreEvent returned %v, want %v", err, errRejected)
+		}
+	}
+	if err := breaker.StoreEvent(context.Background(), testEvent("evt-good")); err != nil {
+		t.Errorf("StoreEvent returned %v after rejections, want nil", err)
+	}
+}
+
+// Writes cancelled by their caller say nothing about the database and do
+// not count as failures
+func TestBreakerIgnoresCancellation(t *testing.T) {
+	inner := newFailingStore()
+	breaker := newBreakerStore(inner)
+
+	inner.fail(context.Canceled)
+	for i := 0; i < 5; i++ {
+		breaker.StoreEvent(context.Background(), testEvent("evt-1"))
+	}
+	inner.fail(nil)
+	if err := breaker.StoreEvent(context.Background(), testEvent("evt-1")); err != nil {
+		t.Errorf("StoreEvent returned %v after cancelled writes, want nil", err)
+	}
+}
+
+// After the cooldown one trial write is let through at a time; its success
+// closes the circuit
+func TestBreakerHalfOpenTrialCloses(t *testing.T) {
+	inner := newFailingStore()
+	gate := &gatedStore{failingStore: inner, entered: make(chan struct{}), release: make(chan struct{})}
+	breaker := newBreakerStore(gate)
+	openBreaker(t, breaker, inner)
+	time.Sleep(2 * testCooldown)
+
+	inner.fail(nil)
+	gate.hold(true)
+	trial := make(chan error, 1)
+	go func() { trial <- breaker.StoreEvent(context.Background(), testEvent("evt-trial")) }()
+	<-gate.entered
+	gate.hold(false)
+
+	if err := breaker.StoreEvent(context.Background(), testEvent("evt-2")); !errors.Is(err, storage.ErrCircuitOpen) {
+		t.Errorf("second write during the trial returned %v, want ErrCircuitOpen", err)
+	}
+	close(gate.release)
+	if err := <-trial; err != nil {
+		t.Fatalf("trial write failed: %v", err)
+	}
+
+	for _, id := range []string{"evt-3", "evt-4"} {
+		if err := breaker.StoreEvent(context.Background(), testEvent(id)); err != nil {
+			t.Errorf("StoreEvent(%s) returned %v after a successful trial, want nil", id, err)
+		}
+	}
+}
+
+// A failed trial write opens the circuit for another cooldown
+func TestBreakerHalfOpenTrialReopens(t *testing.T) {
+	inner := newFailingStore()
+	breaker := newBreakerStore(inner)
+	openBreaker(t, breaker, inner)
+	time.Sleep(2 * testCooldown)
+
+	if err := breaker.StoreEvent(context.Background(), testEvent("evt-trial")); !errors.Is(err, storage.ErrConnClosed) || errors.Is(err, storage.ErrCircuitOpen) {
+		t.Fatalf("trial write returned %v, want the store's error", err)
+	}
+	inner.fail(nil)
+	if err := breaker.StoreEvent(context.Background(), testEvent("evt-2")); !errors.Is(err, storage.ErrCircuitOpen) {
+		t.Errorf("StoreEvent returned %v after a failed trial, want ErrCircuitOpen", err)
+	}
+
+	time.Sleep(2 * testCooldown)
+	if err := breaker.StoreEvent(context.Background(), testEvent("evt-3")); err != nil {
+		t.Errorf("StoreEvent returned %v after the second cooldown, want nil", err)
+	}
+}
+
+// gatedStore is a failingStore whose writes, while held, signal entered
+// and wait for release
+type gatedStore struct {
+	*failingStore
+	held    bool
+	entered chan struct{}
+	release chan struct{}
+}
+
+func (s *gatedStore) hold(held bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.held = held
+}
+
+func (s *gatedStore) StoreEvent(ctx context.Context, event *schema.Event) error {
+	s.mu.Lock()
+	held := s.held
+	s.mu.Unlock()
+	if held {
+		s.entered <- struct{}{}
+		<-s.release
+	}
+	return s.failingStore.StoreEvent(ctx, event)
+}
@@ -0,0 +1,124 @@
+package storage
+
+import (
+	"container/heap"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/assure-compliance/eventid/pkg/schema"
+)
+
+// causalChainSQL selects every event sharing a correlation ID, oldest
+// first, served by idx_events_correlation_id
+const causalChainSQL = selectColumnsSQL + `
+	WHERE {correlation_id} = $1
+	ORDER BY {timestamp} ASC, {id} ASC
+`
+
+// errNoCorrelationID is returned by GetCausalChain for an empty ID, which
+// would otherwise match every uncorrelated event
+var errNoCorrelationID = errors.New("correlation ID is required")
+
+// GetCausalChain returns the events with correlationID in causal order:
+// each event after the one named by its causation ID, and otherwise oldest
+// first
+func (s *PostgresStore) GetCausalChain(correlationID string) ([]schema.Event, error) {
+	if correlationID == "" {
+		return nil, errNoCorrelationID
+	}
+	rows, err := s.db.Query(s.names.render(causalChainSQL), correlationID)
+	if err != nil {
+		return nil, s.checkConn(fmt.Errorf("failed to query causal chain: %w", err))
+	}
+	defer rows.Close()
+
+	var events []schema.Event
+	for rows.Next() {
+		event, err := scanEvent(rows)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, *event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read causal chain: %w", err)
+	}
+	return causalOrder(events), nil
+}
+
+// GetCausalChain returns the events with correlationID in causal order
+func (s *InMemoryStore) GetCausalChain(correlationID string) ([]schema.Event, error) {
+	if correlationID == "" {
+		return nil, errNoCorrelationID
+	}
+	s.mu.RLock()
+	var events []schema.Event
+	for _, event := range s.events {
+		if event.CorrelationID == correlationID {
+			events = append(events, event)
+		}
+	}
+	s.mu.RUnlock()
+
+	sort.SliceStable(events, func(i, j int) bool { return events[i].Timestamp.Before(events[j].Timestamp) })
+	return causalOrder(events), nil
+}
+
+// GetCausalChain returns no events
+func (s *DryRunStore) GetCausalChain(string) ([]schema.Event, error) {
+	return nil, nil
+}
+
+// causalOrder reorders events, given oldest first, so that each comes after
+// the event its causation ID names, keeping the oldest first among those
+// whose cause has been placed. Events whose cause is not among them start
+// chains of their own. Events caught in a causation cycle, which a correct
+// producer never creates, follow the rest oldest first.
+func causalOrder(events []schema.Event) []schema.Event {
+	index := make(map[string]int, len(events))
+	for i, event := range events {
+		index[event.ID] = i
+	}
+	effects := make(map[int][]int)
+	ready := &indexHeap{}
+	for i, event := range events {
+		cause, ok := index[event.CausationID]
+		if event.CausationID == "" || !ok || cause == i {
+			heap.Push(ready, i)
+			continue
+		}
+		effects[cause] = append(effects[cause], i)
+	}
+
+	ordered := make([]schema.Event, 0, len(events))
+	placed := make([]bool, len(events))
+	for ready.Len() > 0 {
+		i := heap.Pop(ready).(int)
+		ordered = append(ordered, events[i])
+		placed[i] = true
+		for _, effect := range effects[i] {
+			heap.Push(ready, effect)
+		}
+	}
+	for i, event := range events {
+		if !placed[i] {
+			ordered = append(ordered, event)
+		}
+	}
+	return ordered
+}
+
+// indexHeap is a min-heap of positions in a slice sorted oldest first
+type indexHeap []int
+
+func (h indexHeap) Len() int           { return len(h) }
+func (h indexHeap) Less(i, j int) bool { return h[i] < h[j] }
+func (h indexHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *indexHeap) Push(x any)        { *h = append(*h, x.(int)) }
+func (h *indexHeap) Pop() any {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
@@ -0,0 +1,40 @@
+package storage
+
+import (
+	"fmt"
+	"strings"
+)
+
+// connString builds a lib/pq connection string from cfg. TLS file paths are
+// only included when set, leaving lib/pq's ~/.postgresql defaults otherwise.
+func connString(cfg Config) string {
+	params := []string{
+		"host=" + quoteConnValue(cfg.Host),
+		fmt.Sprintf("port=%d", cfg.Port),
+		"user=" + quoteConnValue(cfg.User),
+		"password=" + quoteConnValue(cfg.Password),
+		"dbname=" + quoteConnValue(cfg.Database),
+		"sslmode=" + quoteConnValue(cfg.SSLMode),
+	}
+	for _, p := range []struct{ key, value string }{
+		{"sslcert", cfg.SSLCert},
+		{"sslkey", cfg.SSLKey},
+		{"sslrootcert", cfg.SSLRootCert},
+	} {
+		if p.value != "" {
+			params = append(params, p.key+"="+quoteConnValue(p.value))
+		}
+	}
+	return strings.Join(params, " ")
+}
+
+// quoteConnValue quotes a connection string value so that spaces, quotes and
+// backslashes, e.g. in passwords or file paths, are passed through intact
+func quoteConnValue(value string) string {
+	if value != "" && !strings.ContainsAny(value, ` '\`) {
+		return value
+	}
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, `'`, `\'`)
+	return "'" + value + "'"
+}
@@ -0,0 +1,106 @@
+package storage
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"github.com/assure-compliance/eventid/pkg/schema"
+)
+
+// DefaultDedupCacheTTL is how long a stored event is remembered when
+// Config.DedupCacheTTL is unset
+const DefaultDedupCacheTTL = 10 * time.Minute
+
+// dedupKey matches the events unique constraint, so the cache never skips
+// an event the database would accept
+type dedupKey struct {
+	eventID   string
+	timestamp int64 // UnixNano
+}
+
+type dedupEntry struct {
+	key     dedupKey
+	expires time.Time
+}
+
+// dedupCache remembers recently stored events so that redeliveries, which
+// mostly arrive shortly after a rebalance, are skipped without a database
+// round-trip. It holds at most size entries, evicting the least recently
+// seen, and forgets each one ttl after it was stored. The unique constraint
+// remains the authority: a miss only means the insert is tried. A nil cache
+// is disabled.
+type dedupCache struct {
+	mu      sync.Mutex
+	size    int
+	ttl     time.Duration
+	order   *list.List // Most recently seen first
+	entries map[dedupKey]*list.Element
+	metrics *Metrics
+}
+
+// newDedupCache returns a cache of size entries, or nil if size is not
+// positive. Lookups are counted in metrics.
+func newDedupCache(size int, ttl time.Duration, metrics *Metrics) *dedupCache {
+	if size <= 0 {
+		return nil
+	}
+	if ttl <= 0 {
+		ttl = DefaultDedupCacheTTL
+	}
+	return &dedupCache{size: size, ttl: ttl, order: list.New(), entries: make(map[dedupKey]*list.Element), metrics: metrics}
+}
+
+func dedupKeyOf(event *schema.Event) dedupKey {
+	return dedupKey{eventID: event.ID, timestamp: event.Timestamp.UnixNano()}
+}
+
+// seen reports whether event was stored within the TTL, counting the
+// lookup as a hit or miss
+func (c *dedupCache) seen(event *schema.Event) bool {
+	if c == nil {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := dedupKeyOf(event)
+	elem, ok := c.entries[key]
+	if ok && time.Now().After(elem.Value.(*dedupEntry).expires) {
+		c.order.Remove(elem)
+		delete(c.entries, key)
+		c.metrics.dedupCacheEntries.Set(float64(c.order.Len()))
+		ok = false
+	}
+	if !ok {
+		c.metrics.dedupLookups.WithLabelValues("miss").Inc()
+		return false
+	}
+	c.order.MoveToFront(elem)
+	c.metrics.dedupLookups.WithLabelValues("hit").Inc()
+	return true
+}
+
+// add remembers that event is stored
+func (c *dedupCache) add(event *schema.Event) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := dedupKeyOf(event)
+	expires := time.Now().Add(c.ttl)
+	if elem, ok := c.entries[key]; ok {
+		elem.Value.(*dedupEntry).expires = expires
+		c.order.MoveToFront(elem)
+		return
+	}
+	c.entries[key] = c.order.PushFront(&dedupEntry{key: key, expires: expires})
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*dedupEntry).key)
+	}
+	c.metrics.dedupCacheEntries.Set(float64(c.order.Len()))
+}
@@ -0,0 +1,84 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/assure-compliance/eventid/pkg/schema"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func dedupEvent(id string) *schema.Event {
+	return &schema.Event{ID: id, Timestamp: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)}
+}
+
+func newTestDedupCache(size int, ttl time.Duration) *dedupCache {
+	return newDedupCache(size, ttl, NewMetrics(prometheus.NewRegistry(), "", ""))
+}
+
+// Stored events are seen until the cache is full, when the least recently
+// seen is evicted
+func TestDedupCacheEvictsLeastRecentlySeen(t *testing.T) {
+	cache := newTestDedupCache(2, time.Hour)
+	cache.add(dedupEvent("evt-1"))
+	cache.add(dedupEvent("evt-2"))
+	if !cache.seen(dedupEvent("evt-1")) {
+		t.Fatal("evt-1 not seen after being added")
+	}
+
+	// evt-1 was seen last, so evt-2 is evicted
+	cache.add(dedupEvent("evt-3"))
+	if cache.seen(dedupEvent("evt-2")) {
+		t.Error("evt-2 still seen after eviction")
+	}
+	for _, id := range []string{"evt-1", "evt-3"} {
+		if !cache.seen(dedupEvent(id)) {
+			t.Errorf("%s evicted, want it kept", id)
+		}
+	}
+	if n := cache.order.Len(); n != 2 {
+		t.Errorf("cache holds %d entries, want 2", n)
+	}
+}
+
+// The key includes the timestamp, like the unique constraint, so an event
+// reusing an ID at another time is not skipped
+func TestDedupCacheKeysOnTimestamp(t *testing.T) {
+	cache := newTestDedupCache(10, time.Hour)
+	cache.add(dedupEvent("evt-1"))
+	later := dedupEvent("evt-1")
+	later.Timestamp = later.Timestamp.Add(time.Second)
+	if cache.seen(later) {
+		t.Error("event with the same ID and a different timestamp was seen")
+	}
+}
+
+// Entries are forgotten ttl after they were added
+func TestDedupCacheExpires(t *testing.T) {
+	ttl := 20 * time.Millisecond
+	cache := newTestDedupCache(10, ttl)
+	cache.add(dedupEvent("evt-1"))
+	if !cache.seen(dedupEvent("evt-1")) {
+		t.Fatal("evt-1 not seen after being added")
+	}
+
+	time.Sleep(2 * ttl)
+	if cache.seen(dedupEvent("evt-1")) {
+		t.Error("evt-1 still seen after its TTL")
+	}
+	if n := cache.order.Len(); n != 0 {
+		t.Errorf("cache holds %d entries after expiry, want 0", n)
+	}
+}
+
+// A cache with no size is nil and neither remembers nor reports anything
+func TestDedupCacheDisabled(t *testing.T) {
+	cache := newTestDedupCache(0, time.Hour)
+	if cache != nil {
+		t.Fatal("newDedupCache(0) returned a cache, want nil")
+	}
+	cache.add(dedupEvent("evt-1"))
+	if cache.seen(dedupEvent("evt-1")) {
+		t.Error("disabled cache reported an event as seen")
+	}
+}
@@ -0,0 +1,77 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/assure-compliance/eventid/pkg/schema"
+)
+
+// DryRunStore logs each event it is asked to store instead of writing it,
+// for smoke-testing a consumer against live topics without touching the
+// database
+type DryRunStore struct {
+	logger Logger
+}
+
+// NewDryRunStore creates a dry-run store logging to logger, or JSON on stderr
+// when nil
+func NewDryRunStore(logger Logger) *DryRunStore {
+	if logger == nil {
+		logger = defaultLogger()
+	}
+	return &DryRunStore{logger: logger}
+}
+
+// StoreEvent logs event at info level
+func (s *DryRunStore) StoreEvent(_ context.Context, event *schema.Event) error {
+	s.logger.Info("Dry run: would store event", newEventRow(event, nil).dryRunAttrs()...)
+	return nil
+}
+
+// StoreEventBatch logs each event in the batch at info level
+func (s *DryRunStore) StoreEventBatch(ctx context.Context, events []*schema.Event) error {
+	for _, event := range events {
+		s.StoreEvent(ctx, event)
+	}
+	return nil
+}
+
+// GetEventByID always reports the event as not found
+func (s *DryRunStore) GetEventByID(eventID string) (map[string]interface{}, error) {
+	return nil, fmt.Errorf("%w: %s", ErrEventNotFound, eventID)
+}
+
+// QueryEvents returns no events
+func (s *DryRunStore) QueryEvents(EventFilter) ([]schema.Event, error) {
+	return nil, nil
+}
+
+// StreamEvents returns without calling fn
+func (s *DryRunStore) StreamEvents(context.Context, EventFilter, func(schema.Event) error) error {
+	return nil
+}
+
+// Ping always succeeds
+func (s *DryRunStore) Ping(context.Context) error {
+	return nil
+}
+
+// Close does nothing
+func (s *DryRunStore) Close() error {
+	return nil
+}
+
+// dryRunAttrs extends logAttrs with the rest of the row
+func (r *eventRow) dryRunAttrs() []any {
+	return append(r.logAttrs(),
+		"event_version", r.base.EventVersion,
+		"platform", string(r.base.Platform),
+		"timestamp", r.base.Timestamp,
+		"record_timestamp", r.recorded,
+		"kafka_topic", r.topic,
+		"kafka_partition", r.partition,
+		"user_id", r.base.UserID,
+		"payload_bytes", len(r.data),
+	)
+}
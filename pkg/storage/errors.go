@@ -0,0 +1,67 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/lib/pq"
+)
+
+// ErrConnClosed is wrapped by errors returned when the database could not be
+// reached or closed the connection, as opposed to rejecting the request.
+// Such operations may succeed if retried once the database is back.
+var ErrConnClosed = errors.New("database connection unavailable")
+
+// ErrEventNotFound is wrapped by GetEventByID when no event has the ID, and
+// by GetLatest when the entity has no latest event
+var ErrEventNotFound = errors.New("event not found")
+
+// classify wraps err with ErrConnClosed if it means the database was
+// unavailable
+func classify(err error) error {
+	if err == nil || errors.Is(err, ErrConnClosed) || !isUnavailable(err) {
+		return err
+	}
+	return fmt.Errorf("%w: %w", ErrConnClosed, err)
+}
+
+// IsTransient reports whether err is a storage failure that may succeed if
+// the same write is retried: the database being unavailable or too slow
+// (errors wrapping ErrConnClosed, including ErrCircuitOpen, or
+// context.DeadlineExceeded), a serialization failure or deadlock, a lock
+// that could not be taken or a shortage of resources such as connections.
+// Every other error is permanent, e.g. a unique or check constraint
+// violation or a payload the database cannot parse, and retrying it only
+// delays the dead-letter path.
+func IsTransient(err error) bool {
+	switch {
+	case err == nil, errors.Is(err, context.Canceled), errors.Is(err, ErrDuplicateEvent):
+		return false
+	case errors.Is(classify(err), ErrConnClosed), errors.Is(err, context.DeadlineExceeded):
+		return true
+	}
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return false
+	}
+	// Transaction rollback (serialization failure, deadlock), insufficient
+	// resources and lock not available
+	class := pqErr.Code.Class()
+	return class == "40" || class == "53" || pqErr.Code == "55P03"
+}
+
+// isUnavailable reports whether err means the database could not serve the
+// request at all, as opposed to rejecting the event
+func isUnavailable(err error) bool {
+	if isConnectionError(err) {
+		return true
+	}
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		// Connection exceptions and operator intervention, e.g. shutdown
+		class := pqErr.Code.Class()
+		return class == "08" || class == "57"
+	}
+	return false
+}
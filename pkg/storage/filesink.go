@@ -0,0 +1,167 @@
+package storage
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/assure-compliance/eventid/pkg/schema"
+)
+
+// Defaults used when FileSinkConfig fields are unset
+const (
+	DefaultFileSinkMaxBytes       = 64 << 20
+	DefaultFileSinkRotateInterval = time.Hour
+)
+
+// File names used by FileSink. A file is written with partialSuffix and
+// renamed once complete.
+const (
+	fileSinkPrefix = "events-"
+	fileSinkSuffix = ".ndjson"
+	partialSuffix  = ".partial"
+)
+
+// FileSinkConfig configures a FileSink
+type FileSinkConfig struct {
+	// Dir receives the files. It is created if missing and must not be
+	// shared between processes.
+	Dir string
+
+	// A file is completed once it holds MaxBytes (default
+	// DefaultFileSinkMaxBytes) or, at the next write, once it is
+	// RotateInterval old (default DefaultFileSinkRotateInterval)
+	MaxBytes       int
+	RotateInterval time.Duration
+}
+
+// FileSink is a Sink writing events as newline-delimited JSON envelopes
+// into rotating files, for analytics jobs or for copying to object storage.
+// The current file is named events-<start>.ndjson.partial and renamed
+// without the .partial suffix, after an fsync, when it is completed, so a
+// process shipping the directory to S3 or similar should skip .partial
+// files. Files left partial by a crash are completed when the sink is
+// opened.
+type FileSink struct {
+	dir      string
+	maxBytes int
+	interval time.Duration
+
+	mu      sync.Mutex
+	current *os.File // nil until the next write
+	out     *bufio.Writer
+	size    int
+	opened  time.Time
+}
+
+// NewFileSink creates a FileSink writing into cfg.Dir
+func NewFileSink(cfg FileSinkConfig) (*FileSink, error) {
+	if cfg.Dir == "" {
+		return nil, errors.New("file sink directory is required")
+	}
+	if err := os.MkdirAll(cfg.Dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create file sink directory: %w", err)
+	}
+	if cfg.MaxBytes <= 0 {
+		cfg.MaxBytes = DefaultFileSinkMaxBytes
+	}
+	if cfg.RotateInterval <= 0 {
+		cfg.RotateInterval = DefaultFileSinkRotateInterval
+	}
+
+	partial, err := filepath.Glob(filepath.Join(cfg.Dir, fileSinkPrefix+"*"+fileSinkSuffix+partialSuffix))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list partial sink files: %w", err)
+	}
+	for _, path := range partial {
+		if err := os.Rename(path, strings.TrimSuffix(path, partialSuffix)); err != nil {
+			return nil, fmt.Errorf("failed to complete sink file: %w", err)
+		}
+	}
+
+	return &FileSink{dir: cfg.Dir, maxBytes: cfg.MaxBytes, interval: cfg.RotateInterval}, nil
+}
+
+// WriteEvents appends events to the current file, completing it first if it
+// is due for rotation
+func (s *FileSink) WriteEvents(_ context.Context, events []*schema.Event) error {
+	var buf []byte
+	for _, event := range events {
+		line, err := json.Marshal(event)
+		if err != nil {
+			return fmt.Errorf("failed to marshal event: %w", err)
+		}
+		buf = append(append(buf, line...), '\n')
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.current != nil && time.Since(s.opened) >= s.interval {
+		if err := s.complete(); err != nil {
+			return err
+		}
+	}
+	if s.current == nil {
+		if err := s.open(); err != nil {
+			return err
+		}
+	}
+
+	if _, err := s.out.Write(buf); err != nil {
+		return fmt.Errorf("failed to write sink file: %w", err)
+	}
+	if err := s.out.Flush(); err != nil {
+		return fmt.Errorf("failed to write sink file: %w", err)
+	}
+	if s.size += len(buf); s.size >= s.maxBytes {
+		return s.complete()
+	}
+	return nil
+}
+
+// open starts a new partial file. s.mu must be held.
+func (s *FileSink) open() error {
+	now := time.Now().UTC()
+	name := fmt.Sprintf("%s%s-%020d%s%s", fileSinkPrefix, now.Format("20060102T150405Z"), now.UnixNano(), fileSinkSuffix, partialSuffix)
+	f, err := os.OpenFile(filepath.Join(s.dir, name), os.O_CREATE|os.O_WRONLY|os.O_EXCL, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to create sink file: %w", err)
+	}
+	s.current, s.out, s.size, s.opened = f, bufio.NewWriter(f), 0, now
+	return nil
+}
+
+// complete syncs and closes the current file and drops its partial suffix.
+// s.mu must be held.
+func (s *FileSink) complete() error {
+	f := s.current
+	s.current, s.out = nil, nil
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to sync sink file: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to close sink file: %w", err)
+	}
+	if err := os.Rename(f.Name(), strings.TrimSuffix(f.Name(), partialSuffix)); err != nil {
+		return fmt.Errorf("failed to complete sink file: %w", err)
+	}
+	return nil
+}
+
+// Close completes the current file
+func (s *FileSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.current == nil {
+		return nil
+	}
+	return s.complete()
+}
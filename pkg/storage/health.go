@@ -0,0 +1,28 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Ping verifies the database is reachable
+func (s *PostgresStore) Ping(ctx context.Context) error {
+	if err := s.db.PingContext(ctx); err != nil {
+		return s.checkConn(fmt.Errorf("failed to ping database: %w", err))
+	}
+	return nil
+}
+
+// markStored records a successful store. Unlike the counters, the resulting
+// regulatory_events_seconds_since_last_store keeps rising while nothing is
+// stored, so it can alert on a stalled pipeline.
+func (s *PostgresStore) markStored() {
+	s.lastStored.Store(time.Now().UnixNano())
+	s.metrics.sinceLastStore.Set(0)
+}
+
+// updateSinceLastStore sets regulatory_events_seconds_since_last_store
+func (s *PostgresStore) updateSinceLastStore() {
+	s.metrics.sinceLastStore.Set(time.Since(time.Unix(0, s.lastStored.Load())).Seconds())
+}
@@ -0,0 +1,136 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/assure-compliance/eventid/pkg/schema"
+)
+
+// latestColumns lists the latest_events columns in eventRow.args and
+// scanEvent order. The table is not renamed by Config.TableName or Columns.
+const latestColumns = `
+		event_id, event_version, event_type, platform,
+		timestamp, correlation_id, user_id, event_data, entity_id,
+		tenant_id, headers, record_timestamp, kafka_topic,
+		kafka_partition, causation_id`
+
+// LatestKey identifies an entity in the latest-state view: its message key,
+// scoped to the tenant it belongs to and the topic it was read from, since
+// neither tenants nor topics coordinate their keys. Empty TenantID and
+// Topic identify untenanted events and events not read from Kafka.
+type LatestKey struct {
+	TenantID string
+	Topic    string
+	EntityID string
+}
+
+// LatestKeyOf returns the key event's latest state is stored under
+func LatestKeyOf(event *schema.Event) LatestKey {
+	return LatestKey{TenantID: event.TenantID, Topic: event.Topic, EntityID: event.EntityID}
+}
+
+func (k LatestKey) String() string {
+	return fmt.Sprintf("%s (tenant %q, topic %q)", k.EntityID, k.TenantID, k.Topic)
+}
+
+// storeLatestSQL replaces the entity's row unless it holds a newer event.
+// Missing tenants and topics are stored as "", as they are part of the key.
+const storeLatestSQL = `
+	INSERT INTO latest_events (` + latestColumns + `
+	) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, COALESCE($10::varchar, ''), $11, $12,
+		COALESCE($13::varchar, ''), $14, $15)
+	ON CONFLICT (tenant_id, kafka_topic, entity_id) DO UPDATE SET
+		event_id = EXCLUDED.event_id, event_version = EXCLUDED.event_version,
+		event_type = EXCLUDED.event_type, platform = EXCLUDED.platform,
+		timestamp = EXCLUDED.timestamp, correlation_id = EXCLUDED.correlation_id,
+		user_id = EXCLUDED.user_id, event_data = EXCLUDED.event_data,
+		headers = EXCLUDED.headers, record_timestamp = EXCLUDED.record_timestamp,
+		kafka_partition = EXCLUDED.kafka_partition, causation_id = EXCLUDED.causation_id,
+		updated_at = NOW()
+	WHERE latest_events.timestamp <= EXCLUDED.timestamp
+`
+
+const getLatestSQL = `
+	SELECT ` + latestColumns + `
+	FROM latest_events
+	WHERE tenant_id = $1 AND kafka_topic = $2 AND entity_id = $3
+`
+
+// errNoEntityID is returned by StoreLatest for events without an entity ID,
+// which have no key to be stored under
+var errNoEntityID = errors.New("entity ID is required")
+
+// StoreLatest makes event the latest of its entity in the latest_events
+// table, unless the stored event has a later timestamp, so redeliveries and
+// replays never move an entity back in time
+func (s *PostgresStore) StoreLatest(ctx context.Context, event *schema.Event) error {
+	if event.EntityID == "" {
+		return errNoEntityID
+	}
+	row := newEventRow(event, s.storedHeaders)
+	if _, err := s.db.ExecContext(ctx, storeLatestSQL, row.args()...); err != nil {
+		return s.checkConn(fmt.Errorf("failed to store latest event: %w", err))
+	}
+	s.logger.Debug("Stored latest event", row.logAttrs()...)
+	return nil
+}
+
+// GetLatest returns the latest event stored under key, or an error wrapping
+// ErrEventNotFound
+func (s *PostgresStore) GetLatest(key LatestKey) (*schema.Event, error) {
+	rows, err := s.db.Query(getLatestSQL, key.TenantID, key.Topic, key.EntityID)
+	if err != nil {
+		return nil, s.checkConn(fmt.Errorf("failed to query latest event: %w", err))
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return nil, fmt.Errorf("failed to read latest event: %w", err)
+		}
+		return nil, fmt.Errorf("%w: no latest event for entity %s", ErrEventNotFound, key)
+	}
+	return scanEvent(rows)
+}
+
+// StoreLatest keeps event as the latest of its entity unless a later one
+// is held
+func (s *InMemoryStore) StoreLatest(_ context.Context, event *schema.Event) error {
+	if event.EntityID == "" {
+		return errNoEntityID
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := LatestKeyOf(event)
+	if stored, ok := s.latest[key]; ok && stored.Timestamp.After(event.Timestamp) {
+		return nil
+	}
+	s.latest[key] = *event
+	return nil
+}
+
+// GetLatest returns the latest event held under key
+func (s *InMemoryStore) GetLatest(key LatestKey) (*schema.Event, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	event, ok := s.latest[key]
+	if !ok {
+		return nil, fmt.Errorf("%w: no latest event for entity %s", ErrEventNotFound, key)
+	}
+	return &event, nil
+}
+
+// StoreLatest logs the event at debug level
+func (s *DryRunStore) StoreLatest(_ context.Context, event *schema.Event) error {
+	s.logger.Debug("Dry run: would store latest event", newEventRow(event, nil).dryRunAttrs()...)
+	return nil
+}
+
+// GetLatest always reports the entity as having no latest event
+func (s *DryRunStore) GetLatest(key LatestKey) (*schema.Event, error) {
+	return nil, fmt.Errorf("%w: no latest event for entity %s", ErrEventNotFound, key)
+}
@@ -0,0 +1,241 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/assure-compliance/eventid/pkg/schema"
+)
+
+// InMemoryStore keeps events in memory, for tests and local development. It
+// applies the same duplicate and filter semantics as PostgresStore.
+type InMemoryStore struct {
+	mu     sync.RWMutex
+	events []schema.Event // In insertion order
+	ids    map[string]bool
+
+	quarantined []QuarantinedMessage
+	raw         []RawMessage
+	snapshots   []OffsetSnapshot // Oldest first
+
+	latest map[LatestKey]schema.Event // For StoreLatest
+
+	metrics *Metrics
+}
+
+// NewInMemoryStore creates an empty in-memory store reporting to
+// DefaultMetrics
+func NewInMemoryStore() *InMemoryStore {
+	return NewInMemoryStoreWithMetrics(nil)
+}
+
+// NewInMemoryStoreWithMetrics creates an empty in-memory store reporting to
+// metrics, or to DefaultMetrics if nil
+func NewInMemoryStoreWithMetrics(metrics *Metrics) *InMemoryStore {
+	if metrics == nil {
+		metrics = DefaultMetrics()
+	}
+	return &InMemoryStore{ids: make(map[string]bool), latest: make(map[LatestKey]schema.Event), metrics: metrics}
+}
+
+// StoreEvent appends event, returning ErrDuplicateEvent if its ID is
+// already stored
+func (s *InMemoryStore) StoreEvent(_ context.Context, event *schema.Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.ids[event.ID] {
+		s.metrics.duplicateEvents.Inc()
+		return ErrDuplicateEvent
+	}
+	s.ids[event.ID] = true
+	s.events = append(s.events, *event)
+	return nil
+}
+
+// StoreEventBatch appends each event, skipping duplicates
+func (s *InMemoryStore) StoreEventBatch(ctx context.Context, events []*schema.Event) error {
+	for _, event := range events {
+		s.StoreEvent(ctx, event)
+	}
+	return nil
+}
+
+// GetEventByID returns the payload of the event with eventID
+func (s *InMemoryStore) GetEventByID(eventID string) (map[string]interface{}, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, event := range s.events {
+		if event.ID != eventID {
+			continue
+		}
+		var result map[string]interface{}
+		if err := json.Unmarshal(event.Payload, &result); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal event data: %w", err)
+		}
+		return result, nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrEventNotFound, eventID)
+}
+
+// QueryEvents returns events matching filter, newest first
+func (s *InMemoryStore) QueryEvents(filter EventFilter) ([]schema.Event, error) {
+	matched, err := s.match(filter)
+	if err != nil {
+		return nil, err
+	}
+	for i, j := 0, len(matched)-1; i < j; i, j = i+1, j-1 {
+		matched[i], matched[j] = matched[j], matched[i]
+	}
+	return page(matched, filter), nil
+}
+
+// StreamEvents calls fn for each event matching filter, oldest first
+func (s *InMemoryStore) StreamEvents(ctx context.Context, filter EventFilter, fn func(schema.Event) error) error {
+	matched, err := s.match(filter)
+	if err != nil {
+		return err
+	}
+	for _, event := range page(matched, filter) {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := fn(event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Events returns a copy of every stored event in insertion order, for
+// assertions in tests
+func (s *InMemoryStore) Events() []schema.Event {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	events := make([]schema.Event, len(s.events))
+	copy(events, s.events)
+	return events
+}
+
+// Ping always succeeds
+func (s *InMemoryStore) Ping(context.Context) error {
+	return nil
+}
+
+// Close does nothing; stored events remain readable
+func (s *InMemoryStore) Close() error {
+	return nil
+}
+
+// match returns the events matching filter's conditions, oldest first
+func (s *InMemoryStore) match(filter EventFilter) ([]schema.Event, error) {
+	payload, err := payloadFilter(filter.Payload)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var matched []schema.Event
+	for _, event := range s.events {
+		if len(filter.Types) > 0 && !hasType(filter.Types, event.Type) {
+			continue
+		}
+		if !filter.From.IsZero() && event.Timestamp.Before(filter.From) {
+			continue
+		}
+		if !filter.To.IsZero() && event.Timestamp.After(filter.To) {
+			continue
+		}
+		if !filter.RecordFrom.IsZero() && (event.RecordTimestamp.IsZero() || event.RecordTimestamp.Before(filter.RecordFrom)) {
+			continue
+		}
+		if !filter.RecordTo.IsZero() && (event.RecordTimestamp.IsZero() || event.RecordTimestamp.After(filter.RecordTo)) {
+			continue
+		}
+		if filter.Source != "" && event.Source != filter.Source {
+			continue
+		}
+		if filter.EntityID != "" && event.EntityID != filter.EntityID {
+			continue
+		}
+		if filter.TenantID != "" && event.TenantID != filter.TenantID {
+			continue
+		}
+		if !hasHeaders(event.Headers, filter.Headers) {
+			continue
+		}
+		if payload != nil && !hasPayload(event.Payload, payload) {
+			continue
+		}
+		matched = append(matched, event)
+	}
+
+	sort.SliceStable(matched, func(i, j int) bool {
+		return matched[i].Timestamp.Before(matched[j].Timestamp)
+	})
+	return matched, nil
+}
+
+// page applies filter's Offset and Limit to events
+func page(events []schema.Event, filter EventFilter) []schema.Event {
+	if filter.Offset >= len(events) {
+		return nil
+	}
+	if filter.Offset > 0 {
+		events = events[filter.Offset:]
+	}
+	if filter.Limit > 0 && filter.Limit < len(events) {
+		events = events[:filter.Limit]
+	}
+	return events
+}
+
+// hasHeaders reports whether headers contains every entry of want
+func hasHeaders(headers, want map[string]string) bool {
+	for name, value := range want {
+		if v, ok := headers[name]; !ok || v != value {
+			return false
+		}
+	}
+	return true
+}
+
+func hasType(types []schema.EventType, eventType schema.EventType) bool {
+	for _, t := range types {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// payloadFilter decodes the object built by payloadContainment, so that it
+// compares with decoded payloads as the database compares jsonb. It returns
+// nil for an empty filter.
+func payloadFilter(match map[string]interface{}) (interface{}, error) {
+	containment, err := payloadContainment(match)
+	if err != nil || !containment.Valid {
+		return nil, err
+	}
+	var want interface{}
+	if err := json.Unmarshal([]byte(containment.String), &want); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal payload filter: %w", err)
+	}
+	return want, nil
+}
+
+// hasPayload reports whether payload contains want
+func hasPayload(payload json.RawMessage, want interface{}) bool {
+	var doc interface{}
+	if err := json.Unmarshal(payload, &doc); err != nil {
+		return false
+	}
+	return containsJSON(doc, want)
+}
@@ -0,0 +1,194 @@
+package storage
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Metrics holds the package's Prometheus metrics. Create them with
+// NewMetrics and pass them in the stores' configs to register them with a
+// registry of your own; stores configured without them share DefaultMetrics.
+type Metrics struct {
+	dbConnections     *prometheus.GaugeVec
+	duplicateEvents   prometheus.Counter
+	dedupLookups      *prometheus.CounterVec
+	dedupCacheEntries prometheus.Gauge
+	breakerStateGauge prometheus.Gauge
+	breakerRejections prometheus.Counter
+	prunedEvents      *prometheus.CounterVec
+	reconnects        prometheus.Counter
+	revisedEvents     *prometheus.CounterVec
+	spilledEvents     prometheus.Counter
+	spilledPending    prometheus.Gauge
+	spillRejected     prometheus.Counter
+	sinkWrites        *prometheus.CounterVec
+	sinceLastStore    prometheus.Gauge
+	storeDuration     *prometheus.HistogramVec
+	writeRetries      prometheus.Counter
+	writeErrors       *prometheus.CounterVec
+}
+
+var (
+	defaultMetrics     *Metrics
+	defaultMetricsOnce sync.Once
+)
+
+// DefaultMetrics returns the metrics registered with the default registry,
+// used where none are configured. They are created on first use.
+func DefaultMetrics() *Metrics {
+	defaultMetricsOnce.Do(func() {
+		defaultMetrics = NewMetrics(prometheus.DefaultRegisterer, "", "")
+	})
+	return defaultMetrics
+}
+
+// NewMetrics creates the package's metrics and registers them with reg.
+// Their names are prefixed by namespace and subsystem through
+// prometheus.Opts, so that namespace "billing" turns
+// event_store_db_connections into billing_event_store_db_connections; either
+// may be empty. Like promauto, it panics if the metrics are already
+// registered with reg.
+func NewMetrics(reg prometheus.Registerer, namespace, subsystem string) *Metrics {
+	f := promauto.With(reg)
+	m := &Metrics{}
+	m.dbConnections = f.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "event_store_db_connections",
+			Help:      "Database connections in the pool, by state (open, in_use, idle)",
+		},
+		[]string{"state"},
+	)
+	m.duplicateEvents = f.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "regulatory_events_duplicates_total",
+		Help:      "Total number of events skipped because their event ID was already stored",
+	})
+	m.dedupLookups = f.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "regulatory_events_dedup_cache_lookups_total",
+			Help:      "Total number of duplicate cache lookups before inserting an event, by result (hit, miss)",
+		},
+		[]string{"result"},
+	)
+	m.dedupCacheEntries = f.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "regulatory_events_dedup_cache_entries",
+		Help:      "Recently stored events remembered by the duplicate cache",
+	})
+	m.breakerStateGauge = f.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "event_store_circuit_breaker_state",
+		Help:      "State of the storage circuit breaker: 0 closed, 1 open, 2 half-open",
+	})
+	m.breakerRejections = f.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "event_store_circuit_breaker_rejected_total",
+		Help:      "Total number of writes failed fast while the storage circuit breaker was open",
+	})
+	m.prunedEvents = f.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "regulatory_events_pruned_total",
+			Help:      "Total number of events deleted by retention pruning, by event type",
+		},
+		[]string{"event_type"},
+	)
+	m.reconnects = f.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "storage_reconnects_total",
+		Help:      "Total number of times the event store reconnected after losing its database connection",
+	})
+	m.revisedEvents = f.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "regulatory_events_revised_total",
+			Help:      "Total number of stored events revised by an upsert, by event type",
+		},
+		[]string{"event_type"},
+	)
+	m.spilledEvents = f.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "regulatory_events_spilled_total",
+		Help:      "Total number of events buffered on disk because the database was unavailable",
+	})
+	m.spilledPending = f.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "regulatory_events_spill_pending",
+		Help:      "Events buffered on disk that have not yet been flushed to the database",
+	})
+	m.spillRejected = f.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "regulatory_events_spill_rejected_total",
+		Help:      "Total number of buffered events the database rejected when flushed, moved to the spill buffer's rejected file",
+	})
+	m.sinkWrites = f.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "event_sink_events_total",
+			Help:      "Total number of events copied to a FanoutStore sink, by sink and result (success, failure, dropped)",
+		},
+		[]string{"sink", "result"},
+	)
+	m.sinceLastStore = f.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "regulatory_events_seconds_since_last_store",
+		Help:      "Seconds since an event was last stored successfully, or since the store was opened if none has been",
+	})
+	m.storeDuration = f.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "regulatory_event_store_duration_seconds",
+			Help:      "Time taken to store events, by operation (insert, upsert, insert_batch) and result (success, failure)",
+			// 1ms to ~4s
+			Buckets: prometheus.ExponentialBuckets(0.001, 2, 13),
+		},
+		[]string{"operation", "result"},
+	)
+	m.writeRetries = f.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "event_store_write_retries_total",
+		Help:      "Total number of store writes retried by a RetryStore after a transient error",
+	})
+	m.writeErrors = f.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "event_store_write_errors_total",
+			Help:      "Total number of store writes a RetryStore gave up on, by class (transient, permanent)",
+		},
+		[]string{"class"},
+	)
+	return m
+}
+
+// observeStore records the duration of a store operation started at started.
+// Duplicates count as successes.
+func (m *Metrics) observeStore(operation string, started time.Time, err error) {
+	result := "success"
+	if err != nil && !errors.Is(err, ErrDuplicateEvent) {
+		result = "failure"
+	}
+	m.storeDuration.WithLabelValues(operation, result).Observe(time.Since(started).Seconds())
+}
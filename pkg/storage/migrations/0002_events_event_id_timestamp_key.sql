@@ -0,0 +1,5 @@
+-- Deduplicate on (event_id, timestamp) rather than event_id alone. A
+-- partitioned events table can only enforce unique keys that include the
+-- partition key, so inserts use this index as their ON CONFLICT target on
+-- both flat and partitioned tables.
+CREATE UNIQUE INDEX IF NOT EXISTS idx_events_event_id_timestamp ON events(event_id, timestamp);
@@ -0,0 +1,12 @@
+-- Events stay immutable, except that retention pruning may delete rows.
+-- PostgresStore.PruneOlderThan sets eventid.retention_prune for its own
+-- transaction only (SET LOCAL); every other UPDATE or DELETE is rejected.
+CREATE OR REPLACE FUNCTION prevent_event_modification()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'DELETE' AND current_setting('eventid.retention_prune', true) = 'on' THEN
+        RETURN OLD;
+    END IF;
+    RAISE EXCEPTION 'Events are immutable and cannot be modified or deleted';
+END;
+$$ LANGUAGE plpgsql;
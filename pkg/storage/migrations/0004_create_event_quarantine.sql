@@ -0,0 +1,14 @@
+-- Messages that could not be decoded into events, kept for investigation.
+-- Keyed by Kafka position so redelivered messages are stored once.
+CREATE TABLE IF NOT EXISTS event_quarantine (
+    id BIGSERIAL PRIMARY KEY,
+    topic VARCHAR(255) NOT NULL,
+    kafka_partition INTEGER NOT NULL,
+    kafka_offset BIGINT NOT NULL,
+    raw_value BYTEA,
+    error TEXT NOT NULL,
+    quarantined_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    UNIQUE (topic, kafka_partition, kafka_offset)
+);
+
+CREATE INDEX IF NOT EXISTS idx_event_quarantine_quarantined_at ON event_quarantine(quarantined_at DESC);
@@ -0,0 +1,48 @@
+-- Upserted event types may be revised: a re-emitted event with a known ID
+-- and a different payload replaces the stored row, and the trigger copies
+-- the row it replaces into event_revisions first, so nothing is lost.
+-- PostgresStore sets eventid.event_revision for the revising transaction
+-- only (SET LOCAL); every other UPDATE is still rejected.
+ALTER TABLE events ADD COLUMN IF NOT EXISTS revised_at TIMESTAMP WITH TIME ZONE;
+
+CREATE TABLE IF NOT EXISTS event_revisions (
+    id BIGSERIAL PRIMARY KEY,
+    event_id UUID NOT NULL,
+    event_version INTEGER NOT NULL,
+    correlation_id VARCHAR(255),
+    user_id VARCHAR(255),
+    event_data JSONB NOT NULL,
+    stored_at TIMESTAMP WITH TIME ZONE NOT NULL, -- When this revision was stored
+    superseded_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_event_revisions_event_id ON event_revisions(event_id, superseded_at);
+
+CREATE OR REPLACE FUNCTION prevent_event_modification()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'DELETE' AND current_setting('eventid.retention_prune', true) = 'on' THEN
+        IF TG_TABLE_NAME = 'events' THEN
+            DELETE FROM event_revisions WHERE event_id = OLD.event_id;
+        END IF;
+        RETURN OLD;
+    END IF;
+    IF TG_OP = 'UPDATE' AND TG_TABLE_NAME = 'events'
+        AND current_setting('eventid.event_revision', true) = 'on' THEN
+        INSERT INTO event_revisions (
+            event_id, event_version, correlation_id, user_id, event_data, stored_at
+        ) VALUES (
+            OLD.event_id, OLD.event_version, OLD.correlation_id, OLD.user_id,
+            OLD.event_data, COALESCE(OLD.revised_at, OLD.created_at)
+        );
+        RETURN NEW;
+    END IF;
+    RAISE EXCEPTION 'Events are immutable and cannot be modified or deleted';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS prevent_event_revision_modification ON event_revisions;
+CREATE TRIGGER prevent_event_revision_modification
+    BEFORE UPDATE OR DELETE ON event_revisions
+    FOR EACH ROW
+    EXECUTE FUNCTION prevent_event_modification();
@@ -0,0 +1,6 @@
+-- The Kafka message key, which upstream sets to the ID of the entity an
+-- event is about. Events stored before this migration have no entity ID.
+ALTER TABLE events ADD COLUMN IF NOT EXISTS entity_id VARCHAR(255);
+
+-- An entity's audit timeline, oldest first
+CREATE INDEX IF NOT EXISTS idx_events_entity_timestamp ON events(entity_id, timestamp) WHERE entity_id IS NOT NULL;
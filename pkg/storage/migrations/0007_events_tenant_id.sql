@@ -0,0 +1,6 @@
+-- The tenant an event belongs to, from its Kafka tenant header, so that
+-- tenants sharing a topic can be queried and reported on separately.
+-- Events stored before this migration have no tenant.
+ALTER TABLE events ADD COLUMN IF NOT EXISTS tenant_id VARCHAR(255);
+
+CREATE INDEX IF NOT EXISTS idx_events_tenant_timestamp ON events(tenant_id, timestamp DESC) WHERE tenant_id IS NOT NULL;
@@ -0,0 +1,6 @@
+-- Message headers chosen with Config.StoredHeaders, as a JSON object of
+-- name to value. Which headers are kept is configuration, so they share
+-- one column and one index instead of a column each.
+ALTER TABLE events ADD COLUMN IF NOT EXISTS headers JSONB;
+
+CREATE INDEX IF NOT EXISTS idx_events_headers ON events USING GIN (headers jsonb_path_ops) WHERE headers IS NOT NULL;
@@ -0,0 +1,5 @@
+-- Serves EventFilter.Payload, which matches event_data with @>. The
+-- jsonb_path_ops operator class only supports containment but is smaller
+-- and faster than the default. Building it on a large table takes a while
+-- and blocks writes, so apply this migration during a quiet period.
+CREATE INDEX IF NOT EXISTS idx_events_data ON events USING GIN (event_data jsonb_path_ops);
@@ -0,0 +1,7 @@
+-- Kafka record timestamp of each event: when it was produced, or appended
+-- to the log. timestamp stays the event time from the payload, which
+-- retention uses; this is ingest time. Rows stored before this migration
+-- have none.
+ALTER TABLE events ADD COLUMN IF NOT EXISTS record_timestamp TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX IF NOT EXISTS idx_events_record_timestamp ON events(record_timestamp DESC) WHERE record_timestamp IS NOT NULL;
@@ -0,0 +1,12 @@
+-- Snapshots of consumer group offsets, kept outside Kafka so a group can
+-- be restored to a known point after losing __consumer_offsets. The rows
+-- of one snapshot share group_id and taken_at.
+CREATE TABLE IF NOT EXISTS consumer_offset_snapshots (
+    id BIGSERIAL PRIMARY KEY,
+    group_id VARCHAR(255) NOT NULL,
+    topic VARCHAR(255) NOT NULL,
+    kafka_partition INTEGER NOT NULL,
+    kafka_offset BIGINT NOT NULL,
+    taken_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    UNIQUE (group_id, taken_at, topic, kafka_partition)
+);
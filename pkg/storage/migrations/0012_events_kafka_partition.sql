@@ -0,0 +1,7 @@
+-- Kafka topic and partition each event was read from, so the stored events
+-- of a partition can be counted against its offsets by reconcile. Rows
+-- stored before this migration have neither.
+ALTER TABLE events ADD COLUMN IF NOT EXISTS kafka_topic VARCHAR(255);
+ALTER TABLE events ADD COLUMN IF NOT EXISTS kafka_partition INTEGER;
+
+CREATE INDEX IF NOT EXISTS idx_events_kafka_partition ON events(kafka_topic, kafka_partition, record_timestamp) WHERE kafka_topic IS NOT NULL;
@@ -0,0 +1,31 @@
+-- Every Kafka message as received, before decoding, so that what arrived
+-- can be shown apart from what could be processed. Keyed by Kafka position
+-- so redelivered messages are stored once. Rows can never be changed or
+-- deleted.
+CREATE TABLE IF NOT EXISTS raw_messages (
+    id BIGSERIAL PRIMARY KEY,
+    topic VARCHAR(255) NOT NULL,
+    kafka_partition INTEGER NOT NULL,
+    kafka_offset BIGINT NOT NULL,
+    message_key BYTEA,
+    raw_value BYTEA,
+    headers JSONB, -- Array of {"key", "value"} with base64 values, in message order
+    record_timestamp TIMESTAMP WITH TIME ZONE, -- Kafka record timestamp
+    received_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    UNIQUE (topic, kafka_partition, kafka_offset)
+);
+
+CREATE INDEX IF NOT EXISTS idx_raw_messages_received_at ON raw_messages(received_at DESC);
+
+CREATE OR REPLACE FUNCTION prevent_raw_message_modification()
+RETURNS TRIGGER AS $$
+BEGIN
+    RAISE EXCEPTION 'Raw messages are append-only and cannot be modified or deleted';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS prevent_raw_message_modification ON raw_messages;
+CREATE TRIGGER prevent_raw_message_modification
+    BEFORE UPDATE OR DELETE ON raw_messages
+    FOR EACH ROW
+    EXECUTE FUNCTION prevent_raw_message_modification();
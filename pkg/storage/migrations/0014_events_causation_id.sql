@@ -0,0 +1,7 @@
+-- event_id of the event that caused each event, from the payload's
+-- causation_id or the causation-id header. With correlation_id it lets
+-- GetCausalChain rebuild what happened because of a request. Rows stored
+-- before this migration have none.
+ALTER TABLE events ADD COLUMN IF NOT EXISTS causation_id VARCHAR(255);
+
+CREATE INDEX IF NOT EXISTS idx_events_causation_id ON events(causation_id) WHERE causation_id IS NOT NULL;
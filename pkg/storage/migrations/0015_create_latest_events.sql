@@ -0,0 +1,27 @@
+-- The most recent event of each entity, for event types configured to feed
+-- it, as a current-state view beside the full history in events. Rows are
+-- replaced in place, so unlike events this table is mutable; an event older
+-- than the stored one never replaces it. An entity is its message key
+-- within a tenant and topic, which do not coordinate their keys; either is
+-- '' when the event has none.
+CREATE TABLE IF NOT EXISTS latest_events (
+    tenant_id VARCHAR(255) NOT NULL DEFAULT '',
+    kafka_topic VARCHAR(255) NOT NULL DEFAULT '',
+    entity_id VARCHAR(255) NOT NULL, -- Kafka message key
+    event_id UUID NOT NULL,
+    event_version INTEGER NOT NULL,
+    event_type VARCHAR(100) NOT NULL,
+    platform VARCHAR(50) NOT NULL,
+    timestamp TIMESTAMP WITH TIME ZONE NOT NULL,
+    correlation_id VARCHAR(255),
+    user_id VARCHAR(255),
+    event_data JSONB NOT NULL,
+    headers JSONB,
+    record_timestamp TIMESTAMP WITH TIME ZONE,
+    kafka_partition INTEGER,
+    causation_id VARCHAR(255),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (tenant_id, kafka_topic, entity_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_latest_events_event_type ON latest_events(event_type);
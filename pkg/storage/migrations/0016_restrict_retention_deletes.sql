@@ -0,0 +1,52 @@
+-- Retention deletes used to be allowed to any session that set
+-- eventid.retention_prune, so every role granted DELETE on events could
+-- remove audit rows. They now also need the privileges of the table's
+-- owner, who could disable the trigger anyway. Other roles prune through
+-- prune_events, which runs as its owner (SECURITY DEFINER) and is only
+-- executable once granted, e.g.
+--   GRANT EXECUTE ON FUNCTION prune_events(VARCHAR, TIMESTAMPTZ, INTEGER) TO eventid_app;
+CREATE OR REPLACE FUNCTION prevent_event_modification()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'DELETE' AND current_setting('eventid.retention_prune', true) = 'on'
+        AND (SELECT pg_has_role(current_user, relowner, 'USAGE') FROM pg_class WHERE oid = TG_RELID) THEN
+        IF TG_TABLE_NAME = 'events' THEN
+            DELETE FROM event_revisions WHERE event_id = OLD.event_id;
+        END IF;
+        RETURN OLD;
+    END IF;
+    IF TG_OP = 'UPDATE' AND TG_TABLE_NAME = 'events'
+        AND current_setting('eventid.event_revision', true) = 'on' THEN
+        INSERT INTO event_revisions (
+            event_id, event_version, correlation_id, user_id, event_data, stored_at
+        ) VALUES (
+            OLD.event_id, OLD.event_version, OLD.correlation_id, OLD.user_id,
+            OLD.event_data, COALESCE(OLD.revised_at, OLD.created_at)
+        );
+        RETURN NEW;
+    END IF;
+    RAISE EXCEPTION 'Events are immutable and cannot be modified or deleted';
+END;
+$$ LANGUAGE plpgsql;
+
+-- Deletes up to p_limit events of p_event_type older than p_cutoff,
+-- returning how many were deleted
+CREATE OR REPLACE FUNCTION prune_events(p_event_type VARCHAR, p_cutoff TIMESTAMPTZ, p_limit INTEGER)
+RETURNS BIGINT AS $$
+DECLARE
+    deleted BIGINT;
+BEGIN
+    PERFORM set_config('eventid.retention_prune', 'on', true);
+    DELETE FROM events
+    WHERE event_id IN (
+        SELECT event_id FROM events
+        WHERE event_type = p_event_type AND timestamp < p_cutoff
+        LIMIT p_limit
+    );
+    GET DIAGNOSTICS deleted = ROW_COUNT;
+    PERFORM set_config('eventid.retention_prune', 'off', true);
+    RETURN deleted;
+END;
+$$ LANGUAGE plpgsql SECURITY DEFINER SET search_path = public, pg_temp;
+
+REVOKE ALL ON FUNCTION prune_events(VARCHAR, TIMESTAMPTZ, INTEGER) FROM PUBLIC;
@@ -0,0 +1,106 @@
+package storage
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// DefaultTableName is the events table used when Config.TableName is unset
+const DefaultTableName = "events"
+
+// eventColumnNames are the events table columns read and written by
+// PostgresStore, by their default names. Config.Columns may rename any of
+// them.
+var eventColumnNames = []string{
+	"id", "event_id", "event_version", "event_type", "platform", "timestamp",
+	"correlation_id", "user_id", "event_data", "revised_at", "entity_id",
+	"tenant_id", "headers", "record_timestamp", "kafka_topic",
+	"kafka_partition", "causation_id",
+}
+
+// identifierPattern matches the table and column names accepted in Config.
+// Names are quoted in statements regardless, so this only rejects names
+// that are almost certainly mistakes.
+var identifierPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_$]{0,62}$`)
+
+// sqlNames renders the events statements with the configured table and
+// column names. Statements are written with the default names in braces,
+// e.g. {events} and {event_id}, which render as quoted identifiers.
+type sqlNames struct {
+	replacer  *strings.Replacer
+	custom    bool   // Some name differs from its default
+	schema    string // Schema of the events table, "" for the search path
+	tableName string // Unqualified events table name
+}
+
+// ValidateNames reports whether table and columns are acceptable as
+// Config.TableName and Config.Columns
+func ValidateNames(table string, columns map[string]string) error {
+	_, err := newSQLNames(table, columns)
+	return err
+}
+
+// newSQLNames validates table and columns and returns their renderer
+func newSQLNames(table string, columns map[string]string) (*sqlNames, error) {
+	if table == "" {
+		table = DefaultTableName
+	}
+	n := &sqlNames{tableName: table, custom: table != DefaultTableName}
+	if schema, name, ok := strings.Cut(table, "."); ok {
+		n.schema, n.tableName = schema, name
+		if !identifierPattern.MatchString(schema) {
+			return nil, fmt.Errorf("invalid table name %q: schema is not a valid identifier", table)
+		}
+	}
+	if !identifierPattern.MatchString(n.tableName) {
+		return nil, fmt.Errorf("invalid table name %q: not a valid identifier", table)
+	}
+
+	known := make(map[string]bool, len(eventColumnNames))
+	for _, column := range eventColumnNames {
+		known[column] = true
+	}
+	for column, name := range columns {
+		if !known[column] {
+			return nil, fmt.Errorf("unknown events column %q in column mapping", column)
+		}
+		if !identifierPattern.MatchString(name) {
+			return nil, fmt.Errorf("invalid name %q for column %s: not a valid identifier", name, column)
+		}
+		if name != column {
+			n.custom = true
+		}
+	}
+
+	pairs := []string{"{events}", n.table()}
+	for _, column := range eventColumnNames {
+		name, ok := columns[column]
+		if !ok {
+			name = column
+		}
+		pairs = append(pairs, "{"+column+"}", pq.QuoteIdentifier(name))
+	}
+	n.replacer = strings.NewReplacer(pairs...)
+	return n, nil
+}
+
+// render substitutes the configured names into query
+func (n *sqlNames) render(query string) string {
+	return n.replacer.Replace(query)
+}
+
+// table returns the quoted, possibly schema-qualified events table
+func (n *sqlNames) table() string {
+	return n.qualify(n.tableName)
+}
+
+// qualify quotes name, a table in the events table's schema
+func (n *sqlNames) qualify(name string) string {
+	if n.schema == "" {
+		return pq.QuoteIdentifier(name)
+	}
+	return pq.QuoteIdentifier(n.schema) + "." + pq.QuoteIdentifier(name)
+}
@@ -0,0 +1,145 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// ErrSnapshotNotFound is returned by LoadOffsets when the group has no
+// snapshot taken at or before the requested time
+var ErrSnapshotNotFound = errors.New("offset snapshot not found")
+
+// StoredOffset is a committed offset of one partition
+type StoredOffset struct {
+	Topic     string
+	Partition int32
+	Offset    int64
+}
+
+// OffsetSnapshot is a consumer group's committed offsets as saved by
+// SaveOffsets
+type OffsetSnapshot struct {
+	GroupID string
+	TakenAt time.Time
+	Offsets []StoredOffset // Sorted by topic and partition
+}
+
+// insertOffsetSQL stores one partition's offset in a snapshot
+const insertOffsetSQL = `
+	INSERT INTO consumer_offset_snapshots (group_id, topic, kafka_partition, kafka_offset, taken_at)
+	VALUES ($1, $2, $3, $4, $5)
+`
+
+// loadOffsetsSQL selects the group's latest snapshot taken at or before $2,
+// or its latest snapshot if $2 is NULL
+const loadOffsetsSQL = `
+	SELECT topic, kafka_partition, kafka_offset, taken_at
+	FROM consumer_offset_snapshots
+	WHERE group_id = $1 AND taken_at = (
+		SELECT MAX(taken_at) FROM consumer_offset_snapshots
+		WHERE group_id = $1 AND ($2::timestamptz IS NULL OR taken_at <= $2)
+	)
+	ORDER BY topic, kafka_partition
+`
+
+// snapshotTime returns the current time at the database's microsecond
+// precision, so a snapshot's TakenAt matches what is stored
+func snapshotTime() time.Time {
+	return time.Now().UTC().Truncate(time.Microsecond)
+}
+
+// SaveOffsets stores offsets as a snapshot of group's committed offsets in
+// the consumer_offset_snapshots table, returning the time it was taken.
+// Snapshots are kept independently of Kafka's __consumer_offsets, so a
+// group can be restored to one after losing the cluster's state.
+func (s *PostgresStore) SaveOffsets(ctx context.Context, group string, offsets []StoredOffset) (time.Time, error) {
+	takenAt := snapshotTime()
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return time.Time{}, s.checkConn(fmt.Errorf("failed to begin offset snapshot transaction: %w", err))
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, insertOffsetSQL)
+	if err != nil {
+		return time.Time{}, s.checkConn(fmt.Errorf("failed to prepare offset snapshot: %w", err))
+	}
+	defer stmt.Close()
+	for _, o := range offsets {
+		if _, err := stmt.ExecContext(ctx, group, o.Topic, o.Partition, o.Offset, takenAt); err != nil {
+			return time.Time{}, s.checkConn(fmt.Errorf("failed to store offset of %s[%d]: %w", o.Topic, o.Partition, err))
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return time.Time{}, s.checkConn(fmt.Errorf("failed to commit offset snapshot: %w", err))
+	}
+
+	s.logger.Info("Saved offset snapshot", "group", group, "partitions", len(offsets), "taken_at", takenAt)
+	return takenAt, nil
+}
+
+// LoadOffsets returns group's latest snapshot taken at or before at, or its
+// latest snapshot if at is zero. It returns ErrSnapshotNotFound if there is
+// none.
+func (s *PostgresStore) LoadOffsets(ctx context.Context, group string, at time.Time) (*OffsetSnapshot, error) {
+	rows, err := s.db.QueryContext(ctx, loadOffsetsSQL, group, nullTime(at))
+	if err != nil {
+		return nil, s.checkConn(fmt.Errorf("failed to query offset snapshot: %w", err))
+	}
+	defer rows.Close()
+
+	snapshot := &OffsetSnapshot{GroupID: group}
+	for rows.Next() {
+		var o StoredOffset
+		if err := rows.Scan(&o.Topic, &o.Partition, &o.Offset, &snapshot.TakenAt); err != nil {
+			return nil, fmt.Errorf("failed to scan offset snapshot: %w", err)
+		}
+		snapshot.Offsets = append(snapshot.Offsets, o)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read offset snapshot: %w", err)
+	}
+	if len(snapshot.Offsets) == 0 {
+		return nil, fmt.Errorf("%w for group %s", ErrSnapshotNotFound, group)
+	}
+	return snapshot, nil
+}
+
+// SaveOffsets keeps the snapshot in memory
+func (s *InMemoryStore) SaveOffsets(_ context.Context, group string, offsets []StoredOffset) (time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	snapshot := OffsetSnapshot{GroupID: group, TakenAt: snapshotTime(), Offsets: append([]StoredOffset(nil), offsets...)}
+	sortOffsets(snapshot.Offsets)
+	s.snapshots = append(s.snapshots, snapshot)
+	return snapshot.TakenAt, nil
+}
+
+// LoadOffsets returns the latest snapshot of group saved at or before at
+func (s *InMemoryStore) LoadOffsets(_ context.Context, group string, at time.Time) (*OffsetSnapshot, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for i := len(s.snapshots) - 1; i >= 0; i-- {
+		snapshot := s.snapshots[i]
+		if snapshot.GroupID != group || (!at.IsZero() && snapshot.TakenAt.After(at)) {
+			continue
+		}
+		snapshot.Offsets = append([]StoredOffset(nil), snapshot.Offsets...)
+		return &snapshot, nil
+	}
+	return nil, fmt.Errorf("%w for group %s", ErrSnapshotNotFound, group)
+}
+
+func sortOffsets(offsets []StoredOffset) {
+	sort.Slice(offsets, func(i, j int) bool {
+		if offsets[i].Topic != offsets[j].Topic {
+			return offsets[i].Topic < offsets[j].Topic
+		}
+		return offsets[i].Partition < offsets[j].Partition
+	})
+}
@@ -0,0 +1,51 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrNotPartitioned is returned by EnsurePartition when the events table is
+// a plain table rather than partitioned by timestamp
+var ErrNotPartitioned = errors.New("events table is not partitioned")
+
+// EnsurePartition creates the monthly partition of the events table that
+// holds month, if it does not already exist. Months are UTC calendar months.
+// Inserts are routed to the right partition by PostgreSQL, so this only needs
+// to run ahead of the first event of each month. See
+// events_partitioning.sql for converting an existing table.
+func (s *PostgresStore) EnsurePartition(ctx context.Context, month time.Time) error {
+	var partitioned bool
+	err := s.db.QueryRowContext(ctx,
+		"SELECT relkind = 'p' FROM pg_class WHERE oid = $1::regclass", s.names.table()).Scan(&partitioned)
+	if err != nil {
+		return fmt.Errorf("failed to inspect events table: %w", err)
+	}
+	if !partitioned {
+		return ErrNotPartitioned
+	}
+
+	start := time.Date(month.UTC().Year(), month.UTC().Month(), 1, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(0, 1, 0)
+	name := partitionName(s.names.tableName, start)
+
+	// Bounds are derived from the date and identifiers are validated and
+	// quoted, never taken from input text as-is
+	_, err = s.db.ExecContext(ctx, fmt.Sprintf(
+		"CREATE TABLE IF NOT EXISTS %s PARTITION OF %s FOR VALUES FROM ('%s') TO ('%s')",
+		s.names.qualify(name), s.names.table(), start.Format(time.RFC3339), end.Format(time.RFC3339)))
+	if err != nil {
+		return fmt.Errorf("failed to create partition %s: %w", name, err)
+	}
+
+	s.logger.Debug("Ensured events partition", "partition", name)
+	return nil
+}
+
+// partitionName returns the name of table's partition starting at month,
+// e.g. events_y2024m03
+func partitionName(table string, month time.Time) string {
+	return fmt.Sprintf("%s_y%04dm%02d", table, month.Year(), int(month.Month()))
+}
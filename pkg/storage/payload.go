@@ -0,0 +1,96 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// payloadContainment builds the JSON object matched against event_data
+// with @> from EventFilter.Payload, nesting each dot-separated path, e.g.
+// {"jurisdiction.region": "EU"} becomes {"jurisdiction": {"region": "EU"}}.
+// Paths only ever become JSON keys in a query parameter, so they cannot
+// change the SQL. An empty filter returns NULL, which disables the
+// condition.
+func payloadContainment(match map[string]interface{}) (sql.NullString, error) {
+	if len(match) == 0 {
+		return sql.NullString{}, nil
+	}
+
+	paths := make([]string, 0, len(match))
+	for path := range match {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	root := make(map[string]interface{})
+	for _, path := range paths {
+		value := match[path]
+		if !isScalar(value) {
+			return sql.NullString{}, fmt.Errorf("invalid payload filter %q: value must be a string, number, bool or nil", path)
+		}
+
+		node := root
+		keys := strings.Split(path, ".")
+		for i, key := range keys {
+			if key == "" {
+				return sql.NullString{}, fmt.Errorf("invalid payload filter %q: empty key in path", path)
+			}
+			child, exists := node[key]
+			if i == len(keys)-1 {
+				if exists {
+					return sql.NullString{}, fmt.Errorf("invalid payload filter %q: conflicts with another path", path)
+				}
+				node[key] = value
+				break
+			}
+			if !exists {
+				child = make(map[string]interface{})
+				node[key] = child
+			}
+			next, ok := child.(map[string]interface{})
+			if !ok {
+				return sql.NullString{}, fmt.Errorf("invalid payload filter %q: conflicts with another path", path)
+			}
+			node = next
+		}
+	}
+
+	data, err := json.Marshal(root)
+	if err != nil {
+		return sql.NullString{}, fmt.Errorf("failed to marshal payload filter: %w", err)
+	}
+	return sql.NullString{String: string(data), Valid: true}, nil
+}
+
+func isScalar(value interface{}) bool {
+	switch value.(type) {
+	case nil, string, bool, json.Number,
+		int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		return true
+	}
+	return false
+}
+
+// containsJSON reports whether doc contains want as jsonb @> does for the
+// objects built by payloadContainment: every key of want is present in doc
+// with an equal scalar or a containing object
+func containsJSON(doc, want interface{}) bool {
+	wantObj, ok := want.(map[string]interface{})
+	if !ok {
+		return reflect.DeepEqual(doc, want)
+	}
+	docObj, ok := doc.(map[string]interface{})
+	if !ok {
+		return false
+	}
+	for key, value := range wantObj {
+		if v, present := docObj[key]; !present || !containsJSON(v, value) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,126 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/assure-compliance/eventid/pkg/schema"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Dot-separated paths nest into the object matched with @>
+func TestPayloadContainment(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		match map[string]interface{}
+		want  string
+	}{
+		{"top level", map[string]interface{}{"status": "open"}, `{"status":"open"}`},
+		{"nested", map[string]interface{}{"jurisdiction.region": "EU"}, `{"jurisdiction":{"region":"EU"}}`},
+		{"shared prefix", map[string]interface{}{"a.b": 1, "a.c": true, "d": nil}, `{"a":{"b":1,"c":true},"d":null}`},
+		{"number", map[string]interface{}{"severity": json.Number("5")}, `{"severity":5}`},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := payloadContainment(tc.match)
+			if err != nil {
+				t.Fatalf("payloadContainment failed: %v", err)
+			}
+			if !got.Valid || got.String != tc.want {
+				t.Errorf("payloadContainment = %q, want %q", got.String, tc.want)
+			}
+		})
+	}
+
+	if got, err := payloadContainment(nil); err != nil || got.Valid {
+		t.Errorf("empty filter gave %q (%v), want NULL", got.String, err)
+	}
+}
+
+// Validate rejects payload paths and values that cannot be matched
+func TestEventFilterValidate(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		payload map[string]interface{}
+		want    string // Substring of the error, or "" if valid
+	}{
+		{"valid", map[string]interface{}{"jurisdiction.region": "EU", "severity": 5}, ""},
+		{"empty path", map[string]interface{}{"": "EU"}, "empty key"},
+		{"leading dot", map[string]interface{}{".region": "EU"}, "empty key"},
+		{"double dot", map[string]interface{}{"jurisdiction..region": "EU"}, "empty key"},
+		{"trailing dot", map[string]interface{}{"jurisdiction.": "EU"}, "empty key"},
+		{"value under value", map[string]interface{}{"jurisdiction": "EU", "jurisdiction.region": "EU"}, "conflicts"},
+		{"object value", map[string]interface{}{"jurisdiction": map[string]interface{}{"region": "EU"}}, "must be a string"},
+		{"array value", map[string]interface{}{"regions": []interface{}{"EU"}}, "must be a string"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := EventFilter{Payload: tc.payload}.Validate()
+			switch {
+			case tc.want == "" && err != nil:
+				t.Errorf("Validate returned %v, want nil", err)
+			case tc.want != "" && (err == nil || !strings.Contains(err.Error(), tc.want)):
+				t.Errorf("Validate returned %v, want an error containing %q", err, tc.want)
+			}
+		})
+	}
+}
+
+// InMemoryStore matches payload conditions as jsonb containment does: by
+// nested path, with values of the same JSON type only
+func TestInMemoryStorePayloadFilter(t *testing.T) {
+	store := NewInMemoryStoreWithMetrics(NewMetrics(prometheus.NewRegistry(), "", ""))
+	for id, payload := range map[string]string{
+		"evt-eu":     `{"jurisdiction":{"region":"EU","country":"FR"},"severity":5,"open":true}`,
+		"evt-us":     `{"jurisdiction":{"region":"US"},"severity":"5","open":false}`,
+		"evt-flat":   `{"jurisdiction":"EU","severity":null}`,
+		"evt-nested": `{"jurisdiction":{"region":{"code":"EU"}}}`,
+	} {
+		event := &schema.Event{
+			ID:        id,
+			Type:      schema.EventViolationFound,
+			Timestamp: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+			Payload:   json.RawMessage(payload),
+		}
+		if err := store.StoreEvent(context.Background(), event); err != nil {
+			t.Fatalf("StoreEvent failed: %v", err)
+		}
+	}
+
+	for _, tc := range []struct {
+		name    string
+		payload map[string]interface{}
+		want    string // Comma-separated matching IDs, sorted
+	}{
+		{"nested path", map[string]interface{}{"jurisdiction.region": "EU"}, "evt-eu"},
+		{"every condition", map[string]interface{}{"jurisdiction.region": "EU", "jurisdiction.country": "DE"}, ""},
+		{"number", map[string]interface{}{"severity": 5}, "evt-eu"},
+		{"string is not number", map[string]interface{}{"severity": "5"}, "evt-us"},
+		{"bool", map[string]interface{}{"open": false}, "evt-us"},
+		{"null", map[string]interface{}{"severity": nil}, "evt-flat"},
+		{"top level", map[string]interface{}{"jurisdiction": "EU"}, "evt-flat"},
+		{"deeper path", map[string]interface{}{"jurisdiction.region.code": "EU"}, "evt-nested"},
+		{"missing path", map[string]interface{}{"status": "open"}, ""},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			events, err := store.QueryEvents(EventFilter{Payload: tc.payload})
+			if err != nil {
+				t.Fatalf("QueryEvents failed: %v", err)
+			}
+			var ids []string
+			for _, event := range events {
+				ids = append(ids, event.ID)
+			}
+			sort.Strings(ids)
+			if got := strings.Join(ids, ","); got != tc.want {
+				t.Errorf("matched %q, want %q", got, tc.want)
+			}
+		})
+	}
+
+	if _, err := store.QueryEvents(EventFilter{Payload: map[string]interface{}{"a..b": 1}}); err == nil {
+		t.Error("QueryEvents accepted an invalid payload path")
+	}
+}
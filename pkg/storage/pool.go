@@ -0,0 +1,62 @@
+package storage
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Connection pool defaults, used when the corresponding Config field is zero
+const (
+	DefaultMaxOpenConns    = 25
+	DefaultMaxIdleConns    = 5
+	DefaultConnMaxLifetime = 5 * time.Minute
+)
+
+// poolStatsInterval is how often connection pool gauges, and
+// regulatory_events_seconds_since_last_store, are sampled
+const poolStatsInterval = 10 * time.Second
+
+// configurePool applies the pool settings in cfg to db and returns the
+// idle connection limit it set
+func configurePool(db *sql.DB, cfg Config) int {
+	if cfg.MaxOpenConns == 0 {
+		cfg.MaxOpenConns = DefaultMaxOpenConns
+	}
+	if cfg.MaxIdleConns == 0 {
+		cfg.MaxIdleConns = DefaultMaxIdleConns
+	}
+	if cfg.ConnMaxLifetime == 0 {
+		cfg.ConnMaxLifetime = DefaultConnMaxLifetime
+	}
+
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+	return cfg.MaxIdleConns
+}
+
+// monitorPool samples connection pool statistics and the time since the last
+// store until the store is closed
+func (s *PostgresStore) monitorPool(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		s.updatePoolStats()
+		s.updateSinceLastStore()
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// updatePoolStats sets the pool gauges from the current sql.DBStats
+func (s *PostgresStore) updatePoolStats() {
+	stats := s.db.Stats()
+	s.metrics.dbConnections.WithLabelValues("open").Set(float64(stats.OpenConnections))
+	s.metrics.dbConnections.WithLabelValues("in_use").Set(float64(stats.InUse))
+	s.metrics.dbConnections.WithLabelValues("idle").Set(float64(stats.Idle))
+}
@@ -0,0 +1,74 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// QuarantinedMessage is a Kafka message that could not be decoded into an
+// event, stored with the decode error
+type QuarantinedMessage struct {
+	Topic         string
+	Partition     int32
+	Offset        int64
+	Raw           []byte
+	Error         string
+	QuarantinedAt time.Time
+}
+
+// insertQuarantineSQL stores a quarantined message once per Kafka position
+const insertQuarantineSQL = `
+	INSERT INTO event_quarantine (topic, kafka_partition, kafka_offset, raw_value, error)
+	VALUES ($1, $2, $3, $4, $5)
+	ON CONFLICT (topic, kafka_partition, kafka_offset) DO NOTHING
+`
+
+// StoreRawQuarantine stores the raw bytes of a message that failed to decode
+// in the event_quarantine table. A message already quarantined at the same
+// position is left unchanged.
+func (s *PostgresStore) StoreRawQuarantine(ctx context.Context, topic string, partition int32, offset int64, raw []byte, decodeErr error) error {
+	_, err := s.db.ExecContext(ctx, insertQuarantineSQL, topic, partition, offset, raw, decodeErr.Error())
+	if err != nil {
+		return s.checkConn(fmt.Errorf("failed to quarantine message: %w", err))
+	}
+
+	s.logger.Info("Quarantined message",
+		"topic", topic, "partition", partition, "offset", offset, "error", decodeErr)
+	return nil
+}
+
+// StoreRawQuarantine keeps the message in memory
+func (s *InMemoryStore) StoreRawQuarantine(_ context.Context, topic string, partition int32, offset int64, raw []byte, decodeErr error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.quarantined = append(s.quarantined, QuarantinedMessage{
+		Topic:         topic,
+		Partition:     partition,
+		Offset:        offset,
+		Raw:           append([]byte(nil), raw...),
+		Error:         decodeErr.Error(),
+		QuarantinedAt: time.Now(),
+	})
+	return nil
+}
+
+// Quarantined returns a copy of every quarantined message, for assertions
+// in tests
+func (s *InMemoryStore) Quarantined() []QuarantinedMessage {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	messages := make([]QuarantinedMessage, len(s.quarantined))
+	copy(messages, s.quarantined)
+	return messages
+}
+
+// StoreRawQuarantine logs the message at info level
+func (s *DryRunStore) StoreRawQuarantine(_ context.Context, topic string, partition int32, offset int64, raw []byte, decodeErr error) error {
+	s.logger.Info("Dry run: would quarantine message",
+		"topic", topic, "partition", partition, "offset", offset,
+		"payload_bytes", len(raw), "error", decodeErr)
+	return nil
+}
@@ -0,0 +1,248 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/assure-compliance/eventid/pkg/schema"
+	"github.com/lib/pq"
+)
+
+// EventFilter selects events for QueryEvents and StreamEvents. Zero-valued
+// fields do not filter; a zero Limit returns all matching events.
+type EventFilter struct {
+	Types    []schema.EventType
+	From     time.Time // Inclusive
+	To       time.Time // Inclusive
+	Source   string    // Source platform
+	EntityID string    // Kafka message key, for an entity's audit timeline
+	TenantID string    // Only this tenant's events
+	Limit    int
+	Offset   int
+
+	// RecordFrom and RecordTo bound the Kafka record timestamp, i.e. ingest
+	// time, where From and To bound the event's own timestamp. Both are
+	// inclusive. Events stored without a record timestamp match only when
+	// neither is set.
+	RecordFrom time.Time
+	RecordTo   time.Time
+
+	// Headers selects events carrying every listed header with the given
+	// value. Only headers in Config.StoredHeaders are kept, so other names
+	// match nothing.
+	Headers map[string]string
+
+	// Payload selects events whose payload holds every listed value at its
+	// path, e.g. {"jurisdiction.region": "EU"} for
+	// event_data->'jurisdiction'->>'region' = 'EU'. A path is a
+	// dot-separated list of object keys and a value is a JSON scalar
+	// (string, number, bool or nil) that matches only a payload value of
+	// the same type, so "5" does not match 5. The conditions are served by
+	// idx_events_data.
+	Payload map[string]interface{}
+}
+
+// Orderings for events selected by EventFilter.query: QueryEvents returns
+// them newest first, StreamEvents oldest first, in the order they
+// originally occurred
+const (
+	queryEventsOrder  = `ORDER BY {timestamp} DESC`
+	streamEventsOrder = `ORDER BY {timestamp} ASC, {id} ASC`
+)
+
+// selectColumnsSQL selects the events columns in scanEvent order
+const selectColumnsSQL = `
+	SELECT {event_id}, {event_version}, {event_type}, {platform},
+		{timestamp}, {correlation_id}, {user_id}, {event_data}, {entity_id},
+		{tenant_id}, {headers}, {record_timestamp}, {kafka_topic},
+		{kafka_partition}, {causation_id}
+	FROM {events}`
+
+// Validate reports whether filter's Payload conditions are usable, so that
+// callers can reject a filter before starting a query
+func (f EventFilter) Validate() error {
+	_, err := payloadContainment(f.Payload)
+	return err
+}
+
+// query returns the statement selecting the events matching filter in
+// scanEvent column order, sorted by order, and its parameters, or an error
+// if its Payload conditions are invalid. Only the filter's set fields
+// become conditions, so the planner sees each query's actual shape and can
+// use the index serving it: idx_events_type_timestamp (event_type,
+// timestamp DESC) for type + time-range queries, idx_events_timestamp for
+// time ranges alone, idx_events_entity_timestamp and
+// idx_events_tenant_timestamp for entities and tenants,
+// idx_events_record_timestamp for ingest-time ranges, idx_events_headers
+// for headers and idx_events_data (GIN, jsonb_path_ops) for payload values.
+func (f EventFilter) query(order string) (string, []interface{}, error) {
+	var (
+		conditions []string
+		args       []interface{}
+	)
+	where := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if len(f.Types) > 0 {
+		names := make([]string, len(f.Types))
+		for i, t := range f.Types {
+			names[i] = string(t)
+		}
+		where("{event_type} = ANY($%d)", pq.Array(names))
+	}
+	if !f.From.IsZero() {
+		where("{timestamp} >= $%d", f.From)
+	}
+	if !f.To.IsZero() {
+		where("{timestamp} <= $%d", f.To)
+	}
+	if f.Source != "" {
+		where("{platform} = $%d", f.Source)
+	}
+	if f.EntityID != "" {
+		where("{entity_id} = $%d", f.EntityID)
+	}
+	if f.TenantID != "" {
+		where("{tenant_id} = $%d", f.TenantID)
+	}
+	if len(f.Headers) > 0 {
+		data, _ := json.Marshal(f.Headers) // A map of strings always marshals
+		where("{headers} @> $%d::jsonb", string(data))
+	}
+	payload, err := payloadContainment(f.Payload)
+	if err != nil {
+		return "", nil, err
+	}
+	if payload.Valid {
+		where("{event_data} @> $%d::jsonb", payload.String)
+	}
+	if !f.RecordFrom.IsZero() {
+		where("{record_timestamp} >= $%d", f.RecordFrom)
+	}
+	if !f.RecordTo.IsZero() {
+		where("{record_timestamp} <= $%d", f.RecordTo)
+	}
+
+	var sb strings.Builder
+	sb.WriteString(selectColumnsSQL)
+	if len(conditions) > 0 {
+		sb.WriteString("\n\tWHERE ")
+		sb.WriteString(strings.Join(conditions, "\n\t\tAND "))
+	}
+	sb.WriteString("\n\t")
+	sb.WriteString(order)
+	if f.Limit > 0 {
+		args = append(args, f.Limit)
+		fmt.Fprintf(&sb, "\n\tLIMIT $%d", len(args))
+	}
+	if f.Offset > 0 {
+		args = append(args, f.Offset)
+		fmt.Fprintf(&sb, " OFFSET $%d", len(args))
+	}
+	return sb.String(), args, nil
+}
+
+// QueryEvents retrieves events matching filter, newest first
+func (s *PostgresStore) QueryEvents(filter EventFilter) ([]schema.Event, error) {
+	query, args, err := filter.query(queryEventsOrder)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := s.db.Query(s.names.render(query), args...)
+	if err != nil {
+		return nil, s.checkConn(fmt.Errorf("failed to query events: %w", err))
+	}
+	defer rows.Close()
+
+	var results []schema.Event
+	for rows.Next() {
+		event, err := scanEvent(rows)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, *event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read events: %w", err)
+	}
+
+	return results, nil
+}
+
+// StreamEvents calls fn for each event matching filter, oldest first, without
+// loading the result set into memory. It stops at the first error returned
+// by fn and returns it. Cancelling ctx aborts the query.
+func (s *PostgresStore) StreamEvents(ctx context.Context, filter EventFilter, fn func(schema.Event) error) error {
+	query, args, err := filter.query(streamEventsOrder)
+	if err != nil {
+		return err
+	}
+	rows, err := s.db.QueryContext(ctx, s.names.render(query), args...)
+	if err != nil {
+		return s.checkConn(fmt.Errorf("failed to query events: %w", err))
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		event, err := scanEvent(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(*event); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read events: %w", err)
+	}
+	return nil
+}
+
+// scanEvent reads an events row selected in selectColumnsSQL order
+func scanEvent(rows *sql.Rows) (*schema.Event, error) {
+	var (
+		event         schema.Event
+		eventType     string
+		correlationID sql.NullString
+		userID        sql.NullString
+		eventData     []byte
+		entityID      sql.NullString
+		tenantID      sql.NullString
+		headers       []byte
+		recorded      sql.NullTime
+		topic         sql.NullString
+		partition     sql.NullInt32
+		causationID   sql.NullString
+	)
+	if err := rows.Scan(&event.ID, &event.Version, &eventType, &event.Source,
+		&event.Timestamp, &correlationID, &userID, &eventData, &entityID, &tenantID, &headers, &recorded,
+		&topic, &partition, &causationID); err != nil {
+		return nil, fmt.Errorf("failed to scan row: %w", err)
+	}
+
+	event.Type = schema.EventType(eventType)
+	event.CorrelationID = correlationID.String
+	event.CausationID = causationID.String
+	event.UserID = userID.String
+	event.Payload = json.RawMessage(eventData)
+	event.EntityID = entityID.String
+	event.TenantID = tenantID.String
+	event.RecordTimestamp = recorded.Time
+	event.Topic, event.Partition = topic.String, partition.Int32
+	if headers != nil {
+		if err := json.Unmarshal(headers, &event.Headers); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal headers of event %s: %w", event.ID, err)
+		}
+	}
+	return &event, nil
+}
+
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t, Valid: !t.IsZero()}
+}
@@ -0,0 +1,57 @@
+package storage
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/assure-compliance/eventid/pkg/schema"
+)
+
+// Only the filter's set fields become conditions, numbered in order
+func TestEventFilterQuery(t *testing.T) {
+	query, args, err := EventFilter{}.query(queryEventsOrder)
+	if err != nil {
+		t.Fatalf("query failed: %v", err)
+	}
+	if strings.Contains(query, "WHERE") || strings.Contains(query, "LIMIT") || len(args) != 0 {
+		t.Errorf("empty filter gave %q with %d args, want no conditions", query, len(args))
+	}
+
+	filter := EventFilter{
+		Types:    []schema.EventType{schema.EventScanRequested},
+		From:     time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+		TenantID: "tenant-1",
+		Payload:  map[string]interface{}{"jurisdiction.region": "EU"},
+		Limit:    10,
+		Offset:   20,
+	}
+	query, args, err = filter.query(streamEventsOrder)
+	if err != nil {
+		t.Fatalf("query failed: %v", err)
+	}
+	for _, want := range []string{
+		"{event_type} = ANY($1)",
+		"{timestamp} >= $2",
+		"{tenant_id} = $3",
+		"{event_data} @> $4::jsonb",
+		streamEventsOrder,
+		"LIMIT $5 OFFSET $6",
+	} {
+		if !strings.Contains(query, want) {
+			t.Errorf("query lacks %q:\n%s", want, query)
+		}
+	}
+	for _, unwanted := range []string{"IS NULL", "{timestamp} <=", "{platform} =", "{entity_id} =", "{headers} @>", "{record_timestamp} >="} {
+		if strings.Contains(query, unwanted) {
+			t.Errorf("query contains %q for an unset filter:\n%s", unwanted, query)
+		}
+	}
+	if len(args) != 6 || args[3] != `{"jurisdiction":{"region":"EU"}}` || args[4] != 10 || args[5] != 20 {
+		t.Errorf("args = %v, want 6 in condition order", args)
+	}
+
+	if _, _, err := (EventFilter{Payload: map[string]interface{}{"a..b": 1}}).query(queryEventsOrder); err == nil {
+		t.Error("query accepted an invalid payload path")
+	}
+}
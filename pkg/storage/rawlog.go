@@ -0,0 +1,84 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// RawMessage is a Kafka message as received, before it was decoded
+type RawMessage struct {
+	Topic     string
+	Partition int32
+	Offset    int64
+	Key       []byte
+	Value     []byte
+	Headers   []RawHeader // In message order, duplicates included
+	Timestamp time.Time   // Kafka record timestamp, zero if none
+}
+
+// RawHeader is a message header with its value as sent
+type RawHeader struct {
+	Key   string `json:"key"`
+	Value []byte `json:"value"` // Base64 in JSON
+}
+
+// insertRawMessageSQL appends a raw message once per Kafka position
+const insertRawMessageSQL = `
+	INSERT INTO raw_messages (topic, kafka_partition, kafka_offset, message_key, raw_value, headers, record_timestamp)
+	VALUES ($1, $2, $3, $4, $5, $6, $7)
+	ON CONFLICT (topic, kafka_partition, kafka_offset) DO NOTHING
+`
+
+// AppendRaw appends msg to the raw_messages table, which rejects updates
+// and deletes. A message already stored at the same position is left
+// unchanged, so redeliveries are recorded once.
+func (s *PostgresStore) AppendRaw(ctx context.Context, msg RawMessage) error {
+	var headers []byte
+	if len(msg.Headers) > 0 {
+		headers, _ = json.Marshal(msg.Headers) // Strings and bytes always marshal
+	}
+	_, err := s.db.ExecContext(ctx, insertRawMessageSQL, msg.Topic, msg.Partition, msg.Offset,
+		msg.Key, msg.Value, headers, nullTime(msg.Timestamp))
+	if err != nil {
+		return s.checkConn(fmt.Errorf("failed to append raw message: %w", err))
+	}
+	return nil
+}
+
+// AppendRaw keeps msg in memory, once per Kafka position
+func (s *InMemoryStore) AppendRaw(_ context.Context, msg RawMessage) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, stored := range s.raw {
+		if stored.Topic == msg.Topic && stored.Partition == msg.Partition && stored.Offset == msg.Offset {
+			return nil
+		}
+	}
+	msg.Key = append([]byte(nil), msg.Key...)
+	msg.Value = append([]byte(nil), msg.Value...)
+	msg.Headers = append([]RawHeader(nil), msg.Headers...)
+	s.raw = append(s.raw, msg)
+	return nil
+}
+
+// RawMessages returns a copy of every appended raw message, for assertions
+// in tests
+func (s *InMemoryStore) RawMessages() []RawMessage {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	messages := make([]RawMessage, len(s.raw))
+	copy(messages, s.raw)
+	return messages
+}
+
+// AppendRaw logs the message at debug level
+func (s *DryRunStore) AppendRaw(_ context.Context, msg RawMessage) error {
+	s.logger.Debug("Dry run: would append raw message",
+		"topic", msg.Topic, "partition", msg.Partition, "offset", msg.Offset,
+		"payload_bytes", len(msg.Value))
+	return nil
+}
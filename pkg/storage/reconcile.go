@@ -0,0 +1,45 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// countByPartitionSQL counts a topic's events per partition by record
+// timestamp, served by idx_events_kafka_partition
+const countByPartitionSQL = `
+	SELECT {kafka_partition}, COUNT(*)
+	FROM {events}
+	WHERE {kafka_topic} = $1
+		AND ($2::timestamptz IS NULL OR {record_timestamp} >= $2)
+		AND ($3::timestamptz IS NULL OR {record_timestamp} < $3)
+	GROUP BY {kafka_partition}
+`
+
+// CountByPartition returns how many events read from each partition of
+// topic are stored, counting those whose Kafka record timestamp is at or
+// after from and before to. A zero bound is open. Partitions with no events
+// are omitted, as are events stored before the kafka_topic column was
+// added.
+func (s *PostgresStore) CountByPartition(ctx context.Context, topic string, from, to time.Time) (map[int32]int64, error) {
+	rows, err := s.db.QueryContext(ctx, s.names.render(countByPartitionSQL), topic, nullTime(from), nullTime(to))
+	if err != nil {
+		return nil, s.checkConn(fmt.Errorf("failed to count events by partition: %w", err))
+	}
+	defer rows.Close()
+
+	counts := make(map[int32]int64)
+	for rows.Next() {
+		var partition int32
+		var count int64
+		if err := rows.Scan(&partition, &count); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		counts[partition] = count
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read partition counts: %w", err)
+	}
+	return counts, nil
+}
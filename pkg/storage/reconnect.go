@@ -0,0 +1,61 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Backoff between reconnection pings while the database is unreachable
+const (
+	reconnectInitialBackoff = 500 * time.Millisecond
+	reconnectMaxBackoff     = 30 * time.Second
+)
+
+// reconnectPingTimeout bounds each reconnection ping
+const reconnectPingTimeout = 5 * time.Second
+
+// checkConn classifies err like classify and, if it means the database was
+// unreachable, starts reconnecting in the background
+func (s *PostgresStore) checkConn(err error) error {
+	err = classify(err)
+	if errors.Is(err, ErrConnClosed) && s.reconnecting.CompareAndSwap(false, true) {
+		go s.reconnect()
+	}
+	return err
+}
+
+// reconnect drops idle connections, which may be stale after a server
+// restart, and pings with exponential backoff until the database answers
+// or the store is closed
+func (s *PostgresStore) reconnect() {
+	defer s.reconnecting.Store(false)
+
+	s.logger.Warn("Database connection lost, reconnecting")
+	s.db.SetMaxIdleConns(0)
+	defer s.db.SetMaxIdleConns(s.maxIdle)
+
+	started := time.Now()
+	backoff := reconnectInitialBackoff
+	for attempt := 1; ; attempt++ {
+		select {
+		case <-s.done:
+			return
+		case <-time.After(backoff):
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), reconnectPingTimeout)
+		err := s.db.PingContext(ctx)
+		cancel()
+		if err == nil {
+			s.metrics.reconnects.Inc()
+			s.logger.Info("Reconnected to database", "attempts", attempt, "downtime", time.Since(started).String())
+			return
+		}
+
+		if backoff *= 2; backoff > reconnectMaxBackoff {
+			backoff = reconnectMaxBackoff
+		}
+		s.logger.Warn("Database still unreachable", "attempt", attempt, "retry_in", backoff.String(), "error", err)
+	}
+}
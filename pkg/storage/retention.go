@@ -0,0 +1,117 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/assure-compliance/eventid/pkg/schema"
+)
+
+// pruneBatchSize bounds the rows deleted per transaction so that pruning a
+// large backlog does not hold locks or build up WAL in one statement
+const pruneBatchSize = 10000
+
+// pruneEventsSQL deletes up to $3 events of type $1 older than $2 from a
+// table with custom names, which has no prune_events function
+const pruneEventsSQL = `
+	DELETE FROM {events}
+	WHERE {id} IN (
+		SELECT {id} FROM {events}
+		WHERE {event_type} = $1 AND {timestamp} < $2
+		LIMIT $3
+	)
+`
+
+// PruneOlderThan deletes events of eventType whose timestamp is more than age
+// in the past, returning how many were deleted. Rows are deleted in batches,
+// each in its own transaction, so an error may follow a partial prune; the
+// returned count is still accurate. Requires migration 0016: deletes pass
+// the immutability trigger only through the prune_events function, which
+// the store's role must own or be granted EXECUTE on. A store with custom
+// table or column names deletes directly and must connect as the owner of
+// its table.
+func (s *PostgresStore) PruneOlderThan(ctx context.Context, eventType schema.EventType, age time.Duration) (int64, error) {
+	cutoff := time.Now().Add(-age)
+
+	var deleted int64
+	for {
+		n, err := s.pruneBatch(ctx, eventType, cutoff)
+		deleted += n
+		s.metrics.prunedEvents.WithLabelValues(string(eventType)).Add(float64(n))
+		if err != nil {
+			return deleted, err
+		}
+		if n < pruneBatchSize {
+			break
+		}
+	}
+
+	if deleted > 0 {
+		s.logger.Info("Pruned events", "event_type", string(eventType), "cutoff", cutoff, "deleted", deleted)
+	}
+	return deleted, nil
+}
+
+// pruneBatch deletes one batch of events older than cutoff
+func (s *PostgresStore) pruneBatch(ctx context.Context, eventType schema.EventType, cutoff time.Time) (int64, error) {
+	if !s.names.custom {
+		var n int64
+		err := s.db.QueryRowContext(ctx, "SELECT prune_events($1, $2, $3)", string(eventType), cutoff, pruneBatchSize).Scan(&n)
+		if err != nil {
+			return 0, fmt.Errorf("failed to prune events: %w", err)
+		}
+		return n, nil
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin prune transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "SET LOCAL eventid.retention_prune = 'on'"); err != nil {
+		return 0, fmt.Errorf("failed to enable pruning: %w", err)
+	}
+	result, err := tx.ExecContext(ctx, s.names.render(pruneEventsSQL), string(eventType), cutoff, pruneBatchSize)
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune events: %w", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to count pruned events: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit pruned events: %w", err)
+	}
+	return n, nil
+}
+
+// PruneOlderThan deletes stored events of eventType older than age
+func (s *InMemoryStore) PruneOlderThan(_ context.Context, eventType schema.EventType, age time.Duration) (int64, error) {
+	cutoff := time.Now().Add(-age)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	kept := s.events[:0]
+	var deleted int64
+	for _, event := range s.events {
+		if event.Type == eventType && event.Timestamp.Before(cutoff) {
+			delete(s.ids, event.ID)
+			deleted++
+			continue
+		}
+		kept = append(kept, event)
+	}
+	s.events = kept
+
+	s.metrics.prunedEvents.WithLabelValues(string(eventType)).Add(float64(deleted))
+	return deleted, nil
+}
+
+// PruneOlderThan deletes nothing
+func (s *DryRunStore) PruneOlderThan(context.Context, schema.EventType, time.Duration) (int64, error) {
+	return 0, nil
+}
@@ -0,0 +1,145 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"time"
+
+	"github.com/assure-compliance/eventid/pkg/schema"
+)
+
+// Defaults used when RetryConfig fields are unset
+const (
+	DefaultWriteRetries         = 3
+	DefaultWriteRetryBackoff    = 100 * time.Millisecond
+	DefaultMaxWriteRetryBackoff = 5 * time.Second
+)
+
+// RetryConfig configures a RetryStore
+type RetryConfig struct {
+	// MaxRetries is how many times a failed write is retried after the
+	// first attempt (default DefaultWriteRetries)
+	MaxRetries int
+
+	// InitialBackoff bounds the delay before the first retry, doubling for
+	// each further one up to MaxBackoff (default DefaultWriteRetryBackoff
+	// and DefaultMaxWriteRetryBackoff)
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+
+	Logger  Logger   // Defaults to JSON on stderr
+	Metrics *Metrics // Defaults to DefaultMetrics()
+}
+
+// RetryStore wraps an EventStore to retry writes failing with a transient
+// error, as classified by IsTransient. Permanent errors are returned at
+// once, so bad data reaches the caller's dead-letter path without waiting
+// out the retries. Each delay is drawn at random up to the exponential
+// backoff, so that writers failing in the same outage do not retry in
+// lockstep. Retrying stops when the context ends. Reads are not retried.
+type RetryStore struct {
+	EventStore
+
+	maxRetries int
+	initial    time.Duration
+	max        time.Duration
+	logger     Logger
+	metrics    *Metrics
+}
+
+// NewRetryStore wraps store with write retries
+func NewRetryStore(store EventStore, cfg RetryConfig) *RetryStore {
+	if cfg.MaxRetries <= 0 {
+		cfg.MaxRetries = DefaultWriteRetries
+	}
+	if cfg.InitialBackoff <= 0 {
+		cfg.InitialBackoff = DefaultWriteRetryBackoff
+	}
+	if cfg.MaxBackoff <= 0 {
+		cfg.MaxBackoff = DefaultMaxWriteRetryBackoff
+	}
+	if cfg.Logger == nil {
+		cfg.Logger = defaultLogger()
+	}
+	if cfg.Metrics == nil {
+		cfg.Metrics = DefaultMetrics()
+	}
+	return &RetryStore{
+		EventStore: store,
+		maxRetries: cfg.MaxRetries,
+		initial:    cfg.InitialBackoff,
+		max:        cfg.MaxBackoff,
+		logger:     cfg.Logger,
+		metrics:    cfg.Metrics,
+	}
+}
+
+// StoreEvent stores event, retrying transient failures
+func (r *RetryStore) StoreEvent(ctx context.Context, event *schema.Event) error {
+	return r.retry(ctx, func() error {
+		return r.EventStore.StoreEvent(ctx, event)
+	}, "event_id", event.ID)
+}
+
+// StoreEventBatch stores events, retrying transient failures of the whole
+// batch. A *BatchError reporting rejected events is permanent.
+func (r *RetryStore) StoreEventBatch(ctx context.Context, events []*schema.Event) error {
+	return r.retry(ctx, func() error {
+		return r.EventStore.StoreEventBatch(ctx, events)
+	}, "batch_size", len(events))
+}
+
+// StoreLatest updates the latest state of event's entity, retrying
+// transient failures
+func (r *RetryStore) StoreLatest(ctx context.Context, event *schema.Event) error {
+	return r.retry(ctx, func() error {
+		return r.EventStore.StoreLatest(ctx, event)
+	}, "event_id", event.ID)
+}
+
+// retry calls write until it succeeds, fails permanently, the retries are
+// used up or ctx ends, returning the last error. attrs are added to retry
+// logs.
+func (r *RetryStore) retry(ctx context.Context, write func() error, attrs ...any) error {
+	for attempt := 1; ; attempt++ {
+		err := write()
+		if err == nil || errors.Is(err, ErrDuplicateEvent) {
+			return err
+		}
+		if !IsTransient(err) {
+			r.metrics.writeErrors.WithLabelValues("permanent").Inc()
+			return err
+		}
+		if attempt > r.maxRetries {
+			r.metrics.writeErrors.WithLabelValues("transient").Inc()
+			return err
+		}
+
+		delay := r.backoff(attempt)
+		r.metrics.writeRetries.Inc()
+		r.logger.Warn("Retrying storage write after transient error", append(attrs,
+			"attempt", attempt, "max_retries", r.maxRetries, "backoff", delay.String(), "error", err)...)
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			r.metrics.writeErrors.WithLabelValues("transient").Inc()
+			return err
+		}
+	}
+}
+
+// backoff returns a random delay of up to the exponential backoff for the
+// given retry (1-based), capped at the maximum
+func (r *RetryStore) backoff(retry int) time.Duration {
+	ceiling := r.initial
+	for i := 1; i < retry && ceiling < r.max; i++ {
+		ceiling *= 2
+	}
+	if ceiling > r.max {
+		ceiling = r.max
+	}
+	return time.Duration(rand.Int63n(int64(ceiling))) + 1
+}
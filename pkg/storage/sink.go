@@ -0,0 +1,228 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/assure-compliance/eventid/pkg/schema"
+)
+
+// Defaults used when FanoutConfig and SinkConfig fields are unset
+const (
+	DefaultSinkQueueSize    = 1000
+	DefaultSinkCloseTimeout = 10 * time.Second
+)
+
+// Sink receives a copy of the events written to a FanoutStore, e.g. to
+// mirror them to object storage for analytics. Delivery is at least once:
+// a sink may see an event again after a redelivery.
+type Sink interface {
+	WriteEvents(ctx context.Context, events []*schema.Event) error
+	Close() error
+}
+
+// SinkConfig adds a Sink to a FanoutStore
+type SinkConfig struct {
+	Name string // Labels the sink's logs and metrics
+	Sink Sink
+
+	// Required writes to the sink as part of each store, so a failure fails
+	// the store and the events are redelivered. Required sinks are also
+	// written when the event is already stored, so that a redelivery after
+	// a failed write reaches the sink. Optional sinks, the default, are
+	// written in the background: a failing or slow sink never delays the
+	// wrapped store, and events it fails to write are logged, counted and
+	// not retried.
+	Required bool
+
+	// QueueSize is how many writes may wait for an optional sink (default
+	// DefaultSinkQueueSize). Writes beyond it are dropped for that sink.
+	QueueSize int
+}
+
+// FanoutConfig configures a FanoutStore
+type FanoutConfig struct {
+	Sinks []SinkConfig
+
+	// CloseTimeout bounds how long Close waits for optional sinks to drain
+	// their queues (default DefaultSinkCloseTimeout)
+	CloseTimeout time.Duration
+
+	Logger  Logger   // Defaults to JSON on stderr
+	Metrics *Metrics // Defaults to DefaultMetrics()
+}
+
+// FanoutStore wraps an EventStore, which stays authoritative, and copies
+// every event it stores to one or more Sinks. Events the wrapped store
+// rejects are not copied. Reads go to the wrapped store only.
+type FanoutStore struct {
+	EventStore
+
+	required     []SinkConfig
+	optional     []*sinkQueue
+	closeTimeout time.Duration
+	logger       Logger
+	metrics      *Metrics
+	closeOnce    sync.Once
+}
+
+// sinkQueue feeds an optional sink from a background goroutine
+type sinkQueue struct {
+	name    string
+	sink    Sink
+	writes  chan []*schema.Event
+	ctx     context.Context // Cancelled when Close gives up draining
+	cancel  context.CancelFunc
+	stopped chan struct{}
+}
+
+// NewFanoutStore wraps store, copying stored events to the sinks in cfg
+func NewFanoutStore(store EventStore, cfg FanoutConfig) (*FanoutStore, error) {
+	if cfg.CloseTimeout <= 0 {
+		cfg.CloseTimeout = DefaultSinkCloseTimeout
+	}
+	if cfg.Logger == nil {
+		cfg.Logger = defaultLogger()
+	}
+	if cfg.Metrics == nil {
+		cfg.Metrics = DefaultMetrics()
+	}
+
+	f := &FanoutStore{EventStore: store, closeTimeout: cfg.CloseTimeout, logger: cfg.Logger, metrics: cfg.Metrics}
+	names := make(map[string]bool, len(cfg.Sinks))
+	for _, sc := range cfg.Sinks {
+		if sc.Name == "" || sc.Sink == nil {
+			return nil, errors.New("every sink needs a name and a Sink")
+		}
+		if names[sc.Name] {
+			return nil, fmt.Errorf("sink %s is configured twice", sc.Name)
+		}
+		names[sc.Name] = true
+
+		if sc.Required {
+			f.required = append(f.required, sc)
+			continue
+		}
+		if sc.QueueSize <= 0 {
+			sc.QueueSize = DefaultSinkQueueSize
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		q := &sinkQueue{
+			name:    sc.Name,
+			sink:    sc.Sink,
+			writes:  make(chan []*schema.Event, sc.QueueSize),
+			ctx:     ctx,
+			cancel:  cancel,
+			stopped: make(chan struct{}),
+		}
+		f.optional = append(f.optional, q)
+		go f.drain(q)
+	}
+	return f, nil
+}
+
+// StoreEvent stores event and copies it to the sinks
+func (f *FanoutStore) StoreEvent(ctx context.Context, event *schema.Event) error {
+	err := f.EventStore.StoreEvent(ctx, event)
+	if err != nil && !errors.Is(err, ErrDuplicateEvent) {
+		return err
+	}
+	if sinkErr := f.fanout(ctx, []*schema.Event{event}, err != nil); sinkErr != nil {
+		return sinkErr
+	}
+	return err
+}
+
+// StoreEventBatch stores events and copies them to the sinks
+func (f *FanoutStore) StoreEventBatch(ctx context.Context, events []*schema.Event) error {
+	if err := f.EventStore.StoreEventBatch(ctx, events); err != nil {
+		return err
+	}
+	return f.fanout(ctx, events, false)
+}
+
+// StoreLatest updates the latest state in the wrapped store only; sinks
+// receive the full history through StoreEvent
+func (f *FanoutStore) StoreLatest(ctx context.Context, event *schema.Event) error {
+	return f.EventStore.StoreLatest(ctx, event)
+}
+
+// fanout writes events to the required sinks and queues them for the
+// optional ones, which skip events that were already stored
+func (f *FanoutStore) fanout(ctx context.Context, events []*schema.Event, duplicate bool) error {
+	for _, sc := range f.required {
+		if err := sc.Sink.WriteEvents(ctx, events); err != nil {
+			f.metrics.sinkWrites.WithLabelValues(sc.Name, "failure").Add(float64(len(events)))
+			return fmt.Errorf("failed to write to sink %s: %w", sc.Name, err)
+		}
+		f.metrics.sinkWrites.WithLabelValues(sc.Name, "success").Add(float64(len(events)))
+	}
+	if duplicate {
+		return nil
+	}
+
+	for _, q := range f.optional {
+		select {
+		case q.writes <- events:
+		default:
+			f.metrics.sinkWrites.WithLabelValues(q.name, "dropped").Add(float64(len(events)))
+			f.logger.Warn("Sink queue full, dropping events", "sink", q.name, "events", len(events))
+		}
+	}
+	return nil
+}
+
+// drain writes queued events to an optional sink until its queue is closed
+func (f *FanoutStore) drain(q *sinkQueue) {
+	defer close(q.stopped)
+	for events := range q.writes {
+		if err := q.sink.WriteEvents(q.ctx, events); err != nil {
+			f.metrics.sinkWrites.WithLabelValues(q.name, "failure").Add(float64(len(events)))
+			f.logger.Error("Failed to write to sink", "sink", q.name, "events", len(events), "error", err)
+			continue
+		}
+		f.metrics.sinkWrites.WithLabelValues(q.name, "success").Add(float64(len(events)))
+	}
+}
+
+// Close waits up to CloseTimeout for the optional sinks to drain, then
+// closes the sinks and the wrapped store
+func (f *FanoutStore) Close() error {
+	f.closeOnce.Do(func() {
+		for _, q := range f.optional {
+			close(q.writes)
+		}
+		timer := time.NewTimer(f.closeTimeout)
+		defer timer.Stop()
+		for _, q := range f.optional {
+			select {
+			case <-q.stopped:
+			case <-timer.C:
+				// Fail the remaining writes fast rather than hold up shutdown
+				f.logger.Warn("Sinks did not drain before the close timeout", "timeout", f.closeTimeout.String())
+				for _, q := range f.optional {
+					q.cancel()
+				}
+				<-q.stopped
+			}
+		}
+
+		for _, q := range f.optional {
+			q.cancel()
+			f.closeSink(q.name, q.sink)
+		}
+		for _, sc := range f.required {
+			f.closeSink(sc.Name, sc.Sink)
+		}
+	})
+	return f.EventStore.Close()
+}
+
+func (f *FanoutStore) closeSink(name string, sink Sink) {
+	if err := sink.Close(); err != nil {
+		f.logger.Error("Failed to close sink", "sink", name, "error", err)
+	}
+}
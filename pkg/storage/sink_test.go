@@ -0,0 +1,207 @@
+package storage_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/assure-compliance/eventid/pkg/schema"
+	"github.com/assure-compliance/eventid/pkg/storage"
+)
+
+// errSinkDown is returned by a recordingSink set to fail
+var errSinkDown = errors.New("sink unavailable")
+
+// recordingSink records the IDs of the events written to it, or fails
+// every write while failing is set
+type recordingSink struct {
+	mu      sync.Mutex
+	failing bool
+	written []string
+	closed  bool
+}
+
+func (s *recordingSink) fail(failing bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failing = failing
+}
+
+func (s *recordingSink) WriteEvents(_ context.Context, events []*schema.Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.failing {
+		return errSinkDown
+	}
+	for _, event := range events {
+		s.written = append(s.written, event.ID)
+	}
+	return nil
+}
+
+func (s *recordingSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	return nil
+}
+
+func (s *recordingSink) events() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.written...)
+}
+
+func newFanoutStore(t *testing.T, inner storage.EventStore, sinks ...storage.SinkConfig) *storage.FanoutStore {
+	t.Helper()
+	fanout, err := storage.NewFanoutStore(inner, storage.FanoutConfig{
+		Sinks:   sinks,
+		Logger:  discardLogger(),
+		Metrics: newMetrics(),
+	})
+	if err != nil {
+		t.Fatalf("NewFanoutStore failed: %v", err)
+	}
+	return fanout
+}
+
+// A failing required sink fails the store so the event is redelivered, and
+// the redelivery, though already stored, reaches the sink
+func TestFanoutRequiredSinkFailure(t *testing.T) {
+	inner := newFailingStore()
+	sink := &recordingSink{}
+	fanout := newFanoutStore(t, inner, storage.SinkConfig{Name: "file", Sink: sink, Required: true})
+	defer fanout.Close()
+
+	sink.fail(true)
+	if err := fanout.StoreEvent(context.Background(), testEvent("evt-1")); !errors.Is(err, errSinkDown) {
+		t.Fatalf("StoreEvent returned %v, want %v", err, errSinkDown)
+	}
+	if _, err := inner.GetEventByID("evt-1"); err != nil {
+		t.Errorf("event was not stored before the sink failed: %v", err)
+	}
+	if err := fanout.StoreEventBatch(context.Background(), []*schema.Event{testEvent("evt-2")}); !errors.Is(err, errSinkDown) {
+		t.Errorf("StoreEventBatch returned %v, want %v", err, errSinkDown)
+	}
+
+	sink.fail(false)
+	if err := fanout.StoreEvent(context.Background(), testEvent("evt-1")); !errors.Is(err, storage.ErrDuplicateEvent) {
+		t.Errorf("redelivered StoreEvent returned %v, want ErrDuplicateEvent", err)
+	}
+	if got := sink.events(); len(got) != 1 || got[0] != "evt-1" {
+		t.Errorf("sink holds %v, want [evt-1]", got)
+	}
+}
+
+// A failing optional sink never fails the store, and the other sinks still
+// receive every event
+func TestFanoutOptionalSinkFailure(t *testing.T) {
+	inner := newFailingStore()
+	broken, healthy := &recordingSink{}, &recordingSink{}
+	fanout := newFanoutStore(t, inner,
+		storage.SinkConfig{Name: "broken", Sink: broken},
+		storage.SinkConfig{Name: "healthy", Sink: healthy},
+	)
+
+	broken.fail(true)
+	if err := fanout.StoreEvent(context.Background(), testEvent("evt-1")); err != nil {
+		t.Fatalf("StoreEvent returned %v with a failing optional sink, want nil", err)
+	}
+	if err := fanout.StoreEventBatch(context.Background(), []*schema.Event{testEvent("evt-2"), testEvent("evt-3")}); err != nil {
+		t.Fatalf("StoreEventBatch returned %v with a failing optional sink, want nil", err)
+	}
+	// Already stored, so not copied again
+	fanout.StoreEvent(context.Background(), testEvent("evt-1"))
+
+	// Close drains the queues before closing the sinks
+	if err := fanout.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if got := strings.Join(healthy.events(), ","); got != "evt-1,evt-2,evt-3" {
+		t.Errorf("healthy sink holds %s, want evt-1,evt-2,evt-3", got)
+	}
+	if got := broken.events(); len(got) != 0 {
+		t.Errorf("broken sink holds %v, want none", got)
+	}
+	if !broken.closed || !healthy.closed {
+		t.Error("Close did not close every sink")
+	}
+}
+
+// Events the wrapped store fails to store are not copied to any sink
+func TestFanoutSkipsUnstoredEvents(t *testing.T) {
+	inner := newFailingStore()
+	required, optional := &recordingSink{}, &recordingSink{}
+	fanout := newFanoutStore(t, inner,
+		storage.SinkConfig{Name: "required", Sink: required, Required: true},
+		storage.SinkConfig{Name: "optional", Sink: optional},
+	)
+
+	inner.reject("evt-1")
+	if err := fanout.StoreEvent(context.Background(), testEvent("evt-1")); !errors.Is(err, errRejected) {
+		t.Errorf("StoreEvent returned %v, want %v", err, errRejected)
+	}
+	inner.fail(storage.ErrConnClosed)
+	if err := fanout.StoreEventBatch(context.Background(), []*schema.Event{testEvent("evt-2")}); !errors.Is(err, storage.ErrConnClosed) {
+		t.Errorf("StoreEventBatch returned %v, want ErrConnClosed", err)
+	}
+
+	fanout.Close()
+	if got := append(required.events(), optional.events()...); len(got) != 0 {
+		t.Errorf("sinks hold %v, want none", got)
+	}
+}
+
+// FileSink completes its file on Close, and completes files left partial
+// by a crash when opened
+func TestFileSink(t *testing.T) {
+	dir := t.TempDir()
+	sink, err := storage.NewFileSink(storage.FileSinkConfig{Dir: dir})
+	if err != nil {
+		t.Fatalf("NewFileSink failed: %v", err)
+	}
+	if err := sink.WriteEvents(context.Background(), []*schema.Event{testEvent("evt-1"), testEvent("evt-2")}); err != nil {
+		t.Fatalf("WriteEvents failed: %v", err)
+	}
+	if partial, _ := filepath.Glob(filepath.Join(dir, "*.partial")); len(partial) != 1 {
+		t.Fatalf("found %d partial files while writing, want 1", len(partial))
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "events-*.ndjson"))
+	if len(files) != 1 {
+		t.Fatalf("found %d completed files, want 1", len(files))
+	}
+	data, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatalf("failed to read sink file: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("sink file holds %d lines, want 2", len(lines))
+	}
+	var event schema.Event
+	if err := json.Unmarshal([]byte(lines[1]), &event); err != nil || event.ID != "evt-2" {
+		t.Errorf("second line decodes to %q (%v), want evt-2", event.ID, err)
+	}
+
+	crashed := filepath.Join(dir, "events-20260102T030405Z-00000000000000000001.ndjson.partial")
+	if err := os.WriteFile(crashed, []byte(lines[0]+"\n"), 0o600); err != nil {
+		t.Fatalf("failed to write partial file: %v", err)
+	}
+	reopened, err := storage.NewFileSink(storage.FileSinkConfig{Dir: dir})
+	if err != nil {
+		t.Fatalf("NewFileSink failed: %v", err)
+	}
+	defer reopened.Close()
+	if _, err := os.Stat(strings.TrimSuffix(crashed, ".partial")); err != nil {
+		t.Errorf("partial file was not completed on open: %v", err)
+	}
+}
@@ -0,0 +1,439 @@
+package storage
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/assure-compliance/eventid/pkg/schema"
+)
+
+// DefaultSpillFlushInterval is how often a SpillStore tries to drain its
+// buffer when SpillConfig.FlushInterval is unset
+const DefaultSpillFlushInterval = 5 * time.Second
+
+// spillSegmentPrefix and spillSegmentSuffix name buffer segment files
+const (
+	spillSegmentPrefix = "spill-"
+	spillSegmentSuffix = ".log"
+)
+
+// spillRejectedFile, in the buffer directory, holds buffered events the
+// underlying store rejected when they were flushed
+const spillRejectedFile = "rejected.jsonl"
+
+// SpillConfig configures a SpillStore
+type SpillConfig struct {
+	// Dir holds the buffer's segment files. It is created if missing and
+	// must not be shared between processes.
+	Dir string
+
+	// FlushInterval is how often buffered events are written to the
+	// underlying store (default DefaultSpillFlushInterval)
+	FlushInterval time.Duration
+
+	Logger  Logger   // Defaults to JSON on stderr
+	Metrics *Metrics // Defaults to DefaultMetrics()
+}
+
+// SpillStore wraps an EventStore with a local write-ahead buffer for
+// database outages. When storing fails because the database is
+// unreachable, the events are appended to a file in Dir and fsynced, and
+// the store reports success so the consumer can commit their offsets. A
+// background flusher writes buffered events to the underlying store once it
+// is reachable again; events already stored are skipped as duplicates, so a
+// segment that is only partly drained is safe to retry. Other errors, such
+// as constraint violations, are returned as usual.
+//
+// StoreLatest is buffered the same way, and replayed with StoreLatest when
+// flushed. Buffered events are not visible to reads until they are
+// flushed. Events left in Dir by a previous run are flushed after startup.
+//
+// A flush that fails transiently (see IsTransient) is retried by the next
+// one. An event the store rejects for good is moved, with its error, to
+// rejected.jsonl in Dir for an operator to inspect, and the flush carries
+// on, so one bad event cannot hold back the rest of the buffer.
+type SpillStore struct {
+	EventStore
+
+	dir      string
+	logger   Logger
+	metrics  *Metrics
+	interval time.Duration
+
+	mu      sync.Mutex
+	current *os.File // Segment being appended to; nil until the next spill
+	pending int      // Events buffered but not yet flushed
+
+	flushMu   sync.Mutex // Serialises flushes
+	done      chan struct{}
+	stopped   chan struct{}
+	closeOnce sync.Once
+}
+
+// NewSpillStore wraps store with a disk buffer in cfg.Dir and starts its
+// background flusher
+func NewSpillStore(store EventStore, cfg SpillConfig) (*SpillStore, error) {
+	if cfg.Dir == "" {
+		return nil, errors.New("spill buffer directory is required")
+	}
+	if err := os.MkdirAll(cfg.Dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create spill buffer directory: %w", err)
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = DefaultSpillFlushInterval
+	}
+	if cfg.Logger == nil {
+		cfg.Logger = defaultLogger()
+	}
+	if cfg.Metrics == nil {
+		cfg.Metrics = DefaultMetrics()
+	}
+
+	s := &SpillStore{
+		EventStore: store,
+		dir:        cfg.Dir,
+		logger:     cfg.Logger,
+		metrics:    cfg.Metrics,
+		interval:   cfg.FlushInterval,
+		done:       make(chan struct{}),
+		stopped:    make(chan struct{}),
+	}
+
+	segments, err := s.segments()
+	if err != nil {
+		return nil, err
+	}
+	for _, segment := range segments {
+		n, err := countLines(segment)
+		if err != nil {
+			return nil, err
+		}
+		s.pending += n
+	}
+	s.metrics.spilledPending.Set(float64(s.pending))
+	if s.pending > 0 {
+		s.logger.Warn("Found buffered events from a previous run", "dir", s.dir, "events", s.pending)
+	}
+
+	go s.run()
+	return s, nil
+}
+
+// StoreEvent stores event, buffering it on disk if the database is
+// unreachable
+func (s *SpillStore) StoreEvent(ctx context.Context, event *schema.Event) error {
+	err := classify(s.EventStore.StoreEvent(ctx, event))
+	if err == nil || !errors.Is(err, ErrConnClosed) || ctx.Err() != nil {
+		return err
+	}
+	if spillErr := s.spill([]*schema.Event{event}); spillErr != nil {
+		return fmt.Errorf("%w (and failed to buffer event: %v)", err, spillErr)
+	}
+	s.logger.Warn("Database unavailable, buffered event on disk", "event_id", event.ID, "error", err)
+	return nil
+}
+
+// StoreEventBatch stores events, buffering the whole batch on disk if the
+// database is unreachable
+func (s *SpillStore) StoreEventBatch(ctx context.Context, events []*schema.Event) error {
+	err := classify(s.EventStore.StoreEventBatch(ctx, events))
+	if err == nil || !errors.Is(err, ErrConnClosed) || ctx.Err() != nil {
+		return err
+	}
+	if spillErr := s.spill(events); spillErr != nil {
+		return fmt.Errorf("%w (and failed to buffer batch: %v)", err, spillErr)
+	}
+	s.logger.Warn("Database unavailable, buffered batch on disk", "batch_size", len(events), "error", err)
+	return nil
+}
+
+// StoreLatest updates the latest state of event's entity, buffering the
+// update on disk if the database is unreachable
+func (s *SpillStore) StoreLatest(ctx context.Context, event *schema.Event) error {
+	err := classify(s.EventStore.StoreLatest(ctx, event))
+	if err == nil || !errors.Is(err, ErrConnClosed) || ctx.Err() != nil {
+		return err
+	}
+	if spillErr := s.spillRecords([]spillRecord{{Latest: true, Event: *event}}); spillErr != nil {
+		return fmt.Errorf("%w (and failed to buffer latest event: %v)", err, spillErr)
+	}
+	s.logger.Warn("Database unavailable, buffered latest event on disk", "event_id", event.ID, "error", err)
+	return nil
+}
+
+// spillRecord is a line of a segment: an event, replayed with StoreEvent,
+// or with StoreLatest if Latest is set. Lines written before Latest existed
+// hold the event alone and are replayed as StoreEvent.
+type spillRecord struct {
+	Latest bool `json:",omitempty"`
+	schema.Event
+}
+
+// Pending returns the number of events buffered on disk
+func (s *SpillStore) Pending() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.pending
+}
+
+// spill appends events to the current segment and syncs it to disk
+func (s *SpillStore) spill(events []*schema.Event) error {
+	records := make([]spillRecord, len(events))
+	for i, event := range events {
+		records[i].Event = *event
+	}
+	return s.spillRecords(records)
+}
+
+func (s *SpillStore) spillRecords(records []spillRecord) error {
+	var buf []byte
+	for i := range records {
+		line, err := json.Marshal(&records[i])
+		if err != nil {
+			return fmt.Errorf("failed to marshal event: %w", err)
+		}
+		buf = append(append(buf, line...), '\n')
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.current == nil {
+		name := fmt.Sprintf("%s%020d%s", spillSegmentPrefix, time.Now().UnixNano(), spillSegmentSuffix)
+		f, err := os.OpenFile(filepath.Join(s.dir, name), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+		if err != nil {
+			return fmt.Errorf("failed to open spill segment: %w", err)
+		}
+		s.current = f
+	}
+	if _, err := s.current.Write(buf); err != nil {
+		return fmt.Errorf("failed to write spill segment: %w", err)
+	}
+	if err := s.current.Sync(); err != nil {
+		return fmt.Errorf("failed to sync spill segment: %w", err)
+	}
+
+	s.pending += len(records)
+	s.metrics.spilledEvents.Add(float64(len(records)))
+	s.metrics.spilledPending.Set(float64(s.pending))
+	return nil
+}
+
+// run flushes the buffer every interval until Close
+func (s *SpillStore) run() {
+	defer close(s.stopped)
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+			if err := s.Flush(context.Background()); err != nil {
+				s.logger.Warn("Failed to flush buffered events", "dir", s.dir, "pending", s.Pending(), "error", err)
+			}
+		}
+	}
+}
+
+// Flush writes every buffered event to the underlying store, oldest
+// segment first, deleting each segment once it is fully stored. It stops
+// at the first transient failure, leaving the remaining events to the next
+// flush; a segment that fails otherwise is kept and the rest are flushed.
+func (s *SpillStore) Flush(ctx context.Context) error {
+	s.flushMu.Lock()
+	defer s.flushMu.Unlock()
+
+	// Seal the current segment so new spills go to a fresh one
+	s.mu.Lock()
+	if s.current != nil {
+		s.current.Close()
+		s.current = nil
+	}
+	s.mu.Unlock()
+
+	segments, err := s.segments()
+	if err != nil || len(segments) == 0 {
+		return err
+	}
+	if err := s.EventStore.Ping(ctx); err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, segment := range segments {
+		if err := s.flushSegment(ctx, segment); err != nil {
+			errs = append(errs, err)
+			if IsTransient(err) || ctx.Err() != nil {
+				break
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+	s.logger.Info("Flushed buffered events", "dir", s.dir)
+	return nil
+}
+
+// flushSegment stores every event in a sealed segment and removes it.
+// Events rejected for good are moved to the rejected file instead.
+func (s *SpillStore) flushSegment(ctx context.Context, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open spill segment: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	stored := 0
+	for scanner.Scan() {
+		var record spillRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			// A torn write from a crash mid-append; the event was never
+			// acknowledged, so Kafka redelivers it. It was counted as
+			// pending at startup, so count it as flushed.
+			s.logger.Warn("Skipping unreadable buffered event", "segment", path, "error", err)
+			stored++
+			continue
+		}
+		event := record.Event
+		var err error
+		if record.Latest {
+			err = s.EventStore.StoreLatest(ctx, &event)
+		} else {
+			err = s.EventStore.StoreEvent(ctx, &event)
+		}
+		if err != nil && !errors.Is(err, ErrDuplicateEvent) {
+			if IsTransient(err) || ctx.Err() != nil {
+				s.markFlushed(stored)
+				return fmt.Errorf("failed to flush buffered event %s: %w", event.ID, err)
+			}
+			if rejectErr := s.reject(record, err); rejectErr != nil {
+				s.markFlushed(stored)
+				return fmt.Errorf("failed to flush buffered event %s: %w (and %v)", event.ID, err, rejectErr)
+			}
+			s.logger.Error("Buffered event rejected, moved to rejected file",
+				"event_id", event.ID, "file", filepath.Join(s.dir, spillRejectedFile), "error", err)
+		}
+		stored++
+	}
+	if err := scanner.Err(); err != nil {
+		s.markFlushed(stored)
+		return fmt.Errorf("failed to read spill segment: %w", err)
+	}
+
+	f.Close()
+	if err := os.Remove(path); err != nil {
+		s.markFlushed(stored)
+		return fmt.Errorf("failed to remove spill segment: %w", err)
+	}
+	s.markFlushed(stored)
+	return nil
+}
+
+// rejectedRecord is a line of the rejected file: a buffered event and the
+// error the store rejected it with
+type rejectedRecord struct {
+	Error string
+	spillRecord
+}
+
+// reject appends record to the rejected file and syncs it to disk
+func (s *SpillStore) reject(record spillRecord, storeErr error) error {
+	line, err := json.Marshal(&rejectedRecord{Error: storeErr.Error(), spillRecord: record})
+	if err != nil {
+		return fmt.Errorf("failed to marshal rejected event: %w", err)
+	}
+	f, err := os.OpenFile(filepath.Join(s.dir, spillRejectedFile), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open rejected file: %w", err)
+	}
+	defer f.Close()
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write rejected file: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		return fmt.Errorf("failed to sync rejected file: %w", err)
+	}
+	s.metrics.spillRejected.Inc()
+	return nil
+}
+
+// markFlushed subtracts n stored events from the pending count. Events in a
+// partly flushed segment are stored again as duplicates on retry, so the
+// count can briefly undercount what remains on disk.
+func (s *SpillStore) markFlushed(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pending -= n
+	if s.pending < 0 {
+		s.pending = 0
+	}
+	s.metrics.spilledPending.Set(float64(s.pending))
+}
+
+// segments lists the sealed segment files, oldest first
+func (s *SpillStore) segments() ([]string, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read spill buffer directory: %w", err)
+	}
+
+	var paths []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.Type().IsRegular() && strings.HasPrefix(name, spillSegmentPrefix) && strings.HasSuffix(name, spillSegmentSuffix) {
+			paths = append(paths, filepath.Join(s.dir, name))
+		}
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// Close stops the flusher, makes a final attempt to drain the buffer and
+// closes the underlying store. Events still buffered stay in Dir for the
+// next run.
+func (s *SpillStore) Close() error {
+	s.closeOnce.Do(func() {
+		close(s.done)
+		<-s.stopped
+
+		ctx, cancel := context.WithTimeout(context.Background(), s.interval)
+		defer cancel()
+		if err := s.Flush(ctx); err != nil {
+			s.logger.Warn("Buffered events remain on disk", "dir", s.dir, "pending", s.Pending(), "error", err)
+		}
+	})
+	return s.EventStore.Close()
+}
+
+// countLines counts the events in a segment file
+func countLines(path string) (int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open spill segment: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	n := 0
+	for scanner.Scan() {
+		n++
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, fmt.Errorf("failed to read spill segment: %w", err)
+	}
+	return n, nil
+}
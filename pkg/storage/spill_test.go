@@ -0,0 +1,314 @@
+package storage_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/assure-compliance/eventid/pkg/schema"
+	"github.com/assure-compliance/eventid/pkg/storage"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// errRejected is a permanent error failingStore rejects events with
+var errRejected = errors.New("check constraint violated")
+
+// failingStore is an InMemoryStore whose writes fail with err while it is
+// set, as do its pings if err wraps ErrConnClosed. Events whose IDs are in
+// rejected always fail with errRejected.
+type failingStore struct {
+	*storage.InMemoryStore
+
+	mu       sync.Mutex
+	err      error
+	rejected map[string]bool
+}
+
+func newFailingStore() *failingStore {
+	return &failingStore{InMemoryStore: storage.NewInMemoryStoreWithMetrics(newMetrics())}
+}
+
+func (s *failingStore) fail(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.err = err
+}
+
+func (s *failingStore) failure() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+func (s *failingStore) reject(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.rejected == nil {
+		s.rejected = make(map[string]bool)
+	}
+	s.rejected[id] = true
+}
+
+func (s *failingStore) StoreEvent(ctx context.Context, event *schema.Event) error {
+	if err := s.failure(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	rejected := s.rejected[event.ID]
+	s.mu.Unlock()
+	if rejected {
+		return errRejected
+	}
+	return s.InMemoryStore.StoreEvent(ctx, event)
+}
+
+func (s *failingStore) StoreEventBatch(ctx context.Context, events []*schema.Event) error {
+	if err := s.failure(); err != nil {
+		return err
+	}
+	return s.InMemoryStore.StoreEventBatch(ctx, events)
+}
+
+func (s *failingStore) Ping(ctx context.Context) error {
+	if err := s.failure(); err != nil && errors.Is(err, storage.ErrConnClosed) {
+		return err
+	}
+	return s.InMemoryStore.Ping(ctx)
+}
+
+// newMetrics returns storage metrics registered with a registry of their
+// own, so tests do not share counters
+func newMetrics() *storage.Metrics {
+	return storage.NewMetrics(prometheus.NewRegistry(), "", "")
+}
+
+// discardLogger drops every log line
+func discardLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+// newSpillStore wraps inner with a spill buffer in dir that only flushes
+// when asked to
+func newSpillStore(t *testing.T, inner storage.EventStore, dir string) *storage.SpillStore {
+	t.Helper()
+	spill, err := storage.NewSpillStore(inner, storage.SpillConfig{
+		Dir:           dir,
+		FlushInterval: time.Hour,
+		Logger:        discardLogger(),
+		Metrics:       newMetrics(),
+	})
+	if err != nil {
+		t.Fatalf("failed to open spill buffer: %v", err)
+	}
+	return spill
+}
+
+// segments returns the spill segment files in dir
+func segments(t *testing.T, dir string) []string {
+	t.Helper()
+	paths, err := filepath.Glob(filepath.Join(dir, "spill-*.log"))
+	if err != nil {
+		t.Fatalf("failed to list segments: %v", err)
+	}
+	return paths
+}
+
+func testEvent(id string) *schema.Event {
+	return &schema.Event{
+		ID:        id,
+		Type:      schema.EventViolationFound,
+		Version:   1,
+		Timestamp: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+		Source:    string(schema.PlatformScan),
+		EntityID:  id,
+		Payload:   []byte(`{"event_id":"` + id + `"}`),
+	}
+}
+
+// An event that cannot be stored because the database is unreachable is
+// buffered on disk and reported stored, then written and its segment
+// removed once the database is back
+func TestSpillStoreBuffersAndFlushes(t *testing.T) {
+	dir := t.TempDir()
+	inner := newFailingStore()
+	spill := newSpillStore(t, inner, dir)
+	defer spill.Close()
+
+	inner.fail(storage.ErrConnClosed)
+	if err := spill.StoreEvent(context.Background(), testEvent("evt-1")); err != nil {
+		t.Fatalf("StoreEvent returned %v while the database was down, want nil", err)
+	}
+	if n := spill.Pending(); n != 1 {
+		t.Errorf("Pending = %d, want 1", n)
+	}
+	if n := len(segments(t, dir)); n != 1 {
+		t.Errorf("found %d segments, want 1", n)
+	}
+	if n := len(inner.Events()); n != 0 {
+		t.Errorf("inner store holds %d events while down, want 0", n)
+	}
+
+	if err := spill.Flush(context.Background()); err == nil {
+		t.Error("Flush succeeded while the database was down")
+	}
+	if n := spill.Pending(); n != 1 {
+		t.Errorf("Pending = %d after a failed flush, want 1", n)
+	}
+
+	inner.fail(nil)
+	if err := spill.Flush(context.Background()); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	if _, err := inner.GetEventByID("evt-1"); err != nil {
+		t.Errorf("buffered event was not flushed: %v", err)
+	}
+	if n := spill.Pending(); n != 0 {
+		t.Errorf("Pending = %d after flushing, want 0", n)
+	}
+	if n := len(segments(t, dir)); n != 0 {
+		t.Errorf("found %d segments after flushing, want 0", n)
+	}
+}
+
+// Errors other than an unreachable database are returned, not buffered
+func TestSpillStoreReturnsPermanentErrors(t *testing.T) {
+	dir := t.TempDir()
+	inner := newFailingStore()
+	spill := newSpillStore(t, inner, dir)
+	defer spill.Close()
+
+	inner.reject("evt-1")
+	if err := spill.StoreEvent(context.Background(), testEvent("evt-1")); !errors.Is(err, errRejected) {
+		t.Errorf("StoreEvent returned %v, want %v", err, errRejected)
+	}
+	if n := spill.Pending(); n != 0 {
+		t.Errorf("Pending = %d, want 0", n)
+	}
+}
+
+// A buffered event the store rejects when flushed is moved to
+// rejected.jsonl and the rest of the segment is flushed
+func TestSpillStoreRejectsOnFlush(t *testing.T) {
+	dir := t.TempDir()
+	inner := newFailingStore()
+	spill := newSpillStore(t, inner, dir)
+	defer spill.Close()
+
+	inner.fail(storage.ErrConnClosed)
+	for _, id := range []string{"evt-bad", "evt-good"} {
+		if err := spill.StoreEvent(context.Background(), testEvent(id)); err != nil {
+			t.Fatalf("StoreEvent(%s) failed: %v", id, err)
+		}
+	}
+
+	inner.fail(nil)
+	inner.reject("evt-bad")
+	if err := spill.Flush(context.Background()); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	if _, err := inner.GetEventByID("evt-good"); err != nil {
+		t.Errorf("event after the rejected one was not flushed: %v", err)
+	}
+	if n := len(segments(t, dir)); n != 0 {
+		t.Errorf("found %d segments after flushing, want 0", n)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "rejected.jsonl"))
+	if err != nil {
+		t.Fatalf("failed to read rejected file: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("rejected file holds %d lines, want 1", len(lines))
+	}
+	if !strings.Contains(lines[0], `"evt-bad"`) || !strings.Contains(lines[0], errRejected.Error()) {
+		t.Errorf("rejected line %s lacks the event ID or its error", lines[0])
+	}
+}
+
+// A line torn by a crash mid-append is skipped when its segment is flushed,
+// and the events before it are stored
+func TestSpillStoreSkipsTornLine(t *testing.T) {
+	dir := t.TempDir()
+	inner := newFailingStore()
+	inner.fail(storage.ErrConnClosed)
+	spill := newSpillStore(t, inner, dir)
+	if err := spill.StoreEvent(context.Background(), testEvent("evt-1")); err != nil {
+		t.Fatalf("StoreEvent failed: %v", err)
+	}
+	spill.Close()
+
+	paths := segments(t, dir)
+	if len(paths) != 1 {
+		t.Fatalf("found %d segments, want 1", len(paths))
+	}
+	f, err := os.OpenFile(paths[0], os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		t.Fatalf("failed to open segment: %v", err)
+	}
+	if _, err := f.WriteString(`{"event_id":"evt-2","event_ty`); err != nil {
+		t.Fatalf("failed to tear segment: %v", err)
+	}
+	f.Close()
+
+	inner.fail(nil)
+	restarted := newSpillStore(t, inner, dir)
+	defer restarted.Close()
+	if err := restarted.Flush(context.Background()); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	if _, err := inner.GetEventByID("evt-1"); err != nil {
+		t.Errorf("event before the torn line was not flushed: %v", err)
+	}
+	if n := len(inner.Events()); n != 1 {
+		t.Errorf("inner store holds %d events, want 1", n)
+	}
+	if n := restarted.Pending(); n != 0 {
+		t.Errorf("Pending = %d after flushing, want 0", n)
+	}
+	if n := len(segments(t, dir)); n != 0 {
+		t.Errorf("found %d segments after flushing, want 0", n)
+	}
+}
+
+// Events left buffered by Close are counted as pending by the next run
+// and flushed by it
+func TestSpillStorePendingAcrossRestart(t *testing.T) {
+	dir := t.TempDir()
+	inner := newFailingStore()
+	inner.fail(storage.ErrConnClosed)
+
+	spill := newSpillStore(t, inner, dir)
+	batch := []*schema.Event{testEvent("evt-1"), testEvent("evt-2")}
+	if err := spill.StoreEventBatch(context.Background(), batch); err != nil {
+		t.Fatalf("StoreEventBatch failed: %v", err)
+	}
+	if err := spill.StoreEvent(context.Background(), testEvent("evt-3")); err != nil {
+		t.Fatalf("StoreEvent failed: %v", err)
+	}
+	spill.Close()
+
+	restarted := newSpillStore(t, inner, dir)
+	defer restarted.Close()
+	if n := restarted.Pending(); n != 3 {
+		t.Errorf("Pending = %d after restart, want 3", n)
+	}
+
+	inner.fail(nil)
+	if err := restarted.Flush(context.Background()); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	if n := restarted.Pending(); n != 0 {
+		t.Errorf("Pending = %d after flushing, want 0", n)
+	}
+	if n := len(inner.Events()); n != 3 {
+		t.Errorf("inner store holds %d events, want 3", n)
+	}
+}
@@ -0,0 +1,381 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/assure-compliance/eventid/pkg/schema"
+	_ "github.com/lib/pq"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// EventStore is implemented by every event storage backend
+type EventStore interface {
+	// StoreEvent persists an event, returning ErrDuplicateEvent if its ID is
+	// already stored. Errors from PostgresStore wrap ErrConnClosed when the
+	// database was unreachable.
+	StoreEvent(ctx context.Context, event *schema.Event) error
+	// StoreEventBatch persists events together, returning a *BatchError
+	// when only some of them could be stored
+	StoreEventBatch(ctx context.Context, events []*schema.Event) error
+
+	// StoreRawQuarantine keeps a message that could not be decoded, with
+	// its decode error, so it can be investigated
+	StoreRawQuarantine(ctx context.Context, topic string, partition int32, offset int64, raw []byte, err error) error
+
+	// AppendRaw records a message as received, before it is decoded, in an
+	// append-only log
+	AppendRaw(ctx context.Context, msg RawMessage) error
+
+	// GetEventByID returns an event's payload, or an error wrapping
+	// ErrEventNotFound
+	GetEventByID(eventID string) (map[string]interface{}, error)
+	QueryEvents(filter EventFilter) ([]schema.Event, error)
+	// GetCausalChain returns the events sharing correlationID, each after
+	// the event named by its causation ID and otherwise oldest first
+	GetCausalChain(correlationID string) ([]schema.Event, error)
+
+	// StoreLatest records event as the current state of its entity, under
+	// LatestKeyOf(event), replacing an older one, alongside the full
+	// history. GetLatest returns it, or an error wrapping ErrEventNotFound.
+	StoreLatest(ctx context.Context, event *schema.Event) error
+	GetLatest(key LatestKey) (*schema.Event, error)
+	// StreamEvents calls fn for each matching event, oldest first, until fn
+	// returns an error or ctx is cancelled
+	StreamEvents(ctx context.Context, filter EventFilter, fn func(schema.Event) error) error
+
+	// Summary returns the count and most recent timestamp of each stored
+	// event type
+	Summary(ctx context.Context) (map[schema.EventType]TypeStat, error)
+
+	// PruneOlderThan deletes events of eventType older than age for data
+	// retention, returning how many were deleted
+	PruneOlderThan(ctx context.Context, eventType schema.EventType, age time.Duration) (int64, error)
+
+	// Migrate brings the backend's schema up to date
+	Migrate(ctx context.Context) error
+
+	Ping(ctx context.Context) error
+	Close() error
+}
+
+var (
+	_ EventStore = (*PostgresStore)(nil)
+	_ EventStore = (*DryRunStore)(nil)
+	_ EventStore = (*InMemoryStore)(nil)
+	_ EventStore = (*SpillStore)(nil)
+)
+
+// PostgresStore stores events in the PostgreSQL events table
+type PostgresStore struct {
+	db     *sql.DB
+	logger Logger
+	tracer trace.Tracer
+	upsert map[schema.EventType]bool // Types stored with upsertRow
+
+	storedHeaders []string    // Header names kept in the headers column
+	names         *sqlNames   // Renders statements for TableName and Columns
+	dedup         *dedupCache // Set when DedupCacheSize is configured
+	metrics       *Metrics
+
+	done      chan struct{} // Closed by Close to stop pool monitoring
+	closeOnce sync.Once
+
+	maxIdle      int         // Idle connection limit restored after reconnecting
+	reconnecting atomic.Bool // Set while reconnect is running
+
+	lastStored atomic.Int64 // UnixNano of the last successful store, initially when opened
+}
+
+// Config holds database configuration
+type Config struct {
+	Host     string
+	Port     int
+	User     string
+	Password string
+	Database string
+	SSLMode  string
+	Logger   Logger // Defaults to JSON on stderr
+
+	// UpsertTypes lists event types that upstream may revise by re-emitting
+	// an event with the same ID and a new payload. The stored row of such
+	// an event is updated, with revised_at set, after its previous contents
+	// are copied to event_revisions. Other types are insert-only: a known
+	// ID is skipped as a duplicate. Requires migration 0005.
+	UpsertTypes []schema.EventType
+
+	// StoredHeaders lists the message headers, by name, kept with each
+	// event in the indexed events.headers column, so that events can be
+	// selected by them with EventFilter.Headers, e.g. the producing service.
+	// A header in the list is stored by adding it here; no schema change is
+	// needed. Other headers are only seen by handlers. Requires migration
+	// 0008.
+	StoredHeaders []string
+
+	// TableName is the events table, optionally schema-qualified as
+	// schema.table (default DefaultTableName). Columns renames events table
+	// columns, keyed by their default name, e.g. {"event_data": "payload"};
+	// unlisted columns keep their names. Names must be plain identifiers and
+	// are quoted in every statement. Migrate only supports the default
+	// names, so a mapped table must be created and kept up to date by hand.
+	TableName string
+	Columns   map[string]string
+
+	// DedupCacheSize remembers up to this many recently stored events so
+	// that redeliveries, e.g. after a rebalance, are skipped as duplicates
+	// without querying the database. Entries expire DedupCacheTTL after
+	// the event was stored (default DefaultDedupCacheTTL). Upserted types
+	// bypass the cache, since a repeated ID may carry a revision. 0
+	// disables the cache.
+	DedupCacheSize int
+	DedupCacheTTL  time.Duration
+
+	// Metrics receives the store's metrics (default DefaultMetrics(),
+	// registered with the default registry)
+	Metrics *Metrics
+
+	// Client certificate and key for mutual TLS, and the CA bundle used to
+	// verify the server under sslmode verify-ca or verify-full
+	SSLCert     string
+	SSLKey      string
+	SSLRootCert string
+
+	// Connection pool settings; zero uses DefaultMaxOpenConns,
+	// DefaultMaxIdleConns and DefaultConnMaxLifetime. A negative MaxIdleConns
+	// disables idle connections and zero ConnMaxIdleTime never expires them.
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+
+	// Tracing enables OpenTelemetry spans for inserts, parented on the trace
+	// context carried by each event. Spans are created from TracerProvider,
+	// or the global provider when nil.
+	Tracing        bool
+	TracerProvider trace.TracerProvider
+}
+
+// NewEventStore creates a PostgreSQL event store
+func NewEventStore(cfg Config) (*PostgresStore, error) {
+	names, err := newSQLNames(cfg.TableName, cfg.Columns)
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := sql.Open("postgres", connString(cfg))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	// Test connection
+	if err := db.Ping(); err != nil {
+		return nil, fmt.Errorf("failed to ping database: %w", err)
+	}
+
+	// Set connection pool settings
+	maxIdle := configurePool(db, cfg)
+
+	logger := cfg.Logger
+	if logger == nil {
+		logger = defaultLogger()
+	}
+
+	metrics := cfg.Metrics
+	if metrics == nil {
+		metrics = DefaultMetrics()
+	}
+
+	s := &PostgresStore{
+		db:            db,
+		logger:        logger,
+		tracer:        newTracer(cfg),
+		storedHeaders: cfg.StoredHeaders,
+		names:         names,
+		dedup:         newDedupCache(cfg.DedupCacheSize, cfg.DedupCacheTTL, metrics),
+		metrics:       metrics,
+		maxIdle:       maxIdle,
+		done:          make(chan struct{}),
+	}
+	if len(cfg.UpsertTypes) > 0 {
+		s.upsert = make(map[schema.EventType]bool, len(cfg.UpsertTypes))
+		for _, eventType := range cfg.UpsertTypes {
+			s.upsert[eventType] = true
+		}
+	}
+	s.lastStored.Store(time.Now().UnixNano())
+	go s.monitorPool(poolStatsInterval)
+	return s, nil
+}
+
+// insertEventSQL inserts a single events row, skipping events already stored.
+// Like every events statement it is rendered with sqlNames before use.
+const insertEventSQL = `
+	INSERT INTO {events} (` + insertColumns + `
+	) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)
+	ON CONFLICT ({event_id}, {timestamp}) DO NOTHING
+`
+
+// insertColumns lists the columns written by inserts, in eventRow.args order
+const insertColumns = `
+		{event_id}, {event_version}, {event_type}, {platform},
+		{timestamp}, {correlation_id}, {user_id}, {event_data}, {entity_id},
+		{tenant_id}, {headers}, {record_timestamp}, {kafka_topic},
+		{kafka_partition}, {causation_id}`
+
+// ErrDuplicateEvent is returned by StoreEvent when an event with the same ID
+// has already been stored. The existing row is left unchanged, so callers
+// redelivering an event can treat this as success.
+var ErrDuplicateEvent = errors.New("event already stored")
+
+// StoreEvent persists an event to the database. It returns ErrDuplicateEvent
+// if the event ID is already stored, unless the event's type is upserted and
+// its payload has changed, in which case the stored event is revised.
+func (s *PostgresStore) StoreEvent(ctx context.Context, event *schema.Event) (err error) {
+	span := s.startInsertSpan(ctx, event)
+	operation := "insert"
+	if s.upsert[event.Type] {
+		operation = "upsert"
+	}
+	defer func(started time.Time) {
+		err = s.checkConn(err)
+		s.metrics.observeStore(operation, started, err)
+		if err == nil {
+			s.markStored()
+		}
+		endSpan(span, started, err)
+	}(time.Now())
+
+	row := newEventRow(event, s.storedHeaders)
+	if operation == "upsert" {
+		return s.upsertRow(ctx, row)
+	}
+	if s.dedup.seen(event) {
+		s.metrics.duplicateEvents.Inc()
+		s.logger.Info("Skipped recently stored duplicate event", row.logAttrs()...)
+		return ErrDuplicateEvent
+	}
+
+	result, err := s.db.ExecContext(ctx, s.names.render(insertEventSQL), row.args()...)
+
+	if err != nil {
+		return fmt.Errorf("failed to insert event: %w", err)
+	}
+	s.dedup.add(event)
+
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		s.metrics.duplicateEvents.Inc()
+		s.logger.Info("Skipped duplicate event", row.logAttrs()...)
+		return ErrDuplicateEvent
+	}
+
+	s.logger.Debug("Stored event", row.logAttrs()...)
+	return nil
+}
+
+// eventRow holds the column values for a single events table row
+type eventRow struct {
+	base     schema.BaseEvent
+	data     []byte
+	entityID string
+	tenantID string
+	headers  []byte    // JSON object of the stored headers, or nil
+	recorded time.Time // Kafka record timestamp, zero if none
+
+	// Kafka partition the event was read from, topic "" if none
+	topic     string
+	partition int32
+}
+
+// newEventRow maps an event envelope to its row; the payload is stored as-is
+// and of its headers only those named in stored are kept
+func newEventRow(event *schema.Event, stored []string) *eventRow {
+	row := &eventRow{base: event.Base(), data: event.Payload, entityID: event.EntityID, tenantID: event.TenantID, recorded: event.RecordTimestamp}
+	row.topic, row.partition = event.Topic, event.Partition
+
+	kept := make(map[string]string)
+	for _, name := range stored {
+		if value, ok := event.Headers[name]; ok {
+			kept[name] = value
+		}
+	}
+	if len(kept) > 0 {
+		row.headers, _ = json.Marshal(kept) // A map of strings always marshals
+	}
+	return row
+}
+
+// logAttrs returns log attributes identifying the row's event
+func (r *eventRow) logAttrs() []any {
+	attrs := []any{"event_id", r.base.EventID, "event_type", string(r.base.EventType)}
+	if r.base.CorrelationID != "" {
+		attrs = append(attrs, "correlation_id", r.base.CorrelationID)
+	}
+	if r.base.CausationID != "" {
+		attrs = append(attrs, "causation_id", r.base.CausationID)
+	}
+	if r.entityID != "" {
+		attrs = append(attrs, "entity_id", r.entityID)
+	}
+	if r.tenantID != "" {
+		attrs = append(attrs, "tenant_id", r.tenantID)
+	}
+	return attrs
+}
+
+// args returns the INSERT arguments in events column order
+func (r *eventRow) args() []interface{} {
+	return []interface{}{
+		r.base.EventID,
+		r.base.EventVersion,
+		r.base.EventType,
+		r.base.Platform,
+		r.base.Timestamp,
+		sql.NullString{String: r.base.CorrelationID, Valid: r.base.CorrelationID != ""},
+		sql.NullString{String: r.base.UserID, Valid: r.base.UserID != ""},
+		r.data,
+		sql.NullString{String: r.entityID, Valid: r.entityID != ""},
+		sql.NullString{String: r.tenantID, Valid: r.tenantID != ""},
+		sql.NullString{String: string(r.headers), Valid: r.headers != nil},
+		nullTime(r.recorded),
+		sql.NullString{String: r.topic, Valid: r.topic != ""},
+		sql.NullInt32{Int32: r.partition, Valid: r.topic != ""},
+		sql.NullString{String: r.base.CausationID, Valid: r.base.CausationID != ""},
+	}
+}
+
+// GetEventByID retrieves an event by its ID
+func (s *PostgresStore) GetEventByID(eventID string) (map[string]interface{}, error) {
+	query := s.names.render(`
+		SELECT {event_data}
+		FROM {events}
+		WHERE {event_id} = $1
+	`)
+
+	var eventData []byte
+	err := s.db.QueryRow(query, eventID).Scan(&eventData)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("%w: %s", ErrEventNotFound, eventID)
+	}
+	if err != nil {
+		return nil, s.checkConn(fmt.Errorf("failed to query event: %w", err))
+	}
+
+	var result map[string]interface{}
+	if err := json.Unmarshal(eventData, &result); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal event data: %w", err)
+	}
+
+	return result, nil
+}
+
+// Close closes the database connection
+func (s *PostgresStore) Close() error {
+	s.closeOnce.Do(func() { close(s.done) })
+	return s.db.Close()
+}
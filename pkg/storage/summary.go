@@ -0,0 +1,70 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/assure-compliance/eventid/pkg/schema"
+)
+
+// TypeStat summarises the stored events of one type
+type TypeStat struct {
+	Count    int64     `json:"count"`
+	LastSeen time.Time `json:"last_seen"` // Timestamp of the most recent event
+}
+
+// summarySQL counts events and finds the most recent timestamp per type in
+// one pass over the table
+const summarySQL = `
+	SELECT {event_type}, COUNT(*), MAX({timestamp})
+	FROM {events}
+	GROUP BY {event_type}
+`
+
+// Summary returns the count and most recent timestamp of each stored event
+// type
+func (s *PostgresStore) Summary(ctx context.Context) (map[schema.EventType]TypeStat, error) {
+	rows, err := s.db.QueryContext(ctx, s.names.render(summarySQL))
+	if err != nil {
+		return nil, s.checkConn(fmt.Errorf("failed to summarise events: %w", err))
+	}
+	defer rows.Close()
+
+	stats := make(map[schema.EventType]TypeStat)
+	for rows.Next() {
+		var eventType string
+		var stat TypeStat
+		if err := rows.Scan(&eventType, &stat.Count, &stat.LastSeen); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		stats[schema.EventType(eventType)] = stat
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read event summary: %w", err)
+	}
+	return stats, nil
+}
+
+// Summary returns the count and most recent timestamp of each stored event
+// type
+func (s *InMemoryStore) Summary(context.Context) (map[schema.EventType]TypeStat, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	stats := make(map[schema.EventType]TypeStat)
+	for _, event := range s.events {
+		stat := stats[event.Type]
+		stat.Count++
+		if event.Timestamp.After(stat.LastSeen) {
+			stat.LastSeen = event.Timestamp
+		}
+		stats[event.Type] = stat
+	}
+	return stats, nil
+}
+
+// Summary returns no stats
+func (s *DryRunStore) Summary(context.Context) (map[schema.EventType]TypeStat, error) {
+	return map[schema.EventType]TypeStat{}, nil
+}
@@ -0,0 +1,97 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/assure-compliance/eventid/pkg/schema"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// tracerName identifies spans created by this package
+const tracerName = "github.com/assure-compliance/eventid/pkg/storage"
+
+// propagator reads the W3C trace context carried on event envelopes
+var propagator = propagation.TraceContext{}
+
+// dbAttrs are the attributes common to every events INSERT span
+var dbAttrs = []attribute.KeyValue{
+	attribute.String("db.system", "postgresql"),
+	attribute.String("db.operation", "INSERT"),
+	attribute.String("db.sql.table", "events"),
+}
+
+// newTracer returns the tracer for cfg, or a no-op tracer when tracing is
+// disabled
+func newTracer(cfg Config) trace.Tracer {
+	if !cfg.Tracing {
+		return noop.NewTracerProvider().Tracer(tracerName)
+	}
+	provider := cfg.TracerProvider
+	if provider == nil {
+		provider = otel.GetTracerProvider()
+	}
+	return provider.Tracer(tracerName)
+}
+
+// eventContext returns a context carrying the trace context of event, if any
+func eventContext(event *schema.Event) context.Context {
+	return propagator.Extract(context.Background(), propagation.MapCarrier(event.TraceContext))
+}
+
+// startInsertSpan starts a span for storing a single event, as a child of the
+// span in ctx or, failing that, the span that consumed it
+func (s *PostgresStore) startInsertSpan(ctx context.Context, event *schema.Event) trace.Span {
+	if !trace.SpanContextFromContext(ctx).IsValid() {
+		ctx = eventContext(event)
+	}
+	_, span := s.tracer.Start(ctx, "events.insert",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(dbAttrs...),
+		trace.WithAttributes(
+			attribute.String("event.id", event.ID),
+			attribute.String("event.type", string(event.Type)),
+		),
+	)
+	return span
+}
+
+// startBatchSpan starts a span for storing a batch, linked to the span that
+// consumed each event
+func (s *PostgresStore) startBatchSpan(ctx context.Context, events []*schema.Event) trace.Span {
+	links := make([]trace.Link, 0, len(events))
+	for _, event := range events {
+		if sc := trace.SpanContextFromContext(eventContext(event)); sc.IsValid() {
+			links = append(links, trace.Link{SpanContext: sc})
+		}
+	}
+
+	_, span := s.tracer.Start(ctx, "events.insert_batch",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(dbAttrs...),
+		trace.WithAttributes(attribute.Int("db.batch_size", len(events))),
+		trace.WithLinks(links...),
+	)
+	return span
+}
+
+// endSpan records the storage latency and err, if any, and ends span.
+// Duplicates are recorded as an attribute rather than an error.
+func endSpan(span trace.Span, started time.Time, err error) {
+	span.SetAttributes(attribute.Float64("db.duration_ms", float64(time.Since(started).Microseconds())/1000))
+	if errors.Is(err, ErrDuplicateEvent) {
+		span.SetAttributes(attribute.Bool("event.duplicate", true))
+		err = nil
+	}
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
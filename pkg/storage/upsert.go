@@ -0,0 +1,80 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// revisionStateSQL locks the stored row for $1, if any, and reports whether
+// its payload equals $2
+const revisionStateSQL = `
+	SELECT {event_data} = $2::jsonb
+	FROM {events}
+	WHERE {event_id} = $1
+	LIMIT 1
+	FOR UPDATE
+`
+
+// reviseEventSQL replaces a stored event's mutable columns. The
+// immutability trigger copies the previous row into event_revisions.
+const reviseEventSQL = `
+	UPDATE {events}
+	SET {event_version} = $2, {correlation_id} = $3, {user_id} = $4,
+		{event_data} = $5, {revised_at} = NOW()
+	WHERE {event_id} = $1
+`
+
+// upsertRow stores row, or revises the stored event with the same ID if its
+// payload differs. It returns ErrDuplicateEvent if the stored payload is
+// unchanged. The event's type, platform and timestamp are never changed.
+func (s *PostgresStore) upsertRow(ctx context.Context, row *eventRow) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin upsert transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var unchanged, revised bool
+	err = tx.QueryRowContext(ctx, s.names.render(revisionStateSQL), row.base.EventID, row.data).Scan(&unchanged)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		result, err := tx.ExecContext(ctx, s.names.render(insertEventSQL), row.args()...)
+		if err != nil {
+			return fmt.Errorf("failed to insert event: %w", err)
+		}
+		if n, err := result.RowsAffected(); err == nil && n == 0 {
+			// Inserted concurrently since the lookup
+			s.metrics.duplicateEvents.Inc()
+			return ErrDuplicateEvent
+		}
+	case err != nil:
+		return fmt.Errorf("failed to look up stored event: %w", err)
+	case unchanged:
+		s.metrics.duplicateEvents.Inc()
+		s.logger.Info("Skipped duplicate event", row.logAttrs()...)
+		return ErrDuplicateEvent
+	default:
+		if _, err := tx.ExecContext(ctx, "SET LOCAL eventid.event_revision = 'on'"); err != nil {
+			return fmt.Errorf("failed to enable event revision: %w", err)
+		}
+		args := row.args()
+		// event_id, event_version, correlation_id, user_id, event_data
+		if _, err := tx.ExecContext(ctx, s.names.render(reviseEventSQL), args[0], args[1], args[5], args[6], args[7]); err != nil {
+			return fmt.Errorf("failed to revise event: %w", err)
+		}
+		revised = true
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit upsert: %w", err)
+	}
+	if revised {
+		s.metrics.revisedEvents.WithLabelValues(string(row.base.EventType)).Inc()
+		s.logger.Info("Revised event", row.logAttrs()...)
+		return nil
+	}
+	s.logger.Debug("Stored event", row.logAttrs()...)
+	return nil
+}
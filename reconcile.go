@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"log/slog"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/assure-compliance/eventid/pkg/consumer"
+)
+
+// reconcileQueryTimeout bounds the count of stored events
+const reconcileQueryTimeout = 5 * time.Minute
+
+// runReconcile compares the number of records in each partition of a topic
+// whose Kafka timestamp falls in a window with the number of events stored
+// from that partition in the same window, and exits 1 if any differ. It
+// only reads offsets and counts rows, so it is safe to run against
+// production. Events are legitimately missing when they were filtered,
+// dead-lettered, quarantined or were tombstones, and a window ending now
+// counts records not yet consumed, so a difference is a prompt to look
+// rather than proof of loss.
+func runReconcile(logger *slog.Logger, args []string) {
+	flags := flag.NewFlagSet("reconcile", flag.ExitOnError)
+	topic := flags.String("topic", "", "topic to reconcile (default KAFKA_TOPIC)")
+	from := flags.String("from", "", "count records at or after this RFC 3339 time (default the earliest retained)")
+	to := flags.String("to", "", "count records before this RFC 3339 time (default the latest)")
+	flags.Parse(args)
+	fromTime, err := parseFlagTime("from", *from)
+	if err != nil {
+		log.Fatal(err)
+	}
+	toTime, err := parseFlagTime("to", *to)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if !fromTime.IsZero() && !toTime.IsZero() && !toTime.After(fromTime) {
+		log.Fatal("reconcile: -to must be after -from")
+	}
+
+	config := mustLoadConfig()
+	if *topic == "" {
+		*topic = config.KafkaTopic
+	}
+	if *topic == "" {
+		log.Fatal("reconcile: -topic is required when KAFKA_TOPIC is unset")
+	}
+	store := mustOpenStore(config, logger, nil)
+	defer store.Close()
+	pgStore, ok := postgresStore(store)
+	if !ok {
+		log.Fatal("reconcile: stored events are only counted by partition in the postgres backend")
+	}
+
+	records, err := consumer.CountRecords(consumerConfig(config, logger), *topic, fromTime, toTime)
+	if err != nil {
+		log.Fatalf("Failed to count Kafka records: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), reconcileQueryTimeout)
+	defer cancel()
+	stored, err := pgStore.CountByPartition(ctx, *topic, fromTime, toTime)
+	if err != nil {
+		log.Fatalf("Failed to count stored events: %v", err)
+	}
+
+	out := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(out, "PARTITION\tSTART\tEND\tKAFKA\tSTORED\tDIFFERENCE\t")
+	var kafkaTotal, storedTotal int64
+	mismatched := 0
+	for _, p := range records {
+		n := stored[p.Partition]
+		if n != p.Records() {
+			mismatched++
+		}
+		kafkaTotal += p.Records()
+		storedTotal += n
+		fmt.Fprintf(out, "%d\t%d\t%d\t%d\t%d\t%d\t\n", p.Partition, p.Start, p.End, p.Records(), n, p.Records()-n)
+	}
+	fmt.Fprintf(out, "total\t\t\t%d\t%d\t%d\t\n", kafkaTotal, storedTotal, kafkaTotal-storedTotal)
+	out.Flush()
+
+	if mismatched > 0 {
+		log.Printf("%d of %d partitions of %s differ\n", mismatched, len(records), *topic)
+		os.Exit(1)
+	}
+	log.Printf("All %d partitions of %s reconcile\n", len(records), *topic)
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/assure-compliance/eventid/pkg/consumer"
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+)
+
+// seekResponse is the JSON body returned by /seek
+type seekResponse struct {
+	Topic     string `json:"topic"`
+	Partition int32  `json:"partition"`
+	Offset    string `json:"offset"`
+	Error     string `json:"error,omitempty"`
+}
+
+// seekHandler repositions one partition of this instance, e.g.
+// POST /seek?topic=regulatory-events&partition=3&offset=earliest. offset is
+// a number, earliest or latest. It responds 409 if another instance owns
+// the partition.
+func seekHandler(seek func(topic string, partition int32, offset int64) error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		q := r.URL.Query()
+		resp := seekResponse{Topic: q.Get("topic"), Offset: q.Get("offset")}
+		partition, err := strconv.ParseInt(q.Get("partition"), 10, 32)
+		if resp.Topic == "" || err != nil || partition < 0 {
+			http.Error(w, "topic and a non-negative partition are required", http.StatusBadRequest)
+			return
+		}
+		resp.Partition = int32(partition)
+
+		var offset int64
+		switch resp.Offset {
+		case "earliest":
+			offset = int64(kafka.OffsetBeginning)
+		case "latest":
+			offset = int64(kafka.OffsetEnd)
+		default:
+			offset, err = strconv.ParseInt(resp.Offset, 10, 64)
+			if err != nil || offset < 0 {
+				http.Error(w, "offset must be a non-negative number, earliest or latest", http.StatusBadRequest)
+				return
+			}
+		}
+
+		status := http.StatusOK
+		if err := seek(resp.Topic, resp.Partition, offset); err != nil {
+			log.Printf("Failed to seek %s[%d] to %s: %v\n", resp.Topic, resp.Partition, resp.Offset, err)
+			resp.Error = err.Error()
+			switch {
+			case errors.Is(err, consumer.ErrPartitionNotAssigned):
+				status = http.StatusConflict
+			case errors.Is(err, consumer.ErrNotRunning):
+				status = http.StatusServiceUnavailable
+			default:
+				status = http.StatusInternalServerError
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(resp)
+	}
+}
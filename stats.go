@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/assure-compliance/eventid/pkg/schema"
+	"github.com/assure-compliance/eventid/pkg/storage"
+)
+
+// statsCacheTTL is how long a /stats summary is reused before the store is
+// queried again
+const statsCacheTTL = 5 * time.Second
+
+// statsQueryTimeout bounds the summary query, which scans the events table
+const statsQueryTimeout = 10 * time.Second
+
+// statsHandler serves the per-type event counts and last-seen timestamps
+// from store.Summary, cached for statsCacheTTL so that repeated requests
+// do not each scan the events table
+func statsHandler(store storage.EventStore) http.HandlerFunc {
+	var (
+		mu      sync.Mutex
+		cached  map[schema.EventType]storage.TypeStat
+		fetched time.Time
+	)
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		if cached == nil || time.Since(fetched) > statsCacheTTL {
+			ctx, cancel := context.WithTimeout(r.Context(), statsQueryTimeout)
+			stats, err := store.Summary(ctx)
+			cancel()
+			if err != nil {
+				mu.Unlock()
+				log.Printf("Failed to summarise events: %v\n", err)
+				http.Error(w, "failed to summarise events", http.StatusServiceUnavailable)
+				return
+			}
+			cached, fetched = stats, time.Now()
+		}
+		stats := cached
+		mu.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(stats)
+	}
+}